@@ -0,0 +1,158 @@
+package checkin
+
+import (
+	"sort"
+	"time"
+
+	"github.com/guregu/null"
+)
+
+//ArrivalIntervals are the intervals check ins can be bucketed by in ArrivalStats
+var ArrivalIntervals = []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}
+
+//MaxArrivalBuckets is the most intervals ArrivalStats can be bucketed into, which long events
+//need a longer interval to stay under
+const MaxArrivalBuckets = 5000
+
+//StartTrigger is the trigger (time tag) of an event which arrivals are measured relative to,
+//if it is set. Otherwise, they are measured relative to the start of the event
+const StartTrigger = "start"
+
+//Arrival is the check in of a guest, along with the guest's tags
+type Arrival struct {
+	Time time.Time `json:"time"`
+	Tags []string  `json:"tags"`
+}
+
+//ArrivalBucket is the check ins of guests during one interval of ArrivalStats
+type ArrivalBucket struct {
+	Start      time.Time      `json:"start"`
+	CheckIns   int            `json:"checkIns"`
+	Cumulative int            `json:"cumulative"` //check ins by the end of the interval, including those before the event
+	Tags       map[string]int `json:"tags"`       //check ins of guests with each tag, with "" for guests without tags
+}
+
+//ArrivalStats are the check ins of guests of an event over time, bucketed into intervals between its start and end
+//MedianArrival is in minutes after the start trigger (negative if before), and is null if nobody has checked in
+//or the event has no start
+type ArrivalStats struct {
+	Start         time.Time       `json:"start"`
+	End           time.Time       `json:"end"`
+	Interval      int             `json:"interval"` //in minutes
+	Tags          []string        `json:"tags"`     //every tag in the buckets in order, followed by "" if any guests had no tags
+	Buckets       []ArrivalBucket `json:"buckets"`
+	Before        int             `json:"before"` //check ins before the start
+	After         int             `json:"after"`  //check ins at or after the end
+	PeakStart     null.Time       `json:"peakStart"`
+	PeakRate      float64         `json:"peakRate"` //check ins per minute during the busiest interval
+	StartTrigger  null.Time       `json:"startTrigger"`
+	MedianArrival null.Float      `json:"medianArrival"`
+}
+
+//ValidArrivalInterval checks if check ins can be bucketed by the interval
+func ValidArrivalInterval(interval time.Duration) bool {
+	for _, valid := range ArrivalIntervals {
+		if interval == valid {
+			return true
+		}
+	}
+	return false
+}
+
+//NumArrivalBuckets returns how many intervals the time between start and end is bucketed into,
+//where the last may go past the end
+//Returns one more than MaxArrivalBuckets for anything longer, as very long times overflow when rounded up
+func NumArrivalBuckets(start time.Time, end time.Time, interval time.Duration) int {
+	if !end.After(start) {
+		return 0
+	}
+	if end.Sub(start) > time.Duration(MaxArrivalBuckets)*interval {
+		return MaxArrivalBuckets + 1
+	}
+	return int((end.Sub(start) + interval - 1) / interval)
+}
+
+//ArrivalStartTrigger returns the time arrivals at the event are measured relative to: its start trigger
+//if it has one, or else its start
+func (e *Event) ArrivalStartTrigger() null.Time {
+	if trigger, ok := e.TimeTags[StartTrigger]; ok {
+		return null.TimeFrom(trigger)
+	}
+	return e.Start
+}
+
+//NewArrivalStats buckets the arrivals into intervals between start and end, and works out the peak rate of
+//arrival and the median arrival relative to the startTrigger
+//The number of buckets should be checked with NumArrivalBuckets first
+func NewArrivalStats(arrivals []Arrival, start time.Time, end time.Time, interval time.Duration,
+	startTrigger null.Time) ArrivalStats {
+	stats := ArrivalStats{
+		Start:        start,
+		End:          end,
+		Interval:     int(interval / time.Minute),
+		Tags:         make([]string, 0),
+		Buckets:      make([]ArrivalBucket, NumArrivalBuckets(start, end, interval)),
+		StartTrigger: startTrigger,
+	}
+	for i := range stats.Buckets {
+		stats.Buckets[i] = ArrivalBucket{Start: start.Add(time.Duration(i) * interval), Tags: make(map[string]int)}
+	}
+
+	tags, untagged := make(map[string]bool), false
+	for _, arrival := range arrivals {
+		if arrival.Time.Before(start) {
+			stats.Before++
+			continue
+		} else if !arrival.Time.Before(end) {
+			stats.After++
+			continue
+		}
+		bucket := &stats.Buckets[arrival.Time.Sub(start)/interval]
+		bucket.CheckIns++
+		if len(arrival.Tags) == 0 {
+			bucket.Tags[""]++
+			untagged = true
+		}
+		for _, tag := range arrival.Tags {
+			bucket.Tags[tag]++
+			tags[tag] = true
+		}
+	}
+	for tag := range tags {
+		stats.Tags = append(stats.Tags, tag)
+	}
+	sort.Strings(stats.Tags)
+	if untagged {
+		stats.Tags = append(stats.Tags, "")
+	}
+
+	cumulative, peak := stats.Before, 0
+	for i := range stats.Buckets {
+		cumulative += stats.Buckets[i].CheckIns
+		stats.Buckets[i].Cumulative = cumulative
+		if stats.Buckets[i].CheckIns > peak {
+			peak = stats.Buckets[i].CheckIns
+			stats.PeakStart = null.TimeFrom(stats.Buckets[i].Start)
+		}
+	}
+	stats.PeakRate = float64(peak) / interval.Minutes()
+
+	if startTrigger.Valid && len(arrivals) > 0 {
+		stats.MedianArrival = null.FloatFrom(medianArrival(arrivals, startTrigger.Time).Minutes())
+	}
+	return stats
+}
+
+//medianArrival returns the median time of the arrivals relative to the reference time
+func medianArrival(arrivals []Arrival, reference time.Time) time.Duration {
+	offsets := make([]time.Duration, len(arrivals))
+	for i, arrival := range arrivals {
+		offsets[i] = arrival.Time.Sub(reference)
+	}
+	sort.Slice(offsets, func(i, j int) bool { return offsets[i] < offsets[j] })
+	middle := len(offsets) / 2
+	if len(offsets)%2 == 1 {
+		return offsets[middle]
+	}
+	return (offsets[middle-1] + offsets[middle]) / 2
+}
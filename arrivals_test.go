@@ -0,0 +1,83 @@
+package checkin_test
+
+import (
+	"checkin"
+	"checkin/test"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+)
+
+func TestNumArrivalBuckets(t *testing.T) {
+	start := time.Date(2019, 4, 10, 9, 0, 0, 0, time.UTC)
+	test.Equals(t, 12, checkin.NumArrivalBuckets(start, start.Add(time.Hour), 5*time.Minute))
+	test.Equals(t, 5, checkin.NumArrivalBuckets(start, start.Add(61*time.Minute), 15*time.Minute)) //last bucket goes past the end
+	test.Equals(t, 0, checkin.NumArrivalBuckets(start, start, time.Minute))
+	test.Equals(t, 0, checkin.NumArrivalBuckets(start, start.Add(-time.Hour), time.Minute))
+	test.Equals(t, checkin.MaxArrivalBuckets, checkin.NumArrivalBuckets(start, start.Add(checkin.MaxArrivalBuckets*time.Minute), time.Minute))
+	test.Equals(t, checkin.MaxArrivalBuckets+1, checkin.NumArrivalBuckets(start, start.Add(checkin.MaxArrivalBuckets*time.Minute+1), time.Minute))
+	farFuture := time.Date(2262, 1, 1, 0, 0, 0, 0, time.UTC) //too far from start for end.Sub(start) to not saturate
+	test.Equals(t, checkin.MaxArrivalBuckets+1, checkin.NumArrivalBuckets(start.AddDate(-300, 0, 0), farFuture, 15*time.Minute))
+	test.Equals(t, true, checkin.ValidArrivalInterval(15*time.Minute))
+	test.Equals(t, false, checkin.ValidArrivalInterval(10*time.Minute))
+}
+
+func TestArrivalStartTrigger(t *testing.T) {
+	start := time.Date(2019, 4, 10, 9, 0, 0, 0, time.UTC)
+	event := checkin.Event{Start: null.TimeFrom(start), TimeTags: map[string]time.Time{}}
+	test.Equals(t, null.TimeFrom(start), event.ArrivalStartTrigger())
+	event.TimeTags[checkin.StartTrigger] = start.Add(30 * time.Minute)
+	test.Equals(t, null.TimeFrom(start.Add(30*time.Minute)), event.ArrivalStartTrigger())
+	test.Equals(t, null.Time{}, (&checkin.Event{}).ArrivalStartTrigger())
+}
+
+func TestNewArrivalStats(t *testing.T) {
+	start := time.Date(2019, 4, 10, 9, 0, 0, 0, time.UTC)
+	end := start.Add(30 * time.Minute)
+	at := func(minutes int, tags ...string) checkin.Arrival {
+		return checkin.Arrival{Time: start.Add(time.Duration(minutes) * time.Minute), Tags: tags}
+	}
+	arrivals := []checkin.Arrival{
+		at(-5, "VIP"),
+		at(2, "VIP", "STAFF"),
+		at(16),
+		at(17, "STAFF"),
+		at(19, "VIP"),
+		at(29),
+		at(30, "VIP"), //the end is not part of the last bucket
+	}
+
+	stats := checkin.NewArrivalStats(arrivals, start, end, 15*time.Minute, null.TimeFrom(start))
+	test.Equals(t, start, stats.Start)
+	test.Equals(t, end, stats.End)
+	test.Equals(t, 15, stats.Interval)
+	test.Equals(t, []string{"STAFF", "VIP", ""}, stats.Tags)
+	test.Equals(t, []checkin.ArrivalBucket{
+		{Start: start, CheckIns: 1, Cumulative: 2, Tags: map[string]int{"VIP": 1, "STAFF": 1}},
+		{Start: start.Add(15 * time.Minute), CheckIns: 4, Cumulative: 6, Tags: map[string]int{"": 2, "STAFF": 1, "VIP": 1}},
+	}, stats.Buckets)
+	test.Equals(t, 1, stats.Before)
+	test.Equals(t, 1, stats.After)
+	test.Equals(t, null.TimeFrom(start.Add(15*time.Minute)), stats.PeakStart)
+	test.Equals(t, 4.0/15, stats.PeakRate)
+	test.Equals(t, null.FloatFrom(17), stats.MedianArrival) //including those outside of the buckets
+
+	//test medians of an even number of arrivals, relative to a later start trigger
+	stats = checkin.NewArrivalStats(arrivals[1:], start, end, time.Minute, null.TimeFrom(start.Add(20*time.Minute)))
+	test.Equals(t, 30, len(stats.Buckets))
+	test.Equals(t, null.FloatFrom(-2), stats.MedianArrival)
+	test.Equals(t, 1.0, stats.PeakRate)
+	test.Equals(t, null.TimeFrom(start.Add(2*time.Minute)), stats.PeakStart) //the first of the busiest intervals
+
+	//test no arrivals, or no start trigger
+	stats = checkin.NewArrivalStats([]checkin.Arrival{}, start, end, 5*time.Minute, null.TimeFrom(start))
+	test.Equals(t, 6, len(stats.Buckets))
+	test.Equals(t, []string{}, stats.Tags)
+	test.Equals(t, 0, stats.Buckets[5].Cumulative)
+	test.Equals(t, null.Time{}, stats.PeakStart)
+	test.Equals(t, 0.0, stats.PeakRate)
+	test.Equals(t, null.Float{}, stats.MedianArrival)
+	stats = checkin.NewArrivalStats(arrivals, start, end, 5*time.Minute, null.Time{})
+	test.Equals(t, null.Float{}, stats.MedianArrival)
+}
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"checkin"
 	"checkin/bcrypt"
+	"checkin/hmac"
 	"checkin/http"
 	"checkin/http/cors"
 	websocket "checkin/http/gorillawebsocket"
 	"checkin/http/jwt"
+	"checkin/http/pgnotify"
+	"checkin/http/sse"
 	"checkin/postgres"
 	"checkin/qrcode"
+	"checkin/scheduler"
 	"fmt"
 	"log"
 	"os"
@@ -31,24 +36,47 @@ func main() {
 	defer db.Close()
 	log.Println("Successfully Connected!")
 
-	jwtAuthenticator := jwt.Authenticator{SigningKey: []byte(config["AUTH_SECRET"]), ExpiryTime: time.Duration(toInt(config["AUTH_HOURS"])) * time.Hour}
+	ts := &postgres.TokenService{DB: db}
+	jwtAuthenticator := jwt.Authenticator{SigningKey: []byte(config["AUTH_SECRET"]), ExpiryTime: time.Duration(toInt(config["AUTH_HOURS"])) * time.Hour,
+		RefreshExpiryTime: time.Duration(toInt(config["REFRESH_HOURS"])) * time.Hour, TokenService: ts}
 	bcryptHashMethod := bcrypt.HashMethod{HashCost: toInt(config["HASH_COST"])}
+	hmacDigestMethod := hmac.DigestMethod{Key: []byte(config["NRIC_SECRET"])}
 	qrGenerator := qrcode.Generator{Level: qrcode.High}
-	guestMessenger := websocket.NewGuestMessenger(2048, 2048)
+	guestMessenger, err := pgnotify.NewGuestMessenger(websocket.NewGuestMessenger(2048, 2048), hmacDigestMethod, db, config["DATABASE_URL"],
+		20*time.Second) //lets guests connect to any instance of the server
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer guestMessenger.Close()
+	hostMessenger := sse.NewHostMessenger(64, 30*time.Second)
 
 	us := &postgres.UserService{DB: db, HM: bcryptHashMethod}
 	as := &postgres.AuthenticationService{DB: db, HM: bcryptHashMethod}
 	es := &postgres.EventService{DB: db}
-	gs := &postgres.GuestService{DB: db, HM: bcryptHashMethod, HashCache: make(map[string]string)}
-
-	authHandler := http.NewAuthHandler(as, jwtAuthenticator, us)
+	gs := &postgres.GuestService{DB: db, HM: bcryptHashMethod, DM: hmacDigestMethod, HashCache: make(map[string]string)}
+	ss := &postgres.GuestSiteService{DB: db}
+	als := &postgres.AttendanceLogService{DB: db}
+	trs := &postgres.TriggerService{DB: db,
+		RetryPolicy: checkin.RetryPolicy{MaxAttempts: 8, BaseDelay: 30 * time.Second, MaxDelay: time.Hour}}
+	las := &postgres.LoginAttemptService{DB: db,
+		UsernamePolicy: checkin.LockoutPolicy{Threshold: 5, BaseLockout: 30 * time.Second, MaxLockout: time.Hour, ResetAfter: 24 * time.Hour},
+		IPPolicy:       checkin.LockoutPolicy{Threshold: 20, BaseLockout: 30 * time.Second, MaxLockout: time.Hour, ResetAfter: 24 * time.Hour}}
+
+	authHandler := http.NewAuthHandler(as, las, jwtAuthenticator, us)
 	userHandler := http.NewUserHandler(us, jwtAuthenticator)
-	guestHandler := http.NewGuestHandler(gs, es, guestMessenger, jwtAuthenticator, toInt(config["MAX_LENGTH_GUEST_NAME"]),
+	guestHandler := http.NewGuestHandler(gs, als, es, guestMessenger, hostMessenger, jwtAuthenticator, toInt(config["MAX_LENGTH_GUEST_NAME"]),
 		toInt(config["MAX_LENGTH_GUEST_TAG"]))
-	eventHandler := http.NewEventHandler(es, jwtAuthenticator, guestHandler, toInt(config["MAX_LENGTH_EVENT_NAME"]),
+	eventHandler := http.NewEventHandler(es, us, ss, trs, jwtAuthenticator, guestHandler, toInt(config["MAX_LENGTH_EVENT_NAME"]),
 		toInt(config["MAX_LENGTH_EVENT_URL"]), toInt(config["MAX_LENGTH_EVENT_TIMETAG"]))
 	utilityHandler := http.NewUtilityHandler(qrGenerator)
 
+	triggerScheduler := scheduler.New(trs, guestMessenger, 15*time.Second) //fires the actions of triggers as they elapse
+	triggerScheduler.Start()
+	defer triggerScheduler.Close()
+	eventPurger := scheduler.NewPurger(es, checkin.ArchiveRetention, time.Hour) //deletes events archived for too long to be restored
+	eventPurger.Start()
+	defer eventPurger.Close()
+
 	handler := http.Handler{
 		AuthHandler:    authHandler,
 		EventHandler:   eventHandler,
@@ -90,7 +118,9 @@ func getConfig() map[string]string {
 	addConfig("DATABASE_URL", conf)
 	addConfig("AUTH_SECRET", conf)
 	addConfig("AUTH_HOURS", conf)
+	addConfig("REFRESH_HOURS", conf)
 	addConfig("HASH_COST", conf)
+	addConfig("NRIC_SECRET", conf)
 	addConfig("PORT", conf)
 	addConfig("ALLOWED_ORIGINS", conf)
 	addConfig("ALLOWED_METHODS", conf)
@@ -117,6 +147,7 @@ func configurePFH(env map[string]string) cors.PreFlightHandler {
 		AllowedOrigins: tokenizeAndTrim(env["ALLOWED_ORIGINS"]),
 		AllowedMethods: tokenizeAndTrim(env["ALLOWED_METHODS"]),
 		AllowedHeaders: tokenizeAndTrim(env["ALLOWED_HEADERS"]),
+		ExposedHeaders: []string{http.TotalCountHeader, http.ETagHeader},
 	}
 }
 
@@ -31,8 +31,11 @@ create table event(
 	lat float8,
 	long float8,
 	radius float8, --in km
+	geofence text NOT NULL DEFAULT '' CHECK (geofence in ('', 'flag', 'enforce')),
+	timezone text NOT NULL DEFAULT '', -- IANA time zone database name, '' for UTC
 	createdAt TIMESTAMP NOT NULL DEFAULT (NOW() at time zone 'utc'),
-	updatedAt TIMESTAMP NOT NULL DEFAULT (NOW() at time zone 'utc')
+	updatedAt TIMESTAMP NOT NULL DEFAULT (NOW() at time zone 'utc'),
+	archivedAt TIMESTAMP -- set when the event is deleted, until it is restored or purged
 );
 
 create table form (
@@ -45,6 +48,7 @@ create table form (
 
 create table guest(
 	nricHash text NOT NULL,
+	nricDigest text, -- keyed digest of the NRIC, NULL for guests registered before digests were introduced
 	eventID UUID NOT NULL REFERENCES event(ID) ON UPDATE CASCADE ON DELETE CASCADE,
 	name text NOT NULL,
 	tags text[] NOT NULL DEFAULT '{}',
@@ -53,12 +57,163 @@ create table guest(
 	PRIMARY KEY(nricHash, eventID)
 );
 
+create unique index guest_nricdigest_idx on guest(eventID, nricDigest);
+
+-- sittings of events which run over several, such as the days of a course
+create table session(
+	ID UUID PRIMARY KEY NOT NULL DEFAULT uuid_generate_v4(),
+	eventID UUID NOT NULL REFERENCES event(ID) ON UPDATE CASCADE ON DELETE CASCADE,
+	name text NOT NULL,
+	"start" TIMESTAMP,
+	"end" TIMESTAMP,
+	createdAt TIMESTAMP NOT NULL DEFAULT (NOW() at time zone 'utc')
+);
+
+create index session_eventid_idx on session(eventID);
+
+-- guests checked in to each session, separately from guest.checkedIn for the event as a whole
+create table sessionattendance(
+	sessionID UUID NOT NULL REFERENCES session(ID) ON UPDATE CASCADE ON DELETE CASCADE,
+	eventID UUID NOT NULL,
+	nricHash text NOT NULL,
+	checkInTime TIMESTAMP NOT NULL,
+	PRIMARY KEY(sessionID, nricHash),
+	FOREIGN KEY(nricHash, eventID) REFERENCES guest(nricHash, eventID) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
 create table hosts(
 	username text NOT NULL REFERENCES app_user(username) ON UPDATE CASCADE ON DELETE CASCADE,
 	eventID UUID NOT NULL REFERENCES event(ID) ON UPDATE CASCADE ON DELETE CASCADE,
+	role text NOT NULL DEFAULT 'owner' CHECK (role in ('owner', 'cohost', 'usher', 'viewer')),
 	PRIMARY KEY(username, eventID)
 );
 
+create unique index hosts_one_owner_idx on hosts(eventID) where role = 'owner'; -- every event has at most one owner
+
+-- reusable event details saved by users, which events can be made from
+create table eventtemplate(
+	ID UUID PRIMARY KEY NOT NULL DEFAULT uuid_generate_v4(),
+	name text NOT NULL,
+	username text NOT NULL REFERENCES app_user(username) ON UPDATE CASCADE ON DELETE CASCADE,
+	event json NOT NULL, -- the checkin.Event, stored as JSON
+	createdAt TIMESTAMP NOT NULL DEFAULT (NOW() at time zone 'utc')
+);
+
+create index eventtemplate_username_idx on eventtemplate(username);
+
+-- actions fired when a trigger (time tag) of an event elapses
+create table triggeraction(
+	ID UUID PRIMARY KEY NOT NULL DEFAULT uuid_generate_v4(),
+	eventID UUID NOT NULL REFERENCES event(ID) ON UPDATE CASCADE ON DELETE CASCADE,
+	tag text NOT NULL, -- the time tag it is fired by
+	type text NOT NULL CHECK (type in ('webhook', 'broadcast')),
+	url text, -- for webhooks
+	message text, -- for broadcasts
+	secret text NOT NULL DEFAULT '', -- key webhooks are signed with
+	createdAt TIMESTAMP NOT NULL DEFAULT (NOW() at time zone 'utc')
+);
+
+create index triggeraction_eventid_idx on triggeraction(eventID);
+
+-- each firing of an action, kept after it is delivered or given up on
+create table triggerdelivery(
+	ID UUID PRIMARY KEY NOT NULL DEFAULT uuid_generate_v4(),
+	actionID UUID NOT NULL REFERENCES triggeraction(ID) ON UPDATE CASCADE ON DELETE CASCADE,
+	triggerTime TIMESTAMP NOT NULL, -- the time the tag elapsed at, so each time is delivered once
+	status text NOT NULL DEFAULT 'pending' CHECK (status in ('pending', 'delivered', 'failed')),
+	attempts int NOT NULL DEFAULT 0,
+	nextAttempt TIMESTAMP, -- null once delivered or given up on
+	lastError text,
+	deliveredAt TIMESTAMP,
+	UNIQUE(actionID, triggerTime)
+);
+
+create index triggerdelivery_nextattempt_idx on triggerdelivery(nextAttempt) where status = 'pending';
+
+create table guestsite(
+	eventID UUID PRIMARY KEY NOT NULL REFERENCES event(ID) ON UPDATE CASCADE ON DELETE CASCADE,
+	site json NOT NULL, -- the checkin.GuestSite, stored as JSON
+	updatedAt TIMESTAMP NOT NULL DEFAULT (NOW() at time zone 'utc')
+);
+
+-- append-only log of changes to guests' attendance, kept even if the guest is removed
+create table attendancelog(
+	ID bigserial PRIMARY KEY,
+	eventID UUID NOT NULL REFERENCES event(ID) ON UPDATE CASCADE ON DELETE CASCADE,
+	nricHash text NOT NULL,
+	guestName text NOT NULL,
+	action text NOT NULL, -- checkin or markabsent
+	sessionID UUID, -- null for changes to the event as a whole, kept even if the session is removed
+	time TIMESTAMP NOT NULL DEFAULT (NOW() at time zone 'utc'),
+	actor text NOT NULL, -- username of the host or admin, or self
+	ipAddress text NOT NULL DEFAULT '',
+	userAgent text NOT NULL DEFAULT ''
+);
+
+create index attendancelog_eventid_time_idx on attendancelog(eventID, time);
+
+-- append-only record of guests checking themselves in from outside the geofence of an event, for hosts to review
+create table checkinflag(
+	ID bigserial PRIMARY KEY,
+	eventID UUID NOT NULL REFERENCES event(ID) ON UPDATE CASCADE ON DELETE CASCADE,
+	nricHash text NOT NULL,
+	guestName text NOT NULL,
+	lat float8, -- null if the guest did not give their location
+	long float8,
+	distance float8, -- from the event, in km
+	rejected BOOLEAN NOT NULL, -- whether the guest was refused check in
+	time TIMESTAMP NOT NULL DEFAULT (NOW() at time zone 'utc'),
+	actor text NOT NULL,
+	ipAddress text NOT NULL DEFAULT '',
+	userAgent text NOT NULL DEFAULT ''
+);
+
+create index checkinflag_eventid_time_idx on checkinflag(eventID, time);
+
+-- guests with an open connection to an instance of the server, kept up to date by each instance
+create table guestconnection(
+	guestKey text NOT NULL, -- keyed digest of the guest ID, as guest IDs contain NRICs
+	instanceID UUID NOT NULL,
+	lastSeen TIMESTAMP NOT NULL DEFAULT (NOW() at time zone 'utc'),
+	PRIMARY KEY(guestKey, instanceID)
+);
+
+create table refreshtoken(
+	tokenDigest text PRIMARY KEY NOT NULL, -- sha256 of the token, which is only ever given to the client
+	username text NOT NULL, -- of a user or admin, so not a foreign key
+	isAdmin BOOLEAN NOT NULL,
+	issuedAt TIMESTAMP NOT NULL DEFAULT (NOW() at time zone 'utc'),
+	expiresAt TIMESTAMP NOT NULL
+);
+
+-- append-only log of failed logins, for auditing
+create table loginfailure(
+	ID bigserial PRIMARY KEY,
+	username text NOT NULL,
+	isAdmin BOOLEAN NOT NULL,
+	ipAddress text NOT NULL DEFAULT '',
+	userAgent text NOT NULL DEFAULT '',
+	time TIMESTAMP NOT NULL DEFAULT (NOW() at time zone 'utc')
+);
+
+create index loginfailure_username_time_idx on loginfailure(username, time);
+
+-- recent failed logins for each username and IP address, which lock out further logins when there are too many
+create table loginlockout(
+	kind text NOT NULL, -- username or ip
+	key text NOT NULL,
+	failedAttempts int NOT NULL,
+	lastFailedAt TIMESTAMP NOT NULL,
+	lockedUntil TIMESTAMP,
+	PRIMARY KEY(kind, key)
+);
+
+-- access tokens revoked before they expire, which can be removed once they expire
+create table revokedtoken(
+	tokenID text PRIMARY KEY NOT NULL,
+	expiresAt TIMESTAMP NOT NULL
+);
+
 --test
 
 create USER server_access with password 'LongNightShortDay';
@@ -68,4 +223,20 @@ grant SELECT, INSERT, UPDATE on app_admin to server_access;
 grant SELECT, INSERT, UPDATE, DELETE on event to server_access;
 grant SELECT, INSERT, UPDATE, DELETE on hosts to server_access;
 grant SELECT, INSERT, UPDATE, DELETE on guest to server_access;
-grant SELECT, INSERT, UPDATE, DELETE on form to server_access;
\ No newline at end of file
+grant SELECT, INSERT, UPDATE, DELETE on session to server_access;
+grant SELECT, INSERT, UPDATE, DELETE on sessionattendance to server_access;
+grant SELECT, INSERT, UPDATE, DELETE on form to server_access;
+grant SELECT, INSERT, UPDATE, DELETE on guestsite to server_access;
+grant SELECT, INSERT, UPDATE, DELETE on eventtemplate to server_access;
+grant SELECT, INSERT, UPDATE, DELETE on triggeraction to server_access;
+grant SELECT, INSERT, UPDATE, DELETE on triggerdelivery to server_access;
+grant SELECT, INSERT on attendancelog to server_access; -- append-only
+grant USAGE on SEQUENCE attendancelog_id_seq to server_access;
+grant SELECT, INSERT on checkinflag to server_access; -- append-only
+grant USAGE on SEQUENCE checkinflag_id_seq to server_access;
+grant SELECT, INSERT, UPDATE, DELETE on guestconnection to server_access;
+grant SELECT, INSERT, UPDATE, DELETE on refreshtoken to server_access;
+grant SELECT, INSERT, UPDATE, DELETE on revokedtoken to server_access;
+grant SELECT, INSERT on loginfailure to server_access; -- append-only
+grant USAGE on SEQUENCE loginfailure_id_seq to server_access;
+grant SELECT, INSERT, UPDATE, DELETE on loginlockout to server_access;
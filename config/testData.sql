@@ -22,11 +22,11 @@ INSERT into form (ID, name, eventID, survey, submitTime) VALUES
     ('663fd6e1-b781-49e7-b1ed-dd0e3c6ff28e','Bob', 'aa19239f-f9f5-4935-b1f7-0edfdceabba7', '[{"question":"A","answer":"AA2"},{"question":"B","answer":"BB2"},{"question":"C","answer":"CC2"}]', '2019-04-11 09:32:04'),
     ('a6db3963-5389-4dbe-8fc6-bbd7f7ce66b8','Jonathan', '2c59b54d-3422-4bdb-824c-4125775b44c8', '[{"question":"D","answer":"DD"},{"question":"E","answer":"EE"},{"question":"C","answer":"CC3"}]', '2019-02-17 13:18:53');
 
-INSERT into hosts(username, eventID) VALUES
-    ('ME5Bob', '2c59b54d-3422-4bdb-824c-4125775b44c8'),
-	('TestUser', '3820a980-a207-4738-b82b-45808fe7aba8'),
-	('TestUser', 'aa19239f-f9f5-4935-b1f7-0edfdceabba7'),
-	('ME5Bob', 'aa19239f-f9f5-4935-b1f7-0edfdceabba7');
+INSERT into hosts(username, eventID, role) VALUES
+    ('ME5Bob', '2c59b54d-3422-4bdb-824c-4125775b44c8', 'owner'),
+	('TestUser', '3820a980-a207-4738-b82b-45808fe7aba8', 'owner'),
+	('TestUser', 'aa19239f-f9f5-4935-b1f7-0edfdceabba7', 'owner'),
+	('ME5Bob', 'aa19239f-f9f5-4935-b1f7-0edfdceabba7', 'cohost');
 
 INSERT into guest(nricHash, eventID, name, tags, checkedIn, checkInTime) VALUES
     ('A1234', 'aa19239f-f9f5-4935-b1f7-0edfdceabba7', 'A', '{}', FALSE, NULL),
@@ -56,3 +56,12 @@ INSERT into guest(nricHash, eventID, name, tags, checkedIn, checkInTime) VALUES
     ('B1132', 'c14a592c-950d-44ba-b173-bbb9e4f5c8b4', 'D', '{"VIP", "ATTENDING"}', TRUE, NOW()),
     ('Z4432', 'c14a592c-950d-44ba-b173-bbb9e4f5c8b4', 'E', '{"VIP"}', TRUE, NOW()),
     ('D2482', 'c14a592c-950d-44ba-b173-bbb9e4f5c8b4', 'F', '{"OFFICER"}', FALSE, NULL);
+
+INSERT into guestsite(eventID, site) VALUES
+    ('aa19239f-f9f5-4935-b1f7-0edfdceabba7', '{"title":{"cont":"DSD Talk","sz":5},"tagline":{"cont":"Data for all","sz":2},"logo":"https://logo.com/dsd.png","details":[{"title":"Venue","content":"KC3"}],"buttons":[[{"sz":1,"title":"Map","type":"link","cont":"https://maps.google.com"}]]}');
+
+INSERT into attendancelog(eventID, nricHash, guestName, action, time, actor, ipAddress, userAgent) VALUES
+    ('2c59b54d-3422-4bdb-824c-4125775b44c8', 'B7234', 'P', 'checkin', '2019-04-12 09:01:00', 'self', '203.0.113.5', 'Mozilla/5.0'),
+	('2c59b54d-3422-4bdb-824c-4125775b44c8', 'C9648', 'Q', 'checkin', '2019-04-12 09:02:30', 'self', '203.0.113.6', 'Mozilla/5.0'),
+	('2c59b54d-3422-4bdb-824c-4125775b44c8', 'B7234', 'P', 'markabsent', '2019-04-12 09:05:00', 'TestUser', '198.51.100.1', 'curl/7.64.0'),
+	('2c59b54d-3422-4bdb-824c-4125775b44c8', 'B7234', 'P', 'checkin', '2019-04-12 09:06:00', 'TestUser', '198.51.100.1', 'curl/7.64.0');
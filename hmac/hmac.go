@@ -0,0 +1,26 @@
+package hmac
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+//DigestMethod An implementation of the checkin.DigestMethod interface
+//Using HMAC-SHA256 with a secret key
+type DigestMethod struct {
+	Key []byte //must be kept secret, or digests can be brute forced
+}
+
+//Digest returns the hex encoded HMAC-SHA256 of the string, keyed with the
+//key of the hmac.DigestMethod instance
+//Returns an error if no key was supplied
+func (dm DigestMethod) Digest(str string) (string, error) {
+	if len(dm.Key) == 0 {
+		return "", errors.New("Cannot compute digest without a key")
+	}
+	mac := hmac.New(sha256.New, dm.Key)
+	mac.Write([]byte(str)) //never returns an error
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
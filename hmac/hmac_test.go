@@ -0,0 +1,37 @@
+package hmac_test
+
+import (
+	"checkin/hmac"
+	"checkin/test"
+	"testing"
+)
+
+func TestDigest(t *testing.T) {
+	dm := hmac.DigestMethod{Key: []byte("secret")}
+
+	//same string, same digest
+	digest, err := dm.Digest("1234A")
+	test.Ok(t, err)
+	again, err := dm.Digest("1234A")
+	test.Ok(t, err)
+	test.Equals(t, digest, again)
+
+	//different string, different digest
+	other, err := dm.Digest("1234B")
+	test.Ok(t, err)
+	test.Assert(t, digest != other, "Different strings gave the same digest")
+
+	//different key, different digest
+	other, err = hmac.DigestMethod{Key: []byte("another secret")}.Digest("1234A")
+	test.Ok(t, err)
+	test.Assert(t, digest != other, "Different keys gave the same digest")
+
+	//known value
+	digest, err = hmac.DigestMethod{Key: []byte("key")}.Digest("The quick brown fox jumps over the lazy dog")
+	test.Ok(t, err)
+	test.Equals(t, "f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8", digest)
+
+	//no key
+	_, err = hmac.DigestMethod{}.Digest("1234A")
+	test.Assert(t, err != nil, "No error thrown when computing digest without a key")
+}
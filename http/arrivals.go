@@ -0,0 +1,109 @@
+package http
+
+import (
+	"bytes"
+	"checkin"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+//defaultArrivalInterval is the interval check ins are bucketed by if none is given
+const defaultArrivalInterval = 5 * time.Minute
+
+//handleArrivals writes the check ins of the guests of the event given by the eventID in the URL over time,
+//as worked out by arrivalStats
+func (h *GuestHandler) handleArrivals(w http.ResponseWriter, r *http.Request) {
+	stats, _, ok := h.arrivalStats(w, r)
+	if !ok {
+		return
+	}
+	reply, _ := json.Marshal(stats)
+	w.Write(reply)
+}
+
+//handleArrivalsReport writes a CSV report of the check ins of the guests of the event given by the eventID in the URL
+//over time, with a row for each interval, and a column for the check ins of guests with each tag
+//Times are written in the timezone given by the ?loc argument, or else in that of the event
+func (h *GuestHandler) handleArrivalsReport(w http.ResponseWriter, r *http.Request) {
+	stats, event, ok := h.arrivalStats(w, r)
+	if !ok {
+		return
+	}
+	location, ok := requestLocation(w, r, event.Timezone)
+	if !ok {
+		return
+	}
+
+	b := &bytes.Buffer{}
+	wr := csv.NewWriter(b)
+	header := []string{"Interval Start", "Check Ins", "Cumulative"}
+	for _, tag := range stats.Tags {
+		if tag == "" {
+			tag = "Untagged"
+		}
+		header = append(header, tag)
+	}
+	wr.Write(header)
+	for _, bucket := range stats.Buckets {
+		row := []string{bucket.Start.In(location).Format(time.RFC3339), strconv.Itoa(bucket.CheckIns),
+			strconv.Itoa(bucket.Cumulative)}
+		for _, tag := range stats.Tags {
+			row = append(row, strconv.Itoa(bucket.Tags[tag]))
+		}
+		wr.Write(row)
+	}
+	wr.Flush()
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment;filename=ArrivalsReport.csv")
+	w.Write(b.Bytes())
+}
+
+//arrivalStats fetches the event in the request, and buckets the check ins of its guests between its start and end
+//by the interval query parameter, in minutes (see checkin.ArrivalIntervals)
+//Only guests matching the tag filter of the request (see tagFilter) are included
+//If they cannot be worked out, an error is written and false returned
+func (h *GuestHandler) arrivalStats(w http.ResponseWriter, r *http.Request) (checkin.ArrivalStats, checkin.Event, bool) {
+	eventID, interval := mux.Vars(r)["eventID"], defaultArrivalInterval
+	if val := r.FormValue("interval"); val != "" {
+		minutes, err := strconv.Atoi(val)
+		interval = time.Duration(minutes) * time.Minute
+		if err != nil || !checkin.ValidArrivalInterval(interval) {
+			WriteMessage(http.StatusBadRequest, "Form value 'interval' must be 1, 5 or 15 (minutes)", w)
+			return checkin.ArrivalStats{}, checkin.Event{}, false
+		}
+	}
+	filter, ok := h.tagFilter(w, r, "tag")
+	if !ok {
+		return checkin.ArrivalStats{}, checkin.Event{}, false
+	}
+
+	event, err := h.EventService.Event(eventID)
+	if err != nil {
+		h.Logger.Println("Error fetching event for arrivals: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching event", w)
+		return checkin.ArrivalStats{}, checkin.Event{}, false
+	}
+	if !event.Start.Valid || !event.End.Valid {
+		WriteMessage(http.StatusBadRequest, "Event needs a start and end to bucket arrivals between", w)
+		return checkin.ArrivalStats{}, checkin.Event{}, false
+	}
+	if checkin.NumArrivalBuckets(event.Start.Time, event.End.Time, interval) > checkin.MaxArrivalBuckets {
+		WriteMessage(http.StatusBadRequest, "Event is too long to bucket arrivals by this interval, use a longer one", w)
+		return checkin.ArrivalStats{}, checkin.Event{}, false
+	}
+
+	arrivals, err := h.GuestService.Arrivals(eventID, filter)
+	if err != nil {
+		h.Logger.Println("Error fetching arrivals: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching arrivals of guests", w)
+		return checkin.ArrivalStats{}, checkin.Event{}, false
+	}
+	stats := checkin.NewArrivalStats(arrivals, event.Start.Time, event.End.Time, interval, event.ArrivalStartTrigger())
+	return stats, event, true
+}
@@ -0,0 +1,178 @@
+package http_test
+
+import (
+	"checkin"
+	myhttp "checkin/http"
+	"checkin/mock"
+	"checkin/test"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+)
+
+//Generates an Event mock function which returns an event with the ID, start and end given, in Singapore time
+func arrivalEventGenerator(expectedID string, start null.Time, end null.Time, err error) func(string) (checkin.Event, error) {
+	return func(ID string) (checkin.Event, error) {
+		if ID != expectedID {
+			return checkin.Event{}, errors.New("Unexpected ID " + ID)
+		}
+		return checkin.Event{ID: ID, Start: start, End: end, Timezone: "Asia/Singapore",
+			TimeTags: map[string]time.Time{checkin.StartTrigger: start.Time.Add(15 * time.Minute)}}, err
+	}
+}
+
+func TestHandleArrivals(t *testing.T) {
+	var gs mock.GuestService
+	var es mock.EventService
+	var auth mock.Authenticator
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &mock.GuestMessenger{}, &mock.HostMessenger{}, &auth, 64, 64)
+
+	start := time.Date(2019, 4, 10, 1, 0, 0, 0, time.UTC)
+	es.CheckIfExistsFn = checkIfExistsGenerator("100", nil)
+	es.EventFn = arrivalEventGenerator("100", null.TimeFrom(start), null.TimeFrom(start.Add(time.Hour)), nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "100", checkin.RoleViewer, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	var receivedFilter checkin.TagFilter
+	arrivalsGenerator := func(err error) func(string, checkin.TagFilter) ([]checkin.Arrival, error) {
+		return func(eventID string, filter checkin.TagFilter) ([]checkin.Arrival, error) {
+			test.Equals(t, "100", eventID)
+			receivedFilter = filter
+			return []checkin.Arrival{
+				{Time: start.Add(3 * time.Minute), Tags: []string{"VIP"}},
+				{Time: start.Add(4 * time.Minute), Tags: []string{}},
+				{Time: start.Add(40 * time.Minute), Tags: []string{"VIP"}},
+			}, err
+		}
+	}
+	gs.ArrivalsFn = arrivalsGenerator(nil)
+
+	//test normal functionality, in the event's timezone with 5 minute intervals by default
+	r := httptest.NewRequest("GET", "/api/v1-4/events/100/guests/arrivals", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	var stats checkin.ArrivalStats
+	test.Ok(t, json.NewDecoder(w.Result().Body).Decode(&stats))
+	test.Equals(t, 12, len(stats.Buckets))
+	test.Equals(t, 5, stats.Interval)
+	test.Equals(t, "2019-04-10T09:00:00+08:00", stats.Buckets[0].Start.Format(time.RFC3339))
+	test.Equals(t, map[string]int{"VIP": 1, "": 1}, stats.Buckets[0].Tags)
+	test.Equals(t, 3, stats.Buckets[11].Cumulative)
+	test.Equals(t, 0.4, stats.PeakRate)
+	test.Equals(t, null.FloatFrom(-11), stats.MedianArrival)
+	test.Equals(t, checkin.TagFilter{}, receivedFilter)
+
+	//test other intervals and timezones, filtered by tags
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1-4/events/100/guests/arrivals?interval=15&loc=UTC&tag=VIP", nil))
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Ok(t, json.NewDecoder(w.Result().Body).Decode(&stats))
+	test.Equals(t, 4, len(stats.Buckets))
+	test.Equals(t, "2019-04-10T01:15:00Z", stats.Buckets[1].Start.Format(time.RFC3339))
+	test.Equals(t, checkin.HasTag("VIP"), receivedFilter)
+
+	//test invalid intervals and tag filters
+	gs.ArrivalsInvoked = false
+	for _, query := range []string{"interval=10", "interval=five", "filter=VIP+and"} {
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1-4/events/100/guests/arrivals?"+query, nil))
+		test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+	}
+
+	//test events without a start or end, or which are too long to bucket by the interval
+	es.EventFn = arrivalEventGenerator("100", null.TimeFrom(start), null.Time{}, nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+	es.EventFn = arrivalEventGenerator("100", null.TimeFrom(start), null.TimeFrom(start.Add(7*24*time.Hour)), nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1-4/events/100/guests/arrivals?interval=1", nil))
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1-4/events/100/guests/arrivals?interval=15", nil))
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Assert(t, gs.ArrivalsInvoked, "Arrivals not fetched for valid request")
+	es.EventFn = arrivalEventGenerator("100", null.TimeFrom(start), null.TimeFrom(start.Add(time.Hour)), nil)
+
+	//test errors fetching the event and arrivals
+	es.EventFn = arrivalEventGenerator("100", null.TimeFrom(start), null.TimeFrom(start.Add(time.Hour)), errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1-4/events/100/guests/arrivals?loc=UTC", nil))
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	es.EventFn = arrivalEventGenerator("100", null.TimeFrom(start), null.TimeFrom(start.Add(time.Hour)), nil)
+	gs.ArrivalsFn = arrivalsGenerator(errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	gs.ArrivalsFn = arrivalsGenerator(nil)
+
+	//access restriction tests
+	roleAccessTest(t, r, h, &es, "testing_username", "100", []string{checkin.RoleOwner, checkin.RoleCoHost, checkin.RoleViewer},
+		func(r *http.Response) {
+			test.Equals(t, http.StatusOK, r.StatusCode)
+		})
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+	eventDoesNotExistTest(t, httptest.NewRequest("GET", "/api/v1-4/events/200/guests/arrivals", nil), h, &es)
+}
+
+func TestHandleArrivalsReport(t *testing.T) {
+	var gs mock.GuestService
+	var es mock.EventService
+	var auth mock.Authenticator
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &mock.GuestMessenger{}, &mock.HostMessenger{}, &auth, 64, 64)
+
+	start := time.Date(2019, 4, 10, 1, 0, 0, 0, time.UTC)
+	es.CheckIfExistsFn = checkIfExistsGenerator("100", nil)
+	es.EventFn = arrivalEventGenerator("100", null.TimeFrom(start), null.TimeFrom(start.Add(30*time.Minute)), nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "100", checkin.RoleViewer, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	gs.ArrivalsFn = func(eventID string, filter checkin.TagFilter) ([]checkin.Arrival, error) {
+		return []checkin.Arrival{
+			{Time: start.Add(-time.Minute), Tags: []string{"VIP"}},
+			{Time: start.Add(3 * time.Minute), Tags: []string{"VIP", "STAFF"}},
+			{Time: start.Add(20 * time.Minute), Tags: []string{}},
+		}, nil
+	}
+
+	//test normal functionality, with times in the event's timezone
+	r := httptest.NewRequest("GET", "/api/v1-4/events/100/guests/report/arrivals?interval=15", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, "text/csv", w.Result().Header.Get("Content-Type"))
+	data, err := csv.NewReader(w.Result().Body).ReadAll()
+	test.Ok(t, err)
+	test.Equals(t, [][]string{
+		{"Interval Start", "Check Ins", "Cumulative", "STAFF", "VIP", "Untagged"},
+		{"2019-04-10T09:00:00+08:00", "1", "2", "1", "1", "0"},
+		{"2019-04-10T09:15:00+08:00", "1", "3", "0", "0", "1"},
+	}, data)
+
+	//test times in the timezone given
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1-4/events/100/guests/report/arrivals?interval=15&loc=UTC", nil))
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	data, err = csv.NewReader(w.Result().Body).ReadAll()
+	test.Ok(t, err)
+	test.Equals(t, "2019-04-10T01:15:00Z", data[2][0])
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1-4/events/100/guests/report/arrivals?loc=Asia/China", nil))
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	//access restriction tests
+	roleAccessTest(t, r, h, &es, "testing_username", "100", []string{checkin.RoleOwner, checkin.RoleCoHost, checkin.RoleViewer},
+		func(r *http.Response) {
+			test.Equals(t, http.StatusOK, r.StatusCode)
+		})
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+}
@@ -3,9 +3,15 @@ package http
 import (
 	"checkin"
 	"encoding/json"
+	"io"
 	"log"
+	"math"
+	"net"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -14,28 +20,46 @@ import (
 //requests. It needs an AuthenticationService and a logger
 //Also needs a UserService to update the last logged in status of the user
 //Needs an Authenticator to send the client its authentication tokens
+//Needs a LoginAttemptService to lock out clients who fail to log in too many times
 type AuthHandler struct {
 	*mux.Router
-	AuthService   checkin.AuthenticationService
-	UserService   checkin.UserService
-	Authenticator Authenticator
-	Logger        *log.Logger
+	AuthService         checkin.AuthenticationService
+	LoginAttemptService checkin.LoginAttemptService
+	UserService         checkin.UserService
+	Authenticator       Authenticator
+	Logger              *log.Logger
 }
 
 //NewAuthHandler creates a new AuthHandler which uses the given authentication service to check
-//authentication, the given authenticator to issue authorization to the client
+//authentication, the given login attempt service to lock out repeated failures,
+//the given authenticator to issue authorization to the client
 //and the given user service to update the last logged in of the User
-func NewAuthHandler(as checkin.AuthenticationService, auth Authenticator, us checkin.UserService) *AuthHandler {
+func NewAuthHandler(as checkin.AuthenticationService, las checkin.LoginAttemptService, auth Authenticator,
+	us checkin.UserService) *AuthHandler {
 	h := &AuthHandler{
-		Router:        mux.NewRouter(),
-		Logger:        log.New(os.Stderr, "", log.LstdFlags),
-		AuthService:   as,
-		UserService:   us,
-		Authenticator: auth,
+		Router:              mux.NewRouter(),
+		Logger:              log.New(os.Stderr, "", log.LstdFlags),
+		AuthService:         as,
+		LoginAttemptService: las,
+		UserService:         us,
+		Authenticator:       auth,
 	}
+
+	tokenCheck := checkAuth(auth, h.Logger)
+	adminCheck := isAdmin(auth, h.Logger)
+
 	h.Handle("/api/v0/auth/admins/login", http.HandlerFunc(h.handleLogin(true))).Methods("POST")
 	h.Handle("/api/v0/auth/users/login", http.HandlerFunc(h.handleLogin(false))).Methods("POST")
 	h.Handle("/api/v1-3/auth/verify", http.HandlerFunc(h.handleVerify)).Methods("POST")
+	h.Handle("/api/v1-4/auth/refresh", http.HandlerFunc(h.handleRefresh)).Methods("POST")
+	h.Handle("/api/v1-4/auth/logout", Adapt(http.HandlerFunc(h.handleLogout),
+		tokenCheck)).Methods("POST")
+	h.Handle("/api/v1-4/auth/lockouts", Adapt(http.HandlerFunc(h.handleLockouts),
+		tokenCheck, adminCheck, correctTimezonesOutput, jsonSelector)).Methods("GET")
+	h.Handle("/api/v1-4/auth/lockouts/{kind}/{key}", Adapt(http.HandlerFunc(h.handleResetLockout),
+		tokenCheck, adminCheck)).Methods("DELETE")
+	h.Handle("/api/v1-4/auth/failedattempts/{username}", Adapt(http.HandlerFunc(h.handleLoginFailures),
+		tokenCheck, adminCheck, correctTimezonesOutput, jsonSelector)).Methods("GET")
 	return h
 }
 
@@ -53,6 +77,21 @@ func (h *AuthHandler) handleLogin(isAdmin bool) http.HandlerFunc {
 			return
 		}
 
+		ipAddress := clientAddress(r)
+		lockedUntil, err := h.LoginAttemptService.LockedUntil(loginDetails["username"], ipAddress)
+		if err != nil {
+			h.Logger.Println("Login faced an error checking for lockout: " + err.Error())
+			WriteMessage(http.StatusInternalServerError, "Authentication failed due to server error", w)
+			return
+		}
+		if lockedUntil.Valid {
+			retryAfter := int(math.Ceil(time.Until(lockedUntil.Time).Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			WriteMessage(http.StatusTooManyRequests, "Too many failed login attempts, try again in "+
+				strconv.Itoa(retryAfter)+" seconds", w)
+			return
+		}
+
 		isAuthenticated, err := h.AuthService.Authenticate(loginDetails["username"],
 			loginDetails["password"], isAdmin)
 
@@ -63,6 +102,11 @@ func (h *AuthHandler) handleLogin(isAdmin bool) http.HandlerFunc {
 		}
 
 		if isAuthenticated {
+			if err := h.LoginAttemptService.ClearFailures(loginDetails["username"]); err != nil {
+				h.Logger.Println("Login faced an error clearing failed attempts: " + err.Error())
+				WriteMessage(http.StatusInternalServerError, "Error clearing failed login attempts", w)
+				return
+			}
 			if !isAdmin {
 				if err := h.UserService.UpdateLastLoggedIn(loginDetails["username"]); err != nil {
 					h.Logger.Println("Login faced an error in updated last logged in: " + err.Error())
@@ -79,11 +123,37 @@ func (h *AuthHandler) handleLogin(isAdmin bool) http.HandlerFunc {
 				WriteMessage(http.StatusInternalServerError, "Token creation failed", w)
 			}
 		} else {
+			err := h.LoginAttemptService.RecordFailure(checkin.LoginFailure{
+				Username:  loginDetails["username"],
+				IsAdmin:   isAdmin,
+				IPAddress: ipAddress,
+				UserAgent: r.UserAgent(),
+			})
+			if err != nil { //failures which are not counted would let clients get around lockouts
+				h.Logger.Println("Login faced an error recording failed attempt: " + err.Error())
+				WriteMessage(http.StatusInternalServerError, "Authentication failed due to server error", w)
+				return
+			}
 			WriteMessage(http.StatusUnauthorized, "Incorrect Username or Password", w)
 		}
 	}
 }
 
+//clientAddress returns the IP address of the client which made the request
+//The load balancer appends the address connecting to it to the X-Forwarded-For header, so the last
+//address is used - unlike the earlier ones, it cannot be forged by the client (e.g. to get around lockouts,
+//or to hide where a guest was checked in from in the attendance log)
+func clientAddress(r *http.Request) string {
+	if forwardedFor := r.Header.Get("X-Forwarded-For"); forwardedFor != "" {
+		addresses := strings.Split(forwardedFor, ",")
+		return strings.TrimSpace(addresses[len(addresses)-1])
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
 func (h *AuthHandler) handleVerify(w http.ResponseWriter, r *http.Request) {
 	tokenValid, err := h.Authenticator.Authenticate(r)
 	if err != nil {
@@ -94,3 +164,95 @@ func (h *AuthHandler) handleVerify(w http.ResponseWriter, r *http.Request) {
 	reply, _ := json.Marshal(tokenValid)
 	w.Write(reply)
 }
+
+//refreshDetails is the body of refresh and logout requests
+type refreshDetails struct {
+	RefreshToken string `json:"refreshToken"`
+}
+
+func (h *AuthHandler) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	var details refreshDetails
+	err := json.NewDecoder(r.Body).Decode(&details)
+	if err != nil || details.RefreshToken == "" {
+		WriteMessage(http.StatusBadRequest, "Request body must be JSON with a refreshToken", w)
+		return
+	}
+	ok, err := h.Authenticator.RefreshAuthorization(details.RefreshToken, w)
+	if err != nil {
+		h.Logger.Println("Refresh faced an error in token creation: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Token creation failed", w)
+		return
+	}
+	if !ok {
+		WriteMessage(http.StatusUnauthorized, "Invalid Refresh Token", w)
+	}
+}
+
+//handleLogout revokes the access token of the request, and the refresh token in the body (if any)
+func (h *AuthHandler) handleLogout(w http.ResponseWriter, r *http.Request) {
+	var details refreshDetails
+	err := json.NewDecoder(r.Body).Decode(&details)
+	if err != nil && err != io.EOF { //body is optional
+		WriteMessage(http.StatusBadRequest, "Request body must be empty, or JSON with a refreshToken", w)
+		return
+	}
+	err = h.Authenticator.RevokeAuthorization(r, details.RefreshToken)
+	if err != nil {
+		h.Logger.Println("Logout faced an error revoking tokens: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error logging out", w)
+		return
+	}
+	WriteOKMessage("Logged out", w)
+}
+
+//handleLockouts sends a page of the failed login attempt counts of usernames and IP addresses
+func (h *AuthHandler) handleLockouts(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListOptions(r, checkin.SortByTime, checkin.SortByFailedAttempts)
+	if err != nil {
+		WriteMessage(http.StatusBadRequest, err.Error(), w)
+		return
+	}
+	lockouts, total, err := h.LoginAttemptService.Lockouts(opts)
+	if err != nil {
+		h.Logger.Println("Error fetching lockouts: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Could not get lockouts", w)
+		return
+	}
+	writeTotalCount(total, w)
+	reply, _ := json.Marshal(lockouts)
+	w.Write(reply)
+}
+
+//handleResetLockout forgets the failed login attempts of a username or IP address, lifting any lockout
+func (h *AuthHandler) handleResetLockout(w http.ResponseWriter, r *http.Request) {
+	kind := mux.Vars(r)["kind"]
+	if kind != checkin.LockoutByUsername && kind != checkin.LockoutByIP {
+		WriteMessage(http.StatusNotFound, "Lockouts are only by "+checkin.LockoutByUsername+" or "+checkin.LockoutByIP, w)
+		return
+	}
+	err := h.LoginAttemptService.ResetLockout(kind, mux.Vars(r)["key"])
+	if err != nil {
+		h.Logger.Println("Error resetting lockout: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Could not reset lockout", w)
+		return
+	}
+	WriteOKMessage("Lockout reset", w)
+}
+
+//handleLoginFailures sends a page of the failed attempts to log in to a username
+func (h *AuthHandler) handleLoginFailures(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListOptions(r, checkin.SortByTime)
+	if err != nil {
+		WriteMessage(http.StatusBadRequest, err.Error(), w)
+		return
+	}
+	failures, total, err := h.LoginAttemptService.LoginFailures(mux.Vars(r)["username"], opts)
+	if err != nil {
+		h.Logger.Println("Error fetching failed logins: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Could not get failed login attempts", w)
+		return
+	}
+	writeTotalCount(total, w)
+	reply, _ := json.Marshal(failures)
+	w.Write(reply)
+}
@@ -11,14 +11,18 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/guregu/null"
 )
 
 func TestHandleLogin(t *testing.T) {
 	var as mock.AuthenticationService
+	var las mock.LoginAttemptService
 	var us mock.UserService
 	var auth mock.Authenticator
 
-	h := myhttp.NewAuthHandler(&as, &auth, &us)
+	h := myhttp.NewAuthHandler(&as, &las, &auth, &us)
 	as.AuthenticateFn = func(username string, pwdPlaintext string, isAdmin bool) (bool, error) {
 		if username == "user123" && pwdPlaintext == "abcd" && !isAdmin {
 			return true, nil
@@ -37,6 +41,15 @@ func TestHandleLogin(t *testing.T) {
 		test.Assert(t, username == "user123" || username == "admin123", "Unexpected username obtained")
 		return nil
 	}
+	las.LockedUntilFn = func(username string, ipAddress string) (null.Time, error) {
+		return null.Time{}, nil
+	}
+	las.RecordFailureFn = func(failure checkin.LoginFailure) error {
+		return nil
+	}
+	las.ClearFailuresFn = func(username string) error {
+		return nil
+	}
 
 	rUser := httptest.NewRequest("POST", "/api/v0/auth/users/login", strings.NewReader(`{"username":"user123","password":"abcd"}`))
 	rAdmin := httptest.NewRequest("POST", "/api/v0/auth/admins/login", strings.NewReader(`{"username":"admin123","password":"wsxd"}`))
@@ -143,11 +156,275 @@ func TestHandleLogin(t *testing.T) {
 
 }
 
+func TestHandleLoginLockout(t *testing.T) {
+	var as mock.AuthenticationService
+	var las mock.LoginAttemptService
+	var us mock.UserService
+	var auth mock.Authenticator
+	h := myhttp.NewAuthHandler(&as, &las, &auth, &us)
+
+	as.AuthenticateFn = func(username string, pwdPlaintext string, isAdmin bool) (bool, error) {
+		return pwdPlaintext == "abcd", nil
+	}
+	auth.IssueAuthorizationFn = func(au checkin.AuthorizationInfo, w http.ResponseWriter) error {
+		return nil
+	}
+	us.UpdateLastLoggedInFn = func(username string) error {
+		return nil
+	}
+	var lockedUsername, lockedIPAddress string
+	las.LockedUntilFn = func(username string, ipAddress string) (null.Time, error) {
+		lockedUsername, lockedIPAddress = username, ipAddress
+		return null.Time{}, nil
+	}
+	var failure checkin.LoginFailure
+	las.RecordFailureFn = func(f checkin.LoginFailure) error {
+		failure = f
+		return nil
+	}
+	var clearedUsername string
+	las.ClearFailuresFn = func(username string) error {
+		clearedUsername = username
+		return nil
+	}
+
+	//test failed attempt is recorded, from the address the load balancer saw
+	r := httptest.NewRequest("POST", "/api/v0/auth/users/login", strings.NewReader(`{"username":"user123","password":"wxyz"}`))
+	r.Header.Set("X-Forwarded-For", "203.0.113.7, 198.51.100.2")
+	r.Header.Set("User-Agent", "Bot/1.0")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusUnauthorized, w.Result().StatusCode)
+	test.Equals(t, "user123", lockedUsername)
+	test.Equals(t, "198.51.100.2", lockedIPAddress)
+	test.Equals(t, checkin.LoginFailure{Username: "user123", IsAdmin: false, IPAddress: "198.51.100.2", UserAgent: "Bot/1.0"}, failure)
+	test.Equals(t, false, las.ClearFailuresInvoked)
+
+	//test successful login clears failed attempts, without recording a failure
+	las.RecordFailureInvoked = false
+	r = httptest.NewRequest("POST", "/api/v0/auth/admins/login", strings.NewReader(`{"username":"admin123","password":"abcd"}`))
+	r.RemoteAddr = "192.0.2.1:1234"
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, "192.0.2.1", lockedIPAddress)
+	test.Equals(t, "admin123", clearedUsername)
+	test.Equals(t, false, las.RecordFailureInvoked)
+
+	//test locked out logins are refused, even with the right password
+	las.LockedUntilFn = func(username string, ipAddress string) (null.Time, error) {
+		return null.TimeFrom(time.Now().Add(90 * time.Second)), nil
+	}
+	as.AuthenticateInvoked = false
+	r = httptest.NewRequest("POST", "/api/v0/auth/users/login", strings.NewReader(`{"username":"user123","password":"abcd"}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusTooManyRequests, w.Result().StatusCode)
+	test.Equals(t, "90", w.Result().Header.Get("Retry-After"))
+	test.Equals(t, false, as.AuthenticateInvoked)
+
+	//test errors checking for lockouts, recording failures and clearing them
+	las.LockedUntilFn = func(username string, ipAddress string) (null.Time, error) {
+		return null.Time{}, errors.New("An error")
+	}
+	r = httptest.NewRequest("POST", "/api/v0/auth/users/login", strings.NewReader(`{"username":"user123","password":"abcd"}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+
+	las.LockedUntilFn = func(username string, ipAddress string) (null.Time, error) {
+		return null.Time{}, nil
+	}
+	las.RecordFailureFn = func(f checkin.LoginFailure) error {
+		return errors.New("An error")
+	}
+	r = httptest.NewRequest("POST", "/api/v0/auth/users/login", strings.NewReader(`{"username":"user123","password":"wxyz"}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+
+	las.ClearFailuresFn = func(username string) error {
+		return errors.New("An error")
+	}
+	auth.IssueAuthorizationInvoked = false
+	r = httptest.NewRequest("POST", "/api/v0/auth/users/login", strings.NewReader(`{"username":"user123","password":"abcd"}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	test.Equals(t, false, auth.IssueAuthorizationInvoked)
+}
+
+func TestHandleLockouts(t *testing.T) {
+	var as mock.AuthenticationService
+	var las mock.LoginAttemptService
+	var us mock.UserService
+	var auth mock.Authenticator
+	h := myhttp.NewAuthHandler(&as, &las, &auth, &us)
+
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("admin", true, nil)
+	lockouts := []checkin.Lockout{
+		{Kind: checkin.LockoutByUsername, Key: "user123", FailedAttempts: 6,
+			LastFailedAt: time.Date(2019, 4, 1, 1, 0, 0, 0, time.UTC), LockedUntil: null.TimeFrom(time.Date(2019, 4, 1, 1, 1, 0, 0, time.UTC))},
+		{Kind: checkin.LockoutByIP, Key: "198.51.100.2", FailedAttempts: 1, LastFailedAt: time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	var receivedOpts checkin.ListOptions
+	las.LockoutsFn = func(opts checkin.ListOptions) ([]checkin.Lockout, int, error) {
+		receivedOpts = opts
+		return lockouts, 12, nil
+	}
+
+	//test normal behavior
+	r := httptest.NewRequest("GET", "/api/v1-4/auth/lockouts?sort=failedAttempts&order=desc&limit=2&search=user", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, checkin.ListOptions{SortBy: checkin.SortByFailedAttempts, Descending: true, Limit: 2, Search: "user"}, receivedOpts)
+	test.Equals(t, "12", w.Result().Header.Get(myhttp.TotalCountHeader))
+	var received []checkin.Lockout
+	err := json.NewDecoder(w.Result().Body).Decode(&received)
+	test.Ok(t, err)
+	test.Equals(t, lockouts, received)
+
+	//test invalid options and error fetching
+	r = httptest.NewRequest("GET", "/api/v1-4/auth/lockouts?sort=name", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	las.LockoutsFn = func(opts checkin.ListOptions) ([]checkin.Lockout, int, error) {
+		return nil, 0, errors.New("An error")
+	}
+	r = httptest.NewRequest("GET", "/api/v1-4/auth/lockouts", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+
+	//test non admins are denied
+	auth.GetAuthInfoFn = getAuthInfoGenerator("user123", false, nil)
+	las.LockoutsInvoked = false
+	r = httptest.NewRequest("GET", "/api/v1-4/auth/lockouts", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusForbidden, w.Result().StatusCode)
+	test.Equals(t, false, las.LockoutsInvoked)
+}
+
+func TestHandleResetLockout(t *testing.T) {
+	var as mock.AuthenticationService
+	var las mock.LoginAttemptService
+	var us mock.UserService
+	var auth mock.Authenticator
+	h := myhttp.NewAuthHandler(&as, &las, &auth, &us)
+
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("admin", true, nil)
+	var resetKind, resetKey string
+	las.ResetLockoutFn = func(kind string, key string) error {
+		resetKind, resetKey = kind, key
+		return nil
+	}
+
+	//test normal behavior
+	r := httptest.NewRequest("DELETE", "/api/v1-4/auth/lockouts/username/user123", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, checkin.LockoutByUsername, resetKind)
+	test.Equals(t, "user123", resetKey)
+
+	r = httptest.NewRequest("DELETE", "/api/v1-4/auth/lockouts/ip/198.51.100.2", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, checkin.LockoutByIP, resetKind)
+	test.Equals(t, "198.51.100.2", resetKey)
+
+	//test unknown kind of lockout
+	las.ResetLockoutInvoked = false
+	r = httptest.NewRequest("DELETE", "/api/v1-4/auth/lockouts/email/user123", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusNotFound, w.Result().StatusCode)
+	test.Equals(t, false, las.ResetLockoutInvoked)
+
+	//test error resetting
+	las.ResetLockoutFn = func(kind string, key string) error {
+		return errors.New("An error")
+	}
+	r = httptest.NewRequest("DELETE", "/api/v1-4/auth/lockouts/username/user123", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+
+	//test non admins are denied
+	auth.GetAuthInfoFn = getAuthInfoGenerator("user123", false, nil)
+	las.ResetLockoutInvoked = false
+	r = httptest.NewRequest("DELETE", "/api/v1-4/auth/lockouts/username/user123", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusForbidden, w.Result().StatusCode)
+	test.Equals(t, false, las.ResetLockoutInvoked)
+}
+
+func TestHandleLoginFailures(t *testing.T) {
+	var as mock.AuthenticationService
+	var las mock.LoginAttemptService
+	var us mock.UserService
+	var auth mock.Authenticator
+	h := myhttp.NewAuthHandler(&as, &las, &auth, &us)
+
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("admin", true, nil)
+	failures := []checkin.LoginFailure{
+		{ID: 2, Username: "user123", IPAddress: "198.51.100.2", UserAgent: "Bot/1.0", Time: time.Date(2019, 4, 1, 1, 0, 0, 0, time.UTC)},
+		{ID: 1, Username: "user123", IPAddress: "198.51.100.2", UserAgent: "Bot/1.0", Time: time.Date(2019, 4, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	var receivedUsername string
+	var receivedOpts checkin.ListOptions
+	las.LoginFailuresFn = func(username string, opts checkin.ListOptions) ([]checkin.LoginFailure, int, error) {
+		receivedUsername, receivedOpts = username, opts
+		return failures, 2, nil
+	}
+
+	//test normal behavior
+	r := httptest.NewRequest("GET", "/api/v1-4/auth/failedattempts/user123?order=desc", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, "user123", receivedUsername)
+	test.Equals(t, checkin.ListOptions{Descending: true}, receivedOpts)
+	test.Equals(t, "2", w.Result().Header.Get(myhttp.TotalCountHeader))
+	var received []checkin.LoginFailure
+	err := json.NewDecoder(w.Result().Body).Decode(&received)
+	test.Ok(t, err)
+	test.Equals(t, failures, received)
+
+	//test error fetching
+	las.LoginFailuresFn = func(username string, opts checkin.ListOptions) ([]checkin.LoginFailure, int, error) {
+		return nil, 0, errors.New("An error")
+	}
+	r = httptest.NewRequest("GET", "/api/v1-4/auth/failedattempts/user123", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+
+	//test non admins are denied
+	auth.GetAuthInfoFn = getAuthInfoGenerator("user123", false, nil)
+	las.LoginFailuresInvoked = false
+	r = httptest.NewRequest("GET", "/api/v1-4/auth/failedattempts/user123", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusForbidden, w.Result().StatusCode)
+	test.Equals(t, false, las.LoginFailuresInvoked)
+}
+
 func TestHandleVerify(t *testing.T) {
 	var auth mock.Authenticator
 	var as mock.AuthenticationService
+	var las mock.LoginAttemptService
 	var us mock.UserService
-	h := myhttp.NewAuthHandler(&as, &auth, &us)
+	h := myhttp.NewAuthHandler(&as, &las, &auth, &us)
 
 	auth.AuthenticateFn = authenticateGenerator(true, nil)
 
@@ -174,3 +451,110 @@ func TestHandleVerify(t *testing.T) {
 	h.ServeHTTP(w, r)
 	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
 }
+
+func TestHandleRefresh(t *testing.T) {
+	var auth mock.Authenticator
+	var as mock.AuthenticationService
+	var las mock.LoginAttemptService
+	var us mock.UserService
+	h := myhttp.NewAuthHandler(&as, &las, &auth, &us)
+
+	auth.RefreshAuthorizationFn = func(refreshToken string, w http.ResponseWriter) (bool, error) {
+		if refreshToken != "abcd" {
+			return false, nil
+		}
+		w.Write([]byte(`{"accessToken":"new","refreshToken":"efgh"}`))
+		return true, nil
+	}
+
+	//test normal behavior
+	r := httptest.NewRequest("POST", "/api/v1-4/auth/refresh", strings.NewReader(`{"refreshToken":"abcd"}`))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	var reply map[string]string
+	err := json.NewDecoder(w.Result().Body).Decode(&reply)
+	test.Ok(t, err)
+	test.Equals(t, map[string]string{"accessToken": "new", "refreshToken": "efgh"}, reply)
+
+	//test invalid refresh token
+	r = httptest.NewRequest("POST", "/api/v1-4/auth/refresh", strings.NewReader(`{"refreshToken":"efgh"}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusUnauthorized, w.Result().StatusCode)
+
+	//test malformed or missing refresh token
+	for _, body := range []string{`{"refreshToken":"ab`, `{}`} {
+		auth.RefreshAuthorizationInvoked = false
+		r = httptest.NewRequest("POST", "/api/v1-4/auth/refresh", strings.NewReader(body))
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+		test.Equals(t, false, auth.RefreshAuthorizationInvoked)
+	}
+
+	//test error in refreshing
+	auth.RefreshAuthorizationFn = func(refreshToken string, w http.ResponseWriter) (bool, error) {
+		return false, errors.New("An error")
+	}
+	r = httptest.NewRequest("POST", "/api/v1-4/auth/refresh", strings.NewReader(`{"refreshToken":"abcd"}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+}
+
+func TestHandleLogout(t *testing.T) {
+	var auth mock.Authenticator
+	var as mock.AuthenticationService
+	var las mock.LoginAttemptService
+	var us mock.UserService
+	h := myhttp.NewAuthHandler(&as, &las, &auth, &us)
+
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	var revokedRefreshToken string
+	auth.RevokeAuthorizationFn = func(r *http.Request, refreshToken string) error {
+		revokedRefreshToken = refreshToken
+		return nil
+	}
+
+	//test normal behavior, with and without a refresh token
+	r := httptest.NewRequest("POST", "/api/v1-4/auth/logout", strings.NewReader(`{"refreshToken":"abcd"}`))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, "abcd", revokedRefreshToken)
+
+	auth.RevokeAuthorizationInvoked = false
+	r = httptest.NewRequest("POST", "/api/v1-4/auth/logout", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, true, auth.RevokeAuthorizationInvoked)
+	test.Equals(t, "", revokedRefreshToken)
+
+	//test malformed body
+	auth.RevokeAuthorizationInvoked = false
+	r = httptest.NewRequest("POST", "/api/v1-4/auth/logout", strings.NewReader(`{"refreshToken":"ab`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+	test.Equals(t, false, auth.RevokeAuthorizationInvoked)
+
+	//test error in revoking
+	auth.RevokeAuthorizationFn = func(r *http.Request, refreshToken string) error {
+		return errors.New("An error")
+	}
+	r = httptest.NewRequest("POST", "/api/v1-4/auth/logout", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+
+	//test invalid token
+	auth.AuthenticateFn = authenticateGenerator(false, nil)
+	auth.RevokeAuthorizationInvoked = false
+	r = httptest.NewRequest("POST", "/api/v1-4/auth/logout", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusUnauthorized, w.Result().StatusCode)
+	test.Equals(t, false, auth.RevokeAuthorizationInvoked)
+}
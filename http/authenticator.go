@@ -14,6 +14,11 @@ type Authenticator interface {
 	IssueAuthorization(au checkin.AuthorizationInfo, w http.ResponseWriter) error
 	GetAuthInfo(r *http.Request) (checkin.AuthorizationInfo, error)
 	Authenticate(r *http.Request) (bool, error)
+	//RefreshAuthorization issues new authorization in exchange for a refresh token previously issued
+	//Returns false if the refresh token is invalid
+	RefreshAuthorization(refreshToken string, w http.ResponseWriter) (bool, error)
+	//RevokeAuthorization revokes the authorization of the request, as well as the given refresh token (if any)
+	RevokeAuthorization(r *http.Request, refreshToken string) error
 }
 
 //CheckAuth an adapter generator which checks if the request has valid authentication
@@ -50,11 +55,11 @@ func isAdmin(au Authenticator, logger *log.Logger) Adapter {
 	}
 }
 
-//isAdminOrHost Allows the handler to serve the request only if it is admin authorized, or
-//if the username attached to the request is the host of the event
-//Needs an authenticator, an event service, and a string indicating what mux placeholder
-//is used to store the eventID
-func isAdminOrHost(au Authenticator, es checkin.EventService, eventIDKey string, logger *log.Logger) Adapter {
+//hasEventPermission Allows the handler to serve the request only if it is admin authorized, or
+//if the username attached to the request hosts the event in a role which grants the given permission
+//Needs an authenticator, an event service, a string indicating what mux placeholder
+//is used to store the eventID, and one of the checkin.Permission constants
+func hasEventPermission(au Authenticator, es checkin.EventService, eventIDKey string, permission string, logger *log.Logger) Adapter {
 	return func(h http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authDetails, err := au.GetAuthInfo(r)
@@ -65,11 +70,11 @@ func isAdminOrHost(au Authenticator, es checkin.EventService, eventIDKey string,
 				h.ServeHTTP(w, r)
 			} else {
 				eventID := mux.Vars(r)[eventIDKey]
-				ok, err := es.CheckHost(authDetails.Username, eventID)
+				role, err := es.HostRole(authDetails.Username, eventID)
 				if err != nil {
-					logger.Println("Error in checking if user is host: " + err.Error())
+					logger.Println("Error in checking role of host: " + err.Error())
 					WriteMessage(http.StatusInternalServerError, "Error checking host", w)
-				} else if ok {
+				} else if checkin.RoleHasPermission(role, permission) {
 					h.ServeHTTP(w, r)
 				} else {
 					WriteMessage(http.StatusForbidden, "Access Denied", w)
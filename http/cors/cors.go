@@ -11,6 +11,7 @@ type PreFlightHandler struct {
 	AllowedOrigins []string `json:"allowedOrigins"`
 	AllowedMethods []string `json:"allowedMethods"`
 	AllowedHeaders []string `json:"allowedHeaders"`
+	ExposedHeaders []string `json:"exposedHeaders"`
 }
 
 //Handle Takes a http handler, and adapts it to include handling for CORS pre-flight
@@ -22,5 +23,6 @@ func (pfh PreFlightHandler) Handle(h http.Handler) http.Handler {
 		AllowedOrigins: pfh.AllowedOrigins,
 		AllowedMethods: pfh.AllowedMethods,
 		AllowedHeaders: pfh.AllowedHeaders,
+		ExposedHeaders: pfh.ExposedHeaders,
 	}).Handler(h)
 }
@@ -0,0 +1,32 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//ETagHeader is the response header which gives the entity tag of a record, to be sent back in
+//the If-Match header of requests which update it
+const ETagHeader = "ETag"
+
+//etag gives the entity tag of a record last updated at the given time, which changes whenever it is updated
+func etag(updatedAt time.Time) string {
+	return `"` + strconv.FormatInt(updatedAt.Unix(), 36) + "." + strconv.FormatInt(int64(updatedAt.Nanosecond()), 36) + `"`
+}
+
+//ifMatch checks whether the If-Match header of the request allows updating a record with the given entity tag
+//Requests without an If-Match header may update any record
+func ifMatch(r *http.Request, tag string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		if candidate = strings.TrimSpace(candidate); candidate == "*" || candidate == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -5,6 +5,7 @@ import (
 	"checkin"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"os"
@@ -14,11 +15,13 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/gorilla/mux"
+	"github.com/guregu/null"
 )
 
 //EventHandler An extension of mux.Router which handles all event-related requests
 //Uses the given EventService, the given Logger, and a given Authenticator to check if
-//requests are valid
+//requests are valid, and a UserService to check that users exist before they are made hosts
+//The TriggerService keeps the actions fired by the triggers of events
 //Also contains a GuestHandler to handle all the subset of event-related requests
 //that deal with guests
 //Call NewEventHandler to initialize an EventHandler with the correct routes
@@ -26,57 +29,126 @@ type EventHandler struct {
 	*mux.Router
 	GuestHandler     *GuestHandler
 	EventService     checkin.EventService
+	UserService      checkin.UserService
+	GuestSiteService checkin.GuestSiteService
+	TriggerService   checkin.TriggerService
 	Logger           *log.Logger
 	Authenticator    Authenticator
 	MaxLengthName    int
 	MaxLengthURL     int
 	MaxLengthTimeTag int
+	ArchiveRetention time.Duration //how long deleted events can be restored for
 }
 
 //NewEventHandler Creates a new event handler using gorilla/mux for routing
-//and the default Logger.
-//GuestHandler, EventService, Authenticator needs to be set by the calling function
+//and the default Logger. Deleted events can be restored for checkin.ArchiveRetention
+//GuestHandler, EventService, UserService, GuestSiteService, TriggerService, Authenticator needs to be set by the calling function
 //API endpoint changes happen here, as well as changes to the routing library and logger to be used
 //and type of authenticator
-func NewEventHandler(es checkin.EventService, auth Authenticator, gh *GuestHandler, maxLengthName, maxLengthURL, maxLengthTimeTag int) *EventHandler {
+func NewEventHandler(es checkin.EventService, us checkin.UserService, ss checkin.GuestSiteService, ts checkin.TriggerService,
+	auth Authenticator, gh *GuestHandler, maxLengthName, maxLengthURL, maxLengthTimeTag int) *EventHandler {
 	h := &EventHandler{
 		Router:           mux.NewRouter(),
 		Logger:           log.New(os.Stderr, "", log.LstdFlags),
 		Authenticator:    auth,
 		EventService:     es,
+		UserService:      us,
+		GuestSiteService: ss,
+		TriggerService:   ts,
 		GuestHandler:     gh,
 		MaxLengthName:    maxLengthName,
 		MaxLengthURL:     maxLengthURL,
 		MaxLengthTimeTag: maxLengthTimeTag,
+		ArchiveRetention: checkin.ArchiveRetention,
 	}
 	//Adapters to check if handler should serve the request
 	tokenCheck := checkAuth(auth, h.Logger)
-	credentialsCheck := isAdminOrHost(auth, es, "eventID", h.Logger)
+	adminCheck := isAdmin(auth, h.Logger)
+	viewEventCheck := hasEventPermission(auth, es, "eventID", checkin.PermissionViewEvent, h.Logger)
+	editEventCheck := hasEventPermission(auth, es, "eventID", checkin.PermissionEditEvent, h.Logger)
+	deleteEventCheck := hasEventPermission(auth, es, "eventID", checkin.PermissionDeleteEvent, h.Logger)
+	reportsCheck := hasEventPermission(auth, es, "eventID", checkin.PermissionViewReports, h.Logger)
+	manageHostsCheck := hasEventPermission(auth, es, "eventID", checkin.PermissionManageHosts, h.Logger)
 	existCheck := eventExists(es, "eventID", h.Logger)
+	timezonesOutput := eventTimezonesOutput(es, "eventID", h.Logger)
+	timezonesInput := eventTimezonesInput(es, "eventID", h.Logger)
 
 	h.Handle("/api/v1-3/events", Adapt(http.HandlerFunc(h.handleEventsBy),
 		tokenCheck, correctTimezonesOutput, jsonSelector)).Methods("GET")
+	h.Handle("/api/v1-4/events/all", Adapt(http.HandlerFunc(h.handleEvents),
+		tokenCheck, adminCheck, correctTimezonesOutput, jsonSelector)).Methods("GET")
+	h.Handle("/api/v1-4/events/archived", Adapt(http.HandlerFunc(h.handleArchivedEvents),
+		tokenCheck, correctTimezonesOutput, jsonSelector)).Methods("GET")
 	h.Handle("/api/v1-3/events", Adapt(http.HandlerFunc(h.handleCreateEvent),
 		tokenCheck, correctTimezonesInput)).Methods("POST")
+	h.Handle("/api/v1-4/events/templates", Adapt(http.HandlerFunc(h.handleTemplates),
+		tokenCheck, correctTimezonesOutput, jsonSelector)).Methods("GET")
+	h.Handle("/api/v1-4/events/templates/{templateID}", Adapt(http.HandlerFunc(h.handleTemplate),
+		tokenCheck, correctTimezonesOutput)).Methods("GET")
+	h.Handle("/api/v1-4/events/templates/{templateID}", Adapt(http.HandlerFunc(h.handleDeleteTemplate),
+		tokenCheck)).Methods("DELETE")
+	h.Handle("/api/v1-4/events/templates/{templateID}/events", Adapt(http.HandlerFunc(h.handleInstantiateTemplate),
+		tokenCheck, templateTimezonesInput(es, "templateID", h.Logger))).Methods("POST")
 	h.Handle("/api/v0/events/takenurls/{eventURL}", Adapt(http.HandlerFunc(h.handleURLTaken),
 		tokenCheck)).Methods("GET")
 	h.Handle("/api/v1-3/events/id/{eventURL}", http.HandlerFunc(h.handleIDByURL)).Methods("GET")
 	h.Handle("/api/v1-3/events/{eventID}", Adapt(http.HandlerFunc(h.handleEvent),
-		tokenCheck, existCheck, credentialsCheck, correctTimezonesOutput, jsonSelector)).Methods("GET")
+		tokenCheck, existCheck, viewEventCheck, timezonesOutput, jsonSelector)).Methods("GET")
 	h.Handle("/api/v1-3/events/{eventID}", Adapt(http.HandlerFunc(h.handleUpdateEvent),
-		tokenCheck, existCheck, credentialsCheck, correctTimezonesInput)).Methods("PATCH")
+		tokenCheck, existCheck, editEventCheck, timezonesInput)).Methods("PATCH")
 	h.Handle("/api/v0/events/{eventID}", Adapt(http.HandlerFunc(h.handleDeleteEvent),
-		tokenCheck, existCheck, credentialsCheck)).Methods("DELETE")
+		tokenCheck, existCheck, deleteEventCheck)).Methods("DELETE")
+	h.Handle("/api/v1-4/events/{eventID}/restore", Adapt(http.HandlerFunc(h.handleRestoreEvent),
+		tokenCheck, deleteEventCheck)).Methods("POST")
 	h.Handle("/api/v0/events/{eventID}/released", Adapt(http.HandlerFunc(h.handleReleased),
 		existCheck)).Methods("GET")
 	h.Handle("/api/v1-3/events/{eventID}/triggers/{triggername}", Adapt(http.HandlerFunc(h.handleGetTimeTag),
-		existCheck, correctTimezonesOutput)).Methods("GET")
+		existCheck, timezonesOutput)).Methods("GET")
+	h.Handle("/api/v1-4/events/{eventID}/triggers/{triggername}", Adapt(http.HandlerFunc(h.handleSetTimeTag),
+		tokenCheck, existCheck, editEventCheck, timezonesInput)).Methods("PUT")
+	h.Handle("/api/v1-4/events/{eventID}/triggers/{triggername}", Adapt(http.HandlerFunc(h.handleRemoveTimeTag),
+		tokenCheck, existCheck, editEventCheck)).Methods("DELETE")
 	h.Handle("/api/v1-3/events/{eventID}/triggers/{triggername}/occurred", Adapt(http.HandlerFunc(h.handleTimeTagOccurred),
 		existCheck)).Methods("GET")
+	h.Handle("/api/v1-4/events/{eventID}/actions", Adapt(http.HandlerFunc(h.handleActions),
+		tokenCheck, existCheck, viewEventCheck, timezonesOutput)).Methods("GET")
+	h.Handle("/api/v1-4/events/{eventID}/actions", Adapt(http.HandlerFunc(h.handleCreateAction),
+		tokenCheck, existCheck, editEventCheck)).Methods("POST")
+	h.Handle("/api/v1-4/events/{eventID}/actions/{actionID}", Adapt(http.HandlerFunc(h.handleDeleteAction),
+		tokenCheck, existCheck, editEventCheck)).Methods("DELETE")
+	h.Handle("/api/v1-4/events/{eventID}/deliveries", Adapt(http.HandlerFunc(h.handleDeliveries),
+		tokenCheck, existCheck, viewEventCheck, timezonesOutput, jsonSelector)).Methods("GET")
+	h.Handle("/api/v1-4/events/{eventID}/sessions", Adapt(http.HandlerFunc(h.handleSessions),
+		tokenCheck, existCheck, viewEventCheck, timezonesOutput)).Methods("GET")
+	h.Handle("/api/v1-4/events/{eventID}/sessions", Adapt(http.HandlerFunc(h.handleCreateSession),
+		tokenCheck, existCheck, editEventCheck, timezonesInput)).Methods("POST")
+	h.Handle("/api/v1-4/events/{eventID}/sessions/{sessionID}", Adapt(http.HandlerFunc(h.handleUpdateSession),
+		tokenCheck, existCheck, editEventCheck, timezonesInput)).Methods("PUT")
+	h.Handle("/api/v1-4/events/{eventID}/sessions/{sessionID}", Adapt(http.HandlerFunc(h.handleDeleteSession),
+		tokenCheck, existCheck, editEventCheck)).Methods("DELETE")
 	h.Handle("/api/v1-2/events/{eventID}/feedback", Adapt(http.HandlerFunc(h.handleSubmitForm),
 		existCheck)).Methods("POST")
 	h.Handle("/api/v1-2/events/{eventID}/feedback/report", Adapt(http.HandlerFunc(h.handleFeedbackReport),
-		tokenCheck, existCheck, credentialsCheck)).Methods("GET")
+		tokenCheck, existCheck, reportsCheck)).Methods("GET")
+	h.Handle("/api/v1-4/events/url/{eventURL}/site", http.HandlerFunc(h.handleGuestSiteByURL)).Methods("GET")
+	h.Handle("/api/v1-4/events/{eventID}/site", Adapt(http.HandlerFunc(h.handleGuestSite),
+		tokenCheck, existCheck, viewEventCheck)).Methods("GET")
+	h.Handle("/api/v1-4/events/{eventID}/site", Adapt(http.HandlerFunc(h.handleReplaceGuestSite),
+		tokenCheck, existCheck, editEventCheck)).Methods("PUT")
+	h.Handle("/api/v1-4/events/{eventID}/site", Adapt(http.HandlerFunc(h.handleUpdateGuestSite),
+		tokenCheck, existCheck, editEventCheck)).Methods("PATCH")
+	h.Handle("/api/v1-4/events/{eventID}/clone", Adapt(http.HandlerFunc(h.handleCloneEvent),
+		tokenCheck, existCheck, editEventCheck, timezonesInput)).Methods("POST")
+	h.Handle("/api/v1-4/events/{eventID}/template", Adapt(http.HandlerFunc(h.handleCreateTemplate),
+		tokenCheck, existCheck, viewEventCheck)).Methods("POST")
+	h.Handle("/api/v1-4/events/{eventID}/hosts", Adapt(http.HandlerFunc(h.handleHosts),
+		tokenCheck, existCheck, viewEventCheck)).Methods("GET")
+	h.Handle("/api/v1-4/events/{eventID}/hosts", Adapt(http.HandlerFunc(h.handleAddHost),
+		tokenCheck, existCheck, manageHostsCheck)).Methods("POST")
+	h.Handle("/api/v1-4/events/{eventID}/hosts/owner", Adapt(http.HandlerFunc(h.handleTransferOwnership),
+		tokenCheck, existCheck, manageHostsCheck)).Methods("PUT")
+	h.Handle("/api/v1-4/events/{eventID}/hosts/{username}", Adapt(http.HandlerFunc(h.handleRemoveHost),
+		tokenCheck, existCheck, manageHostsCheck)).Methods("DELETE")
 	//route all guest-related requests to the guest handler
 	h.PathPrefix("/api/{versionNumber}/events/{eventID}/guests").Handler(gh)
 
@@ -99,6 +171,9 @@ func (h *EventHandler) handleIDByURL(w http.ResponseWriter, r *http.Request) {
 		h.Logger.Println("Error getting event with the provided URL: " + err.Error())
 		WriteMessage(http.StatusInternalServerError, "Error getting event with the provided URL", w)
 		return
+	} else if event.ArchivedAt.Valid { //URLs of deleted events stay taken in case they are restored
+		WriteMessage(http.StatusNotFound, "No event with that URL", w)
+		return
 	}
 
 	reply, _ := json.Marshal(event.ID)
@@ -137,6 +212,63 @@ func (h *EventHandler) handleTimeTagOccurred(w http.ResponseWriter, r *http.Requ
 	}
 }
 
+//handleSetTimeTag sets the time of the trigger given by the triggername in the URL to the time in the body of the request,
+//adding the trigger if the event does not have it yet
+//Only that trigger is changed, so hosts can edit different triggers of an event at the same time
+func (h *EventHandler) handleSetTimeTag(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(mux.Vars(r)["triggername"])
+	if len(tag) > h.MaxLengthTimeTag {
+		WriteMessage(http.StatusBadRequest, "Trigger name is too long", w)
+		return
+	}
+	var val time.Time
+	dec := json.NewDecoder(r.Body)
+	err := dec.Decode(&val)
+	if err != nil || val.IsZero() { //null leaves the time at zero
+		WriteMessage(http.StatusBadRequest, "Body must be the time of the trigger, as a JSON string in RFC3339 format", w)
+		return
+	}
+	event, err := h.EventService.Event(mux.Vars(r)["eventID"])
+	if err != nil {
+		h.Logger.Println("Error fetching event details: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Could not fetch event information due to internal server issue", w)
+		return
+	}
+
+	err = h.EventService.SetTimeTag(event.ID, tag, val)
+	if err != nil {
+		h.Logger.Println("Error setting time tag: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error setting trigger", w)
+	} else if _, ok := event.TimeTags[tag]; ok {
+		WriteOKMessage("Trigger updated", w)
+	} else {
+		WriteMessage(http.StatusCreated, "Trigger added", w)
+	}
+}
+
+//handleRemoveTimeTag removes the trigger given by the triggername in the URL from the event
+func (h *EventHandler) handleRemoveTimeTag(w http.ResponseWriter, r *http.Request) {
+	event, err := h.EventService.Event(mux.Vars(r)["eventID"])
+	if err != nil {
+		h.Logger.Println("Error fetching event details: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Could not fetch event information due to internal server issue", w)
+		return
+	}
+	tag := strings.ToLower(mux.Vars(r)["triggername"])
+	if _, ok := event.TimeTags[tag]; !ok {
+		WriteMessage(http.StatusNotFound, "No such time tag found", w)
+		return
+	}
+
+	err = h.EventService.RemoveTimeTag(event.ID, tag)
+	if err != nil {
+		h.Logger.Println("Error removing time tag: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error removing trigger", w)
+	} else {
+		WriteOKMessage("Trigger removed", w)
+	}
+}
+
 //Takes a feedback form encoded in JSON, anonymous or otherwise, and writes it into the
 //permanent storage
 func (h *EventHandler) handleSubmitForm(w http.ResponseWriter, r *http.Request) {
@@ -252,12 +384,19 @@ func (h *EventHandler) handleEventsBy(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	events, err := h.EventService.EventsBy(authInfo.Username)
+	opts, err := parseListOptions(r, checkin.SortByName, checkin.SortByStart, checkin.SortByCreatedAt)
+	if err != nil {
+		WriteMessage(http.StatusBadRequest, err.Error(), w)
+		return
+	}
+
+	events, total, err := h.EventService.EventsBy(authInfo.Username, opts)
 	if err != nil {
 		h.Logger.Println("Error in GetUsersEvents: " + err.Error())
 		WriteMessage(http.StatusInternalServerError, "Error fetching user's events", w)
 		return
 	}
+	writeTotalCount(total, w)
 	reply, _ := json.Marshal(events)
 	w.Write(reply)
 }
@@ -270,23 +409,67 @@ func (h *EventHandler) handleEvent(w http.ResponseWriter, r *http.Request) {
 		h.Logger.Println("Error fetching event: " + err.Error())
 		WriteMessage(http.StatusInternalServerError, "Error fetching event", w)
 	} else {
+		w.Header().Set(ETagHeader, etag(ev.UpdatedAt))
 		reply, _ := json.Marshal(ev)
 		w.Write(reply)
 	}
 }
 
 //handleDeleteEvent deletes the event given by the eventID provided in the endpoint
+//The event is archived, so that it can be restored until it is purged after h.ArchiveRetention
 func (h *EventHandler) handleDeleteEvent(w http.ResponseWriter, r *http.Request) {
 	eventID := mux.Vars(r)["eventID"]
-	err := h.EventService.DeleteEvent(eventID)
+	err := h.EventService.ArchiveEvent(eventID)
 	if err != nil {
 		h.Logger.Println("Error deleting event: " + err.Error())
-		WriteMessage(http.StatusInternalServerError, "Error deleting user", w)
+		WriteMessage(http.StatusInternalServerError, "Error deleting event", w)
 	} else {
 		WriteOKMessage("Successfully deleted event", w)
 	}
 }
 
+//handleRestoreEvent brings back the deleted event given by the eventID in the URL,
+//as long as it was deleted within h.ArchiveRetention and has not been purged
+func (h *EventHandler) handleRestoreEvent(w http.ResponseWriter, r *http.Request) {
+	restored, err := h.EventService.RestoreEvent(mux.Vars(r)["eventID"], time.Now().Add(-h.ArchiveRetention))
+	if err != nil {
+		h.Logger.Println("Error restoring event: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error restoring event", w)
+		return
+	} else if !restored {
+		WriteMessage(http.StatusNotFound, "No deleted event which can still be restored with that ID", w)
+		return
+	}
+	WriteOKMessage("Event restored", w)
+}
+
+//handleArchivedEvents writes a page of the deleted events hosted by the user making the request,
+//which can still be restored unless they are about to be purged
+//Accepts the same list options as handleEventsBy
+func (h *EventHandler) handleArchivedEvents(w http.ResponseWriter, r *http.Request) {
+	authInfo, err := h.Authenticator.GetAuthInfo(r)
+	if err != nil {
+		h.Logger.Println("Error fetching authorization info: " + err.Error())
+		WriteMessage(http.StatusBadRequest, "Error in fetching authorization info", w)
+		return
+	}
+	opts, err := parseListOptions(r, checkin.SortByName, checkin.SortByStart, checkin.SortByCreatedAt)
+	if err != nil {
+		WriteMessage(http.StatusBadRequest, err.Error(), w)
+		return
+	}
+
+	events, total, err := h.EventService.Events(checkin.EventFilter{Host: authInfo.Username, Archived: true}, opts)
+	if err != nil {
+		h.Logger.Println("Error fetching archived events: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching user's deleted events", w)
+		return
+	}
+	writeTotalCount(total, w)
+	reply, _ := json.Marshal(events)
+	w.Write(reply)
+}
+
 //handleCreateEvent creates an event
 func (h *EventHandler) handleCreateEvent(w http.ResponseWriter, r *http.Request) {
 	var eventData checkin.Event
@@ -298,18 +481,7 @@ func (h *EventHandler) handleCreateEvent(w http.ResponseWriter, r *http.Request)
 		WriteMessage(http.StatusBadRequest, "Badly formatted JSON in event (Possibly invalid time format or invalid fields)", w)
 		return
 	}
-	if !h.validCreateEventInputs(eventData) {
-		WriteMessage(http.StatusBadRequest, "Invalid arguments to create event", w)
-		return
-	}
-
-	if exists, err := h.EventService.URLExists(eventData.URL.String); err != nil {
-		//check if the URL provided is available
-		h.Logger.Println("Error checking if URL already taken: " + err.Error())
-		WriteMessage(http.StatusInternalServerError, "Error checking if URL is available", w)
-		return
-	} else if exists {
-		WriteMessage(http.StatusConflict, "URL already used by another event", w)
+	if !h.creatable(eventData, w) {
 		return
 	}
 
@@ -339,7 +511,8 @@ func (h *EventHandler) validCreateEventInputs(event checkin.Event) bool {
 			return false
 		}
 	}
-	return !(event.URL.String == "" && event.URL.Valid) && event.Name != "" && event.UpdatedAt == time.Time{} && event.CreatedAt == time.Time{} && len(event.URL.String) <= h.MaxLengthURL && len(event.Name) <= h.MaxLengthName
+	return !(event.URL.String == "" && event.URL.Valid) && event.Name != "" && event.UpdatedAt == time.Time{} && event.CreatedAt == time.Time{} && len(event.URL.String) <= h.MaxLengthURL && len(event.Name) <= h.MaxLengthName &&
+		event.HasValidGeofence() && event.HasValidTimezone()
 }
 
 //checks that no empty string or too long strings are involved in update data
@@ -357,6 +530,8 @@ func (h *EventHandler) validUpdateEventInputs(event checkin.Event) bool {
 //handleUpdateEvent updates the event given by the eventID provided in the endpoint
 //using the fields provided in the body of the request
 //Only need to supply the fields that need updating
+//If the If-Match header is given, the event is only updated if its ETag matches, so hosts editing the event
+//at the same time do not overwrite each other's changes
 func (h *EventHandler) handleUpdateEvent(w http.ResponseWriter, r *http.Request) {
 	//Load original event, marshal JSON into it
 	//This updates only the fields that were supplied
@@ -366,6 +541,10 @@ func (h *EventHandler) handleUpdateEvent(w http.ResponseWriter, r *http.Request)
 		WriteMessage(http.StatusInternalServerError, "Could not fetch original event", w)
 		return
 	}
+	if !ifMatch(r, etag(event.UpdatedAt)) {
+		WriteMessage(http.StatusPreconditionFailed, "Event has been changed since it was fetched", w)
+		return
+	}
 
 	originalURL, originalCreatedAt, originalUpdatedAt := event.URL, event.CreatedAt, event.UpdatedAt
 
@@ -382,13 +561,19 @@ func (h *EventHandler) handleUpdateEvent(w http.ResponseWriter, r *http.Request)
 
 	//validate inputs
 	if (event.ID != mux.Vars(r)["eventID"]) || (event.UpdatedAt != originalUpdatedAt) ||
-		(event.CreatedAt != originalCreatedAt) {
+		(event.CreatedAt != originalCreatedAt) || event.ArchivedAt.Valid {
 		//if caller trying to update these non-updatable fields
 		WriteMessage(http.StatusBadRequest, "Cannot update ID or update and create times", w)
 		return
 	} else if !h.validUpdateEventInputs(event) { //otherwise check that the object you have is valid
 		WriteMessage(http.StatusBadRequest, "Cannot set name or URL or timetag label to empty string, or longer than 64 bytes", w)
 		return
+	} else if !event.HasValidGeofence() {
+		WriteMessage(http.StatusBadRequest, "Geofence must be empty (off), flag or enforce, and needs the event's lat (-90 to 90), long (-180 to 180) and radius if on", w)
+		return
+	} else if !event.HasValidTimezone() {
+		WriteMessage(http.StatusBadRequest, "Timezone must be empty (UTC) or an IANA time zone database name", w)
+		return
 	}
 
 	if event.URL != originalURL { //if the caller is attempting to update the url
@@ -402,10 +587,13 @@ func (h *EventHandler) handleUpdateEvent(w http.ResponseWriter, r *http.Request)
 		}
 	}
 
-	err = h.EventService.UpdateEvent(event)
+	//only update the event as it was read, in case it is changed while this update is made
+	updated, err := h.EventService.UpdateEvent(event, null.TimeFrom(originalUpdatedAt))
 	if err != nil {
 		h.Logger.Println("Error updating user: " + err.Error())
 		WriteMessage(http.StatusInternalServerError, "Error updating event", w)
+	} else if !updated {
+		WriteMessage(http.StatusPreconditionFailed, "Event has been changed since it was fetched", w)
 	} else {
 		WriteOKMessage("Event updated", w)
 	}
@@ -461,15 +649,83 @@ func eventReleased(es checkin.EventService, eventIDKey string, logger *log.Logge
 	}
 }
 
-//CURRENTLY UNUSED/UNTESTED (SHOULD BE FINE THOUGH)
-//handleEvents is a handler which returns all information pertaining to all events
-//func (h *EventHandler) handleEvents(w http.ResponseWriter, r *http.Request) {
-//	events, err := h.EventService.Events()
-//	if err != nil {
-//		h.Logger.Println("Error in GetAllEvents: " + err.Error())
-//		WriteMessage(http.StatusInternalServerError, "Error fetching all events", w)
-//		return
-//	}
-//	reply, _ := json.Marshal(events)
-//	w.Write(reply)
-//}
+//EventOverview is an event, along with the statistics of its guests' attendance
+type EventOverview struct {
+	checkin.Event
+	Stats checkin.GuestStats `json:"stats"`
+}
+
+//handleEvents writes a page of every event, by every user, with each event's guest statistics
+//The events can be filtered by the ?from and ?to query parameters (RFC3339 times) which give a range the
+//event must overlap, ?host (a username) and ?released (true or false)
+//Sorting, searching and pagination are controlled as in parseListOptions
+func (h *EventHandler) handleEvents(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListOptions(r, checkin.SortByName, checkin.SortByStart, checkin.SortByCreatedAt)
+	if err != nil {
+		WriteMessage(http.StatusBadRequest, err.Error(), w)
+		return
+	}
+	filter, err := parseEventFilter(r)
+	if err != nil {
+		WriteMessage(http.StatusBadRequest, err.Error(), w)
+		return
+	}
+
+	events, total, err := h.EventService.Events(filter, opts)
+	if err != nil {
+		h.Logger.Println("Error in handleEvents: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching all events", w)
+		return
+	}
+	overviews := make([]EventOverview, len(events))
+	for i, event := range events {
+		stats, err := h.GuestHandler.GuestService.CheckInStats(event.ID, "", checkin.TagFilter{})
+		if err != nil {
+			h.Logger.Println("Error fetching statistics of event " + event.ID + ": " + err.Error())
+			WriteMessage(http.StatusInternalServerError, "Error fetching statistics of events", w)
+			return
+		}
+		overviews[i] = EventOverview{Event: event, Stats: stats}
+	}
+	writeTotalCount(total, w)
+	reply, _ := json.Marshal(overviews)
+	w.Write(reply)
+}
+
+//parseEventFilter reads the filter for a listing of every event from the query parameters of a request
+//All of them are optional
+func parseEventFilter(r *http.Request) (checkin.EventFilter, error) {
+	var filter checkin.EventFilter
+	if from := r.FormValue("from"); from != "" {
+		t, err := time.Parse(time.RFC3339, from)
+		if err != nil {
+			return filter, errors.New("Form value 'from' must be an RFC3339 time")
+		}
+		filter.From = null.TimeFrom(t)
+	}
+	if to := r.FormValue("to"); to != "" {
+		t, err := time.Parse(time.RFC3339, to)
+		if err != nil {
+			return filter, errors.New("Form value 'to' must be an RFC3339 time")
+		}
+		filter.To = null.TimeFrom(t)
+	}
+	filter.Host = r.FormValue("host")
+	switch strings.ToLower(r.FormValue("released")) {
+	case "":
+	case "true":
+		filter.Released = null.BoolFrom(true)
+	case "false":
+		filter.Released = null.BoolFrom(false)
+	default:
+		return filter, errors.New("Form value 'released' must be either true or false")
+	}
+	switch strings.ToLower(r.FormValue("archived")) {
+	case "", "false":
+	case "true":
+		filter.Archived = true
+	default:
+		return filter, errors.New("Form value 'archived' must be either true or false")
+	}
+	return filter, nil
+}
@@ -35,21 +35,21 @@ func checkIfExistsGenerator(expectedID string, err error) func(string) (bool, er
 	}
 }
 
-//Generates a CheckHost mock function which will return true if the username and eventID passed in
-//both match the expectedUsername and expectedID - returns false otherwise
+//Generates a HostRole mock function which will return the given role if the username and eventID passed in
+//both match the expectedUsername and expectedID - returns an empty role otherwise
 //If err is non-nil, will always return an error (and zero values)
-func checkHostGenerator(expectedUsername string, expectedID string, err error) func(string, string) (bool, error) {
-	return func(username string, eventID string) (bool, error) {
+func hostRoleGenerator(expectedUsername string, expectedID string, role string, err error) func(string, string) (string, error) {
+	return func(username string, eventID string) (string, error) {
 		if err != nil {
-			return false, err
+			return "", err
 		}
 
 		if username != expectedUsername {
-			return false, nil
+			return "", nil
 		} else if eventID != expectedID {
-			return false, nil
+			return "", nil
 		}
-		return true, nil
+		return role, nil
 	}
 }
 
@@ -87,6 +87,17 @@ func urlExistsGenerator(expected string, err error) func(string) (bool, error) {
 	}
 }
 
+//Generates an Event mock function which returns an event with the given ID in the given timezone,
+//for endpoints which only fetch the event to find its timezone
+func eventTimezoneGenerator(expectedID string, timezone string) func(string) (checkin.Event, error) {
+	return func(ID string) (checkin.Event, error) {
+		if ID != expectedID {
+			return checkin.Event{}, errors.New("Unexpected ID " + ID)
+		}
+		return checkin.Event{ID: ID, Timezone: timezone}, nil
+	}
+}
+
 //Tests if a nonvalid token can access an endpoint (it should not be able to)
 //The request r must be made to an endpoint with said access control
 //A mock AuthenticateFn is set up to return false
@@ -108,11 +119,11 @@ func noValidTokenTest(t *testing.T, r *http.Request, h http.Handler, auth *mock.
 
 //Tests if a non-host can access an endpoint, with the expectation that they cant
 //The request r must be made to an endpoint with said access control, and a username
-//must be provided that is not recognized as the username by the CheckHost function (consider deprecating
-//in future, set CheckHost within this test as well)
+//must be provided that is not recognized as the username by the HostRole function (consider deprecating
+//in future, set HostRole within this test as well)
 //A mock GetAuthInfoFn is set to return the fake host without admin controls, and check if this results in
 //a 403 error
-//Also checks for handling of errors in GetAuthInfo (400 Bad Request) and CheckHost (500 Internal Server Error)
+//Also checks for handling of errors in GetAuthInfo (400 Bad Request) and HostRole (500 Internal Server Error)
 func nonHostAccessTest(t *testing.T, r *http.Request, h http.Handler, auth *mock.Authenticator, es *mock.EventService,
 	nonHostUsername string) {
 
@@ -127,13 +138,13 @@ func nonHostAccessTest(t *testing.T, r *http.Request, h http.Handler, auth *mock
 	test.Equals(t, http.StatusForbidden, w.Result().StatusCode)
 	auth.GetAuthInfoFn = original
 
-	//also check what happens if check host fails
-	originalCheckHost := es.CheckHostFn
-	es.CheckHostFn = checkHostGenerator("", "", errors.New("An error"))
+	//also check what happens if fetching the host role fails
+	originalHostRole := es.HostRoleFn
+	es.HostRoleFn = hostRoleGenerator("", "", "", errors.New("An error"))
 	w = httptest.NewRecorder()
 	h.ServeHTTP(w, r)
 	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
-	es.CheckHostFn = originalCheckHost
+	es.HostRoleFn = originalHostRole
 }
 
 //Tests if admins can access an endpoint
@@ -157,6 +168,31 @@ func adminAccessTest(t *testing.T, r *http.Request, h http.Handler, auth *mock.A
 	auth.GetAuthInfoFn = original
 }
 
+//Tests which roles of the host of an event can access an endpoint
+//The request r must be made to an endpoint with said access control, by the given username for the given event
+//For each role, a mock HostRoleFn is set to give the user that role - roles in allowedRoles have the
+//response checked by outputTester, and every other role is expected to get a 403 error
+func roleAccessTest(t *testing.T, r *http.Request, h http.Handler, es *mock.EventService, username string,
+	eventID string, allowedRoles []string, outputTester func(*http.Response)) {
+
+	original := es.HostRoleFn
+	for _, role := range []string{checkin.RoleOwner, checkin.RoleCoHost, checkin.RoleUsher, checkin.RoleViewer} {
+		es.HostRoleFn = hostRoleGenerator(username, eventID, role, nil)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		allowed := false
+		for _, allowedRole := range allowedRoles {
+			allowed = allowed || role == allowedRole
+		}
+		if allowed {
+			outputTester(w.Result())
+		} else {
+			test.Equals(t, http.StatusForbidden, w.Result().StatusCode)
+		}
+	}
+	es.HostRoleFn = original
+}
+
 //Tests a request that is made to an endpoint which the mock event service CheckIfExists should
 //return false (IE a non-existent endpoint). Checks that a 404 is returnd
 //Also checks for a 500 if checkIfExists returns an error
@@ -219,7 +255,7 @@ func TestHandleCreateEvent(t *testing.T) {
 	var es mock.EventService
 	var auth mock.Authenticator
 	gh := myhttp.GuestHandler{}
-	h := myhttp.NewEventHandler(&es, &auth, &gh, 64, 64, 64)
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
 
 	es.URLExistsFn = urlExistsGenerator("/hello", nil)
 	auth.AuthenticateFn = authenticateGenerator(true, nil)
@@ -293,6 +329,26 @@ func TestHandleCreateEvent(t *testing.T) {
 	h.ServeHTTP(w, r)
 	test.Equals(t, http.StatusCreated, w.Result().StatusCode)
 
+	//test times are in the timezone of the event being created without ?loc
+	expectedEvent.Timezone = "Asia/Singapore"
+	r = httptest.NewRequest("POST", "/api/v1-3/events",
+		strings.NewReader(`{"name":"MyEvent","url":"/hello2","startDateTime":"2019-03-15T08:20:00Z",
+			"endDateTime":"2019-03-15T10:00:00Z", "triggers":{"release":"2019-03-15T08:00:00Z"},
+			"lat":"1.388","long":"2","radius":"5","timezone":"Asia/Singapore"}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusCreated, w.Result().StatusCode)
+	expectedEvent.Timezone = ""
+
+	//test invalid timezones
+	for _, timezone := range []string{"Asia/Gotham", "Local"} {
+		r = httptest.NewRequest("POST", "/api/v1-3/events",
+			strings.NewReader(`{"name":"MyEvent","url":"/hello2","timezone":"`+timezone+`"}`))
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+	}
+
 	r = httptest.NewRequest("POST", "/api/v1-3/events?loc=Not/Aregion",
 		strings.NewReader(`{"name":"MyEvent","url":"/hello2","startDateTime":"2019-03-15T08:20:00Z",
 			"endDateTime":"2019-03-15T10:00:00Z", "triggers":{"release":"2019-03-15T08:00:00Z"},
@@ -398,6 +454,24 @@ func TestHandleCreateEvent(t *testing.T) {
 	h.ServeHTTP(w, r)
 	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
 	test.Assert(t, !es.CreateEventInvoked, "Create event invoked even though blank timetag")
+	r = httptest.NewRequest("POST", "/api/v1-3/events",
+		strings.NewReader(`{"name":"MyEvent","url":"something","geofence":"enforce","lat":"1.388","long":"2"}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+	test.Assert(t, !es.CreateEventInvoked, "Create event invoked even though geofence has no radius")
+	r = httptest.NewRequest("POST", "/api/v1-3/events",
+		strings.NewReader(`{"name":"MyEvent","url":"something","geofence":"always","lat":"1.388","long":"2","radius":"5"}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+	test.Assert(t, !es.CreateEventInvoked, "Create event invoked even though geofence mode does not exist")
+	r = httptest.NewRequest("POST", "/api/v1-3/events",
+		strings.NewReader(`{"name":"MyEvent","url":"something","geofence":"flag","lat":"91","long":"2","radius":"5"}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+	test.Assert(t, !es.CreateEventInvoked, "Create event invoked even though geofence centre is out of range")
 
 	//test URL already in use
 	r = httptest.NewRequest("POST", "/api/v1-3/events?loc=Asia/Singapore",
@@ -469,10 +543,10 @@ func TestHandleUpdateEvent(t *testing.T) {
 	var es mock.EventService
 	var auth mock.Authenticator
 	gh := myhttp.GuestHandler{}
-	h := myhttp.NewEventHandler(&es, &auth, &gh, 64, 64, 64)
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
 
 	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
-	es.CheckHostFn = checkHostGenerator("testing_username", "300", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleOwner, nil)
 	auth.AuthenticateFn = authenticateGenerator(true, nil)
 	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
 	srcEvent := checkin.Event{ID: "300",
@@ -495,10 +569,11 @@ func TestHandleUpdateEvent(t *testing.T) {
 	es.EventFn = eventGenerator(srcEvent, nil)
 	es.URLExistsFn = urlExistsGenerator("/knownurl", nil)
 
-	updateEventGenerator := func(expectedEvent *checkin.Event, err error) func(checkin.Event) error {
-		return func(event checkin.Event) error {
+	updateEventGenerator := func(expectedEvent *checkin.Event, err error) func(checkin.Event, null.Time) (bool, error) {
+		return func(event checkin.Event, ifUpdatedAt null.Time) (bool, error) {
 			eventEquals(t, event, *expectedEvent)
-			return err
+			test.Equals(t, null.TimeFrom(expectedEvent.UpdatedAt), ifUpdatedAt) //only update the event as it was read
+			return err == nil, err
 		}
 	}
 	expEvent := checkin.Event{
@@ -667,6 +742,18 @@ func TestHandleUpdateEvent(t *testing.T) {
 	h.ServeHTTP(w, r)
 	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
 	test.Assert(t, !es.UpdateEventInvoked, "Update event invoked even though time tag label set to blank")
+	r = httptest.NewRequest("PATCH", "/api/v1-3/events/300",
+		strings.NewReader(`{"geofence":"flag"}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+	test.Assert(t, !es.UpdateEventInvoked, "Update event invoked even though geofence set without a location")
+	r = httptest.NewRequest("PATCH", "/api/v1-3/events/300",
+		strings.NewReader(`{"timezone":"Asia/Gotham"}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+	test.Assert(t, !es.UpdateEventInvoked, "Update event invoked even though timezone does not exist")
 
 	//test URL already in use
 	r = httptest.NewRequest("PATCH", "/api/v1-3/events/300",
@@ -720,6 +807,40 @@ func TestHandleUpdateEvent(t *testing.T) {
 	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
 	es.UpdateEventFn = updateEventGenerator(&expEvent, nil)
 
+	//Test updating only if the event has not changed since it was fetched
+	r = httptest.NewRequest("GET", "/api/v1-3/events/300", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	tag := w.Result().Header.Get(myhttp.ETagHeader)
+	for _, ifMatch := range []string{tag, "*", `"other", ` + tag} {
+		r = httptest.NewRequest("PATCH", "/api/v1-3/events/300", strings.NewReader(`{"name":"MyEvent", "url":"/hello2", "startDateTime":"2019-03-15T08:20:00Z"}`))
+		r.Header.Set("If-Match", ifMatch)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	}
+	es.UpdateEventInvoked = false
+	changedEvent := srcEvent
+	changedEvent.UpdatedAt = srcEvent.UpdatedAt.Add(time.Microsecond)
+	es.EventFn = eventGenerator(changedEvent, nil)
+	r = httptest.NewRequest("PATCH", "/api/v1-3/events/300", strings.NewReader(`{"name":"MyEvent", "url":"/hello2", "startDateTime":"2019-03-15T08:20:00Z"}`))
+	r.Header.Set("If-Match", tag)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusPreconditionFailed, w.Result().StatusCode)
+	test.Assert(t, !es.UpdateEventInvoked, "Update event invoked even though event changed since it was fetched")
+	es.EventFn = eventGenerator(srcEvent, nil)
+
+	//Test event changed while being updated
+	es.UpdateEventFn = func(event checkin.Event, ifUpdatedAt null.Time) (bool, error) {
+		return false, nil
+	}
+	r = httptest.NewRequest("PATCH", "/api/v1-3/events/300", strings.NewReader(`{"name":"MyEvent"}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusPreconditionFailed, w.Result().StatusCode)
+	es.UpdateEventFn = updateEventGenerator(&expEvent, nil)
+
 	//Test usual access control
 	//Test access by another user
 	r = httptest.NewRequest("PATCH", "/api/v1-3/events/300",
@@ -750,7 +871,7 @@ func TestHandleReleased(t *testing.T) {
 	var es mock.EventService
 	var auth mock.Authenticator
 	gh := myhttp.GuestHandler{}
-	h := myhttp.NewEventHandler(&es, &auth, &gh, 64, 64, 64)
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
 
 	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
 	eventFnGenerator := func(offset time.Duration, trueID string, valid bool, err error) func(string) (checkin.Event, error) {
@@ -816,20 +937,22 @@ func TestHandleEventsBy(t *testing.T) {
 	var es mock.EventService
 	var auth mock.Authenticator
 	gh := myhttp.GuestHandler{}
-	h := myhttp.NewEventHandler(&es, &auth, &gh, 64, 64, 64)
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
 
 	auth.AuthenticateFn = authenticateGenerator(true, nil)
 	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
-	eventsByGenerator := func(err error) func(username string) ([]checkin.Event, error) {
-		return func(username string) ([]checkin.Event, error) {
+	var receivedOpts checkin.ListOptions
+	eventsByGenerator := func(err error) func(username string, opts checkin.ListOptions) ([]checkin.Event, int, error) {
+		return func(username string, opts checkin.ListOptions) ([]checkin.Event, int, error) {
 			if username != "testing_username" {
 				t.Fatal("Unexpected username: " + username + ", expected testing_username")
 			}
+			receivedOpts = opts
 
 			if err != nil {
-				return nil, err
+				return nil, 0, err
 			}
-			return []checkin.Event{checkin.Event{ID: "100"}, checkin.Event{ID: "200", Start: null.TimeFrom(time.Date(2019, 3, 1, 23, 30, 0, 0, time.UTC))}, checkin.Event{ID: "300"}}, nil
+			return []checkin.Event{checkin.Event{ID: "100"}, checkin.Event{ID: "200", Start: null.TimeFrom(time.Date(2019, 3, 1, 23, 30, 0, 0, time.UTC))}, checkin.Event{ID: "300"}}, 13, nil
 		}
 	}
 	es.EventsByFn = eventsByGenerator(nil)
@@ -843,6 +966,24 @@ func TestHandleEventsBy(t *testing.T) {
 	test.Equals(t, []checkin.Event{checkin.Event{ID: "100"},
 		checkin.Event{ID: "200", Start: null.TimeFrom(time.Date(2019, 3, 1, 23, 30, 0, 0, time.UTC))},
 		checkin.Event{ID: "300"}}, events)
+	test.Equals(t, "13", w.Result().Header.Get(myhttp.TotalCountHeader))
+	test.Equals(t, checkin.ListOptions{}, receivedOpts)
+
+	//Test pagination, sorting and search
+	r = httptest.NewRequest("GET", "/api/v1-3/events?offset=3&limit=3&sort=start&order=DESC&search=data", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, "13", w.Result().Header.Get(myhttp.TotalCountHeader))
+	test.Equals(t, checkin.ListOptions{SortBy: checkin.SortByStart, Descending: true, Search: "data", Offset: 3, Limit: 3}, receivedOpts)
+
+	//Test invalid listing options
+	for _, query := range []string{"offset=-1", "limit=-5", "limit=ten", "offset=1.5", "sort=checkInTime", "order=up"} {
+		r = httptest.NewRequest("GET", "/api/v1-3/events?"+query, nil)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+	}
 
 	//test change time zone
 	r = httptest.NewRequest("GET", "/api/v1-3/events?loc=Asia/Singapore", nil)
@@ -903,14 +1044,133 @@ func TestHandleEventsBy(t *testing.T) {
 	noValidTokenTest(t, r, h, &auth)
 }
 
+func TestHandleEvents(t *testing.T) {
+	var es mock.EventService
+	var gs mock.GuestService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{GuestService: &gs}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
+
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("admin_person", true, nil)
+	var receivedFilter checkin.EventFilter
+	var receivedOpts checkin.ListOptions
+	eventsGenerator := func(err error) func(checkin.EventFilter, checkin.ListOptions) ([]checkin.Event, int, error) {
+		return func(filter checkin.EventFilter, opts checkin.ListOptions) ([]checkin.Event, int, error) {
+			receivedFilter, receivedOpts = filter, opts
+			if err != nil {
+				return nil, 0, err
+			}
+			return []checkin.Event{{ID: "100", Name: "Parade"},
+				{ID: "200", Start: null.TimeFrom(time.Date(2019, 3, 1, 23, 30, 0, 0, time.UTC))}}, 7, nil
+		}
+	}
+	es.EventsFn = eventsGenerator(nil)
+	checkInStatsGenerator := func(err error) func(string, string, checkin.TagFilter) (checkin.GuestStats, error) {
+		return func(eventID string, sessionID string, filter checkin.TagFilter) (checkin.GuestStats, error) {
+			test.Equals(t, checkin.TagFilter{}, filter)
+			if err != nil {
+				return checkin.GuestStats{}, err
+			}
+			if eventID == "100" {
+				return checkin.GuestStats{TotalGuests: 4, CheckedIn: 1, PercentCheckedIn: 0.25}, nil
+			}
+			return checkin.GuestStats{}, nil
+		}
+	}
+	gs.CheckInStatsFn = checkInStatsGenerator(nil)
+
+	//Test normal behavior, with the statistics of each event
+	r := httptest.NewRequest("GET", "/api/v1-4/events/all", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	var overviews []myhttp.EventOverview
+	json.NewDecoder(w.Result().Body).Decode(&overviews)
+	test.Equals(t, []myhttp.EventOverview{
+		{Event: checkin.Event{ID: "100", Name: "Parade"}, Stats: checkin.GuestStats{TotalGuests: 4, CheckedIn: 1, PercentCheckedIn: 0.25}},
+		{Event: checkin.Event{ID: "200", Start: null.TimeFrom(time.Date(2019, 3, 1, 23, 30, 0, 0, time.UTC))}},
+	}, overviews)
+	test.Equals(t, "7", w.Result().Header.Get(myhttp.TotalCountHeader))
+	test.Equals(t, checkin.EventFilter{}, receivedFilter)
+	test.Equals(t, checkin.ListOptions{}, receivedOpts)
+
+	//Test the event's fields and statistics are at the same level
+	r = httptest.NewRequest("GET", "/api/v1-4/events/all?field=eventId&field=stats", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	var rawOverviews []map[string]interface{}
+	json.NewDecoder(w.Result().Body).Decode(&rawOverviews)
+	test.Equals(t, map[string]interface{}{
+		"eventId": "100",
+		"stats":   map[string]interface{}{"total": 4.0, "checkedIn": 1.0, "percentCheckedIn": 0.25},
+	}, rawOverviews[0])
+
+	//Test filters
+	r = httptest.NewRequest("GET", "/api/v1-4/events/all?from=2019-03-11T00:00:00Z&to=2019-03-17T23:59:59%2B08:00"+
+		"&host=some_guy&released=FALSE&sort=start&limit=10", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, time.Date(2019, 3, 11, 0, 0, 0, 0, time.UTC), receivedFilter.From.Time.UTC())
+	test.Equals(t, time.Date(2019, 3, 17, 15, 59, 59, 0, time.UTC), receivedFilter.To.Time.UTC())
+	test.Equals(t, "some_guy", receivedFilter.Host)
+	test.Equals(t, null.BoolFrom(false), receivedFilter.Released)
+	test.Equals(t, checkin.ListOptions{SortBy: checkin.SortByStart, Limit: 10}, receivedOpts)
+	r = httptest.NewRequest("GET", "/api/v1-4/events/all?released=true", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, null.BoolFrom(true), receivedFilter.Released)
+	test.Equals(t, false, receivedFilter.Archived)
+
+	//Test admins can list archived events
+	r = httptest.NewRequest("GET", "/api/v1-4/events/all?archived=true", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, checkin.EventFilter{Archived: true}, receivedFilter)
+
+	//Test invalid filters and listing options
+	for _, query := range []string{"from=yesterday", "to=2019-03-17", "released=maybe", "archived=maybe", "sort=checkInTime", "limit=-1"} {
+		r = httptest.NewRequest("GET", "/api/v1-4/events/all?"+query, nil)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+	}
+
+	//Test errors fetching events and statistics
+	r = httptest.NewRequest("GET", "/api/v1-4/events/all", nil)
+	es.EventsFn = eventsGenerator(errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	es.EventsFn = eventsGenerator(nil)
+	gs.CheckInStatsFn = checkInStatsGenerator(errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	gs.CheckInStatsFn = checkInStatsGenerator(nil)
+
+	//Test hosts cannot list every event
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	es.EventsInvoked = false
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusForbidden, w.Result().StatusCode)
+	test.Assert(t, !es.EventsInvoked, "Host able to list every event")
+
+	//Test no valid token
+	noValidTokenTest(t, r, h, &auth)
+}
+
 func TestHandleEvent(t *testing.T) {
 	var es mock.EventService
 	var auth mock.Authenticator
 	gh := myhttp.GuestHandler{}
-	h := myhttp.NewEventHandler(&es, &auth, &gh, 64, 64, 64)
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
 
 	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
-	es.CheckHostFn = checkHostGenerator("testing_username", "300", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleOwner, nil)
 	auth.AuthenticateFn = authenticateGenerator(true, nil)
 	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
 	eventGenerator := func(expectedID string, err error) func(string) (checkin.Event, error) {
@@ -933,6 +1193,7 @@ func TestHandleEvent(t *testing.T) {
 	var event checkin.Event
 	json.NewDecoder(w.Result().Body).Decode(&event)
 	test.Equals(t, checkin.Event{ID: "300", CreatedAt: time.Date(2018, 6, 14, 16, 30, 0, 0, time.UTC)}, event)
+	test.Assert(t, w.Result().Header.Get(myhttp.ETagHeader) != "", "No ETag given for event")
 
 	//Test ?loc query param
 	r = httptest.NewRequest("GET", "/api/v1-3/events/300?loc=Asia/Singapore", nil)
@@ -952,6 +1213,22 @@ func TestHandleEvent(t *testing.T) {
 	h.ServeHTTP(w, r)
 	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
 
+	//Test times are given in the event's own timezone without ?loc, which still overrides it
+	es.EventFn = func(ID string) (checkin.Event, error) {
+		return checkin.Event{ID: "300", Timezone: "Asia/Singapore", CreatedAt: time.Date(2018, 6, 14, 16, 30, 0, 0, time.UTC)}, nil
+	}
+	r = httptest.NewRequest("GET", "/api/v1-3/events/300", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	json.NewDecoder(w.Result().Body).Decode(&event)
+	test.Equals(t, checkin.Event{ID: "300", Timezone: "Asia/Singapore", CreatedAt: time.Date(2018, 6, 15, 0, 30, 0, 0, time.Local)}, event)
+	r = httptest.NewRequest("GET", "/api/v1-3/events/300?loc=UTC", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	json.NewDecoder(w.Result().Body).Decode(&event)
+	test.Equals(t, time.Date(2018, 6, 14, 16, 30, 0, 0, time.UTC), event.CreatedAt)
+	es.EventFn = eventGenerator("300", nil)
+
 	//test ?field query param (and case sensitivity of it)
 	r = httptest.NewRequest("GET", "/api/v1-3/events/300?field=createdat", nil)
 	w = httptest.NewRecorder()
@@ -1009,40 +1286,47 @@ func TestHandleDeleteEvent(t *testing.T) {
 	var es mock.EventService
 	var auth mock.Authenticator
 	gh := myhttp.GuestHandler{}
-	h := myhttp.NewEventHandler(&es, &auth, &gh, 64, 64, 64)
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
 
 	es.CheckIfExistsFn = checkIfExistsGenerator("200", nil)
-	es.CheckHostFn = checkHostGenerator("some_guy", "200", nil)
+	es.HostRoleFn = hostRoleGenerator("some_guy", "200", checkin.RoleOwner, nil)
 	auth.AuthenticateFn = authenticateGenerator(true, nil)
 	auth.GetAuthInfoFn = getAuthInfoGenerator("some_guy", false, nil)
-	deleteEventGenerator := func(expectedID string, err error) func(string) error {
+	archiveEventGenerator := func(expectedID string, err error) func(string) error {
 		return func(ID string) error {
 			if ID != expectedID {
-				t.Fatal("Unexpected ID: " + ID + ", expected 300")
+				t.Fatal("Unexpected ID: " + ID + ", expected " + expectedID)
 			}
 			return err
 		}
 	}
-	es.DeleteEventFn = deleteEventGenerator("200", nil)
+	es.ArchiveEventFn = archiveEventGenerator("200", nil)
 
-	//Test normal behavior
+	//Test normal behavior, where the event is archived rather than deleted for good
 	r := httptest.NewRequest("DELETE", "/api/v0/events/200", nil)
 	w := httptest.NewRecorder()
 	h.ServeHTTP(w, r)
 	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, true, es.ArchiveEventInvoked)
+	test.Equals(t, false, es.DeleteEventInvoked)
 
 	//Test error in event deletion
-	es.DeleteEventFn = deleteEventGenerator("200", errors.New("An error"))
+	es.ArchiveEventFn = archiveEventGenerator("200", errors.New("An error"))
 	w = httptest.NewRecorder()
 	h.ServeHTTP(w, r)
 	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
-	es.DeleteEventFn = deleteEventGenerator("200", nil)
+	es.ArchiveEventFn = archiveEventGenerator("200", nil)
 
 	//Test access restrictions
 
 	//Test access by another user
 	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
 
+	//Test only owners can delete the event
+	roleAccessTest(t, r, h, &es, "some_guy", "200", []string{checkin.RoleOwner}, func(r *http.Response) {
+		test.Equals(t, http.StatusOK, r.StatusCode)
+	})
+
 	//Test access by admin
 	adminAccessTest(t, r, h, &auth, func(r *http.Response) {
 		test.Equals(t, http.StatusOK, r.StatusCode)
@@ -1057,11 +1341,110 @@ func TestHandleDeleteEvent(t *testing.T) {
 
 }
 
+func TestHandleRestoreEvent(t *testing.T) {
+	var es mock.EventService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
+	h.ArchiveRetention = time.Hour
+
+	es.HostRoleFn = hostRoleGenerator("some_guy", "200", checkin.RoleOwner, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("some_guy", false, nil)
+	restoreEventGenerator := func(restored bool, err error) func(string, time.Time) (bool, error) {
+		return func(ID string, archivedSince time.Time) (bool, error) {
+			test.Equals(t, "200", ID)
+			test.Assert(t, time.Since(archivedSince) >= time.Hour && time.Since(archivedSince) < time.Hour+time.Minute,
+				"Events not restorable for the retention period")
+			return restored, err
+		}
+	}
+	es.RestoreEventFn = restoreEventGenerator(true, nil)
+
+	//Test normal behavior, without checking the event exists as archived events do not
+	r := httptest.NewRequest("POST", "/api/v1-4/events/200/restore", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, true, es.RestoreEventInvoked)
+	test.Equals(t, false, es.CheckIfExistsInvoked)
+
+	//Test events which are not archived, or were archived too long ago
+	es.RestoreEventFn = restoreEventGenerator(false, nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusNotFound, w.Result().StatusCode)
+
+	//Test error restoring event
+	es.RestoreEventFn = restoreEventGenerator(false, errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	es.RestoreEventFn = restoreEventGenerator(true, nil)
+
+	//Test access restrictions
+
+	//Test access by another user
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+
+	//Test only owners can restore the event
+	roleAccessTest(t, r, h, &es, "some_guy", "200", []string{checkin.RoleOwner}, func(r *http.Response) {
+		test.Equals(t, http.StatusOK, r.StatusCode)
+	})
+
+	//Test access by admin
+	adminAccessTest(t, r, h, &auth, func(r *http.Response) {
+		test.Equals(t, http.StatusOK, r.StatusCode)
+	})
+
+	//Test invalid token
+	noValidTokenTest(t, r, h, &auth)
+}
+
+func TestHandleArchivedEvents(t *testing.T) {
+	var es mock.EventService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
+
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("some_guy", false, nil)
+	archived := []checkin.Event{{ID: "200", Name: "Old event", ArchivedAt: null.TimeFrom(time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC))}}
+	eventsGenerator := func(err error) func(checkin.EventFilter, checkin.ListOptions) ([]checkin.Event, int, error) {
+		return func(filter checkin.EventFilter, opts checkin.ListOptions) ([]checkin.Event, int, error) {
+			test.Equals(t, checkin.EventFilter{Host: "some_guy", Archived: true}, filter)
+			test.Equals(t, checkin.SortByStart, opts.SortBy)
+			return archived, 1, err
+		}
+	}
+	es.EventsFn = eventsGenerator(nil)
+
+	//Test normal behavior, listing only the user's archived events
+	r := httptest.NewRequest("GET", "/api/v1-4/events/archived?sort=start", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, "1", w.Result().Header.Get(myhttp.TotalCountHeader))
+	var events []checkin.Event
+	test.Ok(t, json.NewDecoder(w.Result().Body).Decode(&events))
+	test.Equals(t, 1, len(events))
+	test.Equals(t, true, events[0].ArchivedAt.Valid)
+
+	//Test error fetching events
+	es.EventsFn = eventsGenerator(errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+
+	//Test invalid token
+	noValidTokenTest(t, r, h, &auth)
+}
+
 func TestHandleURLTaken(t *testing.T) {
 	var es mock.EventService
 	var auth mock.Authenticator
 	gh := myhttp.GuestHandler{}
-	h := myhttp.NewEventHandler(&es, &auth, &gh, 64, 64, 64)
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
 
 	auth.AuthenticateFn = authenticateGenerator(true, nil)
 	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
@@ -1099,7 +1482,7 @@ func TestHandleIDByURL(t *testing.T) {
 	var es mock.EventService
 	var auth mock.Authenticator
 	gh := myhttp.GuestHandler{}
-	h := myhttp.NewEventHandler(&es, &auth, &gh, 64, 64, 64)
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
 
 	eventByURLFnGenerator := func(err error, urlToID *map[string]checkin.Event) func(string) (checkin.Event, error) {
 		return func(url string) (checkin.Event, error) {
@@ -1149,6 +1532,13 @@ func TestHandleIDByURL(t *testing.T) {
 	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
 	es.URLExistsFn = urlExistsGenerator("testurl", nil)
 
+	//test archived events cannot be found by guests, even though their URL is still taken
+	urlToID["testurl"] = checkin.Event{ID: "100", ArchivedAt: null.TimeFrom(time.Now())}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusNotFound, w.Result().StatusCode)
+	urlToID["testurl"] = checkin.Event{ID: "100"}
+
 	//test error fetching event ID
 	es.EventByURLFn = eventByURLFnGenerator(errors.New("An error"), &urlToID)
 	r = httptest.NewRequest("GET", "/api/v1-3/events/id/testurl", nil)
@@ -1161,7 +1551,7 @@ func TestSubmitForm(t *testing.T) {
 	var es mock.EventService
 	var auth mock.Authenticator
 	gh := myhttp.GuestHandler{}
-	h := myhttp.NewEventHandler(&es, &auth, &gh, 64, 64, 64)
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
 
 	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
 	submitFeedbackFnGenerator := func(err error, expected *checkin.FeedbackForm) func(string, checkin.FeedbackForm) error {
@@ -1289,10 +1679,10 @@ func TestHandleFeedbackReport(t *testing.T) {
 	var es mock.EventService
 	var auth mock.Authenticator
 	gh := myhttp.GuestHandler{}
-	h := myhttp.NewEventHandler(&es, &auth, &gh, 64, 64, 64)
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
 
 	es.CheckIfExistsFn = checkIfExistsGenerator("100", nil)
-	es.CheckHostFn = checkHostGenerator("testing_username", "100", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "100", checkin.RoleOwner, nil)
 	auth.AuthenticateFn = authenticateGenerator(true, nil)
 	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
 	ff := []checkin.FeedbackForm{
@@ -1402,7 +1792,7 @@ func TestHandleGetTimeTag(t *testing.T) {
 	var es mock.EventService
 	var auth mock.Authenticator
 	gh := myhttp.GuestHandler{}
-	h := myhttp.NewEventHandler(&es, &auth, &gh, 64, 64, 64)
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
 
 	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
 	eventGenerator := func(err error) func(string) (checkin.Event, error) {
@@ -1476,11 +1866,161 @@ func TestHandleGetTimeTag(t *testing.T) {
 
 }
 
+func TestHandleSetTimeTag(t *testing.T) {
+	var es mock.EventService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 10)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleCoHost, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	es.EventFn = func(ID string) (checkin.Event, error) {
+		return checkin.Event{ID: "300", TimeTags: map[string]time.Time{"release": time.Date(2020, 2, 29, 8, 4, 10, 0, time.UTC)}}, nil
+	}
+	var setTag string
+	var setTime time.Time
+	setTimeTagGenerator := func(err error) func(string, string, time.Time) error {
+		return func(eventID string, tag string, val time.Time) error {
+			test.Equals(t, "300", eventID)
+			setTag, setTime = tag, val
+			return err
+		}
+	}
+	es.SetTimeTagFn = setTimeTagGenerator(nil)
+	setTimeTag := func(url string, body string) *http.Response {
+		r := httptest.NewRequest("PUT", url, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	//test changing an existing trigger, case insensitive
+	res := setTimeTag("/api/v1-4/events/300/triggers/ReLease", `"2020-03-01T09:00:00Z"`)
+	test.Equals(t, http.StatusOK, res.StatusCode)
+	test.Equals(t, "release", setTag)
+	test.Equals(t, time.Date(2020, 3, 1, 9, 0, 0, 0, time.UTC), setTime.UTC())
+
+	//test adding a trigger, in the timezone given by ?loc
+	res = setTimeTag("/api/v1-4/events/300/triggers/doorsopen?loc=Asia/Singapore", `"2020-03-01T17:00:00Z"`)
+	test.Equals(t, http.StatusCreated, res.StatusCode)
+	test.Equals(t, "doorsopen", setTag)
+	test.Equals(t, time.Date(2020, 3, 1, 9, 0, 0, 0, time.UTC), setTime.UTC())
+
+	//test triggers are in the event's own timezone without ?loc, which still overrides it
+	es.EventFn = func(ID string) (checkin.Event, error) {
+		return checkin.Event{ID: "300", Timezone: "America/New_York"}, nil
+	}
+	res = setTimeTag("/api/v1-4/events/300/triggers/doorsopen", `"2020-03-01T04:00:00Z"`)
+	test.Equals(t, http.StatusCreated, res.StatusCode)
+	test.Equals(t, time.Date(2020, 3, 1, 9, 0, 0, 0, time.UTC), setTime.UTC())
+	res = setTimeTag("/api/v1-4/events/300/triggers/doorsopen?loc=UTC", `"2020-03-01T09:00:00Z"`)
+	test.Equals(t, time.Date(2020, 3, 1, 9, 0, 0, 0, time.UTC), setTime.UTC())
+	es.EventFn = func(ID string) (checkin.Event, error) {
+		return checkin.Event{ID: "300", TimeTags: map[string]time.Time{"release": time.Date(2020, 2, 29, 8, 4, 10, 0, time.UTC)}}, nil
+	}
+
+	//test invalid times and names
+	es.SetTimeTagInvoked = false
+	for _, body := range []string{`"tomorrow"`, `null`, `{"release":"2020-03-01T09:00:00Z"}`, ``} {
+		res = setTimeTag("/api/v1-4/events/300/triggers/release", body)
+		test.Equals(t, http.StatusBadRequest, res.StatusCode)
+	}
+	res = setTimeTag("/api/v1-4/events/300/triggers/registrationstart", `"2020-03-01T09:00:00Z"`)
+	test.Equals(t, http.StatusBadRequest, res.StatusCode)
+	test.Assert(t, !es.SetTimeTagInvoked, "Trigger set with invalid time or name")
+
+	//test error setting trigger
+	es.SetTimeTagFn = setTimeTagGenerator(errors.New("An error"))
+	res = setTimeTag("/api/v1-4/events/300/triggers/release", `"2020-03-01T09:00:00Z"`)
+	test.Equals(t, http.StatusInternalServerError, res.StatusCode)
+	es.SetTimeTagFn = setTimeTagGenerator(nil)
+
+	//access restriction tests
+	r := httptest.NewRequest("PUT", "/api/v1-4/events/300/triggers/release", strings.NewReader(`"2020-03-01T09:00:00Z"`))
+	roleAccessTest(t, r, h, &es, "testing_username", "300", []string{checkin.RoleOwner, checkin.RoleCoHost},
+		func(r *http.Response) {
+			test.Assert(t, r.StatusCode != http.StatusForbidden, "Host forbidden from setting trigger")
+		})
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+	r = httptest.NewRequest("PUT", "/api/v1-4/events/100/triggers/release", strings.NewReader(`"2020-03-01T09:00:00Z"`))
+	eventDoesNotExistTest(t, r, h, &es)
+}
+
+func TestHandleRemoveTimeTag(t *testing.T) {
+	var es mock.EventService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleOwner, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	eventGenerator := func(err error) func(string) (checkin.Event, error) {
+		return func(ID string) (checkin.Event, error) {
+			return checkin.Event{ID: "300", TimeTags: map[string]time.Time{"release": time.Date(2020, 2, 29, 8, 4, 10, 0, time.UTC)}}, err
+		}
+	}
+	es.EventFn = eventGenerator(nil)
+	removeTimeTagGenerator := func(err error) func(string, string) error {
+		return func(eventID string, tag string) error {
+			test.Equals(t, "300", eventID)
+			test.Equals(t, "release", tag)
+			return err
+		}
+	}
+	es.RemoveTimeTagFn = removeTimeTagGenerator(nil)
+
+	//test normal functionality, case insensitive
+	r := httptest.NewRequest("DELETE", "/api/v1-4/events/300/triggers/RELEASE", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Assert(t, es.RemoveTimeTagInvoked, "Trigger not removed")
+
+	//test trigger does not exist
+	es.RemoveTimeTagInvoked = false
+	r = httptest.NewRequest("DELETE", "/api/v1-4/events/300/triggers/formrelease", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusNotFound, w.Result().StatusCode)
+	test.Assert(t, !es.RemoveTimeTagInvoked, "Trigger which does not exist removed")
+
+	//test errors fetching event and removing trigger
+	r = httptest.NewRequest("DELETE", "/api/v1-4/events/300/triggers/release", nil)
+	es.EventFn = eventGenerator(errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	es.EventFn = eventGenerator(nil)
+	es.RemoveTimeTagFn = removeTimeTagGenerator(errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	es.RemoveTimeTagFn = removeTimeTagGenerator(nil)
+
+	//access restriction tests
+	roleAccessTest(t, r, h, &es, "testing_username", "300", []string{checkin.RoleOwner, checkin.RoleCoHost},
+		func(r *http.Response) {
+			test.Equals(t, http.StatusOK, r.StatusCode)
+		})
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	adminAccessTest(t, r, h, &auth, func(r *http.Response) {
+		test.Equals(t, http.StatusOK, r.StatusCode)
+	})
+	noValidTokenTest(t, r, h, &auth)
+	r = httptest.NewRequest("DELETE", "/api/v1-4/events/100/triggers/release", nil)
+	eventDoesNotExistTest(t, r, h, &es)
+}
+
 func TestHandleTimeTagOccurred(t *testing.T) {
 	var es mock.EventService
 	var auth mock.Authenticator
 	gh := myhttp.GuestHandler{}
-	h := myhttp.NewEventHandler(&es, &auth, &gh, 64, 64, 64)
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
 
 	es.CheckIfExistsFn = checkIfExistsGenerator("100", nil)
 	eventGenerator := func(err error) func(string) (checkin.Event, error) {
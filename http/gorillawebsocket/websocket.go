@@ -4,6 +4,7 @@ import (
 	myhttp "checkin/http"
 	"errors"
 	"net/http"
+	"strings"
 	"sync"
 
 	"github.com/gorilla/websocket"
@@ -69,30 +70,54 @@ func (gm *GuestMessenger) Send(guestID string, data myhttp.GuestMessage) error {
 	gm.lock.RLock()
 	defer gm.lock.RUnlock()
 	if clients, ok := gm.connections[guestID]; ok {
-		response := make(chan error)
-		errorList := make([]error, len(clients))
-		anyerr := false
-		for i, client := range clients {
-			client.send <- SendTask{message: data, response: response}
-			errorList[i] = <-response
-			if errorList[i] != nil {
-				anyerr = true
-			}
+		return sendToClients(clients, data)
+	}
+	return errors.New("No such guest ID")
+}
+
+//SendAll sends the provided guest data over the websocket connections of every guest ID with the given prefix
+func (gm *GuestMessenger) SendAll(prefix string, data myhttp.GuestMessage) error {
+	gm.lock.RLock()
+	defer gm.lock.RUnlock()
+	errString := ""
+	for guestID, clients := range gm.connections {
+		if !strings.HasPrefix(guestID, prefix) {
+			continue
+		}
+		if err := sendToClients(clients, data); err != nil {
+			errString += err.Error()
+		}
+	}
+	if errString != "" {
+		return errors.New(errString)
+	}
+	return nil
+}
+
+//sendToClients sends the guest data over each of the connections in turn
+func sendToClients(clients []*GuestConnection, data myhttp.GuestMessage) error {
+	response := make(chan error)
+	errorList := make([]error, len(clients))
+	anyerr := false
+	for i, client := range clients {
+		client.send <- SendTask{message: data, response: response}
+		errorList[i] = <-response
+		if errorList[i] != nil {
+			anyerr = true
 		}
+	}
 
-		if anyerr {
-			errString := "Error(s) occured when sending message: "
-			for _, err := range errorList {
-				if err != nil {
-					errString += err.Error() + "\n"
-				}
+	if anyerr {
+		errString := "Error(s) occured when sending message: "
+		for _, err := range errorList {
+			if err != nil {
+				errString += err.Error() + "\n"
 			}
-			return errors.New(errString)
 		}
-
-		return nil
+		return errors.New(errString)
 	}
-	return errors.New("No such guest ID")
+
+	return nil
 }
 
 //HasConnection returns true if there is at least one active connection with the given guest ID
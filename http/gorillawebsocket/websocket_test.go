@@ -144,3 +144,47 @@ func TestSendMultipleConnections(t *testing.T) {
 	gm.Send("2234", myhttp.GuestMessage{Title: "Check in", Content: checkin.Guest{NRIC: "2234", Name: "Jimothy Bob"}})
 	wg.Wait()
 }
+
+func TestSendAll(t *testing.T) {
+	gm := mywebsocket.NewGuestMessenger(2048, 2048)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := gm.OpenConnection(r.Header.Get("GuestID"), w, r)
+		test.Ok(t, err)
+	}))
+	defer s.Close()
+
+	url := "ws" + strings.TrimPrefix(s.URL, "http")
+	var conns []*websocket.Conn
+	for _, guestID := range []string{"300 1234", "300 2234", "400 1234"} {
+		header := make(http.Header)
+		header.Add("GuestID", guestID)
+		ws, _, err := websocket.DefaultDialer.Dial(url, header)
+		test.Ok(t, err)
+		defer ws.Close()
+		conns = append(conns, ws)
+	}
+	for i := 0; i < 100 && !(gm.HasConnection("300 1234") && gm.HasConnection("300 2234") && gm.HasConnection("400 1234")); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	//test only the guests with the prefix get the message
+	err := gm.SendAll("300 ", myhttp.GuestMessage{Title: "trigger/release", Content: "Welcome!"})
+	test.Ok(t, err)
+	var msg struct {
+		Title   string `json:"title"`
+		Content string `json:"content"`
+	}
+	for _, ws := range conns[:2] {
+		err = ws.ReadJSON(&msg)
+		test.Ok(t, err)
+		test.Equals(t, "trigger/release", msg.Title)
+		test.Equals(t, "Welcome!", msg.Content)
+	}
+	conns[2].SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	err = conns[2].ReadJSON(&msg)
+	test.Assert(t, err != nil, "Guest without the prefix was sent the message")
+
+	//test no guests with the prefix
+	err = gm.SendAll("500 ", myhttp.GuestMessage{Title: "trigger/release"})
+	test.Ok(t, err)
+}
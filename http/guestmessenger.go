@@ -14,6 +14,10 @@ type GuestMessenger interface {
 	//Sends a message to the given guest
 	Send(guestID string, msg GuestMessage) error
 
+	//SendAll sends a message to every connected guest whose guest ID starts with the given prefix
+	//Not having any such guests is not an error
+	SendAll(prefix string, msg GuestMessage) error
+
 	//HasConnection checks if there is a connection with the given guestID
 	HasConnection(guestID string) bool
 
@@ -5,12 +5,15 @@ import (
 	"checkin"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/guregu/null"
 )
 
 //GuestHandler is a sub-handler of the EventHandler, which handles all requests pertaining to
@@ -18,60 +21,101 @@ import (
 // and an Authenticator to grant access
 type GuestHandler struct {
 	*mux.Router
-	GuestService   checkin.GuestService
-	EventService   checkin.EventService
-	GuestMessenger GuestMessenger
-	Logger         *log.Logger
-	Authenticator  Authenticator
-	MaxLengthName  int
-	MaxLengthTag   int
+	GuestService         checkin.GuestService
+	AttendanceLogService checkin.AttendanceLogService
+	EventService         checkin.EventService
+	GuestMessenger       GuestMessenger
+	HostMessenger        HostMessenger
+	Logger               *log.Logger
+	Authenticator        Authenticator
+	MaxLengthName        int
+	MaxLengthTag         int
 }
 
 //NewGuestHandler creates a new GuestHandler, using the default logger, with the
 //pre-defined routing
-func NewGuestHandler(gs checkin.GuestService, es checkin.EventService, gm GuestMessenger,
-	auth Authenticator, maxLengthName int, maxLengthTag int) *GuestHandler {
+func NewGuestHandler(gs checkin.GuestService, als checkin.AttendanceLogService, es checkin.EventService, gm GuestMessenger,
+	hm HostMessenger, auth Authenticator, maxLengthName int, maxLengthTag int) *GuestHandler {
 	h := &GuestHandler{
-		Router:         mux.NewRouter(),
-		Logger:         log.New(os.Stderr, "", log.LstdFlags),
-		GuestService:   gs,
-		EventService:   es,
-		GuestMessenger: gm,
-		Authenticator:  auth,
-		MaxLengthName:  maxLengthName,
-		MaxLengthTag:   maxLengthTag,
+		Router:               mux.NewRouter(),
+		Logger:               log.New(os.Stderr, "", log.LstdFlags),
+		GuestService:         gs,
+		AttendanceLogService: als,
+		EventService:         es,
+		GuestMessenger:       gm,
+		HostMessenger:        hm,
+		Authenticator:        auth,
+		MaxLengthName:        maxLengthName,
+		MaxLengthTag:         maxLengthTag,
 	}
 
 	//Adapters to check if handler should serve the request
 	tokenCheck := checkAuth(auth, h.Logger)
-	credentialsCheck := isAdminOrHost(auth, es, "eventID", h.Logger)
+	viewGuestsCheck := hasEventPermission(auth, es, "eventID", checkin.PermissionViewGuests, h.Logger)
+	manageGuestsCheck := hasEventPermission(auth, es, "eventID", checkin.PermissionManageGuests, h.Logger)
+	checkInCheck := hasEventPermission(auth, es, "eventID", checkin.PermissionCheckIn, h.Logger)
+	statsCheck := hasEventPermission(auth, es, "eventID", checkin.PermissionViewStats, h.Logger)
+	reportsCheck := hasEventPermission(auth, es, "eventID", checkin.PermissionViewReports, h.Logger)
 	existCheck := eventExists(es, "eventID", h.Logger)
+	timezonesOutput := eventTimezonesOutput(es, "eventID", h.Logger)
 	releaseCheck := eventReleased(es, "eventID", h.Logger)
 
 	h.Handle("/api/v0/events/{eventID}/guests", Adapt(http.HandlerFunc(h.handleGuests),
-		tokenCheck, existCheck, credentialsCheck)).Methods("GET")
+		tokenCheck, existCheck, viewGuestsCheck)).Methods("GET")
+	h.Handle("/api/v1-4/events/{eventID}/guests", Adapt(http.HandlerFunc(h.handleGuestRecords),
+		tokenCheck, existCheck, viewGuestsCheck, timezonesOutput, jsonSelector)).Methods("GET")
 	h.Handle("/api/v0/events/{eventID}/guests", Adapt(http.HandlerFunc(h.handleRegisterGuest),
-		tokenCheck, existCheck, credentialsCheck)).Methods("POST")
+		tokenCheck, existCheck, manageGuestsCheck)).Methods("POST")
 	h.Handle("/api/v1-3/events/{eventID}/guests", Adapt(http.HandlerFunc(h.handleRegisterGuests),
-		tokenCheck, existCheck, credentialsCheck)).Methods("POST")
+		tokenCheck, existCheck, manageGuestsCheck)).Methods("POST")
+	h.Handle("/api/v1-4/events/{eventID}/guests/sync", Adapt(http.HandlerFunc(h.handleSyncGuests),
+		tokenCheck, existCheck, manageGuestsCheck)).Methods("POST")
 	h.Handle("/api/v0/events/{eventID}/guests", Adapt(http.HandlerFunc(h.handleRemoveGuest),
-		tokenCheck, existCheck, credentialsCheck)).Methods("DELETE")
+		tokenCheck, existCheck, manageGuestsCheck)).Methods("DELETE")
 	h.Handle("/api/v1-3/events/{eventID}/guests/tags", Adapt(http.HandlerFunc(h.handleTags),
-		tokenCheck, existCheck, credentialsCheck)).Methods("GET")
+		tokenCheck, existCheck, viewGuestsCheck)).Methods("GET")
+	h.Handle("/api/v1-4/events/{eventID}/guests/tags/{tag}", Adapt(http.HandlerFunc(h.handleAddTag),
+		tokenCheck, existCheck, manageGuestsCheck)).Methods("POST")
+	h.Handle("/api/v1-4/events/{eventID}/guests/tags/{tag}", Adapt(http.HandlerFunc(h.handleRemoveTag),
+		tokenCheck, existCheck, manageGuestsCheck)).Methods("DELETE")
+	h.Handle("/api/v1-4/events/{eventID}/guests/tags/{tag}/rename", Adapt(http.HandlerFunc(h.handleRenameTag),
+		tokenCheck, existCheck, manageGuestsCheck)).Methods("POST")
 	h.Handle("/api/v0/events/{eventID}/guests/checkedin", Adapt(http.HandlerFunc(h.handleGuestsCheckedIn),
-		tokenCheck, existCheck, credentialsCheck)).Methods("GET")
+		tokenCheck, existCheck, viewGuestsCheck)).Methods("GET")
 	h.Handle("/api/v0/events/{eventID}/guests/checkedin", Adapt(http.HandlerFunc(h.handleCheckInGuest),
 		existCheck, releaseCheck)).Methods("POST")
 	h.Handle("/api/v0/events/{eventID}/guests/checkedin", Adapt(http.HandlerFunc(h.handleMarkGuestAbsent),
-		tokenCheck, existCheck, credentialsCheck)).Methods("DELETE")
+		tokenCheck, existCheck, checkInCheck)).Methods("DELETE")
 	h.Handle("/api/v1-2/events/{eventID}/guests/checkedin/listener/{nric}",
 		Adapt(http.HandlerFunc(h.handleCreateCheckInListener), existCheck))
 	h.Handle("/api/v0/events/{eventID}/guests/notcheckedin", Adapt(http.HandlerFunc(h.handleGuestsNotCheckedIn),
-		tokenCheck, existCheck, credentialsCheck)).Methods("GET")
+		tokenCheck, existCheck, viewGuestsCheck)).Methods("GET")
+	h.Handle("/api/v1-4/events/{eventID}/guests/stream", Adapt(http.HandlerFunc(h.handleOpenHostStream),
+		tokenCheck, existCheck, statsCheck)).Methods("GET")
+	h.Handle("/api/v1-4/events/{eventID}/guests/log", Adapt(http.HandlerFunc(h.handleAttendanceLog),
+		tokenCheck, existCheck, viewGuestsCheck, timezonesOutput, jsonSelector)).Methods("GET")
+	h.Handle("/api/v1-4/events/{eventID}/guests/flagged", Adapt(http.HandlerFunc(h.handleCheckInFlags),
+		tokenCheck, existCheck, viewGuestsCheck, timezonesOutput, jsonSelector)).Methods("GET")
+	h.Handle("/api/v1-4/events/{eventID}/guests/stats/tags", Adapt(http.HandlerFunc(h.handleTagStats),
+		tokenCheck, existCheck, statsCheck)).Methods("GET")
+	h.Handle("/api/v1-4/events/{eventID}/guests/stats/tags/cross", Adapt(http.HandlerFunc(h.handleTagCrossStats),
+		tokenCheck, existCheck, statsCheck)).Methods("GET")
+	h.Handle("/api/v1-4/events/{eventID}/guests/{nric}/tags", Adapt(http.HandlerFunc(h.handleGuestTags),
+		tokenCheck, existCheck, viewGuestsCheck)).Methods("GET")
+	h.Handle("/api/v1-4/events/{eventID}/guests/{nric}/tags", Adapt(http.HandlerFunc(h.handleSetGuestTags),
+		tokenCheck, existCheck, manageGuestsCheck)).Methods("PUT")
 	h.Handle("/api/v0/events/{eventID}/guests/stats", Adapt(http.HandlerFunc(h.handleStats),
-		tokenCheck, existCheck, credentialsCheck)).Methods("GET")
+		tokenCheck, existCheck, statsCheck)).Methods("GET")
 	h.Handle("/api/v0/events/{eventID}/guests/report", Adapt(http.HandlerFunc(h.handleReport),
-		tokenCheck, existCheck, credentialsCheck)).Methods("GET")
+		tokenCheck, existCheck, reportsCheck)).Methods("GET")
+	h.Handle("/api/v1-4/events/{eventID}/guests/sessions", Adapt(http.HandlerFunc(h.handleAttendanceMatrix),
+		tokenCheck, existCheck, reportsCheck, timezonesOutput)).Methods("GET")
+	h.Handle("/api/v1-4/events/{eventID}/guests/report/sessions", Adapt(http.HandlerFunc(h.handleSessionsReport),
+		tokenCheck, existCheck, reportsCheck)).Methods("GET")
+	h.Handle("/api/v1-4/events/{eventID}/guests/arrivals", Adapt(http.HandlerFunc(h.handleArrivals),
+		tokenCheck, existCheck, statsCheck, timezonesOutput)).Methods("GET")
+	h.Handle("/api/v1-4/events/{eventID}/guests/report/arrivals", Adapt(http.HandlerFunc(h.handleArrivalsReport),
+		tokenCheck, existCheck, reportsCheck)).Methods("GET")
 
 	return h
 }
@@ -87,6 +131,9 @@ func (h *GuestHandler) handleTags(w http.ResponseWriter, r *http.Request) {
 	w.Write(reply)
 }
 
+//handleGuests writes the names of the guests of an event, filtered by the ?checkedin, ?tag and ?filter query parameters
+//If any of the listing parameters of parseListOptions are given, only that page of names is written,
+//along with the total count header as for handleGuestRecords. Otherwise every name is written
 func (h *GuestHandler) handleGuests(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
@@ -94,20 +141,43 @@ func (h *GuestHandler) handleGuests(w http.ResponseWriter, r *http.Request) {
 		WriteMessage(http.StatusBadRequest, "Could not parse query string", w)
 		return
 	}
-	var guestsFunction func(string, []string) ([]string, error)
+	var guestsFunction func(string, checkin.TagFilter) ([]string, error)
+	var recordsFunction func(string, checkin.TagFilter, checkin.ListOptions) ([]checkin.GuestRecord, int, error)
 	if val, ok := r.Form["checkedin"]; !ok {
 		//no checkedin=true or checkedin=false is set, so get all guests
-		guestsFunction = h.GuestService.Guests
+		guestsFunction, recordsFunction = h.GuestService.Guests, h.GuestService.GuestRecords
 	} else if strings.ToLower(val[0]) == "true" {
-		guestsFunction = h.GuestService.GuestsCheckedIn
+		guestsFunction, recordsFunction = h.GuestService.GuestsCheckedIn, h.GuestService.GuestRecordsCheckedIn
 	} else if strings.ToLower(val[0]) == "false" {
-		guestsFunction = h.GuestService.GuestsNotCheckedIn
+		guestsFunction, recordsFunction = h.GuestService.GuestsNotCheckedIn, h.GuestService.GuestRecordsNotCheckedIn
 	} else {
 		WriteMessage(http.StatusBadRequest, "Form value 'checkedin' must be either true or false (non-case sensitive)", w)
 		return
 	}
+	opts, err := parseListOptions(r, checkin.SortByName, checkin.SortByCheckInTime)
+	if err != nil {
+		WriteMessage(http.StatusBadRequest, err.Error(), w)
+		return
+	}
+
+	filter, ok := h.tagFilter(w, r, "tag")
+	if !ok {
+		return
+	}
 
-	guests, err := guestsFunction(mux.Vars(r)["eventID"], r.Form["tag"])
+	var guests []string
+	if opts == (checkin.ListOptions{}) {
+		guests, err = guestsFunction(mux.Vars(r)["eventID"], filter)
+	} else {
+		var records []checkin.GuestRecord
+		var total int
+		records, total, err = recordsFunction(mux.Vars(r)["eventID"], filter, opts)
+		guests = make([]string, len(records))
+		for i, record := range records {
+			guests[i] = record.Name
+		}
+		writeTotalCount(total, w)
+	}
 	if err != nil {
 		h.Logger.Println("Error in handleGuests: " + err.Error())
 		WriteMessage(http.StatusInternalServerError, "Error fetching all guests for event", w)
@@ -117,6 +187,49 @@ func (h *GuestHandler) handleGuests(w http.ResponseWriter, r *http.Request) {
 	w.Write(reply)
 }
 
+//handleGuestRecords writes a page of the attendance records (name, tags, check in status and time)
+//of the guests of an event, filtered by the ?checkedin, ?tag and ?filter query parameters
+//Sorting, searching and pagination are controlled as in parseListOptions
+func (h *GuestHandler) handleGuestRecords(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		h.Logger.Println("Error parsing form queries: " + err.Error())
+		WriteMessage(http.StatusBadRequest, "Could not parse query string", w)
+		return
+	}
+	var recordsFunction func(string, checkin.TagFilter, checkin.ListOptions) ([]checkin.GuestRecord, int, error)
+	if val, ok := r.Form["checkedin"]; !ok {
+		//no checkedin=true or checkedin=false is set, so get all guests
+		recordsFunction = h.GuestService.GuestRecords
+	} else if strings.ToLower(val[0]) == "true" {
+		recordsFunction = h.GuestService.GuestRecordsCheckedIn
+	} else if strings.ToLower(val[0]) == "false" {
+		recordsFunction = h.GuestService.GuestRecordsNotCheckedIn
+	} else {
+		WriteMessage(http.StatusBadRequest, "Form value 'checkedin' must be either true or false (non-case sensitive)", w)
+		return
+	}
+	opts, err := parseListOptions(r, checkin.SortByName, checkin.SortByCheckInTime)
+	if err != nil {
+		WriteMessage(http.StatusBadRequest, err.Error(), w)
+		return
+	}
+	filter, ok := h.tagFilter(w, r, "tag")
+	if !ok {
+		return
+	}
+
+	records, total, err := recordsFunction(mux.Vars(r)["eventID"], filter, opts)
+	if err != nil {
+		h.Logger.Println("Error in handleGuestRecords: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching guest records for event", w)
+		return
+	}
+	writeTotalCount(total, w)
+	reply, _ := json.Marshal(records)
+	w.Write(reply)
+}
+
 func (h *GuestHandler) handleRegisterGuests(w http.ResponseWriter, r *http.Request) {
 	var guests []checkin.Guest
 	dec := json.NewDecoder(r.Body)
@@ -240,7 +353,7 @@ func (h *GuestHandler) handleRemoveGuest(w http.ResponseWriter, r *http.Request)
 
 func (h *GuestHandler) handleGuestsCheckedIn(w http.ResponseWriter, r *http.Request) {
 	eventID := mux.Vars(r)["eventID"]
-	guests, err := h.GuestService.GuestsCheckedIn(eventID, nil)
+	guests, err := h.GuestService.GuestsCheckedIn(eventID, checkin.TagFilter{})
 	if err != nil {
 		h.Logger.Println("Error in handleGuestsCheckedIn: " + err.Error())
 		WriteMessage(http.StatusInternalServerError, "Error fetching checked-in guests for event", w)
@@ -250,8 +363,15 @@ func (h *GuestHandler) handleGuestsCheckedIn(w http.ResponseWriter, r *http.Requ
 	w.Write(reply)
 }
 
+//markAbsent is what hosts send to mark a guest absent
+//If a session is given, the guest is only marked absent from that session
+type markAbsent struct {
+	checkin.Guest
+	SessionID string `json:"sessionId"`
+}
+
 func (h *GuestHandler) handleMarkGuestAbsent(w http.ResponseWriter, r *http.Request) {
-	var guest checkin.Guest
+	var guest markAbsent
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
 	err := dec.Decode(&guest)
@@ -275,12 +395,24 @@ func (h *GuestHandler) handleMarkGuestAbsent(w http.ResponseWriter, r *http.Requ
 		WriteMessage(http.StatusInternalServerError, "Error checking if guest exists", w)
 		return
 	}
+	if !h.sessionExists(eventID, guest.SessionID, w) {
+		return
+	}
 
-	err = h.GuestService.MarkAbsent(eventID, guest.NRIC)
+	authInfo, err := h.Authenticator.GetAuthInfo(r)
+	if err != nil {
+		h.Logger.Println("Error fetching authorization info: " + err.Error())
+		WriteMessage(http.StatusBadRequest, "Error in fetching authorization info", w)
+		return
+	}
+
+	name, err := h.GuestService.MarkAbsent(eventID, guest.SessionID, guest.NRIC, attendanceSource(authInfo.Username, r))
 	if err != nil {
 		h.Logger.Println("Error check guest in: " + err.Error())
 		WriteMessage(http.StatusInternalServerError, "Guest check-in failed", w)
+		return
 	}
+	h.sendAttendanceUpdate(eventID, guest.SessionID, name, false)
 
 	//if anyone subscribed to a check in listener on this guest, update them
 	if h.GuestMessenger.HasConnection(generateGuestID(eventID, guest.NRIC)) {
@@ -297,8 +429,21 @@ func (h *GuestHandler) handleMarkGuestAbsent(w http.ResponseWriter, r *http.Requ
 	WriteOKMessage("Successfully marked guest as absent", w)
 }
 
+//selfCheckIn is what guests send to check themselves in
+//Their location is only needed if the event has a geofence
+//If a session is given, they are checked in to that session instead of the event as a whole
+type selfCheckIn struct {
+	checkin.Guest
+	SessionID string     `json:"sessionId"`
+	Lat       null.Float `json:"lat"`
+	Long      null.Float `json:"long"`
+}
+
+//handleCheckInGuest lets a guest check themselves in
+//If the event has a geofence and the guest is not within it (or does not give their location),
+//the check in is flagged for hosts to review, and if the geofence is enforced, refused
 func (h *GuestHandler) handleCheckInGuest(w http.ResponseWriter, r *http.Request) {
-	var guest checkin.Guest
+	var guest selfCheckIn
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
 	err := dec.Decode(&guest)
@@ -311,6 +456,10 @@ func (h *GuestHandler) handleCheckInGuest(w http.ResponseWriter, r *http.Request
 		WriteMessage(http.StatusBadRequest, "Incorrect fields for removing guest (need only NRIC)", w)
 		return
 	}
+	if !checkin.ValidCoordinates(guest.Lat, guest.Long) {
+		WriteMessage(http.StatusBadRequest, "Lat must be between -90 and 90, and long between -180 and 180", w)
+		return
+	}
 
 	eventID := mux.Vars(r)["eventID"]
 	//check if the guest exists before attempting to check it in
@@ -322,14 +471,53 @@ func (h *GuestHandler) handleCheckInGuest(w http.ResponseWriter, r *http.Request
 		WriteMessage(http.StatusInternalServerError, "Error checking if guest exists", w)
 		return
 	}
+	if !h.sessionExists(eventID, guest.SessionID, w) {
+		return
+	}
+
+	//this endpoint is public, so guests check themselves in
+	source := attendanceSource(checkin.ActorSelf, r)
+	event, err := h.EventService.Event(eventID)
+	if err != nil {
+		h.Logger.Println("Error fetching event to check geofence: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching event details", w)
+		return
+	}
+	if event.Geofence != checkin.GeofenceOff && !event.WithinGeofence(guest.Lat, guest.Long) {
+		flag := checkin.CheckInFlag{
+			Lat:              guest.Lat,
+			Long:             guest.Long,
+			Rejected:         event.Geofence == checkin.GeofenceEnforce,
+			AttendanceSource: source,
+		}
+		if guest.Lat.Valid && guest.Long.Valid {
+			flag.Distance = null.FloatFrom(event.DistanceFrom(guest.Lat.Float64, guest.Long.Float64))
+		}
+		err = h.GuestService.FlagCheckIn(eventID, guest.NRIC, flag)
+		if err != nil {
+			//do not let guests check in unnoticed from outside the geofence
+			h.Logger.Println("Error flagging check in: " + err.Error())
+			WriteMessage(http.StatusInternalServerError, "Error recording check in from outside the event", w)
+			return
+		}
+		if flag.Rejected && !flag.Distance.Valid {
+			WriteMessage(http.StatusForbidden, "Location needed to check in to this event", w)
+			return
+		} else if flag.Rejected {
+			WriteMessage(http.StatusForbidden, "Too far from the event to check in", w)
+			return
+		}
+	}
 
-	name, err := h.GuestService.CheckIn(eventID, guest.NRIC)
+	name, err := h.GuestService.CheckIn(eventID, guest.SessionID, guest.NRIC, source)
 	if err != nil {
 		h.Logger.Println("Error check guest in: " + err.Error())
 		WriteMessage(http.StatusInternalServerError, "Guest check-in failed", w)
 		return
 	}
 
+	h.sendAttendanceUpdate(eventID, guest.SessionID, name, true)
+
 	//if anyone subscribed to a check in listener on this guest, update them
 	if h.GuestMessenger.HasConnection(generateGuestID(eventID, guest.NRIC)) {
 		err = h.GuestMessenger.Send(generateGuestID(eventID, guest.NRIC), GuestMessage{
@@ -346,10 +534,62 @@ func (h *GuestHandler) handleCheckInGuest(w http.ResponseWriter, r *http.Request
 	w.Write(reply)
 }
 
+//sendAttendanceUpdate lets any hosts streaming the event know that a guest was checked in or marked absent
+//(from the session given, if any) along with the event's or session's statistics after the change
+//Errors are only logged, as the change itself has already been made
+func (h *GuestHandler) sendAttendanceUpdate(eventID string, sessionID string, name string, checkedIn bool) {
+	if !h.HostMessenger.HasStream(eventID) {
+		return
+	}
+	stats, err := h.GuestService.CheckInStats(eventID, sessionID, checkin.TagFilter{})
+	if err != nil {
+		h.Logger.Println("Error fetching statistics to send to hosts: " + err.Error())
+		return
+	}
+	title := "checkedin/0"
+	if checkedIn {
+		title = "checkedin/1"
+	}
+	err = h.HostMessenger.Send(eventID, HostMessage{
+		Title:   title,
+		Content: AttendanceUpdate{Name: name, SessionID: sessionID, CheckedIn: checkedIn, Stats: stats},
+	})
+	if err != nil {
+		h.Logger.Println("Error sending attendance update to hosts of event " + eventID + ": " + err.Error())
+	}
+}
+
+//handleOpenHostStream streams live attendance updates of an event to its host, as Server-Sent Events
+//Each update is an AttendanceUpdate, with the same title as the message sent to the guest
+func (h *GuestHandler) handleOpenHostStream(w http.ResponseWriter, r *http.Request) {
+	err := h.HostMessenger.OpenStream(mux.Vars(r)["eventID"], w, r)
+	if err != nil {
+		h.Logger.Println("Error when attempting to open host stream: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error starting stream of check ins", w)
+		return
+	}
+	//stream has been closed, nothing further can be written
+}
+
+//attendanceSource describes the client which made a request to change a guest's attendance
+//The client's IP address is found as for lockouts (see clientAddress), so it cannot be forged
+func attendanceSource(actor string, r *http.Request) checkin.AttendanceSource {
+	return checkin.AttendanceSource{
+		Actor:     actor,
+		IPAddress: clientAddress(r),
+		UserAgent: r.UserAgent(),
+	}
+}
+
 //Generates the guest ID to be used for the GuestMessenger
 //using NRIC alone would be insufficient as one guest could go to multiple events
 func generateGuestID(eventID string, guestNRIC string) string {
-	return eventID + " " + guestNRIC
+	return EventGuestsPrefix(eventID) + guestNRIC
+}
+
+//EventGuestsPrefix returns the prefix of the guest IDs of every guest of the event, for use with GuestMessenger.SendAll
+func EventGuestsPrefix(eventID string) string {
+	return eventID + " "
 }
 
 func (h *GuestHandler) handleCreateCheckInListener(w http.ResponseWriter, r *http.Request) {
@@ -368,7 +608,7 @@ func (h *GuestHandler) handleCreateCheckInListener(w http.ResponseWriter, r *htt
 
 func (h *GuestHandler) handleGuestsNotCheckedIn(w http.ResponseWriter, r *http.Request) {
 	eventID := mux.Vars(r)["eventID"]
-	guests, err := h.GuestService.GuestsNotCheckedIn(eventID, nil)
+	guests, err := h.GuestService.GuestsNotCheckedIn(eventID, checkin.TagFilter{})
 	if err != nil {
 		h.Logger.Println("Error in handleNotGuestsCheckedIn: " + err.Error())
 		WriteMessage(http.StatusInternalServerError, "Error fetching not checked-in guests for event", w)
@@ -378,8 +618,60 @@ func (h *GuestHandler) handleGuestsNotCheckedIn(w http.ResponseWriter, r *http.R
 	w.Write(reply)
 }
 
+//handleAttendanceLog writes a page of the attendance log of an event: every check in and
+//mark absent, who made it and from what client
+//Sorting, searching and pagination are controlled as in parseListOptions
+func (h *GuestHandler) handleAttendanceLog(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListOptions(r, checkin.SortByTime, checkin.SortByName)
+	if err != nil {
+		WriteMessage(http.StatusBadRequest, err.Error(), w)
+		return
+	}
+
+	entries, total, err := h.AttendanceLogService.AttendanceLog(mux.Vars(r)["eventID"], opts)
+	if err != nil {
+		h.Logger.Println("Error in handleAttendanceLog: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching attendance log for event", w)
+		return
+	}
+	writeTotalCount(total, w)
+	reply, _ := json.Marshal(entries)
+	w.Write(reply)
+}
+
+//handleCheckInFlags writes a page of the check ins flagged by the event's geofence, for hosts to review
+//Sorting, searching and pagination are controlled as in parseListOptions
+func (h *GuestHandler) handleCheckInFlags(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListOptions(r, checkin.SortByTime, checkin.SortByName)
+	if err != nil {
+		WriteMessage(http.StatusBadRequest, err.Error(), w)
+		return
+	}
+
+	flags, total, err := h.AttendanceLogService.CheckInFlags(mux.Vars(r)["eventID"], opts)
+	if err != nil {
+		h.Logger.Println("Error in handleCheckInFlags: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching flagged check ins for event", w)
+		return
+	}
+	writeTotalCount(total, w)
+	reply, _ := json.Marshal(flags)
+	w.Write(reply)
+}
+
+//handleStats writes the attendance statistics of the event given by the eventID in the URL,
+//or of one of its sessions if given by the session query parameter
+//Only guests matching the ?tag and ?filter query parameters are counted
 func (h *GuestHandler) handleStats(w http.ResponseWriter, r *http.Request) {
-	stats, err := h.GuestService.CheckInStats(mux.Vars(r)["eventID"], nil)
+	eventID, sessionID := mux.Vars(r)["eventID"], r.FormValue("session")
+	filter, ok := h.tagFilter(w, r, "tag")
+	if !ok {
+		return
+	}
+	if !h.sessionExists(eventID, sessionID, w) {
+		return
+	}
+	stats, err := h.GuestService.CheckInStats(eventID, sessionID, filter)
 	if err != nil {
 		h.Logger.Println("Error in handleStats: " + err.Error())
 		WriteMessage(http.StatusInternalServerError, "Error fetching statistics for event", w)
@@ -389,6 +681,9 @@ func (h *GuestHandler) handleStats(w http.ResponseWriter, r *http.Request) {
 	w.Write(reply)
 }
 
+//handleReport writes a CSV report of which guests of the event given by the eventID in the URL are present,
+//filtered down to those with all the tags given by the tags query parameters, and matching the filter query parameter
+//If a session query parameter is given, the report is of who attended that session
 func (h *GuestHandler) handleReport(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
@@ -396,14 +691,22 @@ func (h *GuestHandler) handleReport(w http.ResponseWriter, r *http.Request) {
 		WriteMessage(http.StatusBadRequest, "Could not parse query string", w)
 		return
 	}
-	eventID := mux.Vars(r)["eventID"]
-	absent, err := h.GuestService.GuestsNotCheckedIn(eventID, r.Form["tags"])
+	eventID, sessionID := mux.Vars(r)["eventID"], r.Form.Get("session")
+	filter, ok := h.tagFilter(w, r, "tags")
+	if !ok {
+		return
+	}
+	if sessionID != "" {
+		h.writeSessionReport(w, eventID, sessionID, filter)
+		return
+	}
+	absent, err := h.GuestService.GuestsNotCheckedIn(eventID, filter)
 	if err != nil {
 		h.Logger.Println("Error in handleReport when getting absent guests: " + err.Error())
 		WriteMessage(http.StatusInternalServerError, "Error fetching absentees", w)
 		return
 	}
-	present, err := h.GuestService.GuestsCheckedIn(eventID, r.Form["tags"])
+	present, err := h.GuestService.GuestsCheckedIn(eventID, filter)
 	if err != nil {
 		h.Logger.Println("Error in handleReport when getting present guests: " + err.Error())
 		WriteMessage(http.StatusInternalServerError, "Error fetching those present", w)
@@ -426,3 +729,135 @@ func (h *GuestHandler) handleReport(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Disposition", "attachment;filename=AttendanceReport.csv")
 	w.Write(b.Bytes())
 }
+
+//writeSessionReport writes a CSV report of which guests of the event matching the tag filter attended the session,
+//in the same format as the report of the event as a whole
+func (h *GuestHandler) writeSessionReport(w http.ResponseWriter, eventID string, sessionID string, filter checkin.TagFilter) {
+	if !h.sessionExists(eventID, sessionID, w) {
+		return
+	}
+	matrix, err := h.GuestService.AttendanceMatrix(eventID, filter)
+	if err != nil {
+		h.Logger.Println("Error in handleReport when getting attendance at sessions: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching attendance at session", w)
+		return
+	}
+	column := -1
+	for i, session := range matrix.Sessions {
+		if session.ID == sessionID {
+			column = i
+		}
+	}
+	if column < 0 { //deleted since checking it exists
+		WriteMessage(http.StatusNotFound, "No such session for this event", w)
+		return
+	}
+
+	b := &bytes.Buffer{}
+	wr := csv.NewWriter(b)
+	wr.Write([]string{"Name", "Present"})
+	for _, present := range []bool{true, false} {
+		for _, guest := range matrix.Guests {
+			if guest.Present[column] == present {
+				wr.Write([]string{guest.Name, presentValue(present)})
+			}
+		}
+	}
+	wr.Flush()
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment;filename=AttendanceReport.csv")
+	w.Write(b.Bytes())
+}
+
+//handleAttendanceMatrix writes which sessions of the event given by the eventID in the URL each of its guests
+//attended, filtered down to guests with all the tags given by the tags query parameters
+func (h *GuestHandler) handleAttendanceMatrix(w http.ResponseWriter, r *http.Request) {
+	matrix, err := h.attendanceMatrix(w, r)
+	if err != nil {
+		return
+	}
+	reply, _ := json.Marshal(matrix)
+	w.Write(reply)
+}
+
+//handleSessionsReport writes a CSV report with a row for each guest of the event given by the eventID in the URL,
+//with a column for each of its sessions saying whether the guest attended, and the number they attended
+//Guests are filtered down to those with all the tags given by the tags query parameters
+func (h *GuestHandler) handleSessionsReport(w http.ResponseWriter, r *http.Request) {
+	matrix, err := h.attendanceMatrix(w, r)
+	if err != nil {
+		return
+	}
+
+	b := &bytes.Buffer{}
+	wr := csv.NewWriter(b)
+	header := []string{"Name"}
+	for _, session := range matrix.Sessions {
+		header = append(header, session.Name)
+	}
+	wr.Write(append(header, "Sessions Attended"))
+	for _, guest := range matrix.Guests {
+		row, attended := []string{guest.Name}, 0
+		for _, present := range guest.Present {
+			row = append(row, presentValue(present))
+			if present {
+				attended++
+			}
+		}
+		wr.Write(append(row, strconv.Itoa(attended)))
+	}
+	wr.Flush()
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment;filename=SessionAttendanceReport.csv")
+	w.Write(b.Bytes())
+}
+
+//attendanceMatrix fetches the attendance matrix of the event in the request, for guests matching the tag filter
+//of the request (see tagFilter), writing an error if it cannot be fetched
+func (h *GuestHandler) attendanceMatrix(w http.ResponseWriter, r *http.Request) (checkin.AttendanceMatrix, error) {
+	err := r.ParseForm()
+	if err != nil {
+		h.Logger.Println("Error parsing form queries: " + err.Error())
+		WriteMessage(http.StatusBadRequest, "Could not parse query string", w)
+		return checkin.AttendanceMatrix{}, err
+	}
+	filter, ok := h.tagFilter(w, r, "tags")
+	if !ok {
+		return checkin.AttendanceMatrix{}, errors.New("Invalid tag filter")
+	}
+	matrix, err := h.GuestService.AttendanceMatrix(mux.Vars(r)["eventID"], filter)
+	if err != nil {
+		h.Logger.Println("Error fetching attendance at sessions: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching attendance at sessions", w)
+		return checkin.AttendanceMatrix{}, err
+	}
+	return matrix, nil
+}
+
+//sessionExists checks that the event has the session with the given ID, writing an error if it does not
+//or it could not be checked. No sessionID means the event as a whole, so always exists
+func (h *GuestHandler) sessionExists(eventID string, sessionID string, w http.ResponseWriter) bool {
+	if sessionID == "" {
+		return true
+	}
+	_, exists, err := h.EventService.Session(eventID, sessionID)
+	if err != nil {
+		h.Logger.Println("Error checking if session exists: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error checking if session exists", w)
+		return false
+	} else if !exists {
+		WriteMessage(http.StatusNotFound, "No such session for this event", w)
+		return false
+	}
+	return true
+}
+
+//presentValue is how whether a guest was present is written in reports
+func presentValue(present bool) string {
+	if present {
+		return "1"
+	}
+	return "0"
+}
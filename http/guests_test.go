@@ -11,10 +11,13 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/guregu/null"
 )
 
 //Generates a HasConnection mock function (for use in mock.GuestMessenger) that returns the
@@ -37,33 +40,73 @@ func sendGenerator(t *testing.T, err error, expectedID string,
 	}
 }
 
+//Generates a Send mock function (for use in mock.HostMessenger) that returns the error value
+//provided. Also tests if the eventID and message passed in matches what was expected
+func hostSendGenerator(t *testing.T, err error, expectedID string,
+	expectedMsg myhttp.HostMessage) func(string, myhttp.HostMessage) error {
+	return func(eventID string, msg myhttp.HostMessage) error {
+		test.Equals(t, expectedID, eventID)
+		test.Equals(t, expectedMsg, msg)
+		return err
+	}
+}
+
+//attendanceMatrix is the attendance of the guests of an event at its three sessions
+var attendanceMatrix = checkin.AttendanceMatrix{
+	Sessions: []checkin.Session{
+		{ID: "1", EventID: "100", Name: "Day 1", Start: null.TimeFrom(time.Date(2019, 3, 1, 9, 0, 0, 0, time.UTC))},
+		{ID: "2", EventID: "100", Name: "Day 2", Start: null.TimeFrom(time.Date(2019, 3, 2, 9, 0, 0, 0, time.UTC))},
+		{ID: "3", EventID: "100", Name: "Day 3"},
+	},
+	Guests: []checkin.SessionAttendance{
+		{Name: "Alice", Present: []bool{true, false, false}},
+		{Name: "Bob", Present: []bool{true, true, true}},
+		{Name: "Herman", Present: []bool{false, false, false}},
+	},
+}
+
+//Generates a Session mock function (for use in mock.EventService) where the event with the
+//expected ID has only the session with the given ID
+func sessionGenerator(t *testing.T, expectedID string, sessionID string) func(string, string) (checkin.Session, bool, error) {
+	return func(eventID string, ID string) (checkin.Session, bool, error) {
+		test.Equals(t, expectedID, eventID)
+		if ID != sessionID {
+			return checkin.Session{}, false, nil
+		}
+		return checkin.Session{ID: ID, EventID: eventID, Name: "Day 1"}, true, nil
+	}
+}
+
 func TestHandleGuests(t *testing.T) {
 	// Inject our mock into our handler.
 	var gs mock.GuestService
 	var es mock.EventService
 	var auth mock.Authenticator
 	var gm mock.GuestMessenger
-	h := myhttp.NewGuestHandler(&gs, &es, &gm, &auth, 64, 64)
+	var hm mock.HostMessenger
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &gm, &hm, &auth, 64, 64)
 
 	//mock the required calls
 	es.CheckIfExistsFn = checkIfExistsGenerator("100", nil)
-	es.CheckHostFn = checkHostGenerator("testing_username", "100", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "100", checkin.RoleOwner, nil)
 	auth.AuthenticateFn = authenticateGenerator(true, nil)
 	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
-	guestsGenerator := func(names []string, err error) func(string, []string) ([]string, error) {
-		return func(eventID string, tags []string) ([]string, error) {
+	guestsGenerator := func(names []string, err error) func(string, checkin.TagFilter) ([]string, error) {
+		return func(eventID string, filter checkin.TagFilter) ([]string, error) {
 			if eventID != "100" {
 				t.Fatalf("unexpected id: %s", eventID)
 			}
 			if err != nil {
 				return nil, err
 			}
-			if tags == nil {
+			if filter.MatchesAll() {
 				return names, nil
-			} else if reflect.DeepEqual(tags, []string{"VIP"}) {
+			} else if reflect.DeepEqual(filter, checkin.HasAllTags([]string{"VIP"})) {
 				return []string{"VIP1", "VIP2"}, nil
-			} else if reflect.DeepEqual(tags, []string{"VIP", "ATTENDANCE"}) {
+			} else if reflect.DeepEqual(filter, checkin.HasAllTags([]string{"VIP", "ATTENDANCE"})) {
 				return []string{"AVIP1"}, nil
+			} else if filter.String() == "((VIP or SPEAKER) and not CANCELLED)" {
+				return []string{"VIP1", "SPEAKER1"}, nil
 			}
 
 			t.Fatalf("Unexpected branch of guests")
@@ -71,26 +114,26 @@ func TestHandleGuests(t *testing.T) {
 		}
 	}
 	gs.GuestsFn = guestsGenerator([]string{"Bob", "Jim", "Jacob"}, nil)
-	gs.GuestsCheckedInFn = func(eventID string, tags []string) ([]string, error) {
+	gs.GuestsCheckedInFn = func(eventID string, filter checkin.TagFilter) ([]string, error) {
 		if eventID != "100" {
 			t.Fatalf("unexpected id: %s", eventID)
 		}
 
-		if reflect.DeepEqual(tags, []string{"VIP", "COLONEL"}) {
+		if reflect.DeepEqual(filter, checkin.HasAllTags([]string{"VIP", "COLONEL"})) {
 			return []string{}, nil
-		} else if reflect.DeepEqual(tags, []string{"VIP"}) {
+		} else if reflect.DeepEqual(filter, checkin.HasAllTags([]string{"VIP"})) {
 			return []string{"LOL"}, nil
 		}
 
 		t.Fatal("Unexpected branch of guests checked in")
 		return nil, nil
 	}
-	gs.GuestsNotCheckedInFn = func(eventID string, tags []string) ([]string, error) {
+	gs.GuestsNotCheckedInFn = func(eventID string, filter checkin.TagFilter) ([]string, error) {
 		if eventID != "100" {
 			t.Fatalf("unexpected id: %s", eventID)
 		}
 
-		if tags == nil {
+		if filter.MatchesAll() {
 			return []string{}, nil
 		}
 
@@ -127,6 +170,20 @@ func TestHandleGuests(t *testing.T) {
 	json.NewDecoder(w.Result().Body).Decode(&guests)
 	test.Equals(t, []string{"AVIP1"}, guests)
 
+	//Test tag filter expression
+	r = httptest.NewRequest("GET", "/api/v0/events/100/guests?filter="+url.QueryEscape("(VIP OR SPEAKER) and NOT CANCELLED"), nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	json.NewDecoder(w.Result().Body).Decode(&guests)
+	test.Equals(t, []string{"VIP1", "SPEAKER1"}, guests)
+
+	//Test invalid tag filter expression
+	r = httptest.NewRequest("GET", "/api/v0/events/100/guests?filter="+url.QueryEscape("(VIP or SPEAKER"), nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+
 	//Test checked in
 	r = httptest.NewRequest("GET", "/api/v0/events/100/guests?tag=VIP&tag=COLONEL&checkedin=true", nil)
 	w = httptest.NewRecorder()
@@ -166,6 +223,25 @@ func TestHandleGuests(t *testing.T) {
 	h.ServeHTTP(w, r)
 	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
 
+	//Test pages of names, sorted and searched as for guest records
+	gs.GuestRecordsNotCheckedInFn = func(eventID string, filter checkin.TagFilter, opts checkin.ListOptions) ([]checkin.GuestRecord, int, error) {
+		test.Equals(t, "100", eventID)
+		test.Equals(t, checkin.HasTag("VIP"), filter)
+		test.Equals(t, checkin.ListOptions{SortBy: checkin.SortByCheckInTime, Search: "j", Offset: 1, Limit: 2}, opts)
+		return []checkin.GuestRecord{{Name: "Jim"}, {Name: "Jacob"}}, 5, nil
+	}
+	r = httptest.NewRequest("GET", "/api/v0/events/100/guests?checkedin=false&tag=VIP&sort=checkInTime&search=j&offset=1&limit=2", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, "5", w.Result().Header.Get(myhttp.TotalCountHeader))
+	json.NewDecoder(w.Result().Body).Decode(&guests)
+	test.Equals(t, []string{"Jim", "Jacob"}, guests)
+	r = httptest.NewRequest("GET", "/api/v0/events/100/guests?sort=tags", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+
 	r = httptest.NewRequest("GET", "/api/v0/events/100/guests", nil)
 
 	//Test error getting guests
@@ -193,17 +269,191 @@ func TestHandleGuests(t *testing.T) {
 	eventDoesNotExistTest(t, r, h, &es)
 }
 
+func TestHandleGuestRecords(t *testing.T) {
+	// Inject our mock into our handler.
+	var gs mock.GuestService
+	var es mock.EventService
+	var auth mock.Authenticator
+	var gm mock.GuestMessenger
+	var hm mock.HostMessenger
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &gm, &hm, &auth, 64, 64)
+
+	//mock the required calls
+	es.CheckIfExistsFn = checkIfExistsGenerator("100", nil)
+	es.EventFn = eventTimezoneGenerator("100", "")
+	es.HostRoleFn = hostRoleGenerator("testing_username", "100", checkin.RoleOwner, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	checkInTime := time.Date(2019, 4, 10, 8, 30, 0, 0, time.UTC)
+	allRecords := []checkin.GuestRecord{
+		{Name: "Bob", Tags: []string{"VIP"}, CheckedIn: true, CheckInTime: null.TimeFrom(checkInTime)},
+		{Name: "Jim", Tags: []string{}, CheckedIn: false, CheckInTime: null.Time{}},
+	}
+	var receivedOpts checkin.ListOptions
+	recordsGenerator := func(records []checkin.GuestRecord, err error) func(string, checkin.TagFilter, checkin.ListOptions) ([]checkin.GuestRecord, int, error) {
+		return func(eventID string, filter checkin.TagFilter, opts checkin.ListOptions) ([]checkin.GuestRecord, int, error) {
+			if eventID != "100" {
+				t.Fatalf("unexpected id: %s", eventID)
+			}
+			receivedOpts = opts
+			if err != nil {
+				return nil, 0, err
+			}
+			if filter.MatchesAll() {
+				return records, len(records), nil
+			} else if reflect.DeepEqual(filter, checkin.HasAllTags([]string{"VIP"})) {
+				return records[:1], 1, nil
+			}
+
+			t.Fatalf("Unexpected branch of guest records")
+			return nil, 0, nil
+		}
+	}
+	gs.GuestRecordsFn = recordsGenerator(allRecords, nil)
+	gs.GuestRecordsCheckedInFn = recordsGenerator(allRecords[:1], nil)
+	gs.GuestRecordsNotCheckedInFn = recordsGenerator(allRecords[1:], nil)
+
+	//Test all records
+	r := httptest.NewRequest("GET", "/api/v1-4/events/100/guests", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	var records []checkin.GuestRecord
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Ok(t, json.NewDecoder(w.Result().Body).Decode(&records))
+	test.Equals(t, 2, len(records))
+	test.Equals(t, "Bob", records[0].Name)
+	test.Equals(t, []string{"VIP"}, records[0].Tags)
+	test.Equals(t, true, records[0].CheckedIn)
+	test.Assert(t, records[0].CheckInTime.Valid, "Checked in guest should have a check in time")
+	test.Assert(t, records[0].CheckInTime.Time.Equal(checkInTime), "Check in time not equal")
+	test.Equals(t, "Jim", records[1].Name)
+	test.Equals(t, []string{}, records[1].Tags)
+	test.Equals(t, false, records[1].CheckedIn)
+	test.Assert(t, !records[1].CheckInTime.Valid, "Guest not checked in should have null check in time")
+	test.Assert(t, gs.GuestRecordsInvoked, "GuestRecords not invoked")
+	test.Equals(t, "2", w.Result().Header.Get(myhttp.TotalCountHeader))
+	test.Equals(t, checkin.ListOptions{}, receivedOpts)
+
+	//Test pagination, sorting and search
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/guests?checkedin=true&sort=checkInTime&order=asc&offset=20&limit=10&search=b", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, checkin.ListOptions{SortBy: checkin.SortByCheckInTime, Search: "b", Offset: 20, Limit: 10}, receivedOpts)
+
+	//Test invalid listing options
+	for _, query := range []string{"offset=-1", "limit=all", "sort=start", "order=backwards"} {
+		r = httptest.NewRequest("GET", "/api/v1-4/events/100/guests?"+query, nil)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+	}
+
+	//Test null check in time is written as null
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/guests?checkedin=false", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	var rawRecords []map[string]interface{}
+	json.NewDecoder(w.Result().Body).Decode(&rawRecords)
+	test.Equals(t, 1, len(rawRecords))
+	test.Equals(t, nil, rawRecords[0]["checkInTime"])
+	test.Assert(t, gs.GuestRecordsNotCheckedInInvoked, "GuestRecordsNotCheckedIn not invoked")
+
+	//Test checked in, and that checkedin argument is not case sensitive
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/guests?checkedin=TRUE", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	records = nil
+	json.NewDecoder(w.Result().Body).Decode(&records)
+	test.Equals(t, 1, len(records))
+	test.Equals(t, "Bob", records[0].Name)
+	test.Assert(t, gs.GuestRecordsCheckedInInvoked, "GuestRecordsCheckedIn not invoked")
+
+	//Test tags
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/guests?tag=VIP", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	records = nil
+	json.NewDecoder(w.Result().Body).Decode(&records)
+	test.Equals(t, 1, len(records))
+	test.Equals(t, "Bob", records[0].Name)
+
+	//Test timezone conversion of check in time
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/guests?loc=Asia/Singapore", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	json.NewDecoder(w.Result().Body).Decode(&rawRecords)
+	test.Equals(t, "2019-04-10T16:30:00+08:00", rawRecords[0]["checkInTime"])
+
+	//Test invalid timezone
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/guests?loc=Not/Aregion", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	//Test field selection
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/guests?field=name&field=checkedIn", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	rawRecords = nil
+	json.NewDecoder(w.Result().Body).Decode(&rawRecords)
+	test.Equals(t, []map[string]interface{}{
+		{"name": "Bob", "checkedIn": true},
+		{"name": "Jim", "checkedIn": false},
+	}, rawRecords)
+
+	//Test checkedin set to invalid values
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/guests?checkedin=somethingelse", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	//check invalid form syntax
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/guests?checkedin=false=", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/guests", nil)
+
+	//Test error getting records
+	gs.GuestRecordsFn = recordsGenerator(nil, errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	gs.GuestRecordsFn = recordsGenerator(allRecords, nil)
+
+	//access restriction tests
+	//Test access by another user
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+
+	//Test access by admin
+	adminAccessTest(t, r, h, &auth, func(r *http.Response) {
+		records = nil
+		json.NewDecoder(r.Body).Decode(&records)
+		test.Equals(t, 2, len(records))
+	})
+
+	//Test invalid token
+	noValidTokenTest(t, r, h, &auth)
+
+	//Test invalid eventID
+	r = httptest.NewRequest("GET", "/api/v1-4/events/200/guests", nil)
+	eventDoesNotExistTest(t, r, h, &es)
+}
+
 func TestHandleTags(t *testing.T) {
 	// Inject our mock into our handler.
 	var gs mock.GuestService
 	var es mock.EventService
 	var auth mock.Authenticator
 	var gm mock.GuestMessenger
-	h := myhttp.NewGuestHandler(&gs, &es, &gm, &auth, 64, 64)
+	var hm mock.HostMessenger
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &gm, &hm, &auth, 64, 64)
 
 	//mock the required calls
 	es.CheckIfExistsFn = checkIfExistsGenerator("100", nil)
-	es.CheckHostFn = checkHostGenerator("testing_username", "100", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "100", checkin.RoleOwner, nil)
 	auth.AuthenticateFn = authenticateGenerator(true, nil)
 	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
 	allTagsGenerator := func(err error, output []string) func(string) ([]string, error) {
@@ -263,11 +513,12 @@ func TestHandleRegisterGuests(t *testing.T) {
 	var es mock.EventService
 	var auth mock.Authenticator
 	var gm mock.GuestMessenger
-	h := myhttp.NewGuestHandler(&gs, &es, &gm, &auth, 64, 64)
+	var hm mock.HostMessenger
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &gm, &hm, &auth, 64, 64)
 
 	//mock the required calls
 	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
-	es.CheckHostFn = checkHostGenerator("testing_username", "300", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleOwner, nil)
 	auth.AuthenticateFn = authenticateGenerator(true, nil)
 	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
 	registerGuestsGenerator := func(err error, expectedGuests []checkin.Guest) func(string, []checkin.Guest) error {
@@ -487,11 +738,12 @@ func TestHandleRegisterGuest(t *testing.T) {
 	var es mock.EventService
 	var auth mock.Authenticator
 	var gm mock.GuestMessenger
-	h := myhttp.NewGuestHandler(&gs, &es, &gm, &auth, 64, 64)
+	var hm mock.HostMessenger
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &gm, &hm, &auth, 64, 64)
 
 	//mock the required calls
 	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
-	es.CheckHostFn = checkHostGenerator("testing_username", "300", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleOwner, nil)
 	auth.AuthenticateFn = authenticateGenerator(true, nil)
 	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
 	registerGuestGenerator := func(err error, expectedTags []string) func(string, checkin.Guest) error {
@@ -641,11 +893,12 @@ func TestHandleRemoveGuest(t *testing.T) {
 	var es mock.EventService
 	var auth mock.Authenticator
 	var gm mock.GuestMessenger
-	h := myhttp.NewGuestHandler(&gs, &es, &gm, &auth, 64, 64)
+	var hm mock.HostMessenger
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &gm, &hm, &auth, 64, 64)
 
 	//mock the required calls
 	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
-	es.CheckHostFn = checkHostGenerator("testing_username", "300", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleOwner, nil)
 	auth.AuthenticateFn = authenticateGenerator(true, nil)
 	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
 	removeGuestGenerator := func(err error) func(string, string) error {
@@ -722,20 +975,21 @@ func TestHandleGuestsCheckedIn(t *testing.T) {
 	var es mock.EventService
 	var auth mock.Authenticator
 	var gm mock.GuestMessenger
-	h := myhttp.NewGuestHandler(&gs, &es, &gm, &auth, 64, 64)
+	var hm mock.HostMessenger
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &gm, &hm, &auth, 64, 64)
 
 	//mock the required calls
 	es.CheckIfExistsFn = checkIfExistsGenerator("100", nil)
-	es.CheckHostFn = checkHostGenerator("testing_username", "100", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "100", checkin.RoleOwner, nil)
 	auth.AuthenticateFn = authenticateGenerator(true, nil)
 	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
-	guestsCheckedInGenerator := func(names []string, err error) func(string, []string) ([]string, error) {
-		return func(eventID string, tags []string) ([]string, error) {
+	guestsCheckedInGenerator := func(names []string, err error) func(string, checkin.TagFilter) ([]string, error) {
+		return func(eventID string, filter checkin.TagFilter) ([]string, error) {
 			if eventID != "100" {
 				t.Fatalf("unexpected id: %s", eventID)
 			}
-			if tags != nil && len(tags) != 0 {
-				t.Fatal("Expected nil/empty tags but got", tags)
+			if !filter.MatchesAll() {
+				t.Fatal("Expected empty tag filter but got", filter)
 			}
 			return names, err
 		}
@@ -789,7 +1043,8 @@ func TestHandleCheckInGuest(t *testing.T) {
 	var es mock.EventService
 	var auth mock.Authenticator
 	var gm mock.GuestMessenger
-	h := myhttp.NewGuestHandler(&gs, &es, &gm, &auth, 64, 64)
+	var hm mock.HostMessenger
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &gm, &hm, &auth, 64, 64)
 
 	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
 	//mock the required calls
@@ -812,10 +1067,13 @@ func TestHandleCheckInGuest(t *testing.T) {
 		}
 	}
 	es.EventFn = eventFnGenerator(-1*time.Hour, true, nil) //to meet release check
-	checkInFnGenerator := func(err error) func(string, string) (string, error) {
-		return func(eventID string, nric string) (string, error) {
+	var receivedSource checkin.AttendanceSource
+	var receivedSessionID string
+	checkInFnGenerator := func(err error) func(string, string, string, checkin.AttendanceSource) (string, error) {
+		return func(eventID string, sessionID string, nric string, source checkin.AttendanceSource) (string, error) {
 			test.Equals(t, "300", eventID)
 			test.Equals(t, "1234F", nric)
+			receivedSource, receivedSessionID = source, sessionID
 			if err != nil {
 				return "", err
 			}
@@ -823,6 +1081,7 @@ func TestHandleCheckInGuest(t *testing.T) {
 		}
 	}
 	gs.CheckInFn = checkInFnGenerator(nil)
+	hm.HasStreamFn = hasConnectionGenerator(t, "300", false)
 	guestExistsFnGenerator := func(err error) func(string, string) (bool, error) {
 		return func(eventID string, nric string) (bool, error) {
 			test.Equals(t, "300", eventID)
@@ -843,6 +1102,74 @@ func TestHandleCheckInGuest(t *testing.T) {
 	var name string
 	json.NewDecoder(w.Result().Body).Decode(&name)
 	test.Equals(t, "Jim", name)
+	//httptest requests come from 192.0.2.1
+	test.Equals(t, checkin.AttendanceSource{Actor: checkin.ActorSelf, IPAddress: "192.0.2.1"}, receivedSource)
+
+	//Test client metadata is recorded, with the client IP taken from the load balancer's header
+	r = httptest.NewRequest("POST", "/api/v0/events/300/guests/checkedin",
+		strings.NewReader("{\"nric\":\"1234F\"}"))
+	r.Header.Set("X-Forwarded-For", "203.0.113.7, 198.51.100.2") //the first address is set by the client, so is not trusted
+	r.Header.Set("User-Agent", "Mozilla/5.0")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, checkin.AttendanceSource{Actor: checkin.ActorSelf, IPAddress: "198.51.100.2", UserAgent: "Mozilla/5.0"},
+		receivedSource)
+
+	//Test hosts streaming the event are sent the guest's name and the updated stats
+	hm.HasStreamFn = hasConnectionGenerator(t, "300", true)
+	gs.CheckInStatsFn = func(eventID string, sessionID string, filter checkin.TagFilter) (checkin.GuestStats, error) {
+		test.Equals(t, "300", eventID)
+		return checkin.GuestStats{TotalGuests: 4, CheckedIn: 2, PercentCheckedIn: 0.5}, nil
+	}
+	hm.SendFn = hostSendGenerator(t, nil, "300", myhttp.HostMessage{
+		Title: "checkedin/1",
+		Content: myhttp.AttendanceUpdate{
+			Name:      "Jim",
+			CheckedIn: true,
+			Stats:     checkin.GuestStats{TotalGuests: 4, CheckedIn: 2, PercentCheckedIn: 0.5},
+		},
+	})
+	r = httptest.NewRequest("POST", "/api/v0/events/300/guests/checkedin",
+		strings.NewReader("{\"nric\":\"1234F\"}"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Assert(t, hm.SendInvoked, "Hosts not sent attendance update")
+	test.Equals(t, "", receivedSessionID)
+
+	//Test checking in to a session, with hosts sent the stats of the session
+	es.SessionFn = sessionGenerator(t, "300", "1")
+	gs.CheckInStatsFn = func(eventID string, sessionID string, filter checkin.TagFilter) (checkin.GuestStats, error) {
+		test.Equals(t, "300", eventID)
+		test.Equals(t, "1", sessionID)
+		return checkin.GuestStats{TotalGuests: 4, CheckedIn: 1, PercentCheckedIn: 0.25}, nil
+	}
+	hm.SendFn = hostSendGenerator(t, nil, "300", myhttp.HostMessage{
+		Title: "checkedin/1",
+		Content: myhttp.AttendanceUpdate{
+			Name:      "Jim",
+			SessionID: "1",
+			CheckedIn: true,
+			Stats:     checkin.GuestStats{TotalGuests: 4, CheckedIn: 1, PercentCheckedIn: 0.25},
+		},
+	})
+	r = httptest.NewRequest("POST", "/api/v0/events/300/guests/checkedin",
+		strings.NewReader("{\"nric\":\"1234F\",\"sessionId\":\"1\"}"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, "1", receivedSessionID)
+
+	//Test checking in to a session the event does not have
+	gs.CheckInInvoked = false
+	r = httptest.NewRequest("POST", "/api/v0/events/300/guests/checkedin",
+		strings.NewReader("{\"nric\":\"1234F\",\"sessionId\":\"2\"}"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusNotFound, w.Result().StatusCode)
+	test.Assert(t, !gs.CheckInInvoked, "Guest checked in to missing session")
+	hm.HasStreamFn = hasConnectionGenerator(t, "300", false)
 
 	//Test guest messenger active
 	gm.HasConnectionFn = hasConnectionGenerator(t, "300 1234F", true)
@@ -957,24 +1284,256 @@ func TestHandleCheckInGuest(t *testing.T) {
 
 }
 
+func TestHandleCheckInGuestGeofence(t *testing.T) {
+	// Inject our mock into our handler.
+	var gs mock.GuestService
+	var es mock.EventService
+	var auth mock.Authenticator
+	var gm mock.GuestMessenger
+	var hm mock.HostMessenger
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &gm, &hm, &auth, 64, 64)
+
+	//mock the required calls
+	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
+	event := checkin.Event{
+		TimeTags: map[string]time.Time{"release": time.Now().UTC().Add(-1 * time.Hour)},
+		Lat:      null.FloatFrom(1.335932),
+		Long:     null.FloatFrom(103.744708),
+		Radius:   null.FloatFrom(0.5),
+		Geofence: checkin.GeofenceFlag,
+	}
+	es.EventFn = func(ID string) (checkin.Event, error) {
+		test.Equals(t, "300", ID)
+		return event, nil
+	}
+	gs.GuestExistsFn = func(eventID string, nric string) (bool, error) {
+		return true, nil
+	}
+	gs.CheckInFn = func(eventID string, sessionID string, nric string, source checkin.AttendanceSource) (string, error) {
+		return "Jim", nil
+	}
+	var receivedFlag checkin.CheckInFlag
+	flagCheckInFnGenerator := func(err error) func(string, string, checkin.CheckInFlag) error {
+		return func(eventID string, nric string, flag checkin.CheckInFlag) error {
+			test.Equals(t, "300", eventID)
+			test.Equals(t, "1234F", nric)
+			receivedFlag = flag
+			return err
+		}
+	}
+	gs.FlagCheckInFn = flagCheckInFnGenerator(nil)
+	hm.HasStreamFn = hasConnectionGenerator(t, "300", false)
+	gm.HasConnectionFn = hasConnectionGenerator(t, "300 1234F", false)
+	checkIn := func(body string) *http.Response {
+		r := httptest.NewRequest("POST", "/api/v0/events/300/guests/checkedin", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	//Test check in within the geofence is not flagged
+	res := checkIn(`{"nric":"1234F","lat":1.3360,"long":103.7450}`)
+	test.Equals(t, http.StatusOK, res.StatusCode)
+	test.Assert(t, gs.CheckInInvoked, "Guest within geofence not checked in")
+	test.Assert(t, !gs.FlagCheckInInvoked, "Check in within geofence was flagged")
+
+	//Test check in outside the geofence is flagged, but the guest is still checked in
+	gs.CheckInInvoked = false
+	res = checkIn(`{"nric":"1234F","lat":1.4,"long":103.8}`)
+	test.Equals(t, http.StatusOK, res.StatusCode)
+	test.Assert(t, gs.CheckInInvoked, "Flagged guest not checked in")
+	test.Assert(t, gs.FlagCheckInInvoked, "Check in outside geofence not flagged")
+	test.Equals(t, null.FloatFrom(1.4), receivedFlag.Lat)
+	test.Equals(t, null.FloatFrom(103.8), receivedFlag.Long)
+	test.Assert(t, receivedFlag.Distance.Float64 > 9 && receivedFlag.Distance.Float64 < 10,
+		"Distance from event not within 9-10km")
+	test.Equals(t, false, receivedFlag.Rejected)
+	test.Equals(t, checkin.AttendanceSource{Actor: checkin.ActorSelf, IPAddress: "192.0.2.1"}, receivedFlag.AttendanceSource)
+
+	//Test check in without a location is flagged
+	gs.FlagCheckInInvoked = false
+	res = checkIn(`{"nric":"1234F"}`)
+	test.Equals(t, http.StatusOK, res.StatusCode)
+	test.Assert(t, gs.FlagCheckInInvoked, "Check in without location not flagged")
+	test.Equals(t, checkin.CheckInFlag{AttendanceSource: receivedFlag.AttendanceSource}, receivedFlag)
+
+	//Test error flagging check in
+	gs.FlagCheckInFn = flagCheckInFnGenerator(errors.New("An error"))
+	gs.CheckInInvoked = false
+	res = checkIn(`{"nric":"1234F","lat":1.4,"long":103.8}`)
+	test.Equals(t, http.StatusInternalServerError, res.StatusCode)
+	test.Assert(t, !gs.CheckInInvoked, "Guest checked in without check in being flagged")
+	gs.FlagCheckInFn = flagCheckInFnGenerator(nil)
+
+	//Test enforced geofence refuses check in outside it, or without a location
+	event.Geofence = checkin.GeofenceEnforce
+	res = checkIn(`{"nric":"1234F","lat":1.4,"long":103.8}`)
+	test.Equals(t, http.StatusForbidden, res.StatusCode)
+	test.Assert(t, !gs.CheckInInvoked, "Guest outside enforced geofence checked in")
+	test.Equals(t, true, receivedFlag.Rejected)
+	res = checkIn(`{"nric":"1234F"}`)
+	test.Equals(t, http.StatusForbidden, res.StatusCode)
+	test.Assert(t, !gs.CheckInInvoked, "Guest without location checked in to event with enforced geofence")
+	res = checkIn(`{"nric":"1234F","lat":1.3360,"long":103.7450}`)
+	test.Equals(t, http.StatusOK, res.StatusCode)
+	test.Assert(t, gs.CheckInInvoked, "Guest within enforced geofence not checked in")
+
+	//Test geofence off does not check location
+	event.Geofence = checkin.GeofenceOff
+	gs.FlagCheckInInvoked = false
+	res = checkIn(`{"nric":"1234F","lat":1.4,"long":103.8}`)
+	test.Equals(t, http.StatusOK, res.StatusCode)
+	test.Assert(t, !gs.FlagCheckInInvoked, "Check in flagged without geofence")
+
+	//Test badly formatted location, or one out of range
+	res = checkIn(`{"nric":"1234F","lat":"north"}`)
+	test.Equals(t, http.StatusBadRequest, res.StatusCode)
+	gs.CheckInInvoked, gs.FlagCheckInInvoked = false, false
+	for _, body := range []string{
+		`{"nric":"1234F","lat":90.5,"long":103.8}`,
+		`{"nric":"1234F","lat":-91,"long":103.8}`,
+		`{"nric":"1234F","lat":1.4,"long":180.1}`,
+		`{"nric":"1234F","lat":1.4,"long":-200}`,
+	} {
+		res = checkIn(body)
+		test.Equals(t, http.StatusBadRequest, res.StatusCode)
+	}
+	test.Assert(t, !gs.CheckInInvoked && !gs.FlagCheckInInvoked, "Check in with location out of range was recorded")
+}
+
+func TestHandleCheckInFlags(t *testing.T) {
+	// Inject our mock into our handler.
+	var gs mock.GuestService
+	var als mock.AttendanceLogService
+	var es mock.EventService
+	var auth mock.Authenticator
+	var gm mock.GuestMessenger
+	var hm mock.HostMessenger
+	h := myhttp.NewGuestHandler(&gs, &als, &es, &gm, &hm, &auth, 64, 64)
+
+	//mock the required calls
+	es.CheckIfExistsFn = checkIfExistsGenerator("100", nil)
+	es.EventFn = eventTimezoneGenerator("100", "")
+	es.HostRoleFn = hostRoleGenerator("testing_username", "100", checkin.RoleOwner, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	flags := []checkin.CheckInFlag{
+		{ID: 1, EventID: "100", GuestHash: "hash1", GuestName: "Bob", Lat: null.FloatFrom(1.4), Long: null.FloatFrom(103.8),
+			Distance: null.FloatFrom(9.5), Rejected: false, Time: time.Date(2019, 4, 12, 9, 1, 0, 0, time.UTC),
+			AttendanceSource: checkin.AttendanceSource{Actor: checkin.ActorSelf, IPAddress: "203.0.113.5", UserAgent: "Mozilla/5.0"}},
+		{ID: 2, EventID: "100", GuestHash: "hash2", GuestName: "Alice", Rejected: true,
+			Time:             time.Date(2019, 4, 12, 9, 5, 0, 0, time.UTC),
+			AttendanceSource: checkin.AttendanceSource{Actor: checkin.ActorSelf, IPAddress: "198.51.100.1"}},
+	}
+	var receivedOpts checkin.ListOptions
+	checkInFlagsGenerator := func(err error) func(string, checkin.ListOptions) ([]checkin.CheckInFlag, int, error) {
+		return func(eventID string, opts checkin.ListOptions) ([]checkin.CheckInFlag, int, error) {
+			if eventID != "100" {
+				t.Fatalf("unexpected id: %s", eventID)
+			}
+			receivedOpts = opts
+			if err != nil {
+				return nil, 0, err
+			}
+			return flags, 12, nil
+		}
+	}
+	als.CheckInFlagsFn = checkInFlagsGenerator(nil)
+
+	//Test normal behavior
+	r := httptest.NewRequest("GET", "/api/v1-4/events/100/guests/flagged", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	var fetched []checkin.CheckInFlag
+	json.NewDecoder(w.Result().Body).Decode(&fetched)
+	test.Equals(t, flags, fetched)
+	test.Equals(t, "12", w.Result().Header.Get(myhttp.TotalCountHeader))
+	test.Equals(t, checkin.ListOptions{}, receivedOpts)
+
+	//Test unknown locations are null
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/guests/flagged?field=guestName&field=distance", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	var rawFlags []map[string]interface{}
+	json.NewDecoder(w.Result().Body).Decode(&rawFlags)
+	test.Equals(t, []map[string]interface{}{
+		{"guestName": "Bob", "distance": 9.5},
+		{"guestName": "Alice", "distance": nil},
+	}, rawFlags)
+
+	//Test pagination, sorting and search
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/guests/flagged?sort=name&limit=2&search=al", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, checkin.ListOptions{SortBy: checkin.SortByName, Limit: 2, Search: "al"}, receivedOpts)
+
+	//Test invalid listing options
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/guests/flagged?sort=checkInTime", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/guests/flagged", nil)
+
+	//Test error getting flags
+	als.CheckInFlagsFn = checkInFlagsGenerator(errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	als.CheckInFlagsFn = checkInFlagsGenerator(nil)
+
+	//access restriction tests
+	//Test access by another user
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+
+	//Test ushers cannot review flagged check ins
+	roleAccessTest(t, r, h, &es, "testing_username", "100",
+		[]string{checkin.RoleOwner, checkin.RoleCoHost}, func(r *http.Response) {
+			test.Equals(t, http.StatusOK, r.StatusCode)
+		})
+
+	//Test access by admin
+	adminAccessTest(t, r, h, &auth, func(r *http.Response) {
+		fetched = nil
+		json.NewDecoder(r.Body).Decode(&fetched)
+		test.Equals(t, flags, fetched)
+	})
+
+	//Test invalid token
+	noValidTokenTest(t, r, h, &auth)
+
+	//Test invalid eventID
+	r = httptest.NewRequest("GET", "/api/v1-4/events/200/guests/flagged", nil)
+	eventDoesNotExistTest(t, r, h, &es)
+}
+
 func TestHandleMarkGuestAbsent(t *testing.T) {
 	// Inject our mock into our handler.
 	var gs mock.GuestService
 	var es mock.EventService
 	var auth mock.Authenticator
 	var gm mock.GuestMessenger
-	h := myhttp.NewGuestHandler(&gs, &es, &gm, &auth, 64, 64)
+	var hm mock.HostMessenger
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &gm, &hm, &auth, 64, 64)
 
 	//mock the required calls
 	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
-	es.CheckHostFn = checkHostGenerator("testing_username", "300", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleOwner, nil)
 	auth.AuthenticateFn = authenticateGenerator(true, nil)
 	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
-	markAbsentFnGenerator := func(err error) func(string, string) error {
-		return func(eventID string, nric string) error {
+	var receivedSource checkin.AttendanceSource
+	var receivedSessionID string
+	markAbsentFnGenerator := func(err error) func(string, string, string, checkin.AttendanceSource) (string, error) {
+		return func(eventID string, sessionID string, nric string, source checkin.AttendanceSource) (string, error) {
 			test.Equals(t, "300", eventID)
 			test.Equals(t, "1234F", nric)
-			return err
+			receivedSource, receivedSessionID = source, sessionID
+			if err != nil {
+				return "", err
+			}
+			return "Jim", nil
 		}
 	}
 	gs.MarkAbsentFn = markAbsentFnGenerator(nil)
@@ -989,6 +1548,7 @@ func TestHandleMarkGuestAbsent(t *testing.T) {
 	}
 	gs.GuestExistsFn = guestExistsFnGenerator(nil)
 	gm.HasConnectionFn = hasConnectionGenerator(t, "300 1234F", false)
+	hm.HasStreamFn = hasConnectionGenerator(t, "300", false)
 
 	//Test normal behavior
 	r := httptest.NewRequest("DELETE", "/api/v0/events/300/guests/checkedin",
@@ -996,6 +1556,7 @@ func TestHandleMarkGuestAbsent(t *testing.T) {
 	w := httptest.NewRecorder()
 	h.ServeHTTP(w, r)
 	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, checkin.AttendanceSource{Actor: "testing_username", IPAddress: "192.0.2.1"}, receivedSource)
 
 	//Test listener active/need to send guest message
 	gm.HasConnectionFn = hasConnectionGenerator(t, "300 1234F", true)
@@ -1020,6 +1581,100 @@ func TestHandleMarkGuestAbsent(t *testing.T) {
 	gm.HasConnectionFn = hasConnectionGenerator(t, "300 1234F", false)
 	gm.SendFn = nil
 
+	//Test hosts streaming the event are sent the guest's name and the updated stats
+	hm.HasStreamFn = hasConnectionGenerator(t, "300", true)
+	gs.CheckInStatsFn = func(eventID string, sessionID string, filter checkin.TagFilter) (checkin.GuestStats, error) {
+		test.Equals(t, "300", eventID)
+		return checkin.GuestStats{TotalGuests: 4, CheckedIn: 1, PercentCheckedIn: 0.25}, nil
+	}
+	hm.SendFn = hostSendGenerator(t, nil, "300", myhttp.HostMessage{
+		Title: "checkedin/0",
+		Content: myhttp.AttendanceUpdate{
+			Name:      "Jim",
+			CheckedIn: false,
+			Stats:     checkin.GuestStats{TotalGuests: 4, CheckedIn: 1, PercentCheckedIn: 0.25},
+		},
+	})
+	r = httptest.NewRequest("DELETE", "/api/v0/events/300/guests/checkedin",
+		strings.NewReader("{\"nric\":\"1234F\"}"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Assert(t, hm.SendInvoked, "Hosts not sent attendance update")
+	test.Equals(t, "", receivedSessionID)
+
+	//Test marking absent from a session, with hosts sent the stats of the session
+	es.SessionFn = sessionGenerator(t, "300", "1")
+	eventStatsFn := gs.CheckInStatsFn
+	gs.CheckInStatsFn = func(eventID string, sessionID string, filter checkin.TagFilter) (checkin.GuestStats, error) {
+		test.Equals(t, "1", sessionID)
+		return checkin.GuestStats{TotalGuests: 4, CheckedIn: 3, PercentCheckedIn: 0.75}, nil
+	}
+	hm.SendFn = hostSendGenerator(t, nil, "300", myhttp.HostMessage{
+		Title: "checkedin/0",
+		Content: myhttp.AttendanceUpdate{
+			Name:      "Jim",
+			SessionID: "1",
+			CheckedIn: false,
+			Stats:     checkin.GuestStats{TotalGuests: 4, CheckedIn: 3, PercentCheckedIn: 0.75},
+		},
+	})
+	r = httptest.NewRequest("DELETE", "/api/v0/events/300/guests/checkedin",
+		strings.NewReader("{\"nric\":\"1234F\",\"sessionId\":\"1\"}"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, "1", receivedSessionID)
+
+	//Test marking absent from a session the event does not have
+	gs.MarkAbsentInvoked = false
+	r = httptest.NewRequest("DELETE", "/api/v0/events/300/guests/checkedin",
+		strings.NewReader("{\"nric\":\"1234F\",\"sessionId\":\"2\"}"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusNotFound, w.Result().StatusCode)
+	test.Assert(t, !gs.MarkAbsentInvoked, "Guest marked absent from missing session")
+	gs.CheckInStatsFn = eventStatsFn
+
+	//Test errors updating hosts do not stop the guest being marked absent
+	hm.SendFn = func(eventID string, msg myhttp.HostMessage) error {
+		return errors.New("An error")
+	}
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("DELETE", "/api/v0/events/300/guests/checkedin",
+		strings.NewReader("{\"nric\":\"1234F\"}"))
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	gs.CheckInStatsFn = func(eventID string, sessionID string, filter checkin.TagFilter) (checkin.GuestStats, error) {
+		return checkin.GuestStats{}, errors.New("An error")
+	}
+	hm.SendInvoked = false
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest("DELETE", "/api/v0/events/300/guests/checkedin",
+		strings.NewReader("{\"nric\":\"1234F\"}"))
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Assert(t, !hm.SendInvoked, "Hosts sent attendance update without stats")
+	hm.HasStreamFn = hasConnectionGenerator(t, "300", false)
+	hm.SendFn = nil
+
+	//Test ushers can mark guests absent, but viewers cannot
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleUsher, nil)
+	r = httptest.NewRequest("DELETE", "/api/v0/events/300/guests/checkedin",
+		strings.NewReader("{\"nric\":\"1234F\"}"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleViewer, nil)
+	gs.MarkAbsentInvoked = false
+	r = httptest.NewRequest("DELETE", "/api/v0/events/300/guests/checkedin",
+		strings.NewReader("{\"nric\":\"1234F\"}"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusForbidden, w.Result().StatusCode)
+	test.Assert(t, !gs.MarkAbsentInvoked, "Viewer able to mark guest absent")
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleOwner, nil)
+
 	//Test guest does not exist with that nric
 	r = httptest.NewRequest("DELETE", "/api/v0/events/300/guests/checkedin",
 		strings.NewReader("{\"nric\":\"5678F\"}"))
@@ -1094,7 +1749,8 @@ func TestHandleCreateCheckInListener(t *testing.T) {
 	var es mock.EventService
 	var auth mock.Authenticator
 	var gm mock.GuestMessenger
-	h := myhttp.NewGuestHandler(&gs, &es, &gm, &auth, 64, 64)
+	var hm mock.HostMessenger
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &gm, &hm, &auth, 64, 64)
 
 	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
 	openConnectionGen := func(err error) func(string, http.ResponseWriter, *http.Request) error {
@@ -1127,26 +1783,79 @@ func TestHandleCreateCheckInListener(t *testing.T) {
 	eventDoesNotExistTest(t, r, h, &es)
 }
 
+func TestHandleOpenHostStream(t *testing.T) {
+	// Inject our mock into our handler.
+	var gs mock.GuestService
+	var es mock.EventService
+	var auth mock.Authenticator
+	var gm mock.GuestMessenger
+	var hm mock.HostMessenger
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &gm, &hm, &auth, 64, 64)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleOwner, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	openStreamGen := func(err error) func(string, http.ResponseWriter, *http.Request) error {
+		return func(eventID string, w http.ResponseWriter, r *http.Request) error {
+			test.Equals(t, "300", eventID)
+			return err
+		}
+	}
+	hm.OpenStreamFn = openStreamGen(nil)
+
+	//Test normal behavior
+	r := httptest.NewRequest("GET", "/api/v1-4/events/300/guests/stream", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Assert(t, hm.OpenStreamInvoked, "OpenStream not invoked")
+
+	//Test open stream fails
+	hm.OpenStreamFn = openStreamGen(errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	hm.OpenStreamFn = openStreamGen(nil)
+
+	//access restriction tests
+	//Test access by another user
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+
+	//Test access by admin
+	adminAccessTest(t, r, h, &auth, func(r *http.Response) {
+		test.Equals(t, http.StatusOK, r.StatusCode)
+	})
+
+	//Test invalid token
+	noValidTokenTest(t, r, h, &auth)
+
+	//Test invalid eventID
+	r = httptest.NewRequest("GET", "/api/v1-4/events/200/guests/stream", nil)
+	eventDoesNotExistTest(t, r, h, &es)
+}
+
 func TestHandleGuestsNotCheckedIn(t *testing.T) {
 	// Inject our mock into our handler.
 	var gs mock.GuestService
 	var es mock.EventService
 	var auth mock.Authenticator
 	var gm mock.GuestMessenger
-	h := myhttp.NewGuestHandler(&gs, &es, &gm, &auth, 64, 64)
+	var hm mock.HostMessenger
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &gm, &hm, &auth, 64, 64)
 
 	//mock the required calls
 	es.CheckIfExistsFn = checkIfExistsGenerator("100", nil)
-	es.CheckHostFn = checkHostGenerator("testing_username", "100", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "100", checkin.RoleOwner, nil)
 	auth.AuthenticateFn = authenticateGenerator(true, nil)
 	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
-	guestsNotCheckedInFnGenerator := func(names []string, err error) func(string, []string) ([]string, error) {
-		return func(eventID string, tags []string) ([]string, error) {
+	guestsNotCheckedInFnGenerator := func(names []string, err error) func(string, checkin.TagFilter) ([]string, error) {
+		return func(eventID string, filter checkin.TagFilter) ([]string, error) {
 			if eventID != "100" {
 				t.Fatalf("unexpected id: %s", eventID)
 			}
-			if tags != nil && len(tags) != 0 {
-				t.Fatal("Expected nil or empty tags, but got ", tags)
+			if !filter.MatchesAll() {
+				t.Fatal("Expected empty tag filter, but got ", filter)
 			}
 			return names, err
 		}
@@ -1195,27 +1904,143 @@ func TestHandleGuestsNotCheckedIn(t *testing.T) {
 	eventDoesNotExistTest(t, r, h, &es)
 }
 
-func TestHandleStats(t *testing.T) {
+func TestHandleAttendanceLog(t *testing.T) {
 	// Inject our mock into our handler.
 	var gs mock.GuestService
+	var als mock.AttendanceLogService
 	var es mock.EventService
 	var auth mock.Authenticator
 	var gm mock.GuestMessenger
-	h := myhttp.NewGuestHandler(&gs, &es, &gm, &auth, 64, 64)
+	var hm mock.HostMessenger
+	h := myhttp.NewGuestHandler(&gs, &als, &es, &gm, &hm, &auth, 64, 64)
 
 	//mock the required calls
 	es.CheckIfExistsFn = checkIfExistsGenerator("100", nil)
-	es.CheckHostFn = checkHostGenerator("testing_username", "100", nil)
+	es.EventFn = eventTimezoneGenerator("100", "")
+	es.HostRoleFn = hostRoleGenerator("testing_username", "100", checkin.RoleOwner, nil)
 	auth.AuthenticateFn = authenticateGenerator(true, nil)
 	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
-	checkInStatsFnGenerator := func(err error) func(string, []string) (checkin.GuestStats, error) {
-		return func(eventID string, tags []string) (checkin.GuestStats, error) {
+	entries := []checkin.AttendanceEntry{
+		{ID: 1, EventID: "100", GuestHash: "hash1", GuestName: "Bob", Action: checkin.ActionCheckIn,
+			Time:             time.Date(2019, 4, 12, 9, 1, 0, 0, time.UTC),
+			AttendanceSource: checkin.AttendanceSource{Actor: checkin.ActorSelf, IPAddress: "203.0.113.5", UserAgent: "Mozilla/5.0"}},
+		{ID: 2, EventID: "100", GuestHash: "hash1", GuestName: "Bob", Action: checkin.ActionMarkAbsent,
+			Time:             time.Date(2019, 4, 12, 9, 5, 0, 0, time.UTC),
+			AttendanceSource: checkin.AttendanceSource{Actor: "testing_username", IPAddress: "198.51.100.1", UserAgent: "curl/7.64.0"}},
+	}
+	var receivedOpts checkin.ListOptions
+	attendanceLogGenerator := func(err error) func(string, checkin.ListOptions) ([]checkin.AttendanceEntry, int, error) {
+		return func(eventID string, opts checkin.ListOptions) ([]checkin.AttendanceEntry, int, error) {
 			if eventID != "100" {
 				t.Fatalf("unexpected id: %s", eventID)
 			}
-			if tags != nil && len(tags) != 0 {
-				t.Fatal("Expected nil or empty tags but got ", tags)
+			receivedOpts = opts
+			if err != nil {
+				return nil, 0, err
 			}
+			return entries, 40, nil
+		}
+	}
+	als.AttendanceLogFn = attendanceLogGenerator(nil)
+
+	//Test normal behavior
+	r := httptest.NewRequest("GET", "/api/v1-4/events/100/guests/log", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	var attendanceLog []checkin.AttendanceEntry
+	json.NewDecoder(w.Result().Body).Decode(&attendanceLog)
+	test.Equals(t, entries, attendanceLog)
+	test.Equals(t, "40", w.Result().Header.Get(myhttp.TotalCountHeader))
+	test.Equals(t, checkin.ListOptions{}, receivedOpts)
+
+	//Test client metadata is flattened into each entry
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/guests/log?field=action&field=actor&field=ipAddress", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	var rawLog []map[string]interface{}
+	json.NewDecoder(w.Result().Body).Decode(&rawLog)
+	test.Equals(t, []map[string]interface{}{
+		{"action": "checkin", "actor": "self", "ipAddress": "203.0.113.5"},
+		{"action": "markabsent", "actor": "testing_username", "ipAddress": "198.51.100.1"},
+	}, rawLog)
+
+	//Test timezone conversion
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/guests/log?loc=Asia/Singapore&field=time", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	rawLog = nil
+	json.NewDecoder(w.Result().Body).Decode(&rawLog)
+	test.Equals(t, "2019-04-12T17:01:00+08:00", rawLog[0]["time"])
+
+	//Test pagination, sorting and search
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/guests/log?order=desc&limit=2&offset=4&search=bo", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, checkin.ListOptions{Descending: true, Limit: 2, Offset: 4, Search: "bo"}, receivedOpts)
+
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/guests/log?sort=name", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, checkin.SortByName, receivedOpts.SortBy)
+
+	//Test invalid listing options
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/guests/log?sort=checkInTime", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/guests/log", nil)
+
+	//Test error getting log
+	als.AttendanceLogFn = attendanceLogGenerator(errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	als.AttendanceLogFn = attendanceLogGenerator(nil)
+
+	//access restriction tests
+	//Test access by another user
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+
+	//Test access by admin
+	adminAccessTest(t, r, h, &auth, func(r *http.Response) {
+		attendanceLog = nil
+		json.NewDecoder(r.Body).Decode(&attendanceLog)
+		test.Equals(t, entries, attendanceLog)
+	})
+
+	//Test invalid token
+	noValidTokenTest(t, r, h, &auth)
+
+	//Test invalid eventID
+	r = httptest.NewRequest("GET", "/api/v1-4/events/200/guests/log", nil)
+	eventDoesNotExistTest(t, r, h, &es)
+}
+
+func TestHandleStats(t *testing.T) {
+	// Inject our mock into our handler.
+	var gs mock.GuestService
+	var es mock.EventService
+	var auth mock.Authenticator
+	var gm mock.GuestMessenger
+	var hm mock.HostMessenger
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &gm, &hm, &auth, 64, 64)
+
+	//mock the required calls
+	es.CheckIfExistsFn = checkIfExistsGenerator("100", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "100", checkin.RoleOwner, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	var receivedSessionID string
+	var receivedFilter checkin.TagFilter
+	checkInStatsFnGenerator := func(err error) func(string, string, checkin.TagFilter) (checkin.GuestStats, error) {
+		return func(eventID string, sessionID string, filter checkin.TagFilter) (checkin.GuestStats, error) {
+			if eventID != "100" {
+				t.Fatalf("unexpected id: %s", eventID)
+			}
+			receivedSessionID, receivedFilter = sessionID, filter
 			if err != nil {
 				return checkin.GuestStats{}, err
 			}
@@ -1240,6 +2065,32 @@ func TestHandleStats(t *testing.T) {
 		CheckedIn:        5,
 		PercentCheckedIn: 0.5,
 	}, stats)
+	test.Equals(t, "", receivedSessionID)
+	test.Equals(t, checkin.TagFilter{}, receivedFilter)
+
+	//Test stats of guests matching a tag filter
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v0/events/100/guests/stats?filter="+url.QueryEscape("not CANCELLED"), nil))
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, checkin.TagFilter{Op: checkin.TagOpNot, Operands: []checkin.TagFilter{checkin.HasTag("CANCELLED")}},
+		receivedFilter)
+	gs.CheckInStatsInvoked = false
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v0/events/100/guests/stats?filter=not", nil))
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+	test.Assert(t, !gs.CheckInStatsInvoked, "Stats fetched with invalid tag filter")
+
+	//Test stats of a session, which the event must have
+	es.SessionFn = sessionGenerator(t, "100", "1")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v0/events/100/guests/stats?session=1", nil))
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, "1", receivedSessionID)
+	gs.CheckInStatsInvoked = false
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v0/events/100/guests/stats?session=2", nil))
+	test.Equals(t, http.StatusNotFound, w.Result().StatusCode)
+	test.Assert(t, !gs.CheckInStatsInvoked, "Stats fetched for missing session")
 
 	//Test error getting stats
 	gs.CheckInStatsFn = checkInStatsFnGenerator(errors.New("An error"))
@@ -1252,6 +2103,12 @@ func TestHandleStats(t *testing.T) {
 	//Test access by another user
 	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
 
+	//Test ushers cannot see stats
+	roleAccessTest(t, r, h, &es, "testing_username", "100",
+		[]string{checkin.RoleOwner, checkin.RoleCoHost, checkin.RoleViewer}, func(r *http.Response) {
+			test.Equals(t, http.StatusOK, r.StatusCode)
+		})
+
 	//Test access by admin
 	adminAccessTest(t, r, h, &auth, func(r *http.Response) {
 		json.NewDecoder(r.Body).Decode(&stats)
@@ -1276,37 +2133,38 @@ func TestHandleReport(t *testing.T) {
 	var es mock.EventService
 	var auth mock.Authenticator
 	var gm mock.GuestMessenger
-	h := myhttp.NewGuestHandler(&gs, &es, &gm, &auth, 64, 64)
+	var hm mock.HostMessenger
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &gm, &hm, &auth, 64, 64)
 
 	es.CheckIfExistsFn = checkIfExistsGenerator("100", nil)
-	es.CheckHostFn = checkHostGenerator("testing_username", "100", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "100", checkin.RoleOwner, nil)
 	auth.AuthenticateFn = authenticateGenerator(true, nil)
 	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
-	guestsCheckedInGenerator := func(names []string, filterednames []string, err error) func(string, []string) ([]string, error) {
-		return func(eventID string, tags []string) ([]string, error) {
+	guestsCheckedInGenerator := func(names []string, filterednames []string, err error) func(string, checkin.TagFilter) ([]string, error) {
+		return func(eventID string, filter checkin.TagFilter) ([]string, error) {
 			if eventID != "100" {
 				t.Fatalf("unexpected id: %s", eventID)
 			}
-			log.Println(tags)
-			if len(tags) == 2 && tags[0] == "CONFIRMED" && tags[1] == "VIP" {
+			log.Println(filter)
+			if reflect.DeepEqual(filter, checkin.HasAllTags([]string{"CONFIRMED", "VIP"})) {
 				return filterednames, err
-			} else if tags != nil && len(tags) != 0 {
-				t.Fatal("Expected nil or empty tags but got ", tags)
+			} else if !filter.MatchesAll() {
+				t.Fatal("Expected empty tag filter but got ", filter)
 			}
 			return names, err
 		}
 	}
 	gs.GuestsCheckedInFn = guestsCheckedInGenerator([]string{"Alice", "Jim", "Bob"}, []string{"Alice", "Bob"}, nil)
-	guestsNotCheckedInFnGenerator := func(names []string, filterednames []string, err error) func(string, []string) ([]string, error) {
-		return func(eventID string, tags []string) ([]string, error) {
+	guestsNotCheckedInFnGenerator := func(names []string, filterednames []string, err error) func(string, checkin.TagFilter) ([]string, error) {
+		return func(eventID string, filter checkin.TagFilter) ([]string, error) {
 			if eventID != "100" {
 				t.Fatalf("unexpected id: %s", eventID)
 			}
-			log.Println(tags)
-			if len(tags) == 2 && tags[0] == "CONFIRMED" && tags[1] == "VIP" {
+			log.Println(filter)
+			if reflect.DeepEqual(filter, checkin.HasAllTags([]string{"CONFIRMED", "VIP"})) {
 				return filterednames, err
-			} else if tags != nil && len(tags) != 0 {
-				t.Fatal("Expected nil or empty tags or confirmed/vip but got ", tags)
+			} else if !filter.MatchesAll() {
+				t.Fatal("Expected empty tag filter or confirmed/vip but got ", filter)
 			}
 			return names, err
 		}
@@ -1402,6 +2260,27 @@ func TestHandleReport(t *testing.T) {
 	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
 	gs.GuestsNotCheckedInFn = guestsNotCheckedInFnGenerator([]string{"Herman", "Ritchie"}, []string{}, nil)
 
+	//test report of a session, with those present first
+	es.SessionFn = sessionGenerator(t, "100", "2")
+	gs.AttendanceMatrixFn = func(eventID string, filter checkin.TagFilter) (checkin.AttendanceMatrix, error) {
+		test.Equals(t, "100", eventID)
+		test.Equals(t, checkin.HasTag("VIP"), filter)
+		return attendanceMatrix, nil
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v0/events/100/guests/report?session=2&tags=VIP", nil))
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	data, err = csv.NewReader(w.Result().Body).ReadAll()
+	test.Ok(t, err)
+	test.Equals(t, [][]string{{"Name", "Present"}, {"Bob", "1"}, {"Alice", "0"}, {"Herman", "0"}}, data)
+
+	//test report of a session the event does not have
+	gs.AttendanceMatrixInvoked = false
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v0/events/100/guests/report?session=3", nil))
+	test.Equals(t, http.StatusNotFound, w.Result().StatusCode)
+	test.Assert(t, !gs.AttendanceMatrixInvoked, "Report made of missing session")
+
 	//access restriction tests
 	//Test access by another user
 	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
@@ -1429,3 +2308,136 @@ func TestHandleReport(t *testing.T) {
 	r = httptest.NewRequest("GET", "/api/v0/events/1001/guests/report", nil)
 	eventDoesNotExistTest(t, r, h, &es)
 }
+
+func TestHandleAttendanceMatrix(t *testing.T) {
+	var gs mock.GuestService
+	var es mock.EventService
+	var auth mock.Authenticator
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &mock.GuestMessenger{}, &mock.HostMessenger{}, &auth, 64, 64)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("100", nil)
+	es.EventFn = eventTimezoneGenerator("100", "")
+	es.HostRoleFn = hostRoleGenerator("testing_username", "100", checkin.RoleViewer, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	var receivedFilter checkin.TagFilter
+	attendanceMatrixGenerator := func(err error) func(string, checkin.TagFilter) (checkin.AttendanceMatrix, error) {
+		return func(eventID string, filter checkin.TagFilter) (checkin.AttendanceMatrix, error) {
+			test.Equals(t, "100", eventID)
+			receivedFilter = filter
+			return attendanceMatrix, err
+		}
+	}
+	gs.AttendanceMatrixFn = attendanceMatrixGenerator(nil)
+
+	//test normal functionality
+	r := httptest.NewRequest("GET", "/api/v1-4/events/100/guests/sessions", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	var fetched checkin.AttendanceMatrix
+	err := json.NewDecoder(w.Result().Body).Decode(&fetched)
+	test.Ok(t, err)
+	test.Equals(t, attendanceMatrix, fetched)
+	test.Equals(t, checkin.TagFilter{}, receivedFilter)
+
+	//test filtering by tags
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1-4/events/100/guests/sessions?tags=VIP&tags=CONFIRMED", nil))
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, checkin.HasAllTags([]string{"VIP", "CONFIRMED"}), receivedFilter)
+
+	//test filtering by a tag filter expression, along with tags
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1-4/events/100/guests/sessions?tags=CONFIRMED&filter="+
+		url.QueryEscape("VIP or SPEAKER"), nil))
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, checkin.HasTag("CONFIRMED").And(checkin.TagFilter{Op: checkin.TagOpOr,
+		Operands: []checkin.TagFilter{checkin.HasTag("VIP"), checkin.HasTag("SPEAKER")}}), receivedFilter)
+
+	//test invalid tag filter expression
+	receivedFilter = checkin.TagFilter{}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1-4/events/100/guests/sessions?filter="+url.QueryEscape("VIP or"), nil))
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+	test.Equals(t, checkin.TagFilter{}, receivedFilter)
+
+	//test error fetching attendance
+	gs.AttendanceMatrixFn = attendanceMatrixGenerator(errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	gs.AttendanceMatrixFn = attendanceMatrixGenerator(nil)
+
+	//access restriction tests
+	roleAccessTest(t, r, h, &es, "testing_username", "100", []string{checkin.RoleOwner, checkin.RoleCoHost, checkin.RoleViewer},
+		func(r *http.Response) {
+			test.Equals(t, http.StatusOK, r.StatusCode)
+		})
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+	r = httptest.NewRequest("GET", "/api/v1-4/events/1001/guests/sessions", nil)
+	eventDoesNotExistTest(t, r, h, &es)
+}
+
+func TestHandleSessionsReport(t *testing.T) {
+	var gs mock.GuestService
+	var es mock.EventService
+	var auth mock.Authenticator
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &mock.GuestMessenger{}, &mock.HostMessenger{}, &auth, 64, 64)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("100", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "100", checkin.RoleOwner, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	attendanceMatrixGenerator := func(matrix checkin.AttendanceMatrix, err error) func(string, checkin.TagFilter) (checkin.AttendanceMatrix, error) {
+		return func(eventID string, filter checkin.TagFilter) (checkin.AttendanceMatrix, error) {
+			test.Equals(t, "100", eventID)
+			return matrix, err
+		}
+	}
+	gs.AttendanceMatrixFn = attendanceMatrixGenerator(attendanceMatrix, nil)
+
+	//test normal functionality, with a column for each session
+	r := httptest.NewRequest("GET", "/api/v1-4/events/100/guests/report/sessions", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, "text/csv", w.Result().Header.Get("Content-Type"))
+	data, err := csv.NewReader(w.Result().Body).ReadAll()
+	test.Ok(t, err)
+	test.Equals(t, [][]string{
+		{"Name", "Day 1", "Day 2", "Day 3", "Sessions Attended"},
+		{"Alice", "1", "0", "0", "1"},
+		{"Bob", "1", "1", "1", "3"},
+		{"Herman", "0", "0", "0", "0"},
+	}, data)
+
+	//test event without sessions
+	gs.AttendanceMatrixFn = attendanceMatrixGenerator(checkin.AttendanceMatrix{
+		Sessions: []checkin.Session{},
+		Guests:   []checkin.SessionAttendance{{Name: "Alice", Present: []bool{}}},
+	}, nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	data, err = csv.NewReader(w.Result().Body).ReadAll()
+	test.Ok(t, err)
+	test.Equals(t, [][]string{{"Name", "Sessions Attended"}, {"Alice", "0"}}, data)
+
+	//test error fetching attendance
+	gs.AttendanceMatrixFn = attendanceMatrixGenerator(checkin.AttendanceMatrix{}, errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	gs.AttendanceMatrixFn = attendanceMatrixGenerator(attendanceMatrix, nil)
+
+	//access restriction tests
+	roleAccessTest(t, r, h, &es, "testing_username", "100", []string{checkin.RoleOwner, checkin.RoleCoHost, checkin.RoleViewer},
+		func(r *http.Response) {
+			test.Equals(t, http.StatusOK, r.StatusCode)
+		})
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+	r = httptest.NewRequest("GET", "/api/v1-4/events/1001/guests/report/sessions", nil)
+	eventDoesNotExistTest(t, r, h, &es)
+}
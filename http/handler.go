@@ -22,7 +22,7 @@ func (h Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// URL in the form of /api/{versionNumber}/{handlerType}/....
-	// e.g. /api/v1-2/auth/failedattempts/{username}
+	// e.g. /api/v1-4/auth/failedattempts/{username}
 	//Use {handlerType} to distinguish what handler to use
 	if urlSections[3] == "events" {
 		h.EventHandler.ServeHTTP(w, r)
@@ -0,0 +1,36 @@
+package http
+
+import (
+	"checkin"
+	"net/http"
+)
+
+//HostMessenger allows for the server to push live updates about an event to its hosts
+type HostMessenger interface {
+	//OpenStream streams every message sent to the event to the host who made the request
+	//Blocks until the host disconnects, or can no longer keep up with the stream
+	//Returns an error only if the stream could not be opened, in which case nothing has been written to w
+	OpenStream(eventID string, w http.ResponseWriter, r *http.Request) error
+
+	//Send sends a message to every host streaming the given event
+	Send(eventID string, msg HostMessage) error
+
+	//HasStream checks if any host is streaming the given event
+	HasStream(eventID string) bool
+}
+
+//HostMessage is a message to be sent to the hosts of an event
+type HostMessage struct {
+	Title   string      `json:"title"`
+	Content interface{} `json:"content"`
+}
+
+//AttendanceUpdate is the content of the message sent to hosts whenever a guest is checked in or marked absent
+//If the change was to a session of the event, its ID is given, and Stats are the statistics of that session
+//Otherwise, Stats are the statistics of the event after the change
+type AttendanceUpdate struct {
+	Name      string             `json:"name"`
+	SessionID string             `json:"sessionId,omitempty"`
+	CheckedIn bool               `json:"checkedIn"`
+	Stats     checkin.GuestStats `json:"stats"`
+}
@@ -0,0 +1,126 @@
+package http
+
+import (
+	"checkin"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+//handleHosts writes the hosts of the event given by the eventID in the URL, with their roles
+func (h *EventHandler) handleHosts(w http.ResponseWriter, r *http.Request) {
+	hosts, err := h.EventService.Hosts(mux.Vars(r)["eventID"])
+	if err != nil {
+		h.Logger.Println("Error fetching hosts: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching hosts of event", w)
+		return
+	}
+	reply, _ := json.Marshal(hosts)
+	w.Write(reply)
+}
+
+//handleAddHost makes the user given in the body of the request a host of the event given by the eventID in the URL,
+//with the given role
+//The role cannot be owner, as the owner only changes when ownership is transferred
+func (h *EventHandler) handleAddHost(w http.ResponseWriter, r *http.Request) {
+	var host checkin.Host
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&host)
+	if err != nil {
+		h.Logger.Println("Error decoding host JSON: " + err.Error())
+		WriteMessage(http.StatusBadRequest, "Badly formatted JSON in host (need username and role)", w)
+		return
+	}
+	if host.Username == "" || !checkin.IsValidRole(host.Role) || host.Role == checkin.RoleOwner {
+		WriteMessage(http.StatusBadRequest, "Need a username, and a role of cohost, usher or viewer", w)
+		return
+	}
+
+	eventID := mux.Vars(r)["eventID"]
+	if !h.hostable(host.Username, w) {
+		return
+	}
+	if role, err := h.EventService.HostRole(host.Username, eventID); err != nil {
+		h.Logger.Println("Error checking if user is already a host: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error checking if user is already a host", w)
+		return
+	} else if role != "" {
+		WriteMessage(http.StatusConflict, "User is already a host of the event", w)
+		return
+	}
+
+	err = h.EventService.AddHost(eventID, host.Username, host.Role)
+	if err != nil {
+		h.Logger.Println("Error adding host: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error adding host", w)
+		return
+	}
+	WriteMessage(http.StatusCreated, "Host added", w)
+}
+
+//handleRemoveHost removes the user given by the username in the URL from the hosts of the event
+//The owner cannot be removed, so that the event always has a host who can manage it
+func (h *EventHandler) handleRemoveHost(w http.ResponseWriter, r *http.Request) {
+	eventID, username := mux.Vars(r)["eventID"], mux.Vars(r)["username"]
+	if role, err := h.EventService.HostRole(username, eventID); err != nil {
+		h.Logger.Println("Error checking if user is a host: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error checking if user is a host", w)
+		return
+	} else if role == "" {
+		WriteMessage(http.StatusNotFound, "User is not a host of the event", w)
+		return
+	} else if role == checkin.RoleOwner {
+		WriteMessage(http.StatusConflict, "Cannot remove the owner of the event; transfer ownership first", w)
+		return
+	}
+
+	err := h.EventService.RemoveHost(eventID, username)
+	if err != nil {
+		h.Logger.Println("Error removing host: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error removing host", w)
+		return
+	}
+	WriteOKMessage("Host removed", w)
+}
+
+//handleTransferOwnership makes the user given in the body of the request the owner of the event,
+//adding them as a host if they are not one. The previous owner stays on as a co-host
+func (h *EventHandler) handleTransferOwnership(w http.ResponseWriter, r *http.Request) {
+	var owner struct {
+		Username string `json:"username"`
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&owner)
+	if err != nil || owner.Username == "" {
+		WriteMessage(http.StatusBadRequest, "Badly formatted JSON (need the username of the new owner)", w)
+		return
+	}
+	if !h.hostable(owner.Username, w) {
+		return
+	}
+
+	err = h.EventService.TransferOwnership(mux.Vars(r)["eventID"], owner.Username)
+	if err != nil {
+		h.Logger.Println("Error transferring ownership: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error transferring ownership of event", w)
+		return
+	}
+	WriteOKMessage("Ownership transferred", w)
+}
+
+//hostable checks that a user exists so they can host an event, writing an error response if not
+func (h *EventHandler) hostable(username string, w http.ResponseWriter) bool {
+	exists, err := h.UserService.CheckIfExists(username)
+	if err != nil {
+		h.Logger.Println("Error checking if user exists: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error checking if user exists", w)
+		return false
+	} else if !exists {
+		WriteMessage(http.StatusNotFound, "No such user", w)
+		return false
+	}
+	return true
+}
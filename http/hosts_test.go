@@ -0,0 +1,314 @@
+package http_test
+
+import (
+	"checkin"
+	myhttp "checkin/http"
+	"checkin/mock"
+	"checkin/test"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+//Generates a HostRole mock function which gives each user the role in roles, if the eventID matches
+//the expectedID - returns an empty role for users not in roles, or other events
+func hostRolesGenerator(expectedID string, roles map[string]string) func(string, string) (string, error) {
+	return func(username string, eventID string) (string, error) {
+		if eventID != expectedID {
+			return "", nil
+		}
+		return roles[username], nil
+	}
+}
+
+//Generates a CheckIfExists mock function for users, which returns true only for the given usernames
+func usersExistGenerator(usernames ...string) func(string) (bool, error) {
+	return func(username string) (bool, error) {
+		for _, u := range usernames {
+			if u == username {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+func TestHandleHosts(t *testing.T) {
+	var es mock.EventService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleViewer, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	hosts := []checkin.Host{
+		{Username: "owner_person", Role: checkin.RoleOwner},
+		{Username: "testing_username", Role: checkin.RoleViewer},
+	}
+	hostsGenerator := func(err error) func(string) ([]checkin.Host, error) {
+		return func(eventID string) ([]checkin.Host, error) {
+			test.Equals(t, "300", eventID)
+			if err != nil {
+				return nil, err
+			}
+			return hosts, nil
+		}
+	}
+	es.HostsFn = hostsGenerator(nil)
+
+	//test normal functionality, which every host can see
+	r := httptest.NewRequest("GET", "/api/v1-4/events/300/hosts", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	var fetched []checkin.Host
+	json.NewDecoder(w.Result().Body).Decode(&fetched)
+	test.Equals(t, hosts, fetched)
+
+	//test error fetching hosts
+	es.HostsFn = hostsGenerator(errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	es.HostsFn = hostsGenerator(nil)
+
+	//access restriction tests
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	adminAccessTest(t, r, h, &auth, func(r *http.Response) {
+		test.Equals(t, http.StatusOK, r.StatusCode)
+	})
+	noValidTokenTest(t, r, h, &auth)
+	r = httptest.NewRequest("GET", "/api/v1-4/events/200/hosts", nil)
+	eventDoesNotExistTest(t, r, h, &es)
+}
+
+func TestHandleAddHost(t *testing.T) {
+	var es mock.EventService
+	var us mock.UserService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &us, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
+	es.HostRoleFn = hostRolesGenerator("300", map[string]string{
+		"testing_username": checkin.RoleOwner,
+		"co_host":          checkin.RoleCoHost,
+	})
+	us.CheckIfExistsFn = usersExistGenerator("testing_username", "co_host", "new_host")
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	addHostGenerator := func(expectedRole string, err error) func(string, string, string) error {
+		return func(eventID string, username string, role string) error {
+			test.Equals(t, "300", eventID)
+			test.Equals(t, "new_host", username)
+			test.Equals(t, expectedRole, role)
+			return err
+		}
+	}
+	es.AddHostFn = addHostGenerator(checkin.RoleUsher, nil)
+	addHost := func(body string) *http.Response {
+		r := httptest.NewRequest("POST", "/api/v1-4/events/300/hosts", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	//test normal functionality
+	res := addHost(`{"username":"new_host","role":"usher"}`)
+	test.Equals(t, http.StatusCreated, res.StatusCode)
+	test.Assert(t, es.AddHostInvoked, "Host not added")
+
+	//test hosts cannot be added as owners, or with roles which do not exist
+	es.AddHostInvoked = false
+	res = addHost(`{"username":"new_host","role":"owner"}`)
+	test.Equals(t, http.StatusBadRequest, res.StatusCode)
+	res = addHost(`{"username":"new_host","role":"admin"}`)
+	test.Equals(t, http.StatusBadRequest, res.StatusCode)
+	res = addHost(`{"username":"new_host"}`)
+	test.Equals(t, http.StatusBadRequest, res.StatusCode)
+	res = addHost(`{"role":"usher"}`)
+	test.Equals(t, http.StatusBadRequest, res.StatusCode)
+	res = addHost(`{"username":"new_host","role":"usher","extra":"field"}`)
+	test.Equals(t, http.StatusBadRequest, res.StatusCode)
+	test.Assert(t, !es.AddHostInvoked, "Host added with invalid fields")
+
+	//test user does not exist
+	res = addHost(`{"username":"nobody","role":"usher"}`)
+	test.Equals(t, http.StatusNotFound, res.StatusCode)
+
+	//test user is already a host
+	res = addHost(`{"username":"co_host","role":"usher"}`)
+	test.Equals(t, http.StatusConflict, res.StatusCode)
+	test.Assert(t, !es.AddHostInvoked, "Host added twice")
+
+	//test errors
+	us.CheckIfExistsFn = func(username string) (bool, error) {
+		return false, errors.New("An error")
+	}
+	res = addHost(`{"username":"new_host","role":"usher"}`)
+	test.Equals(t, http.StatusInternalServerError, res.StatusCode)
+	us.CheckIfExistsFn = usersExistGenerator("testing_username", "co_host", "new_host")
+	es.AddHostFn = addHostGenerator(checkin.RoleViewer, errors.New("An error"))
+	res = addHost(`{"username":"new_host","role":"viewer"}`)
+	test.Equals(t, http.StatusInternalServerError, res.StatusCode)
+	es.AddHostFn = addHostGenerator(checkin.RoleUsher, nil)
+
+	//test only owners can add hosts
+	es.HostRoleFn = hostRolesGenerator("300", map[string]string{"testing_username": checkin.RoleCoHost})
+	res = addHost(`{"username":"new_host","role":"usher"}`)
+	test.Equals(t, http.StatusForbidden, res.StatusCode)
+
+	//access restriction tests
+	r := httptest.NewRequest("POST", "/api/v1-4/events/300/hosts", strings.NewReader(`{"username":"new_host","role":"usher"}`))
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	adminAccessTest(t, httptest.NewRequest("POST", "/api/v1-4/events/300/hosts", strings.NewReader(`{"username":"new_host","role":"usher"}`)),
+		h, &auth, func(r *http.Response) {
+			test.Equals(t, http.StatusCreated, r.StatusCode)
+		})
+	noValidTokenTest(t, r, h, &auth)
+	r = httptest.NewRequest("POST", "/api/v1-4/events/200/hosts", strings.NewReader(`{"username":"new_host","role":"usher"}`))
+	eventDoesNotExistTest(t, r, h, &es)
+}
+
+func TestHandleRemoveHost(t *testing.T) {
+	var es mock.EventService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
+	roles := map[string]string{
+		"testing_username": checkin.RoleOwner,
+		"usher_person":     checkin.RoleUsher,
+	}
+	es.HostRoleFn = hostRolesGenerator("300", roles)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	removeHostGenerator := func(err error) func(string, string) error {
+		return func(eventID string, username string) error {
+			test.Equals(t, "300", eventID)
+			test.Equals(t, "usher_person", username)
+			return err
+		}
+	}
+	es.RemoveHostFn = removeHostGenerator(nil)
+
+	//test normal functionality
+	r := httptest.NewRequest("DELETE", "/api/v1-4/events/300/hosts/usher_person", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Assert(t, es.RemoveHostInvoked, "Host not removed")
+
+	//test owner cannot be removed, so the event is never left without hosts
+	es.RemoveHostInvoked = false
+	r = httptest.NewRequest("DELETE", "/api/v1-4/events/300/hosts/testing_username", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusConflict, w.Result().StatusCode)
+	test.Assert(t, !es.RemoveHostInvoked, "Owner removed from event")
+
+	//test user is not a host
+	r = httptest.NewRequest("DELETE", "/api/v1-4/events/300/hosts/nobody", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusNotFound, w.Result().StatusCode)
+
+	//test error removing host
+	es.RemoveHostFn = removeHostGenerator(errors.New("An error"))
+	r = httptest.NewRequest("DELETE", "/api/v1-4/events/300/hosts/usher_person", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	es.RemoveHostFn = removeHostGenerator(nil)
+
+	//test only owners can remove hosts
+	roles["testing_username"] = checkin.RoleCoHost
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusForbidden, w.Result().StatusCode)
+	roles["testing_username"] = checkin.RoleOwner
+
+	//access restriction tests
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	adminAccessTest(t, r, h, &auth, func(r *http.Response) {
+		test.Equals(t, http.StatusOK, r.StatusCode)
+	})
+	noValidTokenTest(t, r, h, &auth)
+	r = httptest.NewRequest("DELETE", "/api/v1-4/events/200/hosts/usher_person", nil)
+	eventDoesNotExistTest(t, r, h, &es)
+}
+
+func TestHandleTransferOwnership(t *testing.T) {
+	var es mock.EventService
+	var us mock.UserService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &us, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleOwner, nil)
+	us.CheckIfExistsFn = usersExistGenerator("testing_username", "new_owner")
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	transferOwnershipGenerator := func(err error) func(string, string) error {
+		return func(eventID string, username string) error {
+			test.Equals(t, "300", eventID)
+			test.Equals(t, "new_owner", username)
+			return err
+		}
+	}
+	es.TransferOwnershipFn = transferOwnershipGenerator(nil)
+	transfer := func(body string) *http.Response {
+		r := httptest.NewRequest("PUT", "/api/v1-4/events/300/hosts/owner", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	//test normal functionality
+	res := transfer(`{"username":"new_owner"}`)
+	test.Equals(t, http.StatusOK, res.StatusCode)
+	test.Assert(t, es.TransferOwnershipInvoked, "Ownership not transferred")
+
+	//test badly formatted requests
+	es.TransferOwnershipInvoked = false
+	res = transfer(`{}`)
+	test.Equals(t, http.StatusBadRequest, res.StatusCode)
+	res = transfer(`{"username":"new_owner","role":"owner"}`)
+	test.Equals(t, http.StatusBadRequest, res.StatusCode)
+	res = transfer(``)
+	test.Equals(t, http.StatusBadRequest, res.StatusCode)
+
+	//test new owner does not exist
+	res = transfer(`{"username":"nobody"}`)
+	test.Equals(t, http.StatusNotFound, res.StatusCode)
+	test.Assert(t, !es.TransferOwnershipInvoked, "Ownership transferred with invalid request")
+
+	//test error transferring ownership
+	es.TransferOwnershipFn = transferOwnershipGenerator(errors.New("An error"))
+	res = transfer(`{"username":"new_owner"}`)
+	test.Equals(t, http.StatusInternalServerError, res.StatusCode)
+	es.TransferOwnershipFn = transferOwnershipGenerator(nil)
+
+	//test only owners can transfer ownership
+	r := httptest.NewRequest("PUT", "/api/v1-4/events/300/hosts/owner", strings.NewReader(`{"username":"new_owner"}`))
+	roleAccessTest(t, r, h, &es, "testing_username", "300", []string{checkin.RoleOwner}, func(r *http.Response) {
+		test.Equals(t, http.StatusOK, r.StatusCode)
+	})
+
+	//access restriction tests
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+	adminAccessTest(t, httptest.NewRequest("PUT", "/api/v1-4/events/300/hosts/owner", strings.NewReader(`{"username":"new_owner"}`)),
+		h, &auth, func(r *http.Response) {
+			test.Equals(t, http.StatusOK, r.StatusCode)
+		})
+	r = httptest.NewRequest("PUT", "/api/v1-4/events/200/hosts/owner", strings.NewReader(`{"username":"new_owner"}`))
+	eventDoesNotExistTest(t, r, h, &es)
+}
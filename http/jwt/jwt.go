@@ -2,6 +2,8 @@ package jwt
 
 import (
 	"checkin"
+	"crypto/rand"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,35 +13,58 @@ import (
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
 )
 
 //Authenticator is an implementation of http.Authenticator which uses java web tokens
 //for authentication
+//Short lived access tokens are issued along with long lived refresh tokens, which are stored
+//in the TokenService and can each be exchanged once for a new pair of tokens
 type Authenticator struct {
-	SigningKey []byte
-	ExpiryTime time.Duration
+	SigningKey        []byte
+	ExpiryTime        time.Duration
+	RefreshExpiryTime time.Duration
+	TokenService      checkin.TokenService
 }
 
 const (
 	jwtUsername    = "username"
 	jwtExpiryTime  = "exp"
 	jwtAdminStatus = "admin"
+	jwtTokenID     = "jti"
+	jwtIssuedAt    = "iat"
 )
 
+//refreshTokenBytes is the number of random bytes in a refresh token
+const refreshTokenBytes = 32
+
 //Authenticate returns true if the request has a valid (non-expired) token
-//Returns false if the token is expired or otherwise invalid
-//Returns false and error if the token isn't even formatted correctly
+//Returns false if the token is expired, revoked, issued before its user was last updated, or otherwise invalid
+//Returns false and error if the token isn't even formatted correctly, or it could not be checked for revocation
 func (jwta Authenticator) Authenticate(r *http.Request) (bool, error) {
 	jwtString, err := getJWTString(r)
 	if err != nil {
 		return false, errors.New("Error in authenticate: " + err.Error())
 	}
 
-	_, err = jwt.Parse(jwtString, jwta.keyGetter)
+	token, err := jwt.Parse(jwtString, jwta.keyGetter)
 	if err != nil { //err is non-nil if token is invalid
 		return false, nil
 	}
-	return true, nil
+	claims, _ := token.Claims.(jwt.MapClaims)
+	tokenID, ok := claims[jwtTokenID].(string)
+	issuedAt, hasIssuedAt := claims[jwtIssuedAt].(float64)
+	username, hasUsername := claims[jwtUsername].(string)
+	isAdmin, hasAdminStatus := claims[jwtAdminStatus].(bool)
+	if !ok || !hasIssuedAt || !hasUsername || !hasAdminStatus { //issued before tokens could be revoked
+		return false, nil
+	}
+	valid, err := jwta.TokenService.AccessTokenValid(tokenID,
+		checkin.AuthorizationInfo{Username: username, IsAdmin: isAdmin}, time.Unix(int64(issuedAt), 0))
+	if err != nil {
+		return false, errors.New("Error checking if token was revoked: " + err.Error())
+	}
+	return valid, nil
 }
 
 //GetAuthInfo From a web token, return the authorization info
@@ -61,26 +86,81 @@ func (jwta Authenticator) GetAuthInfo(r *http.Request) (checkin.AuthorizationInf
 }
 
 //IssueAuthorization Writes a response using the given ResponseWriter containing authorization info
-//For the recipient
+//For the recipient, as an access token and a refresh token
 func (jwta Authenticator) IssueAuthorization(au checkin.AuthorizationInfo, w http.ResponseWriter) error {
 	jwt, err := jwta.createToken(au)
 	if err != nil {
 		return errors.New("Error creating token: " + err.Error())
 	}
-	reply, _ := json.Marshal(map[string]string{"accessToken": jwt})
+	refreshToken, err := createRefreshToken()
+	if err != nil {
+		return errors.New("Error creating refresh token: " + err.Error())
+	}
+	err = jwta.TokenService.AddRefreshToken(refreshToken, au, time.Now().Add(jwta.RefreshExpiryTime))
+	if err != nil {
+		return errors.New("Error storing refresh token: " + err.Error())
+	}
+	reply, _ := json.Marshal(map[string]string{"accessToken": jwt, "refreshToken": refreshToken})
 	w.Write(reply)
 	return nil
 }
 
+//RefreshAuthorization exchanges the refresh token for new authorization info, written like IssueAuthorization
+//The refresh token cannot be used again
+//Returns false if the refresh token is invalid, in which case nothing is written
+func (jwta Authenticator) RefreshAuthorization(refreshToken string, w http.ResponseWriter) (bool, error) {
+	au, ok, err := jwta.TokenService.UseRefreshToken(refreshToken)
+	if err != nil {
+		return false, errors.New("Error using refresh token: " + err.Error())
+	}
+	if !ok {
+		return false, nil
+	}
+	return true, jwta.IssueAuthorization(au, w)
+}
+
+//RevokeAuthorization revokes the access token of the request, along with the given refresh token
+//The refresh token may be empty, in which case only the access token is revoked
+func (jwta Authenticator) RevokeAuthorization(r *http.Request, refreshToken string) error {
+	jwtString, err := getJWTString(r)
+	if err != nil {
+		return errors.New("Error extracting token string: " + err.Error())
+	}
+	token, err := jwt.Parse(jwtString, jwta.keyGetter)
+	if err != nil {
+		return errors.New("Error parsing token: " + err.Error())
+	}
+	claims, _ := token.Claims.(jwt.MapClaims)
+	tokenID, ok := claims[jwtTokenID].(string)
+	expiryTime, hasExpiryTime := claims[jwtExpiryTime].(float64)
+	if !ok || !hasExpiryTime {
+		return errors.New("Token has no ID or expiry time")
+	}
+	err = jwta.TokenService.RevokeAccessToken(tokenID, time.Unix(int64(expiryTime), 0))
+	if err != nil {
+		return errors.New("Error revoking access token: " + err.Error())
+	}
+	if refreshToken != "" {
+		err = jwta.TokenService.RemoveRefreshToken(refreshToken)
+		if err != nil {
+			return errors.New("Error removing refresh token: " + err.Error())
+		}
+	}
+	return nil
+}
+
 //createToken Given a user (or admin's) authorization info, returns an encrypted web token string
 //Uses signing method HS256
 func (jwta Authenticator) createToken(au checkin.AuthorizationInfo) (string, error) {
 	token := jwt.New(jwt.SigningMethodHS256)
 	claims := token.Claims.(jwt.MapClaims)
 
+	now := time.Now()
 	claims[jwtUsername] = au.Username
-	claims[jwtExpiryTime] = time.Now().Add(jwta.ExpiryTime).Unix()
+	claims[jwtExpiryTime] = now.Add(jwta.ExpiryTime).Unix()
 	claims[jwtAdminStatus] = au.IsAdmin
+	claims[jwtTokenID] = uuid.New().String() //so the token can be revoked
+	claims[jwtIssuedAt] = now.Unix()
 
 	tokenSigned, err := token.SignedString(jwta.SigningKey)
 	if err != nil {
@@ -90,6 +170,15 @@ func (jwta Authenticator) createToken(au checkin.AuthorizationInfo) (string, err
 	return tokenSigned, nil
 }
 
+//createRefreshToken returns a random, URL safe refresh token string
+func createRefreshToken() (string, error) {
+	buf := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
 //keyGetter checks if the provided token follows the appropriate signing method
 //Returns an error if not
 //Returns the signing key if it does follow the appropriate method
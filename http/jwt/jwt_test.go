@@ -3,6 +3,7 @@ package jwt_test
 import (
 	"checkin"
 	myjwt "checkin/http/jwt"
+	"checkin/mock"
 	"checkin/test"
 	"encoding/json"
 	"net/http/httptest"
@@ -12,10 +13,39 @@ import (
 	jwt "github.com/dgrijalva/jwt-go"
 )
 
+//tokenService returns a mock TokenService which keeps its tokens in memory
+func tokenService() *mock.TokenService {
+	refreshTokens := make(map[string]checkin.AuthorizationInfo)
+	revoked := make(map[string]bool)
+	return &mock.TokenService{
+		AddRefreshTokenFn: func(token string, au checkin.AuthorizationInfo, expiresAt time.Time) error {
+			refreshTokens[token] = au
+			return nil
+		},
+		UseRefreshTokenFn: func(token string) (checkin.AuthorizationInfo, bool, error) {
+			au, ok := refreshTokens[token]
+			delete(refreshTokens, token)
+			return au, ok, nil
+		},
+		RemoveRefreshTokenFn: func(token string) error {
+			delete(refreshTokens, token)
+			return nil
+		},
+		RevokeAccessTokenFn: func(tokenID string, expiresAt time.Time) error {
+			revoked[tokenID] = true
+			return nil
+		},
+		AccessTokenValidFn: func(tokenID string, au checkin.AuthorizationInfo, issuedAt time.Time) (bool, error) {
+			return !revoked[tokenID], nil
+		},
+	}
+}
+
 func TestValidJWT(t *testing.T) {
 	jwta := myjwt.Authenticator{
-		SigningKey: []byte("SomePassword"),
-		ExpiryTime: time.Hour,
+		SigningKey:   []byte("SomePassword"),
+		ExpiryTime:   time.Hour,
+		TokenService: tokenService(),
 	}
 	w := httptest.NewRecorder()
 	authInfo := checkin.AuthorizationInfo{
@@ -54,8 +84,9 @@ func TestValidJWT(t *testing.T) {
 
 	//an empty string JWT should still work
 	jwta = myjwt.Authenticator{
-		SigningKey: []byte(""),
-		ExpiryTime: time.Hour,
+		SigningKey:   []byte(""),
+		ExpiryTime:   time.Hour,
+		TokenService: tokenService(),
 	}
 	w = httptest.NewRecorder()
 	err = jwta.IssueAuthorization(authInfo, w)
@@ -74,8 +105,9 @@ func TestValidJWT(t *testing.T) {
 	//how are empty nil signing keys dealt with
 	//pls no panic
 	jwta = myjwt.Authenticator{
-		SigningKey: nil,
-		ExpiryTime: time.Hour,
+		SigningKey:   nil,
+		ExpiryTime:   time.Hour,
+		TokenService: tokenService(),
 	}
 	w = httptest.NewRecorder()
 	authInfo = checkin.AuthorizationInfo{
@@ -98,8 +130,9 @@ func TestValidJWT(t *testing.T) {
 
 func TestExpiredJWT(t *testing.T) {
 	jwta := myjwt.Authenticator{
-		SigningKey: []byte("SomePassword"),
-		ExpiryTime: -1 * time.Hour,
+		SigningKey:   []byte("SomePassword"),
+		ExpiryTime:   -1 * time.Hour,
+		TokenService: tokenService(),
 	}
 	w := httptest.NewRecorder()
 	authInfo := checkin.AuthorizationInfo{
@@ -177,6 +210,83 @@ func TestBadFormatJWT(t *testing.T) {
 
 }
 
+func TestRefreshAndRevokeJWT(t *testing.T) {
+	ts := tokenService()
+	jwta := myjwt.Authenticator{
+		SigningKey:        []byte("SomePassword"),
+		ExpiryTime:        time.Hour,
+		RefreshExpiryTime: 24 * time.Hour,
+		TokenService:      ts,
+	}
+	authInfo := checkin.AuthorizationInfo{Username: "Jim", IsAdmin: true}
+	var refreshExpiry time.Time
+	addRefreshToken := ts.AddRefreshTokenFn
+	ts.AddRefreshTokenFn = func(token string, au checkin.AuthorizationInfo, expiresAt time.Time) error {
+		refreshExpiry = expiresAt
+		return addRefreshToken(token, au, expiresAt)
+	}
+	w := httptest.NewRecorder()
+	err := jwta.IssueAuthorization(authInfo, w)
+	test.Ok(t, err)
+	var reply map[string]string
+	json.NewDecoder(w.Result().Body).Decode(&reply)
+	test.Assert(t, reply["refreshToken"] != "", "No refresh token issued")
+	test.Assert(t, refreshExpiry.After(time.Now().Add(23*time.Hour)), "Refresh token does not expire after the refresh expiry time")
+
+	//exchanging the refresh token gives new, valid tokens, and the old one cannot be used again
+	w = httptest.NewRecorder()
+	ok, err := jwta.RefreshAuthorization(reply["refreshToken"], w)
+	test.Ok(t, err)
+	test.Equals(t, true, ok)
+	ok, err = jwta.RefreshAuthorization(reply["refreshToken"], httptest.NewRecorder())
+	test.Ok(t, err)
+	test.Equals(t, false, ok)
+	var refreshed map[string]string
+	json.NewDecoder(w.Result().Body).Decode(&refreshed)
+	test.Assert(t, refreshed["refreshToken"] != reply["refreshToken"], "Same refresh token issued again")
+	r := httptest.NewRequest("POST", "/hello/some/url", nil)
+	r.Header.Set("Authorization", "Bearer "+refreshed["accessToken"])
+	auth, err := jwta.Authenticate(r)
+	test.Ok(t, err)
+	test.Assert(t, auth, "Expected successful authentication, did not get it")
+	ai, err := jwta.GetAuthInfo(r)
+	test.Ok(t, err)
+	test.Equals(t, authInfo, ai)
+
+	//invalid refresh tokens give nothing
+	w = httptest.NewRecorder()
+	ok, err = jwta.RefreshAuthorization("notatoken", w)
+	test.Ok(t, err)
+	test.Equals(t, false, ok)
+	test.Equals(t, 0, w.Body.Len())
+
+	//revoking the access token stops it from authenticating, and removes the refresh token
+	var removedToken string
+	ts.RemoveRefreshTokenFn = func(token string) error {
+		removedToken = token
+		return nil
+	}
+	err = jwta.RevokeAuthorization(r, refreshed["refreshToken"])
+	test.Ok(t, err)
+	test.Equals(t, refreshed["refreshToken"], removedToken)
+	auth, err = jwta.Authenticate(r)
+	test.Ok(t, err)
+	test.Assert(t, !auth, "Expected unsuccessful authentication with revoked token, was successful")
+
+	//tokens without an ID (issued before tokens could be revoked) are rejected
+	token := jwt.New(jwt.SigningMethodHS256)
+	claims := token.Claims.(jwt.MapClaims)
+	claims["username"] = "Jim"
+	claims["exp"] = time.Now().Add(time.Hour).Unix()
+	claims["admin"] = false
+	tokenSigned, err := token.SignedString(jwta.SigningKey)
+	test.Ok(t, err)
+	r.Header.Set("Authorization", "Bearer "+tokenSigned)
+	auth, err = jwta.Authenticate(r)
+	test.Ok(t, err)
+	test.Assert(t, !auth, "Expected unsuccessful authentication with token without ID, was successful")
+}
+
 func TestBadJWTAuthenticator(t *testing.T) {
 
 }
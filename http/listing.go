@@ -0,0 +1,55 @@
+package http
+
+import (
+	"checkin"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//TotalCountHeader is the response header which listings use to give the total number of items
+//across all pages, as the body only contains the requested page
+const TotalCountHeader = "X-Total-Count"
+
+//parseListOptions reads the listing options from the query parameters of a request:
+//?offset and ?limit (non-negative integers) for pagination, ?sort (one of sortKeys) and
+//?order (asc or desc) for ordering, and ?search for a case insensitive name prefix
+//All of them are optional
+func parseListOptions(r *http.Request, sortKeys ...string) (checkin.ListOptions, error) {
+	var opts checkin.ListOptions
+	var err error
+	if offset := r.FormValue("offset"); offset != "" {
+		opts.Offset, err = strconv.Atoi(offset)
+		if err != nil {
+			return opts, errors.New("Form value 'offset' must be an integer")
+		}
+	}
+	if limit := r.FormValue("limit"); limit != "" {
+		opts.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			return opts, errors.New("Form value 'limit' must be an integer")
+		}
+	}
+	switch strings.ToLower(r.FormValue("order")) {
+	case "", "asc":
+		opts.Descending = false
+	case "desc":
+		opts.Descending = true
+	default:
+		return opts, errors.New("Form value 'order' must be either asc or desc")
+	}
+	opts.SortBy = r.FormValue("sort")
+	opts.Search = r.FormValue("search")
+
+	if !opts.IsValid(sortKeys...) {
+		return opts, errors.New("Form values 'offset' and 'limit' cannot be negative, and 'sort' must be one of: " +
+			strings.Join(sortKeys, ", "))
+	}
+	return opts, nil
+}
+
+//writeTotalCount sets the total count header of a listing. Must be called before the body is written
+func writeTotalCount(total int, w http.ResponseWriter) {
+	w.Header().Set(TotalCountHeader, strconv.Itoa(total))
+}
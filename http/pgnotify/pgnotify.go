@@ -0,0 +1,307 @@
+package pgnotify
+
+import (
+	"checkin"
+	myhttp "checkin/http"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+//Channel is the postgres notification channel messages to guests are relayed on
+const Channel = "guestmessages"
+
+//notification is the payload of a notification on the channel
+//Guests are identified by a keyed digest of their guest ID, as guest IDs contain NRICs
+//Messages to every guest with a prefix carry the prefix instead, which must not contain NRICs
+type notification struct {
+	GuestKey string          `json:"guestKey,omitempty"`
+	Prefix   string          `json:"prefix,omitempty"`
+	Close    bool            `json:"close"`
+	Title    string          `json:"title,omitempty"`
+	Content  json.RawMessage `json:"content,omitempty"`
+}
+
+//GuestMessenger is an implementation of http.GuestMessenger which lets several instances of the server
+//share guests. It wraps the GuestMessenger of each instance, which holds the connections to the guests
+//of that instance, and relays every Send and CloseConnection through postgres LISTEN/NOTIFY so that
+//every instance acts on its own connections
+//Which guests are connected to which instance is kept in the guestconnection table, so HasConnection
+//is true if the guest is connected to any instance
+type GuestMessenger struct {
+	Local      myhttp.GuestMessenger
+	DM         checkin.DigestMethod
+	Logger     *log.Logger
+	db         *sqlx.DB
+	listener   *pq.Listener
+	instanceID string
+	guests     map[string]string //guest keys to guest IDs of the guests connected to this instance
+	ttl        time.Duration
+	done       chan bool
+	lock       sync.RWMutex
+}
+
+//NewGuestMessenger creates a GuestMessenger around the local GuestMessenger of this instance, which listens
+//for notifications on a dedicated connection to the database at dbURL. Guest IDs are digested with dm
+//Every refreshPeriod, the instance prunes guests who have disconnected from it and marks the rest as
+//still connected. Guests not marked for 3 refresh periods (e.g. if their instance crashed) are treated as gone
+func NewGuestMessenger(local myhttp.GuestMessenger, dm checkin.DigestMethod, db *sqlx.DB, dbURL string, refreshPeriod time.Duration) (*GuestMessenger, error) {
+	gm := &GuestMessenger{
+		Local:      local,
+		DM:         dm,
+		Logger:     log.New(os.Stderr, "", log.LstdFlags),
+		db:         db,
+		instanceID: uuid.New().String(),
+		guests:     make(map[string]string),
+		ttl:        3 * refreshPeriod,
+		done:       make(chan bool),
+		lock:       sync.RWMutex{},
+	}
+	gm.listener = pq.NewListener(dbURL, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			gm.Logger.Println("Error in guest message listener: " + err.Error())
+		}
+	})
+	err := gm.listener.Listen(Channel)
+	if err != nil {
+		gm.listener.Close()
+		return nil, errors.New("Could not listen for guest messages: " + err.Error())
+	}
+
+	go gm.listen()
+	go gm.refresh(refreshPeriod)
+	return gm, nil
+}
+
+//OpenConnection opens a connection with the guest on this instance, and records that the guest is connected to it
+func (gm *GuestMessenger) OpenConnection(guestID string, w http.ResponseWriter, r *http.Request) error {
+	key, err := gm.guestKey(guestID)
+	if err != nil {
+		return err
+	}
+	err = gm.Local.OpenConnection(guestID, w, r)
+	if err != nil {
+		return err
+	}
+	gm.lock.Lock()
+	gm.guests[key] = guestID
+	gm.lock.Unlock()
+
+	//the connection is already open, so if this fails the next refresh records it instead
+	_, err = gm.db.Exec(`INSERT into guestconnection (guestKey, instanceID, lastSeen) VALUES ($1, $2, NOW() at time zone 'utc')
+	ON CONFLICT (guestKey, instanceID) DO UPDATE SET lastSeen = NOW() at time zone 'utc'`, key, gm.instanceID)
+	if err != nil {
+		gm.Logger.Println("Error recording guest connection: " + err.Error())
+	}
+	return nil
+}
+
+//Send sends the message to the guest on whichever instances they are connected to
+//Returns once the message is published, so errors sending it over the connections themselves are only logged
+func (gm *GuestMessenger) Send(guestID string, msg myhttp.GuestMessage) error {
+	content, err := json.Marshal(msg.Content)
+	if err != nil {
+		return errors.New("Error marshalling message content to JSON: " + err.Error())
+	}
+	key, err := gm.guestKey(guestID)
+	if err != nil {
+		return err
+	}
+	return gm.notify(notification{GuestKey: key, Title: msg.Title, Content: content})
+}
+
+//SendAll sends the message to every guest with the prefix, on whichever instances they are connected to
+//As with Send, errors sending it over the connections themselves are only logged
+func (gm *GuestMessenger) SendAll(prefix string, msg myhttp.GuestMessage) error {
+	content, err := json.Marshal(msg.Content)
+	if err != nil {
+		return errors.New("Error marshalling message content to JSON: " + err.Error())
+	}
+	return gm.notify(notification{Prefix: prefix, Title: msg.Title, Content: content})
+}
+
+//HasConnection returns true if the guest is connected to any instance
+func (gm *GuestMessenger) HasConnection(guestID string) bool {
+	if gm.Local.HasConnection(guestID) {
+		return true
+	}
+	key, err := gm.guestKey(guestID)
+	if err != nil {
+		gm.Logger.Println(err.Error())
+		return false
+	}
+	var exists bool
+	err = gm.db.QueryRow(`SELECT EXISTS (SELECT 1 from guestconnection where guestKey = $1
+	and lastSeen > (NOW() at time zone 'utc') - $2::float8 * interval '1 millisecond')`, key, gm.ttl.Milliseconds()).Scan(&exists)
+	if err != nil {
+		gm.Logger.Println("Error checking for guest connection: " + err.Error())
+		return false
+	}
+	return exists
+}
+
+//CloseConnection closes the connections with the guest on every instance
+func (gm *GuestMessenger) CloseConnection(guestID string) error {
+	if !gm.HasConnection(guestID) {
+		return errors.New("No such connection with that guest ID exists")
+	}
+	key, err := gm.guestKey(guestID)
+	if err != nil {
+		return err
+	}
+	return gm.notify(notification{GuestKey: key, Close: true})
+}
+
+//Close stops relaying messages to the guests of this instance, and removes them from the guestconnection table
+//The local GuestMessenger is left as is
+func (gm *GuestMessenger) Close() error {
+	close(gm.done)
+	err := gm.listener.Close()
+	if err != nil {
+		return errors.New("Could not close guest message listener: " + err.Error())
+	}
+	_, err = gm.db.Exec("DELETE from guestconnection where instanceID = $1", gm.instanceID)
+	if err != nil {
+		return errors.New("Could not remove guest connections: " + err.Error())
+	}
+	return nil
+}
+
+func (gm *GuestMessenger) notify(n notification) error {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return errors.New("Error marshalling guest message notification: " + err.Error())
+	}
+	_, err = gm.db.Exec("SELECT pg_notify($1, $2)", Channel, string(payload))
+	if err != nil {
+		return errors.New("Could not publish guest message: " + err.Error())
+	}
+	return nil
+}
+
+//listen delivers notifications to the guests of this instance until the GuestMessenger is closed
+func (gm *GuestMessenger) listen() {
+	for {
+		select {
+		case <-gm.done:
+			return
+		case pgn, ok := <-gm.listener.Notify:
+			if !ok {
+				return
+			}
+			if pgn == nil { //connection was re-established, so notifications may have been missed
+				gm.Logger.Println("Guest message listener reconnected, some messages may have been lost")
+				continue
+			}
+			gm.deliver(pgn.Extra)
+		case <-time.After(90 * time.Second):
+			//check the connection is still alive, as a dead connection is otherwise not noticed until a notification is due
+			go gm.listener.Ping()
+		}
+	}
+}
+
+func (gm *GuestMessenger) deliver(payload string) {
+	var n notification
+	err := json.Unmarshal([]byte(payload), &n)
+	if err != nil {
+		gm.Logger.Println("Error reading guest message notification: " + err.Error())
+		return
+	}
+	if n.Prefix != "" {
+		err = gm.Local.SendAll(n.Prefix, myhttp.GuestMessage{Title: n.Title, Content: n.Content})
+		if err != nil {
+			gm.Logger.Println("Error delivering guest message: " + err.Error())
+		}
+		return
+	}
+	gm.lock.RLock()
+	guestID, ok := gm.guests[n.GuestKey]
+	gm.lock.RUnlock()
+	if !ok || !gm.Local.HasConnection(guestID) { //guest is connected to another instance
+		return
+	}
+
+	if n.Close {
+		err = gm.Local.CloseConnection(guestID)
+		gm.forget(n.GuestKey)
+	} else {
+		err = gm.Local.Send(guestID, myhttp.GuestMessage{Title: n.Title, Content: n.Content})
+	}
+	if err != nil {
+		gm.Logger.Println("Error delivering guest message: " + err.Error())
+	}
+}
+
+//forget removes a guest who is no longer connected to this instance
+func (gm *GuestMessenger) forget(key string) {
+	gm.lock.Lock()
+	delete(gm.guests, key)
+	gm.lock.Unlock()
+	_, err := gm.db.Exec("DELETE from guestconnection where guestKey = $1 and instanceID = $2", key, gm.instanceID)
+	if err != nil {
+		gm.Logger.Println("Error removing guest connection: " + err.Error())
+	}
+}
+
+//refresh periodically syncs the guestconnection table with the connections of this instance
+//until the GuestMessenger is closed
+func (gm *GuestMessenger) refresh(period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-gm.done:
+			return
+		case <-ticker.C:
+		}
+
+		var connected []string
+		var disconnected []string
+		gm.lock.Lock()
+		for key, guestID := range gm.guests {
+			if gm.Local.HasConnection(guestID) {
+				connected = append(connected, key)
+			} else {
+				disconnected = append(disconnected, key)
+				delete(gm.guests, key)
+			}
+		}
+		gm.lock.Unlock()
+
+		_, err := gm.db.Exec("DELETE from guestconnection where instanceID = $1 and guestKey = ANY($2)",
+			gm.instanceID, pq.Array(disconnected))
+		if err != nil {
+			gm.Logger.Println("Error removing guest connections: " + err.Error())
+		}
+		_, err = gm.db.Exec(`INSERT into guestconnection (guestKey, instanceID, lastSeen) SELECT unnest($1::text[]), $2, NOW() at time zone 'utc'
+		ON CONFLICT (guestKey, instanceID) DO UPDATE SET lastSeen = NOW() at time zone 'utc'`, pq.Array(connected), gm.instanceID)
+		if err != nil {
+			gm.Logger.Println("Error recording guest connections: " + err.Error())
+		}
+		//clear out guests of instances which stopped without closing
+		_, err = gm.db.Exec("DELETE from guestconnection where lastSeen < (NOW() at time zone 'utc') - $1::float8 * interval '1 millisecond'",
+			gm.ttl.Milliseconds())
+		if err != nil {
+			gm.Logger.Println("Error clearing stale guest connections: " + err.Error())
+		}
+	}
+}
+
+//guestKey derives the key a guest is identified by in the database and notifications
+//The digest is keyed, so the NRICs in guest IDs cannot be recovered by digesting guesses of them
+func (gm *GuestMessenger) guestKey(guestID string) (string, error) {
+	key, err := gm.DM.Digest(guestID)
+	if err != nil {
+		return "", errors.New("Error computing guest key: " + err.Error())
+	}
+	return key, nil
+}
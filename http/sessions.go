@@ -0,0 +1,127 @@
+package http
+
+import (
+	"checkin"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/guregu/null"
+)
+
+//sessionDetails are the fields of a session which can be set when it is created or updated
+type sessionDetails struct {
+	Name  string    `json:"name"`
+	Start null.Time `json:"startDateTime"`
+	End   null.Time `json:"endDateTime"`
+}
+
+//handleSessions writes the sessions of the event given by the eventID in the URL, in order of their start
+func (h *EventHandler) handleSessions(w http.ResponseWriter, r *http.Request) {
+	sessions, err := h.EventService.Sessions(mux.Vars(r)["eventID"])
+	if err != nil {
+		h.Logger.Println("Error fetching sessions: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching sessions of event", w)
+		return
+	}
+	reply, _ := json.Marshal(sessions)
+	w.Write(reply)
+}
+
+//handleCreateSession adds the session in the body of the request to the event given by the eventID in the URL,
+//and writes its ID
+func (h *EventHandler) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	details, ok := h.decodeSession(w, r)
+	if !ok {
+		return
+	}
+	session := checkin.Session{
+		ID:      uuid.New().String(),
+		EventID: mux.Vars(r)["eventID"],
+		Name:    details.Name,
+		Start:   details.Start,
+		End:     details.End,
+	}
+	err := h.EventService.CreateSession(session)
+	if err != nil {
+		h.Logger.Println("Error creating session: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error creating session", w)
+		return
+	}
+	reply, _ := json.Marshal(struct {
+		ID string `json:"sessionId"`
+	}{session.ID})
+	w.WriteHeader(http.StatusCreated)
+	w.Write(reply)
+}
+
+//handleUpdateSession replaces the name, start and end of the session given by the sessionID in the URL
+//with those in the body of the request
+func (h *EventHandler) handleUpdateSession(w http.ResponseWriter, r *http.Request) {
+	eventID, sessionID := mux.Vars(r)["eventID"], mux.Vars(r)["sessionID"]
+	if _, exists, err := h.EventService.Session(eventID, sessionID); err != nil {
+		h.Logger.Println("Error checking if session exists: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error checking if session exists", w)
+		return
+	} else if !exists {
+		WriteMessage(http.StatusNotFound, "No such session for this event", w)
+		return
+	}
+	details, ok := h.decodeSession(w, r)
+	if !ok {
+		return
+	}
+	err := h.EventService.UpdateSession(checkin.Session{
+		ID:      sessionID,
+		EventID: eventID,
+		Name:    details.Name,
+		Start:   details.Start,
+		End:     details.End,
+	})
+	if err != nil {
+		h.Logger.Println("Error updating session: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error updating session", w)
+		return
+	}
+	WriteOKMessage("Session updated", w)
+}
+
+//handleDeleteSession deletes the session given by the sessionID in the URL from the event given by the eventID,
+//along with the attendance of guests at it
+func (h *EventHandler) handleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	exists, err := h.EventService.DeleteSession(mux.Vars(r)["eventID"], mux.Vars(r)["sessionID"])
+	if err != nil {
+		h.Logger.Println("Error deleting session: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error deleting session", w)
+		return
+	} else if !exists {
+		WriteMessage(http.StatusNotFound, "No such session for this event", w)
+		return
+	}
+	WriteOKMessage("Session deleted", w)
+}
+
+//decodeSession decodes and validates the details of a session in the body of the request
+//Sessions need a name which is not too long, and cannot end before they start
+//If they are not valid, an error is written and false returned
+func (h *EventHandler) decodeSession(w http.ResponseWriter, r *http.Request) (sessionDetails, bool) {
+	var details sessionDetails
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&details)
+	if err != nil {
+		h.Logger.Println("Error decoding session JSON: " + err.Error())
+		WriteMessage(http.StatusBadRequest, "Badly formatted JSON in session (Possibly invalid fields or time format)", w)
+		return sessionDetails{}, false
+	}
+	if details.Name == "" || len(details.Name) > h.MaxLengthName {
+		WriteMessage(http.StatusBadRequest, "Session needs a name which is not too long", w)
+		return sessionDetails{}, false
+	}
+	if details.Start.Valid && details.End.Valid && details.End.Time.Before(details.Start.Time) {
+		WriteMessage(http.StatusBadRequest, "Session cannot end before it starts", w)
+		return sessionDetails{}, false
+	}
+	return details, true
+}
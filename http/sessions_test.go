@@ -0,0 +1,260 @@
+package http_test
+
+import (
+	"checkin"
+	myhttp "checkin/http"
+	"checkin/mock"
+	"checkin/test"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+)
+
+func TestHandleSessions(t *testing.T) {
+	var es mock.EventService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
+	es.EventFn = eventTimezoneGenerator("300", "")
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleViewer, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	sessions := []checkin.Session{
+		{ID: "1", EventID: "300", Name: "Day 1", Start: null.TimeFrom(time.Date(2019, 3, 1, 9, 0, 0, 0, time.UTC)),
+			End: null.TimeFrom(time.Date(2019, 3, 1, 17, 0, 0, 0, time.UTC))},
+		{ID: "2", EventID: "300", Name: "Day 2", Start: null.TimeFrom(time.Date(2019, 3, 2, 9, 0, 0, 0, time.UTC))},
+	}
+	sessionsGenerator := func(err error) func(string) ([]checkin.Session, error) {
+		return func(eventID string) ([]checkin.Session, error) {
+			test.Equals(t, "300", eventID)
+			return sessions, err
+		}
+	}
+	es.SessionsFn = sessionsGenerator(nil)
+
+	//test normal functionality
+	r := httptest.NewRequest("GET", "/api/v1-4/events/300/sessions", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	var fetched []checkin.Session
+	err := json.NewDecoder(w.Result().Body).Decode(&fetched)
+	test.Ok(t, err)
+	test.Equals(t, sessions, fetched)
+
+	//test error fetching sessions
+	es.SessionsFn = sessionsGenerator(errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	es.SessionsFn = sessionsGenerator(nil)
+
+	//access restriction tests
+	roleAccessTest(t, r, h, &es, "testing_username", "300",
+		[]string{checkin.RoleOwner, checkin.RoleCoHost, checkin.RoleUsher, checkin.RoleViewer},
+		func(r *http.Response) {
+			test.Equals(t, http.StatusOK, r.StatusCode)
+		})
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/sessions", nil)
+	eventDoesNotExistTest(t, r, h, &es)
+}
+
+func TestHandleCreateSession(t *testing.T) {
+	var es mock.EventService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 10, 64, 64)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
+	es.EventFn = eventTimezoneGenerator("300", "")
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleCoHost, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	var created checkin.Session
+	createSessionGenerator := func(err error) func(checkin.Session) error {
+		return func(s checkin.Session) error {
+			created = s
+			return err
+		}
+	}
+	es.CreateSessionFn = createSessionGenerator(nil)
+	createSession := func(body string) *http.Response {
+		r := httptest.NewRequest("POST", "/api/v1-4/events/300/sessions?loc=Asia/Singapore", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	//test normal functionality, with times given in the location of the event
+	res := createSession(`{"name":"Day 1","startDateTime":"2019-03-01T09:00:00Z","endDateTime":"2019-03-01T17:00:00Z"}`)
+	test.Equals(t, http.StatusCreated, res.StatusCode)
+	var reply struct {
+		ID string `json:"sessionId"`
+	}
+	err := json.NewDecoder(res.Body).Decode(&reply)
+	test.Ok(t, err)
+	test.Equals(t, created.ID, reply.ID)
+	test.Equals(t, "300", created.EventID)
+	test.Equals(t, "Day 1", created.Name)
+	test.Assert(t, created.Start.Time.Equal(time.Date(2019, 3, 1, 1, 0, 0, 0, time.UTC)), "Start not in location of event")
+	test.Assert(t, created.End.Time.Equal(time.Date(2019, 3, 1, 9, 0, 0, 0, time.UTC)), "End not in location of event")
+
+	//test sessions without times
+	res = createSession(`{"name":"Day 2"}`)
+	test.Equals(t, http.StatusCreated, res.StatusCode)
+	test.Assert(t, !created.Start.Valid && !created.End.Valid, "Session given times")
+
+	//test invalid sessions
+	es.CreateSessionInvoked = false
+	for _, body := range []string{
+		`{"name":""}`,
+		`{"name":"Day 1 of the course"}`,
+		`{"name":"Day 1","startDateTime":"2019-03-01T09:00:00Z","endDateTime":"2019-03-01T08:00:00Z"}`,
+		`{"name":"Day 1","eventId":"200"}`,
+		`not json`,
+	} {
+		res = createSession(body)
+		test.Equals(t, http.StatusBadRequest, res.StatusCode)
+	}
+	test.Assert(t, !es.CreateSessionInvoked, "Invalid session created")
+
+	//test error creating session
+	es.CreateSessionFn = createSessionGenerator(errors.New("An error"))
+	res = createSession(`{"name":"Day 1"}`)
+	test.Equals(t, http.StatusInternalServerError, res.StatusCode)
+	es.CreateSessionFn = createSessionGenerator(nil)
+
+	//access restriction tests
+	r := httptest.NewRequest("POST", "/api/v1-4/events/300/sessions", strings.NewReader(`{"name":"Day 1"}`))
+	roleAccessTest(t, r, h, &es, "testing_username", "300", []string{checkin.RoleOwner, checkin.RoleCoHost},
+		func(r *http.Response) {
+			test.Assert(t, r.StatusCode != http.StatusForbidden, "Host forbidden from creating session")
+		})
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+	r = httptest.NewRequest("POST", "/api/v1-4/events/100/sessions", strings.NewReader(`{"name":"Day 1"}`))
+	eventDoesNotExistTest(t, r, h, &es)
+}
+
+func TestHandleUpdateSession(t *testing.T) {
+	var es mock.EventService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
+	es.EventFn = eventTimezoneGenerator("300", "")
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleOwner, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	es.SessionFn = func(eventID string, sessionID string) (checkin.Session, bool, error) {
+		test.Equals(t, "300", eventID)
+		return checkin.Session{ID: sessionID, EventID: eventID, Name: "Day 1"}, sessionID == "1", nil
+	}
+	var updated checkin.Session
+	updateSessionGenerator := func(err error) func(checkin.Session) error {
+		return func(s checkin.Session) error {
+			updated = s
+			return err
+		}
+	}
+	es.UpdateSessionFn = updateSessionGenerator(nil)
+	updateSession := func(sessionID string, body string) *http.Response {
+		r := httptest.NewRequest("PUT", "/api/v1-4/events/300/sessions/"+sessionID, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	//test normal functionality
+	res := updateSession("1", `{"name":"First day","startDateTime":"2019-03-01T09:00:00Z"}`)
+	test.Equals(t, http.StatusOK, res.StatusCode)
+	test.Equals(t, "1", updated.ID)
+	test.Equals(t, "300", updated.EventID)
+	test.Equals(t, "First day", updated.Name)
+	test.Assert(t, updated.Start.Time.Equal(time.Date(2019, 3, 1, 9, 0, 0, 0, time.UTC)), "Start not updated")
+	test.Assert(t, !updated.End.Valid, "End not cleared")
+
+	//test invalid session and session does not exist
+	es.UpdateSessionInvoked = false
+	res = updateSession("1", `{"name":""}`)
+	test.Equals(t, http.StatusBadRequest, res.StatusCode)
+	res = updateSession("2", `{"name":"Second day"}`)
+	test.Equals(t, http.StatusNotFound, res.StatusCode)
+	test.Assert(t, !es.UpdateSessionInvoked, "Invalid session updated")
+
+	//test error updating session
+	es.UpdateSessionFn = updateSessionGenerator(errors.New("An error"))
+	res = updateSession("1", `{"name":"First day"}`)
+	test.Equals(t, http.StatusInternalServerError, res.StatusCode)
+	es.UpdateSessionFn = updateSessionGenerator(nil)
+
+	//access restriction tests
+	r := httptest.NewRequest("PUT", "/api/v1-4/events/300/sessions/1", strings.NewReader(`{"name":"First day"}`))
+	roleAccessTest(t, r, h, &es, "testing_username", "300", []string{checkin.RoleOwner, checkin.RoleCoHost},
+		func(r *http.Response) {
+			test.Assert(t, r.StatusCode != http.StatusForbidden, "Host forbidden from updating session")
+		})
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+	r = httptest.NewRequest("PUT", "/api/v1-4/events/100/sessions/1", strings.NewReader(`{"name":"First day"}`))
+	eventDoesNotExistTest(t, r, h, &es)
+}
+
+func TestHandleDeleteSession(t *testing.T) {
+	var es mock.EventService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleOwner, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	deleteSessionGenerator := func(err error) func(string, string) (bool, error) {
+		return func(eventID string, sessionID string) (bool, error) {
+			test.Equals(t, "300", eventID)
+			return sessionID == "1", err
+		}
+	}
+	es.DeleteSessionFn = deleteSessionGenerator(nil)
+
+	//test normal functionality
+	r := httptest.NewRequest("DELETE", "/api/v1-4/events/300/sessions/1", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Assert(t, es.DeleteSessionInvoked, "Session not deleted")
+
+	//test session does not exist
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/v1-4/events/300/sessions/2", nil))
+	test.Equals(t, http.StatusNotFound, w.Result().StatusCode)
+
+	//test error deleting session
+	es.DeleteSessionFn = deleteSessionGenerator(errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	es.DeleteSessionFn = deleteSessionGenerator(nil)
+
+	//access restriction tests
+	roleAccessTest(t, r, h, &es, "testing_username", "300", []string{checkin.RoleOwner, checkin.RoleCoHost},
+		func(r *http.Response) {
+			test.Equals(t, http.StatusOK, r.StatusCode)
+		})
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+	r = httptest.NewRequest("DELETE", "/api/v1-4/events/100/sessions/1", nil)
+	eventDoesNotExistTest(t, r, h, &es)
+}
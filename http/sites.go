@@ -0,0 +1,118 @@
+package http
+
+import (
+	"checkin"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+//handleGuestSite writes the guest website of the event given by the eventID in the URL
+func (h *EventHandler) handleGuestSite(w http.ResponseWriter, r *http.Request) {
+	site, err := h.GuestSiteService.GuestSite(mux.Vars(r)["eventID"])
+	if err != nil {
+		h.Logger.Println("Error fetching guest site: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching guest site", w)
+		return
+	}
+	reply, _ := json.Marshal(site)
+	w.Write(reply)
+}
+
+//handleGuestSiteByURL writes the guest website of the event with the eventURL given in the URL
+//Requires no authentication, as it is meant for guests, but the event must have been released
+func (h *EventHandler) handleGuestSiteByURL(w http.ResponseWriter, r *http.Request) {
+	url := mux.Vars(r)["eventURL"]
+	exists, err := h.EventService.URLExists(url)
+	if err != nil {
+		h.Logger.Println("Error checking whether event exists with that URL: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error checking if event exists with that URL", w)
+		return
+	} else if !exists {
+		WriteMessage(http.StatusNotFound, "No event with that URL", w)
+		return
+	}
+
+	event, err := h.EventService.EventByURL(url)
+	if err != nil {
+		h.Logger.Println("Error getting event with the provided URL: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error getting event with the provided URL", w)
+		return
+	} else if event.ArchivedAt.Valid {
+		WriteMessage(http.StatusNotFound, "No event with that URL", w)
+		return
+	}
+	if !event.TimeTags["release"].Before(time.Now()) {
+		WriteMessage(http.StatusForbidden, "Event has not been released yet", w)
+		return
+	}
+
+	site, err := h.GuestSiteService.GuestSite(event.ID)
+	if err != nil {
+		h.Logger.Println("Error fetching guest site: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching guest site", w)
+		return
+	}
+	reply, _ := json.Marshal(site)
+	w.Write(reply)
+}
+
+//handleReplaceGuestSite replaces the guest website of the event given by the eventID in the URL
+//with the site in the body of the request
+func (h *EventHandler) handleReplaceGuestSite(w http.ResponseWriter, r *http.Request) {
+	var site checkin.GuestSite
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&site)
+	if err != nil {
+		h.Logger.Println("Error decoding guest site JSON: " + err.Error())
+		WriteMessage(http.StatusBadRequest, "Badly formatted JSON in guest site (Possibly invalid button type or invalid fields)", w)
+		return
+	}
+
+	h.writeGuestSite(mux.Vars(r)["eventID"], site, w)
+}
+
+//handleUpdateGuestSite updates the guest website of the event given by the eventID in the URL
+//Only the fields that need updating need to be supplied
+func (h *EventHandler) handleUpdateGuestSite(w http.ResponseWriter, r *http.Request) {
+	eventID := mux.Vars(r)["eventID"]
+	//Load original site, unmarshal JSON into it
+	//This updates only the fields that were supplied
+	site, err := h.GuestSiteService.GuestSite(eventID)
+	if err != nil {
+		h.Logger.Println("Error fetching original guest site: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Could not fetch original guest site", w)
+		return
+	}
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&site)
+	if err != nil {
+		h.Logger.Println("Error when decoding guest site update fields: " + err.Error())
+		WriteMessage(http.StatusBadRequest, "JSON could not be decoded (Possibly invalid button type or unknown fields)", w)
+		return
+	}
+
+	h.writeGuestSite(eventID, site, w)
+}
+
+//writeGuestSite validates the guest site and saves it as the site of the given event,
+//writing the outcome using the given ResponseWriter
+func (h *EventHandler) writeGuestSite(eventID string, site checkin.GuestSite, w http.ResponseWriter) {
+	if !site.IsValid() {
+		WriteMessage(http.StatusBadRequest, "Element sizes must be between 0 and 7, and buttons must be of type link or modal", w)
+		return
+	}
+
+	err := h.GuestSiteService.UpdateGuestSite(eventID, site)
+	if err != nil {
+		h.Logger.Println("Error updating guest site: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error updating guest site", w)
+	} else {
+		WriteOKMessage("Guest site updated", w)
+	}
+}
@@ -0,0 +1,266 @@
+package http_test
+
+import (
+	"checkin"
+	myhttp "checkin/http"
+	"checkin/mock"
+	"checkin/test"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testGuestSite() checkin.GuestSite {
+	return checkin.GuestSite{
+		Title:   checkin.TextElement{Content: "Parade", Size: 5},
+		Tagline: checkin.TextElement{Content: "Step off at 0800", Size: 2},
+		LogoURL: "https://logo.com/parade.png",
+		Details: []checkin.DetailsElement{{Title: "Venue", Content: "Parade Square"}},
+		Buttons: []checkin.ButtonRow{{{Size: 1, Title: "Map", Type: checkin.Link, Content: "https://maps.google.com"}}},
+	}
+}
+
+//Generates a GuestSite mock function which returns the site given if the eventID matches
+//the expectedID, failing the test otherwise
+//If err is non-nil, will return an error and an empty site
+func guestSiteGenerator(t *testing.T, expectedID string, site checkin.GuestSite, err error) func(string) (checkin.GuestSite, error) {
+	return func(eventID string) (checkin.GuestSite, error) {
+		test.Equals(t, expectedID, eventID)
+		if err != nil {
+			return checkin.GuestSite{}, err
+		}
+		return site, nil
+	}
+}
+
+//Generates an UpdateGuestSite mock function which checks that the eventID and site passed in
+//match what is expected, and returns err
+func updateGuestSiteGenerator(t *testing.T, expectedID string, expectedSite checkin.GuestSite, err error) func(string, checkin.GuestSite) error {
+	return func(eventID string, site checkin.GuestSite) error {
+		test.Equals(t, expectedID, eventID)
+		test.Equals(t, expectedSite, site)
+		return err
+	}
+}
+
+func TestHandleGuestSite(t *testing.T) {
+	var es mock.EventService
+	var ss mock.GuestSiteService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &ss, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleOwner, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	ss.GuestSiteFn = guestSiteGenerator(t, "300", testGuestSite(), nil)
+
+	//test normal functionality
+	r := httptest.NewRequest("GET", "/api/v1-4/events/300/site", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	var site checkin.GuestSite
+	err := json.NewDecoder(w.Result().Body).Decode(&site)
+	test.Ok(t, err)
+	test.Equals(t, testGuestSite(), site)
+
+	//test error fetching site
+	ss.GuestSiteFn = guestSiteGenerator(t, "300", checkin.GuestSite{}, errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	ss.GuestSiteFn = guestSiteGenerator(t, "300", testGuestSite(), nil)
+
+	//access restriction tests
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	adminAccessTest(t, r, h, &auth, func(r *http.Response) {
+		test.Equals(t, http.StatusOK, r.StatusCode)
+	})
+	noValidTokenTest(t, r, h, &auth)
+
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/site", nil)
+	eventDoesNotExistTest(t, r, h, &es)
+}
+
+func TestHandleGuestSiteByURL(t *testing.T) {
+	var es mock.EventService
+	var ss mock.GuestSiteService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &ss, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
+
+	es.URLExistsFn = urlExistsGenerator("parade", nil)
+	eventByURLGenerator := func(release time.Time, err error) func(string) (checkin.Event, error) {
+		return func(url string) (checkin.Event, error) {
+			test.Equals(t, "parade", url)
+			if err != nil {
+				return checkin.Event{}, err
+			}
+			return checkin.Event{ID: "300", TimeTags: map[string]time.Time{"release": release}}, nil
+		}
+	}
+	es.EventByURLFn = eventByURLGenerator(time.Now().Add(-time.Hour), nil)
+	ss.GuestSiteFn = guestSiteGenerator(t, "300", testGuestSite(), nil)
+
+	//test normal functionality (no authentication needed)
+	r := httptest.NewRequest("GET", "/api/v1-4/events/url/parade/site", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	var site checkin.GuestSite
+	err := json.NewDecoder(w.Result().Body).Decode(&site)
+	test.Ok(t, err)
+	test.Equals(t, testGuestSite(), site)
+	test.Equals(t, false, auth.AuthenticateInvoked)
+
+	//test event not yet released
+	ss.GuestSiteInvoked = false
+	es.EventByURLFn = eventByURLGenerator(time.Now().Add(time.Hour), nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusForbidden, w.Result().StatusCode)
+	test.Equals(t, false, ss.GuestSiteInvoked)
+	es.EventByURLFn = eventByURLGenerator(time.Now().Add(-time.Hour), nil)
+
+	//test error fetching event or site
+	es.EventByURLFn = eventByURLGenerator(time.Time{}, errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	es.EventByURLFn = eventByURLGenerator(time.Now().Add(-time.Hour), nil)
+
+	ss.GuestSiteFn = guestSiteGenerator(t, "300", checkin.GuestSite{}, errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+
+	//test no event with that URL
+	r = httptest.NewRequest("GET", "/api/v1-4/events/url/nope/site", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusNotFound, w.Result().StatusCode)
+
+	es.URLExistsFn = urlExistsGenerator("", errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+}
+
+func TestHandleReplaceGuestSite(t *testing.T) {
+	var es mock.EventService
+	var ss mock.GuestSiteService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &ss, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleOwner, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	ss.UpdateGuestSiteFn = updateGuestSiteGenerator(t, "300", testGuestSite(), nil)
+
+	siteJSON, _ := json.Marshal(testGuestSite())
+
+	//test normal functionality
+	r := httptest.NewRequest("PUT", "/api/v1-4/events/300/site", strings.NewReader(string(siteJSON)))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, true, ss.UpdateGuestSiteInvoked)
+
+	//test invalid button type
+	ss.UpdateGuestSiteInvoked = false
+	r = httptest.NewRequest("PUT", "/api/v1-4/events/300/site",
+		strings.NewReader(`{"buttons":[[{"sz":1,"title":"Map","type":"popup","cont":"Hello"}]]}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+	test.Equals(t, false, ss.UpdateGuestSiteInvoked)
+
+	//test invalid size
+	r = httptest.NewRequest("PUT", "/api/v1-4/events/300/site",
+		strings.NewReader(`{"title":{"cont":"Hello","sz":8}}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+	test.Equals(t, false, ss.UpdateGuestSiteInvoked)
+
+	//test unknown fields
+	r = httptest.NewRequest("PUT", "/api/v1-4/events/300/site",
+		strings.NewReader(`{"title":{"cont":"Hello","sz":1},"footer":"lol"}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+	test.Equals(t, false, ss.UpdateGuestSiteInvoked)
+
+	//test error updating site
+	ss.UpdateGuestSiteFn = updateGuestSiteGenerator(t, "300", testGuestSite(), errors.New("An error"))
+	r = httptest.NewRequest("PUT", "/api/v1-4/events/300/site", strings.NewReader(string(siteJSON)))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	ss.UpdateGuestSiteFn = updateGuestSiteGenerator(t, "300", testGuestSite(), nil)
+
+	//access restriction tests
+	r = httptest.NewRequest("PUT", "/api/v1-4/events/300/site", strings.NewReader(string(siteJSON)))
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+
+	r = httptest.NewRequest("PUT", "/api/v1-4/events/100/site", strings.NewReader(string(siteJSON)))
+	eventDoesNotExistTest(t, r, h, &es)
+}
+
+func TestHandleUpdateGuestSite(t *testing.T) {
+	var es mock.EventService
+	var ss mock.GuestSiteService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &ss, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleOwner, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	ss.GuestSiteFn = guestSiteGenerator(t, "300", testGuestSite(), nil)
+
+	//test only the supplied fields are updated
+	expectedSite := testGuestSite()
+	expectedSite.Title = checkin.TextElement{Content: "Graduation Parade", Size: 6}
+	expectedSite.LogoURL = ""
+	ss.UpdateGuestSiteFn = updateGuestSiteGenerator(t, "300", expectedSite, nil)
+	r := httptest.NewRequest("PATCH", "/api/v1-4/events/300/site",
+		strings.NewReader(`{"title":{"cont":"Graduation Parade","sz":6},"logo":""}`))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, true, ss.UpdateGuestSiteInvoked)
+
+	//test invalid size after update
+	ss.UpdateGuestSiteInvoked = false
+	r = httptest.NewRequest("PATCH", "/api/v1-4/events/300/site",
+		strings.NewReader(`{"tagline":{"cont":"Hi","sz":9}}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+	test.Equals(t, false, ss.UpdateGuestSiteInvoked)
+
+	//test error fetching original site
+	ss.GuestSiteFn = guestSiteGenerator(t, "300", checkin.GuestSite{}, errors.New("An error"))
+	r = httptest.NewRequest("PATCH", "/api/v1-4/events/300/site", strings.NewReader(`{"logo":""}`))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	test.Equals(t, false, ss.UpdateGuestSiteInvoked)
+	ss.GuestSiteFn = guestSiteGenerator(t, "300", testGuestSite(), nil)
+
+	//access restriction tests
+	r = httptest.NewRequest("PATCH", "/api/v1-4/events/300/site", strings.NewReader(`{"logo":""}`))
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+}
@@ -0,0 +1,138 @@
+package sse
+
+import (
+	myhttp "checkin/http"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//HostMessenger is an implementation of http.HostMessenger which streams messages to hosts
+//as Server-Sent Events. Each message is sent as an event named by its title, with its content as JSON data
+type HostMessenger struct {
+	streams         map[string]map[chan []byte]bool
+	bufferSize      int
+	heartbeatPeriod time.Duration
+	lock            sync.RWMutex
+}
+
+//NewHostMessenger creates a HostMessenger which buffers up to bufferSize messages for each host
+//Hosts who fall further behind than that are disconnected, and expected to reconnect
+//A comment is sent every heartbeatPeriod, so proxies do not close idle streams
+//(e.g. Heroku closes connections idle for 55 seconds)
+func NewHostMessenger(bufferSize int, heartbeatPeriod time.Duration) *HostMessenger {
+	return &HostMessenger{
+		streams:         make(map[string]map[chan []byte]bool),
+		bufferSize:      bufferSize,
+		heartbeatPeriod: heartbeatPeriod,
+		lock:            sync.RWMutex{},
+	}
+}
+
+//OpenStream starts an event stream to the host, given a responsewriter and request from said host,
+//and writes every message sent to the event to it until the host disconnects
+func (hm *HostMessenger) OpenStream(eventID string, w http.ResponseWriter, r *http.Request) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return errors.New("Response writer does not support streaming")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") //stop nginx-like proxies from buffering the stream
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	stream := hm.addStream(eventID)
+	defer hm.removeStream(eventID, stream)
+
+	heartbeat := time.NewTicker(hm.heartbeatPeriod)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done(): //host disconnected
+			return nil
+		case msg, ok := <-stream:
+			if !ok { //host fell too far behind, and was dropped by Send
+				return nil
+			}
+			if _, err := w.Write(msg); err != nil {
+				return nil
+			}
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return nil
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+//Send sends the message to every host streaming the event with the given ID
+//Never blocks on a slow host - any host whose buffer is full is disconnected instead
+func (hm *HostMessenger) Send(eventID string, msg myhttp.HostMessage) error {
+	content, err := json.Marshal(msg.Content)
+	if err != nil {
+		return errors.New("Error marshalling message content to JSON: " + err.Error())
+	}
+	//JSON from json.Marshal never contains a newline, so fits in a single data field
+	event := []byte("event: " + msg.Title + "\ndata: " + string(content) + "\n\n")
+
+	hm.lock.Lock()
+	defer hm.lock.Unlock()
+	streams, ok := hm.streams[eventID]
+	if !ok {
+		return errors.New("No such event ID")
+	}
+	for stream := range streams {
+		select {
+		case stream <- event:
+		default:
+			hm.closeStream(eventID, stream)
+		}
+	}
+	return nil
+}
+
+//HasStream returns true if there is at least one host streaming the event with the given ID
+func (hm *HostMessenger) HasStream(eventID string) bool {
+	hm.lock.RLock()
+	defer hm.lock.RUnlock()
+	_, ok := hm.streams[eventID]
+	return ok
+}
+
+func (hm *HostMessenger) addStream(eventID string) chan []byte {
+	hm.lock.Lock()
+	defer hm.lock.Unlock()
+	stream := make(chan []byte, hm.bufferSize)
+	if _, ok := hm.streams[eventID]; !ok {
+		hm.streams[eventID] = make(map[chan []byte]bool)
+	}
+	hm.streams[eventID][stream] = true
+	return stream
+}
+
+func (hm *HostMessenger) removeStream(eventID string, stream chan []byte) {
+	hm.lock.Lock()
+	defer hm.lock.Unlock()
+	hm.closeStream(eventID, stream)
+}
+
+//closeStream closes the stream, if it has not already been closed
+//Must hold the lock before calling
+func (hm *HostMessenger) closeStream(eventID string, stream chan []byte) {
+	streams, ok := hm.streams[eventID]
+	if !ok || !streams[stream] {
+		return
+	}
+	delete(streams, stream)
+	close(stream)
+	if len(streams) == 0 {
+		delete(hm.streams, eventID)
+	}
+}
@@ -0,0 +1,154 @@
+package sse_test
+
+import (
+	"bufio"
+	"checkin"
+	myhttp "checkin/http"
+	"checkin/http/sse"
+	"checkin/test"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//waits up to a second for the messenger to have (or not have) a stream for the event
+func waitForStream(hm *sse.HostMessenger, eventID string, expected bool) bool {
+	for i := 0; i < 100; i++ {
+		if hm.HasStream(eventID) == expected {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+//reads lines from the stream until (and excluding) the next blank line, which ends an event
+func readEvent(t *testing.T, reader *bufio.Reader) []string {
+	var lines []string
+	for {
+		line, err := reader.ReadString('\n')
+		test.Ok(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return lines
+		}
+		lines = append(lines, line)
+	}
+}
+
+func TestHostMessenger(t *testing.T) {
+	hm := sse.NewHostMessenger(16, 50*time.Millisecond)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := hm.OpenStream(r.Header.Get("EventID"), w, r)
+		test.Ok(t, err)
+	}))
+	defer s.Close()
+
+	//try sending to an event no one is streaming
+	test.Equals(t, false, hm.HasStream("100"))
+	err := hm.Send("100", myhttp.HostMessage{Title: "checkedin/1"})
+	test.Assert(t, err != nil, "Sending to an event with no streams fails to throw an error")
+
+	//open two streams on the same event, and one on another
+	openStream := func(eventID string) (*http.Response, context.CancelFunc) {
+		ctx, cancel := context.WithCancel(context.Background())
+		req, err := http.NewRequest("GET", s.URL, nil)
+		test.Ok(t, err)
+		req.Header.Set("EventID", eventID)
+		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		test.Ok(t, err)
+		test.Equals(t, http.StatusOK, resp.StatusCode)
+		test.Equals(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		return resp, cancel
+	}
+	resp1, cancel1 := openStream("100")
+	defer cancel1()
+	resp2, cancel2 := openStream("100")
+	defer cancel2()
+	resp3, cancel3 := openStream("200")
+	defer cancel3()
+	test.Assert(t, waitForStream(hm, "100", true), "Stream for event 100 not opened")
+	test.Assert(t, waitForStream(hm, "200", true), "Stream for event 200 not opened")
+	reader1 := bufio.NewReader(resp1.Body)
+	reader2 := bufio.NewReader(resp2.Body)
+	reader3 := bufio.NewReader(resp3.Body)
+
+	//both hosts of the event should get the message
+	err = hm.Send("100", myhttp.HostMessage{
+		Title: "checkedin/1",
+		Content: myhttp.AttendanceUpdate{Name: "Jim", CheckedIn: true,
+			Stats: checkin.GuestStats{TotalGuests: 2, CheckedIn: 1, PercentCheckedIn: 0.5}},
+	})
+	test.Ok(t, err)
+	expected := []string{
+		"event: checkedin/1",
+		`data: {"name":"Jim","checkedIn":true,"stats":{"total":2,"checkedIn":1,"percentCheckedIn":0.5}}`,
+	}
+	for _, reader := range []*bufio.Reader{reader1, reader2} {
+		lines := readEvent(t, reader)
+		for len(lines) == 1 && strings.HasPrefix(lines[0], ":") { //skip heartbeats
+			lines = readEvent(t, reader)
+		}
+		test.Equals(t, expected, lines)
+	}
+
+	//hosts of other events only get heartbeats
+	test.Equals(t, []string{": heartbeat"}, readEvent(t, reader3))
+
+	//closing the last stream of an event removes it
+	cancel3()
+	test.Assert(t, waitForStream(hm, "200", false), "Stream for event 200 not closed after host disconnected")
+	cancel1()
+	test.Equals(t, true, hm.HasStream("100"))
+	cancel2()
+	test.Assert(t, waitForStream(hm, "100", false), "Stream for event 100 not closed after hosts disconnected")
+}
+
+//blockingWriter is a http.ResponseWriter which blocks on every write until unblocked
+type blockingWriter struct {
+	*httptest.ResponseRecorder
+	unblock chan bool
+}
+
+func (bw *blockingWriter) Write(b []byte) (int, error) {
+	<-bw.unblock
+	return bw.ResponseRecorder.Write(b)
+}
+
+func TestHostMessengerSlowHost(t *testing.T) {
+	hm := sse.NewHostMessenger(1, time.Hour)
+	w := &blockingWriter{ResponseRecorder: httptest.NewRecorder(), unblock: make(chan bool)}
+	r := httptest.NewRequest("GET", "/", nil)
+	done := make(chan error)
+	go func() {
+		done <- hm.OpenStream("100", w, r)
+	}()
+	test.Assert(t, waitForStream(hm, "100", true), "Stream not opened")
+
+	//the host is stuck on the first message, so once its buffer fills up it should be dropped
+	//instead of blocking the sender
+	for i := 0; i < 3; i++ {
+		hm.Send("100", myhttp.HostMessage{Title: "checkedin/1"})
+	}
+	test.Equals(t, false, hm.HasStream("100"))
+
+	close(w.unblock)
+	select {
+	case err := <-done:
+		test.Ok(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("OpenStream did not return after host was dropped")
+	}
+}
+
+func TestHostMessengerNoFlusher(t *testing.T) {
+	hm := sse.NewHostMessenger(16, time.Hour)
+	var w struct{ http.ResponseWriter } //hides the Flusher of the recorder
+	w.ResponseWriter = httptest.NewRecorder()
+	err := hm.OpenStream("100", w, httptest.NewRequest("GET", "/", nil))
+	test.Assert(t, err != nil, "Opening stream on a writer which cannot flush fails to throw an error")
+	test.Equals(t, false, hm.HasStream("100"))
+}
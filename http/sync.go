@@ -0,0 +1,84 @@
+package http
+
+import (
+	"checkin"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+//handleSyncGuests makes the guests of the event given by the eventID in the URL the same as the array of guests
+//in the body, matched by NRIC: guests not yet registered are added, and those registered have their names and tags
+//updated, keeping their check in status. Guests missing from the array are only removed with ?remove=true
+//Writes the changes made, or with ?dryrun=true, the changes which would be made without making them
+func (h *GuestHandler) handleSyncGuests(w http.ResponseWriter, r *http.Request) {
+	var guests []checkin.Guest
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&guests)
+	if err != nil {
+		h.Logger.Println("Error when decoding guests to sync: " + err.Error())
+		WriteMessage(http.StatusBadRequest,
+			`Incorrect fields for syncing guests. Must be an array of guest objects, i.e. in the form [{"nric":"1234A","name":"Hello","tags":["VIP","CONFIRMED"]}]`,
+			w)
+		return
+	}
+	if len(guests) == 0 {
+		WriteMessage(http.StatusBadRequest, "Cannot sync with an empty or null array of guests", w)
+		return
+	}
+	opts, err := syncOptions(r)
+	if err != nil {
+		WriteMessage(http.StatusBadRequest, err.Error(), w)
+		return
+	}
+
+	listed := make(map[string]bool)
+	for _, guest := range guests {
+		nric := strings.ToUpper(guest.NRIC)
+		if nric == "" {
+			WriteMessage(http.StatusBadRequest, "Every guest to sync needs an NRIC", w)
+			return
+		} else if listed[nric] {
+			WriteMessage(http.StatusBadRequest, "NRIC listed more than once: "+guest.NRIC, w)
+			return
+		}
+		listed[nric] = true
+		if !h.validGuest(guest) {
+			WriteMessage(http.StatusBadRequest, "The name or one of the tags of the guest is too long, for guest: "+guest.NRIC, w)
+			return
+		}
+	}
+
+	sync, err := h.GuestService.SyncGuests(mux.Vars(r)["eventID"], guests, opts)
+	if err != nil {
+		h.Logger.Println("Error syncing guests: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Sync of guests failed; thus none of the changes were made", w)
+		return
+	}
+	reply, _ := json.Marshal(sync)
+	w.Write(reply)
+}
+
+//syncOptions reads the options of a sync from the remove and dryrun query parameters, which default to false
+func syncOptions(r *http.Request) (checkin.SyncOptions, error) {
+	var opts checkin.SyncOptions
+	switch strings.ToLower(r.FormValue("remove")) {
+	case "", "false":
+	case "true":
+		opts.RemoveMissing = true
+	default:
+		return opts, errors.New("Form value 'remove' must be either true or false")
+	}
+	switch strings.ToLower(r.FormValue("dryrun")) {
+	case "", "false":
+	case "true":
+		opts.DryRun = true
+	default:
+		return opts, errors.New("Form value 'dryrun' must be either true or false")
+	}
+	return opts, nil
+}
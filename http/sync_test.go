@@ -0,0 +1,101 @@
+package http_test
+
+import (
+	"checkin"
+	myhttp "checkin/http"
+	"checkin/mock"
+	"checkin/test"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleSyncGuests(t *testing.T) {
+	var gs mock.GuestService
+	var es mock.EventService
+	var auth mock.Authenticator
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &mock.GuestMessenger{}, &mock.HostMessenger{}, &auth, 64, 10)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("100", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "100", checkin.RoleOwner, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	var receivedOpts checkin.SyncOptions
+	syncGuestsGenerator := func(err error) func(string, []checkin.Guest, checkin.SyncOptions) (checkin.GuestSync, error) {
+		return func(eventID string, guests []checkin.Guest, opts checkin.SyncOptions) (checkin.GuestSync, error) {
+			test.Equals(t, "100", eventID)
+			test.Equals(t, []checkin.Guest{
+				{NRIC: "1234A", Name: "Alice", Tags: []string{"VIP"}},
+				{NRIC: "5678B", Name: "Bob"},
+			}, guests)
+			receivedOpts = opts
+			return checkin.GuestSync{
+				Added: []checkin.Guest{{NRIC: "5678B", Name: "Bob", Tags: []string{}}},
+				Updated: []checkin.GuestUpdate{{NRIC: "1234A", Name: "Alice", Tags: []string{"VIP"},
+					OldName: "Alicia", OldTags: []string{}}},
+				Removed: []checkin.GuestRecord{{Name: "Carol", Tags: []string{}, CheckedIn: false}},
+				DryRun:  opts.DryRun,
+			}, err
+		}
+	}
+	gs.SyncGuestsFn = syncGuestsGenerator(nil)
+	body := `[{"nric":"1234A","name":"Alice","tags":["VIP"]},{"nric":"5678B","name":"Bob"}]`
+	syncGuests := func(query string, body string) *http.Response {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1-4/events/100/guests/sync"+query, strings.NewReader(body)))
+		return w.Result()
+	}
+
+	//test normal functionality, which keeps guests missing from the list by default
+	res := syncGuests("", body)
+	test.Equals(t, http.StatusOK, res.StatusCode)
+	var sync checkin.GuestSync
+	test.Ok(t, json.NewDecoder(res.Body).Decode(&sync))
+	test.Equals(t, "Bob", sync.Added[0].Name)
+	test.Equals(t, "Alicia", sync.Updated[0].OldName)
+	test.Equals(t, false, sync.DryRun)
+	test.Equals(t, checkin.SyncOptions{}, receivedOpts)
+
+	//test dry runs, and removing guests missing from the list
+	res = syncGuests("?dryrun=true&remove=TRUE", body)
+	test.Equals(t, http.StatusOK, res.StatusCode)
+	test.Ok(t, json.NewDecoder(res.Body).Decode(&sync))
+	test.Equals(t, true, sync.DryRun)
+	test.Equals(t, checkin.SyncOptions{RemoveMissing: true, DryRun: true}, receivedOpts)
+
+	//test invalid options and lists of guests
+	gs.SyncGuestsInvoked = false
+	test.Equals(t, http.StatusBadRequest, syncGuests("?remove=yes", body).StatusCode)
+	test.Equals(t, http.StatusBadRequest, syncGuests("?dryrun=1", body).StatusCode)
+	for _, invalid := range []string{
+		`[]`,
+		`null`,
+		`{"nric":"1234A","name":"Alice"}`,
+		`[{"nric":"1234A","name":"Alice","age":30}]`,
+		`[{"name":"Alice"}]`,
+		`[{"nric":"1234A","name":"Alice"},{"nric":"1234a","name":"Bob"}]`,
+		`[{"nric":"1234A","name":"Alice","tags":["MUCHTOOLONGTAG"]}]`,
+		`[{"nric":"1234A","name":"Alice","tags":[""]}]`,
+	} {
+		test.Equals(t, http.StatusBadRequest, syncGuests("", invalid).StatusCode)
+	}
+	test.Assert(t, !gs.SyncGuestsInvoked, "Guests synced even though the request is invalid")
+
+	//test error syncing guests
+	gs.SyncGuestsFn = syncGuestsGenerator(errors.New("An error"))
+	test.Equals(t, http.StatusInternalServerError, syncGuests("", body).StatusCode)
+	gs.SyncGuestsFn = syncGuestsGenerator(nil)
+
+	//access restriction tests
+	r := httptest.NewRequest("POST", "/api/v1-4/events/100/guests/sync", strings.NewReader(body))
+	roleAccessTest(t, r, h, &es, "testing_username", "100", []string{checkin.RoleOwner, checkin.RoleCoHost},
+		func(r *http.Response) {
+			test.Assert(t, r.StatusCode != http.StatusForbidden, "Host forbidden from syncing guests")
+		})
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+	eventDoesNotExistTest(t, httptest.NewRequest("POST", "/api/v1-4/events/200/guests/sync", strings.NewReader(body)), h, &es)
+}
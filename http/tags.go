@@ -0,0 +1,241 @@
+package http
+
+import (
+	"checkin"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/guregu/null"
+)
+
+//guestFilter is the body of changes to a tag across many guests, picking out the guests to change: those with
+//all of the tags, matching the filter expression (see checkin.ParseTagFilter), and with the check in status if given
+type guestFilter struct {
+	Tags      []string  `json:"tags"`
+	Filter    string    `json:"filter"`
+	CheckedIn null.Bool `json:"checkedIn"`
+}
+
+//tagsChanged is the reply to changes to a tag across many guests
+type tagsChanged struct {
+	Updated int `json:"updated"` //number of guests whose tags were changed
+}
+
+//handleGuestTags writes the tags of the guest given by the nric in the URL
+func (h *GuestHandler) handleGuestTags(w http.ResponseWriter, r *http.Request) {
+	eventID, nric := mux.Vars(r)["eventID"], mux.Vars(r)["nric"]
+	if !h.guestExists(w, eventID, nric) {
+		return
+	}
+	tags, err := h.GuestService.Tags(eventID, nric)
+	if err != nil {
+		h.Logger.Println("Error fetching tags of guest: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching tags of guest", w)
+		return
+	}
+	reply, _ := json.Marshal(tags)
+	w.Write(reply)
+}
+
+//handleSetGuestTags replaces the tags of the guest given by the nric in the URL with the array of tags in the body
+func (h *GuestHandler) handleSetGuestTags(w http.ResponseWriter, r *http.Request) {
+	eventID, nric := mux.Vars(r)["eventID"], mux.Vars(r)["nric"]
+	var tags []string
+	err := json.NewDecoder(r.Body).Decode(&tags)
+	if err != nil {
+		h.Logger.Println("Error decoding tags: " + err.Error())
+		WriteMessage(http.StatusBadRequest, "Tags must be an array of strings", w)
+		return
+	}
+	for _, tag := range tags {
+		if !h.validTag(tag) {
+			WriteMessage(http.StatusBadRequest, "Tags cannot be empty or too long", w)
+			return
+		}
+	}
+	if !h.guestExists(w, eventID, nric) {
+		return
+	}
+	err = h.GuestService.SetTags(eventID, nric, tags)
+	if err != nil {
+		h.Logger.Println("Error setting tags of guest: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error setting tags of guest", w)
+		return
+	}
+	WriteOKMessage("Tags of guest set", w)
+}
+
+//handleAddTag adds the tag in the URL to every guest who passes the filter in the body, or every guest
+//if there is no body, and writes how many guests it was added to
+func (h *GuestHandler) handleAddTag(w http.ResponseWriter, r *http.Request) {
+	h.changeTag(w, r, h.GuestService.AddTag)
+}
+
+//handleRemoveTag removes the tag in the URL from every guest who passes the filter in the body, or every guest
+//if there is no body, and writes how many guests it was removed from
+func (h *GuestHandler) handleRemoveTag(w http.ResponseWriter, r *http.Request) {
+	h.changeTag(w, r, h.GuestService.RemoveTag)
+}
+
+//changeTag makes the change to the tag in the URL for the guests who pass the filter in the body
+func (h *GuestHandler) changeTag(w http.ResponseWriter, r *http.Request,
+	change func(eventID string, tag string, filter checkin.GuestFilter) (int, error)) {
+	tag := mux.Vars(r)["tag"]
+	if !h.validTag(tag) {
+		WriteMessage(http.StatusBadRequest, "Tag cannot be empty or too long", w)
+		return
+	}
+	var body guestFilter
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&body)
+	if err != nil && err != io.EOF { //no body means every guest
+		h.Logger.Println("Error decoding guest filter: " + err.Error())
+		WriteMessage(http.StatusBadRequest, "Badly formatted JSON in guest filter (can only have tags, filter and checkedIn)", w)
+		return
+	}
+	filter, err := checkin.ParseTagFilter(body.Filter)
+	if err != nil {
+		WriteMessage(http.StatusBadRequest, "Invalid tag filter: "+err.Error(), w)
+		return
+	}
+
+	updated, err := change(mux.Vars(r)["eventID"], tag,
+		checkin.GuestFilter{Tags: checkin.HasAllTags(body.Tags).And(filter), CheckedIn: body.CheckedIn})
+	if err != nil {
+		h.Logger.Println("Error changing tag of guests: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error changing tag of guests", w)
+		return
+	}
+	reply, _ := json.Marshal(tagsChanged{Updated: updated})
+	w.Write(reply)
+}
+
+//handleRenameTag renames the tag in the URL to the name in the body for every guest who has it,
+//merging it into that tag for guests who have both, and writes how many guests had it
+func (h *GuestHandler) handleRenameTag(w http.ResponseWriter, r *http.Request) {
+	var rename struct {
+		Name string `json:"name"`
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&rename)
+	if err != nil {
+		h.Logger.Println("Error decoding new tag name: " + err.Error())
+		WriteMessage(http.StatusBadRequest, "Badly formatted JSON in tag (can only have the new name)", w)
+		return
+	}
+	if !h.validTag(mux.Vars(r)["tag"]) || !h.validTag(rename.Name) {
+		WriteMessage(http.StatusBadRequest, "Tags cannot be empty or too long", w)
+		return
+	}
+
+	updated, err := h.GuestService.RenameTag(mux.Vars(r)["eventID"], mux.Vars(r)["tag"], rename.Name)
+	if err != nil {
+		h.Logger.Println("Error renaming tag: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error renaming tag", w)
+		return
+	}
+	reply, _ := json.Marshal(tagsChanged{Updated: updated})
+	w.Write(reply)
+}
+
+//handleTagStats writes the attendance statistics of the guests with each tag of the event given by the eventID
+//in the URL, followed by those of the guests without any tags (with the tag "")
+//The session, tag and filter query parameters are as for handleStats
+func (h *GuestHandler) handleTagStats(w http.ResponseWriter, r *http.Request) {
+	eventID, sessionID := mux.Vars(r)["eventID"], r.FormValue("session")
+	filter, ok := h.tagFilter(w, r, "tag")
+	if !ok {
+		return
+	}
+	if !h.sessionExists(eventID, sessionID, w) {
+		return
+	}
+	stats, err := h.GuestService.TagStats(eventID, sessionID, filter)
+	if err != nil {
+		h.Logger.Println("Error in handleTagStats: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching statistics of tags", w)
+		return
+	}
+	reply, _ := json.Marshal(stats)
+	w.Write(reply)
+}
+
+//handleTagCrossStats writes the attendance statistics of the guests of the event given by the eventID in the URL
+//with each pair of a tag given by the rows query parameters and one given by the columns query parameters
+//The session, tag and filter query parameters are as for handleStats
+func (h *GuestHandler) handleTagCrossStats(w http.ResponseWriter, r *http.Request) {
+	eventID, sessionID := mux.Vars(r)["eventID"], r.FormValue("session")
+	rows, columns := r.Form["rows"], r.Form["columns"]
+	if !h.validDimension(rows) || !h.validDimension(columns) {
+		WriteMessage(http.StatusBadRequest, "Form values 'rows' and 'columns' must each be between 1 and "+
+			strconv.Itoa(maxDimensionTags)+" valid tags", w)
+		return
+	}
+	filter, ok := h.tagFilter(w, r, "tag")
+	if !ok {
+		return
+	}
+	if !h.sessionExists(eventID, sessionID, w) {
+		return
+	}
+	stats, err := h.GuestService.TagCrossStats(eventID, sessionID, rows, columns, filter)
+	if err != nil {
+		h.Logger.Println("Error in handleTagCrossStats: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching cross tabulated statistics of tags", w)
+		return
+	}
+	reply, _ := json.Marshal(stats)
+	w.Write(reply)
+}
+
+//maxDimensionTags is the most tags each dimension of cross tabulated statistics can have
+const maxDimensionTags = 32
+
+//validDimension checks the tags of a dimension of cross tabulated statistics are valid, and not too many
+func (h *GuestHandler) validDimension(tags []string) bool {
+	if len(tags) == 0 || len(tags) > maxDimensionTags {
+		return false
+	}
+	for _, tag := range tags {
+		if !h.validTag(tag) {
+			return false
+		}
+	}
+	return true
+}
+
+//tagFilter parses the filter guests of the request must match: the expression in the filter query parameter
+//(see checkin.ParseTagFilter), along with the tags of repeated tagsKey query parameters, which guests must all have
+//If the expression is not valid, an error is written and false returned
+func (h *GuestHandler) tagFilter(w http.ResponseWriter, r *http.Request, tagsKey string) (checkin.TagFilter, bool) {
+	filter, err := checkin.ParseTagFilter(r.FormValue("filter"))
+	if err != nil {
+		WriteMessage(http.StatusBadRequest, "Invalid tag filter: "+err.Error(), w)
+		return checkin.TagFilter{}, false
+	}
+	return checkin.HasAllTags(r.Form[tagsKey]).And(filter), true
+}
+
+func (h *GuestHandler) validTag(tag string) bool {
+	return tag != "" && len(tag) <= h.MaxLengthTag
+}
+
+//guestExists checks that the event has a guest with the NRIC, writing an error response
+//and returning false if it does not
+func (h *GuestHandler) guestExists(w http.ResponseWriter, eventID string, nric string) bool {
+	exists, err := h.GuestService.GuestExists(eventID, nric)
+	if err != nil {
+		h.Logger.Println("Error checking if guest exists: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error checking if guest exists", w)
+		return false
+	} else if !exists {
+		WriteMessage(http.StatusNotFound, "Guest does not exist", w)
+		return false
+	}
+	return true
+}
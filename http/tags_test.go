@@ -0,0 +1,417 @@
+package http_test
+
+import (
+	"checkin"
+	myhttp "checkin/http"
+	"checkin/mock"
+	"checkin/test"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/guregu/null"
+)
+
+//Generates a GuestExists mock function which returns true only for the guest with NRIC 1234A of event 100
+func guestWithNRICGenerator(err error) func(string, string) (bool, error) {
+	return func(eventID string, nric string) (bool, error) {
+		return eventID == "100" && nric == "1234A", err
+	}
+}
+
+func TestHandleGuestTags(t *testing.T) {
+	var gs mock.GuestService
+	var es mock.EventService
+	var auth mock.Authenticator
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &mock.GuestMessenger{}, &mock.HostMessenger{}, &auth, 64, 10)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("100", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "100", checkin.RoleCoHost, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	gs.GuestExistsFn = guestWithNRICGenerator(nil)
+	tagsGenerator := func(err error) func(string, string) ([]string, error) {
+		return func(eventID string, nric string) ([]string, error) {
+			test.Equals(t, "1234A", nric)
+			return []string{"VIP", "PENDING"}, err
+		}
+	}
+	gs.TagsFn = tagsGenerator(nil)
+
+	//test normal functionality
+	r := httptest.NewRequest("GET", "/api/v1-4/events/100/guests/1234A/tags", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	var tags []string
+	test.Ok(t, json.NewDecoder(w.Result().Body).Decode(&tags))
+	test.Equals(t, []string{"VIP", "PENDING"}, tags)
+
+	//test guests who do not exist
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1-4/events/100/guests/5678B/tags", nil))
+	test.Equals(t, http.StatusNotFound, w.Result().StatusCode)
+
+	//test errors checking guest exists and fetching tags
+	gs.GuestExistsFn = guestWithNRICGenerator(errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	gs.GuestExistsFn = guestWithNRICGenerator(nil)
+	gs.TagsFn = tagsGenerator(errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	gs.TagsFn = tagsGenerator(nil)
+
+	//access restriction tests
+	roleAccessTest(t, r, h, &es, "testing_username", "100", []string{checkin.RoleOwner, checkin.RoleCoHost},
+		func(r *http.Response) {
+			test.Equals(t, http.StatusOK, r.StatusCode)
+		})
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+	eventDoesNotExistTest(t, httptest.NewRequest("GET", "/api/v1-4/events/200/guests/1234A/tags", nil), h, &es)
+}
+
+func TestHandleSetGuestTags(t *testing.T) {
+	var gs mock.GuestService
+	var es mock.EventService
+	var auth mock.Authenticator
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &mock.GuestMessenger{}, &mock.HostMessenger{}, &auth, 64, 10)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("100", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "100", checkin.RoleCoHost, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	gs.GuestExistsFn = guestWithNRICGenerator(nil)
+	var setTags []string
+	setTagsGenerator := func(err error) func(string, string, []string) error {
+		return func(eventID string, nric string, tags []string) error {
+			test.Equals(t, "1234A", nric)
+			setTags = tags
+			return err
+		}
+	}
+	gs.SetTagsFn = setTagsGenerator(nil)
+	setGuestTags := func(nric string, body string) *http.Response {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("PUT", "/api/v1-4/events/100/guests/"+nric+"/tags", strings.NewReader(body)))
+		return w.Result()
+	}
+
+	//test normal functionality, including removing every tag
+	test.Equals(t, http.StatusOK, setGuestTags("1234A", `["VIP", "CONFIRMED"]`).StatusCode)
+	test.Equals(t, []string{"VIP", "CONFIRMED"}, setTags)
+	test.Equals(t, http.StatusOK, setGuestTags("1234A", `[]`).StatusCode)
+	test.Equals(t, []string{}, setTags)
+
+	//test guests who do not exist, and invalid tags
+	test.Equals(t, http.StatusNotFound, setGuestTags("5678B", `["VIP"]`).StatusCode)
+	gs.SetTagsInvoked = false
+	for _, body := range []string{`["VIP", ""]`, `["MUCHTOOLONGTAG"]`, `"VIP"`, `{"tags":["VIP"]}`, ``} {
+		test.Equals(t, http.StatusBadRequest, setGuestTags("1234A", body).StatusCode)
+	}
+	test.Assert(t, !gs.SetTagsInvoked, "Tags set even though they are invalid")
+
+	//test error setting tags
+	gs.SetTagsFn = setTagsGenerator(errors.New("An error"))
+	test.Equals(t, http.StatusInternalServerError, setGuestTags("1234A", `["VIP"]`).StatusCode)
+	gs.SetTagsFn = setTagsGenerator(nil)
+
+	//access restriction tests
+	r := httptest.NewRequest("PUT", "/api/v1-4/events/100/guests/1234A/tags", strings.NewReader(`["VIP"]`))
+	roleAccessTest(t, r, h, &es, "testing_username", "100", []string{checkin.RoleOwner, checkin.RoleCoHost},
+		func(r *http.Response) {
+			test.Assert(t, r.StatusCode != http.StatusForbidden, "Host forbidden from setting tags of guest")
+		})
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+	eventDoesNotExistTest(t, httptest.NewRequest("PUT", "/api/v1-4/events/200/guests/1234A/tags", strings.NewReader(`[]`)), h, &es)
+}
+
+func TestHandleAddAndRemoveTag(t *testing.T) {
+	var gs mock.GuestService
+	var es mock.EventService
+	var auth mock.Authenticator
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &mock.GuestMessenger{}, &mock.HostMessenger{}, &auth, 64, 10)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("100", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "100", checkin.RoleCoHost, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	var changedTag string
+	var receivedFilter checkin.GuestFilter
+	changeTagGenerator := func(err error) func(string, string, checkin.GuestFilter) (int, error) {
+		return func(eventID string, tag string, filter checkin.GuestFilter) (int, error) {
+			test.Equals(t, "100", eventID)
+			changedTag, receivedFilter = tag, filter
+			return 200, err
+		}
+	}
+	gs.AddTagFn = changeTagGenerator(nil)
+	gs.RemoveTagFn = changeTagGenerator(nil)
+	changeTag := func(method string, tag string, body string) *http.Response {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "/api/v1-4/events/100/guests/tags/"+tag, strings.NewReader(body)))
+		return w.Result()
+	}
+
+	//test adding a tag to guests who pass the filter, writing how many there were
+	res := changeTag("POST", "CONFIRMED", `{"tags":["PENDING"],"checkedIn":false}`)
+	test.Equals(t, http.StatusOK, res.StatusCode)
+	test.Equals(t, true, gs.AddTagInvoked)
+	test.Equals(t, "CONFIRMED", changedTag)
+	test.Equals(t, checkin.GuestFilter{Tags: checkin.HasTag("PENDING"), CheckedIn: null.BoolFrom(false)}, receivedFilter)
+	var changed map[string]int
+	test.Ok(t, json.NewDecoder(res.Body).Decode(&changed))
+	test.Equals(t, map[string]int{"updated": 200}, changed)
+
+	//test removing a tag from every guest, without a filter
+	res = changeTag("DELETE", "PENDING", ``)
+	test.Equals(t, http.StatusOK, res.StatusCode)
+	test.Equals(t, true, gs.RemoveTagInvoked)
+	test.Equals(t, "PENDING", changedTag)
+	test.Equals(t, checkin.GuestFilter{}, receivedFilter)
+
+	//test filter expressions, along with the tags guests must all have
+	res = changeTag("POST", "CONFIRMED", `{"tags":["PENDING"],"filter":"VIP or SPEAKER"}`)
+	test.Equals(t, http.StatusOK, res.StatusCode)
+	test.Equals(t, "(PENDING and (VIP or SPEAKER))", receivedFilter.Tags.String())
+	test.Equals(t, null.Bool{}, receivedFilter.CheckedIn)
+
+	//test invalid tags and filters
+	gs.AddTagInvoked = false
+	test.Equals(t, http.StatusBadRequest, changeTag("POST", "MUCHTOOLONGTAG", ``).StatusCode)
+	test.Equals(t, http.StatusBadRequest, changeTag("POST", "VIP", `{"tags":"PENDING"}`).StatusCode)
+	test.Equals(t, http.StatusBadRequest, changeTag("POST", "VIP", `{"name":"Bob"}`).StatusCode)
+	test.Equals(t, http.StatusBadRequest, changeTag("POST", "VIP", `{"filter":"VIP and"}`).StatusCode)
+	test.Assert(t, !gs.AddTagInvoked, "Tag added even though the tag or filter is invalid")
+
+	//test errors changing tags
+	gs.AddTagFn = changeTagGenerator(errors.New("An error"))
+	test.Equals(t, http.StatusInternalServerError, changeTag("POST", "VIP", ``).StatusCode)
+	gs.RemoveTagFn = changeTagGenerator(errors.New("An error"))
+	test.Equals(t, http.StatusInternalServerError, changeTag("DELETE", "VIP", ``).StatusCode)
+	gs.AddTagFn = changeTagGenerator(nil)
+	gs.RemoveTagFn = changeTagGenerator(nil)
+
+	//access restriction tests
+	for _, method := range []string{"POST", "DELETE"} {
+		r := httptest.NewRequest(method, "/api/v1-4/events/100/guests/tags/VIP", nil)
+		roleAccessTest(t, r, h, &es, "testing_username", "100", []string{checkin.RoleOwner, checkin.RoleCoHost},
+			func(r *http.Response) {
+				test.Equals(t, http.StatusOK, r.StatusCode)
+			})
+		nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+		noValidTokenTest(t, r, h, &auth)
+		eventDoesNotExistTest(t, httptest.NewRequest(method, "/api/v1-4/events/200/guests/tags/VIP", nil), h, &es)
+	}
+}
+
+func TestHandleRenameTag(t *testing.T) {
+	var gs mock.GuestService
+	var es mock.EventService
+	var auth mock.Authenticator
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &mock.GuestMessenger{}, &mock.HostMessenger{}, &auth, 64, 10)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("100", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "100", checkin.RoleOwner, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	renameTagGenerator := func(err error) func(string, string, string) (int, error) {
+		return func(eventID string, tag string, newTag string) (int, error) {
+			test.Equals(t, "100", eventID)
+			test.Equals(t, "PENDING", tag)
+			test.Equals(t, "CONFIRMED", newTag)
+			return 3, err
+		}
+	}
+	gs.RenameTagFn = renameTagGenerator(nil)
+	renameTag := func(tag string, body string) *http.Response {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1-4/events/100/guests/tags/"+tag+"/rename", strings.NewReader(body)))
+		return w.Result()
+	}
+
+	//test normal functionality
+	res := renameTag("PENDING", `{"name":"CONFIRMED"}`)
+	test.Equals(t, http.StatusOK, res.StatusCode)
+	var changed map[string]int
+	test.Ok(t, json.NewDecoder(res.Body).Decode(&changed))
+	test.Equals(t, map[string]int{"updated": 3}, changed)
+
+	//test invalid names
+	gs.RenameTagInvoked = false
+	for _, body := range []string{`{"name":""}`, `{"name":"MUCHTOOLONGTAG"}`, `{"tag":"CONFIRMED"}`, `"CONFIRMED"`} {
+		test.Equals(t, http.StatusBadRequest, renameTag("PENDING", body).StatusCode)
+	}
+	test.Equals(t, http.StatusBadRequest, renameTag("MUCHTOOLONGTAG", `{"name":"CONFIRMED"}`).StatusCode)
+	test.Assert(t, !gs.RenameTagInvoked, "Tag renamed even though a name is invalid")
+
+	//test error renaming tag
+	gs.RenameTagFn = renameTagGenerator(errors.New("An error"))
+	test.Equals(t, http.StatusInternalServerError, renameTag("PENDING", `{"name":"CONFIRMED"}`).StatusCode)
+	gs.RenameTagFn = renameTagGenerator(nil)
+
+	//access restriction tests
+	r := httptest.NewRequest("POST", "/api/v1-4/events/100/guests/tags/PENDING/rename", strings.NewReader(`{"name":"CONFIRMED"}`))
+	roleAccessTest(t, r, h, &es, "testing_username", "100", []string{checkin.RoleOwner, checkin.RoleCoHost},
+		func(r *http.Response) {
+			test.Assert(t, r.StatusCode != http.StatusForbidden, "Host forbidden from renaming tag")
+		})
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+}
+
+func TestHandleTagStats(t *testing.T) {
+	var gs mock.GuestService
+	var es mock.EventService
+	var auth mock.Authenticator
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &mock.GuestMessenger{}, &mock.HostMessenger{}, &auth, 64, 10)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("100", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "100", checkin.RoleViewer, nil)
+	es.SessionFn = sessionGenerator(t, "100", "1")
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	tagStats := []checkin.TagStats{
+		{Tag: "VIP", GuestStats: checkin.GuestStats{TotalGuests: 4, CheckedIn: 1, PercentCheckedIn: 0.25}},
+		{Tag: "", GuestStats: checkin.GuestStats{TotalGuests: 2, CheckedIn: 0, PercentCheckedIn: 0}},
+	}
+	var receivedSessionID string
+	var receivedFilter checkin.TagFilter
+	tagStatsGenerator := func(err error) func(string, string, checkin.TagFilter) ([]checkin.TagStats, error) {
+		return func(eventID string, sessionID string, filter checkin.TagFilter) ([]checkin.TagStats, error) {
+			test.Equals(t, "100", eventID)
+			receivedSessionID, receivedFilter = sessionID, filter
+			return tagStats, err
+		}
+	}
+	gs.TagStatsFn = tagStatsGenerator(nil)
+
+	//test normal functionality
+	r := httptest.NewRequest("GET", "/api/v1-4/events/100/guests/stats/tags", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	var fetched []checkin.TagStats
+	test.Ok(t, json.NewDecoder(w.Result().Body).Decode(&fetched))
+	test.Equals(t, tagStats, fetched)
+	test.Equals(t, "", receivedSessionID)
+	test.Equals(t, checkin.TagFilter{}, receivedFilter)
+
+	//test stats of a session, of guests matching a tag filter
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1-4/events/100/guests/stats/tags?session=1&tag=CONFIRMED", nil))
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, "1", receivedSessionID)
+	test.Equals(t, checkin.HasTag("CONFIRMED"), receivedFilter)
+
+	//test sessions which do not exist, and invalid tag filters
+	gs.TagStatsInvoked = false
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1-4/events/100/guests/stats/tags?session=2", nil))
+	test.Equals(t, http.StatusNotFound, w.Result().StatusCode)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1-4/events/100/guests/stats/tags?filter=VIP+or", nil))
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+	test.Assert(t, !gs.TagStatsInvoked, "Stats fetched for invalid request")
+
+	//test error fetching stats
+	gs.TagStatsFn = tagStatsGenerator(errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	gs.TagStatsFn = tagStatsGenerator(nil)
+
+	//access restriction tests
+	roleAccessTest(t, r, h, &es, "testing_username", "100", []string{checkin.RoleOwner, checkin.RoleCoHost, checkin.RoleViewer},
+		func(r *http.Response) {
+			test.Equals(t, http.StatusOK, r.StatusCode)
+		})
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+	eventDoesNotExistTest(t, httptest.NewRequest("GET", "/api/v1-4/events/200/guests/stats/tags", nil), h, &es)
+}
+
+func TestHandleTagCrossStats(t *testing.T) {
+	var gs mock.GuestService
+	var es mock.EventService
+	var auth mock.Authenticator
+	h := myhttp.NewGuestHandler(&gs, &mock.AttendanceLogService{}, &es, &mock.GuestMessenger{}, &mock.HostMessenger{}, &auth, 64, 10)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("100", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "100", checkin.RoleViewer, nil)
+	es.SessionFn = sessionGenerator(t, "100", "1")
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	crossStats := checkin.TagCrossStats{
+		Rows:    []string{"VIP", "STAFF"},
+		Columns: []string{"DAY1"},
+		Cells: [][]checkin.GuestStats{
+			{{TotalGuests: 2, CheckedIn: 1, PercentCheckedIn: 0.5}},
+			{{TotalGuests: 0, CheckedIn: 0, PercentCheckedIn: 0}},
+		},
+	}
+	var receivedRows, receivedColumns []string
+	var receivedFilter checkin.TagFilter
+	crossStatsGenerator := func(err error) func(string, string, []string, []string, checkin.TagFilter) (checkin.TagCrossStats, error) {
+		return func(eventID string, sessionID string, rows []string, columns []string, filter checkin.TagFilter) (checkin.TagCrossStats, error) {
+			test.Equals(t, "100", eventID)
+			receivedRows, receivedColumns, receivedFilter = rows, columns, filter
+			return crossStats, err
+		}
+	}
+	gs.TagCrossStatsFn = crossStatsGenerator(nil)
+
+	//test normal functionality
+	r := httptest.NewRequest("GET", "/api/v1-4/events/100/guests/stats/tags/cross?rows=VIP&rows=STAFF&columns=DAY1", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	var fetched checkin.TagCrossStats
+	test.Ok(t, json.NewDecoder(w.Result().Body).Decode(&fetched))
+	test.Equals(t, crossStats, fetched)
+	test.Equals(t, []string{"VIP", "STAFF"}, receivedRows)
+	test.Equals(t, []string{"DAY1"}, receivedColumns)
+	test.Equals(t, checkin.TagFilter{}, receivedFilter)
+
+	//test filtering by tags
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1-4/events/100/guests/stats/tags/cross?rows=VIP&columns=DAY1&filter=not+CANCELLED", nil))
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, checkin.TagFilter{Op: checkin.TagOpNot, Operands: []checkin.TagFilter{checkin.HasTag("CANCELLED")}}, receivedFilter)
+
+	//test invalid dimensions
+	gs.TagCrossStatsInvoked = false
+	invalid := []string{"rows=VIP", "columns=DAY1", "rows=VIP&columns=", "rows=VIP&columns=" + strings.Repeat("A", 11),
+		"columns=DAY1" + strings.Repeat("&rows=VIP", 33)}
+	for _, query := range invalid {
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1-4/events/100/guests/stats/tags/cross?"+query, nil))
+		test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1-4/events/100/guests/stats/tags/cross?rows=VIP&columns=DAY1&session=2", nil))
+	test.Equals(t, http.StatusNotFound, w.Result().StatusCode)
+	test.Assert(t, !gs.TagCrossStatsInvoked, "Stats fetched for invalid request")
+
+	//test error fetching stats
+	gs.TagCrossStatsFn = crossStatsGenerator(errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	gs.TagCrossStatsFn = crossStatsGenerator(nil)
+
+	//access restriction tests
+	roleAccessTest(t, r, h, &es, "testing_username", "100", []string{checkin.RoleOwner, checkin.RoleCoHost, checkin.RoleViewer},
+		func(r *http.Response) {
+			test.Equals(t, http.StatusOK, r.StatusCode)
+		})
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+}
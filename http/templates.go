@@ -0,0 +1,227 @@
+package http
+
+import (
+	"checkin"
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+)
+
+//handleCloneEvent creates a clone of the event given by the eventID in the URL, as described by the clone options
+//in the body of the request, and writes the ID of the clone
+//The user cloning the event becomes its owner, and the other hosts of the original keep their roles in the clone
+func (h *EventHandler) handleCloneEvent(w http.ResponseWriter, r *http.Request) {
+	opts, ok := h.decodeCloneOptions(w, r)
+	if !ok {
+		return
+	}
+	eventID := mux.Vars(r)["eventID"]
+	event, err := h.EventService.Event(eventID)
+	if err != nil {
+		h.Logger.Println("Error fetching event to clone: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching event to clone", w)
+		return
+	}
+	clone := event.Copy(opts)
+	if !h.creatable(clone, w) {
+		return
+	}
+	authInfo, err := h.Authenticator.GetAuthInfo(r)
+	if err != nil {
+		h.Logger.Println("Error fetching authorization info: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error in fetching authorization info", w)
+		return
+	}
+
+	clone.ID = uuid.New().String()
+	err = h.EventService.CloneEvent(eventID, clone, authInfo.Username, opts.Guests)
+	if err != nil {
+		h.Logger.Println("Error cloning event: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error cloning event", w)
+		return
+	}
+	reply, _ := json.Marshal(clone.ID)
+	w.WriteHeader(http.StatusCreated)
+	w.Write(reply)
+}
+
+//handleCreateTemplate saves the details of the event given by the eventID in the URL as a template
+//with the name given in the body of the request, and writes the ID of the template
+func (h *EventHandler) handleCreateTemplate(w http.ResponseWriter, r *http.Request) {
+	var template checkin.EventTemplate
+	var details struct {
+		Name string `json:"name"`
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&details)
+	if err != nil || details.Name == "" || len(details.Name) > h.MaxLengthName {
+		WriteMessage(http.StatusBadRequest, "Badly formatted JSON (need the name of the template)", w)
+		return
+	}
+	event, err := h.EventService.Event(mux.Vars(r)["eventID"])
+	if err != nil {
+		h.Logger.Println("Error fetching event to save as template: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching event to save as template", w)
+		return
+	}
+	authInfo, err := h.Authenticator.GetAuthInfo(r)
+	if err != nil {
+		h.Logger.Println("Error fetching authorization info: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error in fetching authorization info", w)
+		return
+	}
+
+	template.ID, template.Name = uuid.New().String(), details.Name
+	template.Username = authInfo.Username
+	template.Event = event.Copy(checkin.CloneOptions{})
+	err = h.EventService.CreateTemplate(template)
+	if err != nil {
+		h.Logger.Println("Error creating template: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error creating template", w)
+		return
+	}
+	reply, _ := json.Marshal(template.ID)
+	w.WriteHeader(http.StatusCreated)
+	w.Write(reply)
+}
+
+//handleTemplates writes a page of the templates saved by the user, with the total number of their templates
+//in the X-Total-Count header
+func (h *EventHandler) handleTemplates(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListOptions(r, checkin.SortByName, checkin.SortByCreatedAt)
+	if err != nil {
+		WriteMessage(http.StatusBadRequest, err.Error(), w)
+		return
+	}
+	authInfo, err := h.Authenticator.GetAuthInfo(r)
+	if err != nil {
+		h.Logger.Println("Error fetching authorization info: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error in fetching authorization info", w)
+		return
+	}
+
+	templates, total, err := h.EventService.Templates(authInfo.Username, opts)
+	if err != nil {
+		h.Logger.Println("Error fetching templates: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching templates", w)
+		return
+	}
+	writeTotalCount(total, w)
+	reply, _ := json.Marshal(templates)
+	w.Write(reply)
+}
+
+//handleTemplate writes the template given by the templateID in the URL
+func (h *EventHandler) handleTemplate(w http.ResponseWriter, r *http.Request) {
+	template, ok := h.ownTemplate(w, r)
+	if !ok {
+		return
+	}
+	reply, _ := json.Marshal(template)
+	w.Write(reply)
+}
+
+//handleDeleteTemplate deletes the template given by the templateID in the URL
+func (h *EventHandler) handleDeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	template, ok := h.ownTemplate(w, r)
+	if !ok {
+		return
+	}
+	err := h.EventService.DeleteTemplate(template.ID)
+	if err != nil {
+		h.Logger.Println("Error deleting template: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error deleting template", w)
+		return
+	}
+	WriteOKMessage("Template deleted", w)
+}
+
+//handleInstantiateTemplate creates an event from the template given by the templateID in the URL,
+//as described by the clone options in the body of the request, and writes the ID of the event
+//Templates have no guests to copy
+func (h *EventHandler) handleInstantiateTemplate(w http.ResponseWriter, r *http.Request) {
+	opts, ok := h.decodeCloneOptions(w, r)
+	if !ok {
+		return
+	} else if opts.Guests {
+		WriteMessage(http.StatusBadRequest, "Templates have no guests to copy", w)
+		return
+	}
+	template, ok := h.ownTemplate(w, r)
+	if !ok {
+		return
+	}
+	event := template.Event.Copy(opts)
+	if !h.creatable(event, w) {
+		return
+	}
+
+	event.ID = uuid.New().String()
+	err := h.EventService.CreateEvent(event, template.Username)
+	if err != nil {
+		h.Logger.Println("Error in creating event from template: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error in creating event", w)
+		return
+	}
+	reply, _ := json.Marshal(event.ID)
+	w.WriteHeader(http.StatusCreated)
+	w.Write(reply)
+}
+
+//decodeCloneOptions decodes the clone options in the body of the request, writing an error response if they are invalid
+func (h *EventHandler) decodeCloneOptions(w http.ResponseWriter, r *http.Request) (checkin.CloneOptions, bool) {
+	var opts checkin.CloneOptions
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&opts)
+	if err != nil {
+		h.Logger.Println("Error decoding clone options JSON: " + err.Error())
+		WriteMessage(http.StatusBadRequest, "Badly formatted JSON in clone options (Possibly invalid time format or invalid fields)", w)
+		return checkin.CloneOptions{}, false
+	}
+	return opts, true
+}
+
+//creatable checks that a new event is valid and its URL is free, writing an error response if not
+func (h *EventHandler) creatable(event checkin.Event, w http.ResponseWriter) bool {
+	if !h.validCreateEventInputs(event) {
+		WriteMessage(http.StatusBadRequest, "Invalid arguments to create event", w)
+		return false
+	}
+	if exists, err := h.EventService.URLExists(event.URL.String); err != nil {
+		h.Logger.Println("Error checking if URL already taken: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error checking if URL is available", w)
+		return false
+	} else if exists {
+		WriteMessage(http.StatusConflict, "URL already used by another event", w)
+		return false
+	}
+	return true
+}
+
+//ownTemplate fetches the template given by the templateID in the URL, writing an error response
+//if it does not exist or was not saved by the user making the request
+func (h *EventHandler) ownTemplate(w http.ResponseWriter, r *http.Request) (checkin.EventTemplate, bool) {
+	authInfo, err := h.Authenticator.GetAuthInfo(r)
+	if err != nil {
+		h.Logger.Println("Error fetching authorization info: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error in fetching authorization info", w)
+		return checkin.EventTemplate{}, false
+	}
+	template, exists, err := h.EventService.Template(mux.Vars(r)["templateID"])
+	if err != nil {
+		h.Logger.Println("Error fetching template: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching template", w)
+		return checkin.EventTemplate{}, false
+	} else if !exists {
+		WriteMessage(http.StatusNotFound, "No such template", w)
+		return checkin.EventTemplate{}, false
+	} else if template.Username != authInfo.Username {
+		WriteMessage(http.StatusForbidden, "Template was not saved by you", w)
+		return checkin.EventTemplate{}, false
+	}
+	return template, true
+}
@@ -0,0 +1,399 @@
+package http_test
+
+import (
+	"checkin"
+	myhttp "checkin/http"
+	"checkin/mock"
+	"checkin/test"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+)
+
+//Generates a Template mock function which returns the template if its ID matches the ID asked for,
+//and says no template exists otherwise
+func templateGenerator(template checkin.EventTemplate, err error) func(string) (checkin.EventTemplate, bool, error) {
+	return func(ID string) (checkin.EventTemplate, bool, error) {
+		if err != nil {
+			return checkin.EventTemplate{}, false, err
+		}
+		if ID != template.ID {
+			return checkin.EventTemplate{}, false, nil
+		}
+		return template, true, nil
+	}
+}
+
+func TestHandleCloneEvent(t *testing.T) {
+	var es mock.EventService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleCoHost, nil)
+	es.URLExistsFn = urlExistsGenerator("takenurl", nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	eventGenerator := func(err error) func(string) (checkin.Event, error) {
+		return func(ID string) (checkin.Event, error) {
+			test.Equals(t, "300", ID)
+			return checkin.Event{
+				ID:       "300",
+				Name:     "Monthly Parade",
+				URL:      null.StringFrom("parade"),
+				Start:    null.TimeFrom(time.Date(2019, 3, 4, 8, 0, 0, 0, time.UTC)),
+				TimeTags: map[string]time.Time{"release": time.Date(2019, 3, 1, 9, 0, 0, 0, time.UTC)},
+			}, err
+		}
+	}
+	es.EventFn = eventGenerator(nil)
+	var clone checkin.Event
+	var copiedGuests bool
+	cloneEventGenerator := func(err error) func(string, checkin.Event, string, bool) error {
+		return func(ID string, e checkin.Event, hostUsername string, copyGuests bool) error {
+			test.Equals(t, "300", ID)
+			test.Equals(t, "testing_username", hostUsername)
+			clone, copiedGuests = e, copyGuests
+			return err
+		}
+	}
+	es.CloneEventFn = cloneEventGenerator(nil)
+	cloneEvent := func(body string) *http.Response {
+		r := httptest.NewRequest("POST", "/api/v1-4/events/300/clone", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	//test normal functionality, with the triggers moved along with the start
+	res := cloneEvent(`{"name":"April Parade","startDateTime":"2019-04-01T08:00:00Z","guests":true}`)
+	test.Equals(t, http.StatusCreated, res.StatusCode)
+	var ID string
+	json.NewDecoder(res.Body).Decode(&ID)
+	test.Equals(t, ID, clone.ID)
+	test.Equals(t, "April Parade", clone.Name)
+	test.Equals(t, null.String{}, clone.URL)
+	test.Equals(t, time.Date(2019, 4, 1, 8, 0, 0, 0, time.UTC), clone.Start.Time.UTC())
+	test.Equals(t, time.Date(2019, 3, 29, 9, 0, 0, 0, time.UTC), clone.TimeTags["release"].UTC())
+	test.Equals(t, true, copiedGuests)
+
+	//test the start is in the timezone given by ?loc, and the guest list is not copied unless asked for
+	r := httptest.NewRequest("POST", "/api/v1-4/events/300/clone?loc=Asia/Singapore",
+		strings.NewReader(`{"url":"aprilparade","startDateTime":"2019-04-01T16:00:00Z"}`))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusCreated, w.Result().StatusCode)
+	test.Equals(t, "Monthly Parade", clone.Name)
+	test.Equals(t, null.StringFrom("aprilparade"), clone.URL)
+	test.Equals(t, time.Date(2019, 4, 1, 8, 0, 0, 0, time.UTC), clone.Start.Time.UTC())
+	test.Equals(t, false, copiedGuests)
+
+	//test invalid clone options
+	es.CloneEventInvoked = false
+	res = cloneEvent(`{"name":"April Parade","hosts":true}`)
+	test.Equals(t, http.StatusBadRequest, res.StatusCode)
+	res = cloneEvent(`{"url":""}`)
+	test.Equals(t, http.StatusBadRequest, res.StatusCode)
+	res = cloneEvent(`{"name":"` + strings.Repeat("a", 65) + `"}`)
+	test.Equals(t, http.StatusBadRequest, res.StatusCode)
+	res = cloneEvent(`{"startDateTime":"next month"}`)
+	test.Equals(t, http.StatusBadRequest, res.StatusCode)
+	test.Assert(t, !es.CloneEventInvoked, "Event cloned with invalid options")
+
+	//test URL already in use
+	res = cloneEvent(`{"url":"takenurl"}`)
+	test.Equals(t, http.StatusConflict, res.StatusCode)
+	test.Assert(t, !es.CloneEventInvoked, "Event cloned with URL already in use")
+
+	//test errors fetching and cloning the event
+	es.EventFn = eventGenerator(errors.New("An error"))
+	res = cloneEvent(`{}`)
+	test.Equals(t, http.StatusInternalServerError, res.StatusCode)
+	es.EventFn = eventGenerator(nil)
+	es.CloneEventFn = cloneEventGenerator(errors.New("An error"))
+	res = cloneEvent(`{}`)
+	test.Equals(t, http.StatusInternalServerError, res.StatusCode)
+	es.CloneEventFn = cloneEventGenerator(nil)
+
+	//access restriction tests
+	r = httptest.NewRequest("POST", "/api/v1-4/events/300/clone", strings.NewReader(`{}`))
+	roleAccessTest(t, r, h, &es, "testing_username", "300", []string{checkin.RoleOwner, checkin.RoleCoHost},
+		func(r *http.Response) {
+			test.Assert(t, r.StatusCode != http.StatusForbidden, "Host forbidden from cloning event")
+		})
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+	r = httptest.NewRequest("POST", "/api/v1-4/events/200/clone", strings.NewReader(`{}`))
+	eventDoesNotExistTest(t, r, h, &es)
+}
+
+func TestHandleCreateTemplate(t *testing.T) {
+	var es mock.EventService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleViewer, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	es.EventFn = func(ID string) (checkin.Event, error) {
+		return checkin.Event{ID: "300", Name: "Monthly Parade", URL: null.StringFrom("parade"),
+			CreatedAt: time.Date(2019, 2, 1, 0, 0, 0, 0, time.UTC)}, nil
+	}
+	var created checkin.EventTemplate
+	createTemplateGenerator := func(err error) func(checkin.EventTemplate) error {
+		return func(template checkin.EventTemplate) error {
+			created = template
+			return err
+		}
+	}
+	es.CreateTemplateFn = createTemplateGenerator(nil)
+	createTemplate := func(body string) *http.Response {
+		r := httptest.NewRequest("POST", "/api/v1-4/events/300/template", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	//test normal functionality, where the template has the details of the event without its ID and URL
+	res := createTemplate(`{"name":"Parade"}`)
+	test.Equals(t, http.StatusCreated, res.StatusCode)
+	var ID string
+	json.NewDecoder(res.Body).Decode(&ID)
+	test.Equals(t, checkin.EventTemplate{
+		ID:       ID,
+		Name:     "Parade",
+		Username: "testing_username",
+		Event:    checkin.Event{Name: "Monthly Parade", TimeTags: map[string]time.Time{}},
+	}, created)
+
+	//test invalid names
+	es.CreateTemplateInvoked = false
+	for _, body := range []string{`{}`, `{"name":""}`, `{"name":"` + strings.Repeat("a", 65) + `"}`, `{"name":"Parade","event":{}}`} {
+		res = createTemplate(body)
+		test.Equals(t, http.StatusBadRequest, res.StatusCode)
+	}
+	test.Assert(t, !es.CreateTemplateInvoked, "Template created with invalid name")
+
+	//test error creating template
+	es.CreateTemplateFn = createTemplateGenerator(errors.New("An error"))
+	res = createTemplate(`{"name":"Parade"}`)
+	test.Equals(t, http.StatusInternalServerError, res.StatusCode)
+	es.CreateTemplateFn = createTemplateGenerator(nil)
+
+	//access restriction tests
+	r := httptest.NewRequest("POST", "/api/v1-4/events/300/template", strings.NewReader(`{"name":"Parade"}`))
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+	r = httptest.NewRequest("POST", "/api/v1-4/events/200/template", strings.NewReader(`{"name":"Parade"}`))
+	eventDoesNotExistTest(t, r, h, &es)
+}
+
+func TestHandleTemplates(t *testing.T) {
+	var es mock.EventService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
+
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	templates := []checkin.EventTemplate{{ID: "1", Name: "Parade", Username: "testing_username",
+		Event: checkin.Event{Name: "Monthly Parade", TimeTags: map[string]time.Time{}}}}
+	var receivedOpts checkin.ListOptions
+	templatesGenerator := func(err error) func(string, checkin.ListOptions) ([]checkin.EventTemplate, int, error) {
+		return func(username string, opts checkin.ListOptions) ([]checkin.EventTemplate, int, error) {
+			test.Equals(t, "testing_username", username)
+			receivedOpts = opts
+			return templates, 3, err
+		}
+	}
+	es.TemplatesFn = templatesGenerator(nil)
+
+	//test normal functionality
+	r := httptest.NewRequest("GET", "/api/v1-4/events/templates?sort=createdAt&search=par", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	var fetched []checkin.EventTemplate
+	json.NewDecoder(w.Result().Body).Decode(&fetched)
+	test.Equals(t, templates, fetched)
+	test.Equals(t, "3", w.Result().Header.Get(myhttp.TotalCountHeader))
+	test.Equals(t, checkin.ListOptions{SortBy: checkin.SortByCreatedAt, Search: "par"}, receivedOpts)
+
+	//test invalid listing options
+	r = httptest.NewRequest("GET", "/api/v1-4/events/templates?sort=start", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+
+	//test error fetching templates
+	es.TemplatesFn = templatesGenerator(errors.New("An error"))
+	r = httptest.NewRequest("GET", "/api/v1-4/events/templates", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+
+	noValidTokenTest(t, r, h, &auth)
+}
+
+func TestHandleTemplate(t *testing.T) {
+	var es mock.EventService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
+
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	template := checkin.EventTemplate{ID: "1", Name: "Parade", Username: "testing_username",
+		Event: checkin.Event{Name: "Monthly Parade", TimeTags: map[string]time.Time{}}}
+	es.TemplateFn = templateGenerator(template, nil)
+	es.DeleteTemplateFn = func(ID string) error {
+		test.Equals(t, "1", ID)
+		return nil
+	}
+
+	//test fetching and deleting the template
+	r := httptest.NewRequest("GET", "/api/v1-4/events/templates/1", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	var fetched checkin.EventTemplate
+	json.NewDecoder(w.Result().Body).Decode(&fetched)
+	test.Equals(t, template, fetched)
+
+	r = httptest.NewRequest("DELETE", "/api/v1-4/events/templates/1", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Assert(t, es.DeleteTemplateInvoked, "Template not deleted")
+
+	//test template does not exist, or belongs to someone else
+	es.DeleteTemplateInvoked = false
+	for _, method := range []string{"GET", "DELETE"} {
+		r = httptest.NewRequest(method, "/api/v1-4/events/templates/2", nil)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		test.Equals(t, http.StatusNotFound, w.Result().StatusCode)
+
+		auth.GetAuthInfoFn = getAuthInfoGenerator("someone_else", false, nil)
+		r = httptest.NewRequest(method, "/api/v1-4/events/templates/1", nil)
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		test.Equals(t, http.StatusForbidden, w.Result().StatusCode)
+		auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	}
+	test.Assert(t, !es.DeleteTemplateInvoked, "Template deleted by someone else")
+
+	//test errors fetching and deleting the template
+	es.DeleteTemplateFn = func(ID string) error {
+		return errors.New("An error")
+	}
+	r = httptest.NewRequest("DELETE", "/api/v1-4/events/templates/1", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	es.TemplateFn = templateGenerator(template, errors.New("An error"))
+	r = httptest.NewRequest("GET", "/api/v1-4/events/templates/1", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+
+	noValidTokenTest(t, r, h, &auth)
+}
+
+func TestHandleInstantiateTemplate(t *testing.T) {
+	var es mock.EventService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &mock.TriggerService{}, &auth, &gh, 64, 64, 64)
+
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	es.URLExistsFn = urlExistsGenerator("takenurl", nil)
+	parade := checkin.EventTemplate{ID: "1", Name: "Parade", Username: "testing_username",
+		Event: checkin.Event{
+			Name:     "Monthly Parade",
+			Start:    null.TimeFrom(time.Date(2019, 3, 4, 8, 0, 0, 0, time.UTC)),
+			End:      null.TimeFrom(time.Date(2019, 3, 4, 10, 0, 0, 0, time.UTC)),
+			TimeTags: map[string]time.Time{},
+		}}
+	es.TemplateFn = templateGenerator(parade, nil)
+	var created checkin.Event
+	createEventGenerator := func(err error) func(checkin.Event, string) error {
+		return func(e checkin.Event, hostUsername string) error {
+			test.Equals(t, "testing_username", hostUsername)
+			created = e
+			return err
+		}
+	}
+	es.CreateEventFn = createEventGenerator(nil)
+	instantiate := func(ID string, body string) *http.Response {
+		r := httptest.NewRequest("POST", "/api/v1-4/events/templates/"+ID+"/events", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	//test normal functionality
+	res := instantiate("1", `{"url":"aprilparade","startDateTime":"2019-04-01T08:00:00Z"}`)
+	test.Equals(t, http.StatusCreated, res.StatusCode)
+	var ID string
+	json.NewDecoder(res.Body).Decode(&ID)
+	test.Equals(t, ID, created.ID)
+	test.Equals(t, "Monthly Parade", created.Name)
+	test.Equals(t, null.StringFrom("aprilparade"), created.URL)
+	test.Equals(t, time.Date(2019, 4, 1, 10, 0, 0, 0, time.UTC), created.End.Time.UTC())
+
+	//test the start is in the timezone of the template's event, unless ?loc is given
+	template := checkin.EventTemplate{ID: "3", Name: "Local Parade", Username: "testing_username",
+		Event: checkin.Event{
+			Name:     "Local Parade",
+			Timezone: "Asia/Singapore",
+			Start:    null.TimeFrom(time.Date(2019, 3, 4, 0, 0, 0, 0, time.UTC)),
+			TimeTags: map[string]time.Time{},
+		}}
+	es.TemplateFn = templateGenerator(template, nil)
+	res = instantiate("3", `{"startDateTime":"2019-04-01T16:00:00Z"}`)
+	test.Equals(t, http.StatusCreated, res.StatusCode)
+	test.Equals(t, time.Date(2019, 4, 1, 8, 0, 0, 0, time.UTC), created.Start.Time.UTC())
+	r := httptest.NewRequest("POST", "/api/v1-4/events/templates/3/events?loc=UTC",
+		strings.NewReader(`{"startDateTime":"2019-04-01T16:00:00Z"}`))
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	test.Equals(t, time.Date(2019, 4, 1, 16, 0, 0, 0, time.UTC), created.Start.Time.UTC())
+	es.TemplateFn = templateGenerator(template, errors.New("An error"))
+	res = instantiate("3", `{}`)
+	test.Equals(t, http.StatusInternalServerError, res.StatusCode)
+	es.TemplateFn = templateGenerator(parade, nil)
+
+	//test invalid options, and templates which cannot be used
+	es.CreateEventInvoked = false
+	res = instantiate("1", `{"guests":true}`)
+	test.Equals(t, http.StatusBadRequest, res.StatusCode)
+	res = instantiate("1", `{"extra":"field"}`)
+	test.Equals(t, http.StatusBadRequest, res.StatusCode)
+	res = instantiate("1", `{"url":"takenurl"}`)
+	test.Equals(t, http.StatusConflict, res.StatusCode)
+	res = instantiate("2", `{}`)
+	test.Equals(t, http.StatusNotFound, res.StatusCode)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("someone_else", false, nil)
+	res = instantiate("1", `{}`)
+	test.Equals(t, http.StatusForbidden, res.StatusCode)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	test.Assert(t, !es.CreateEventInvoked, "Event created from template which could not be used")
+
+	//test error creating event
+	es.CreateEventFn = createEventGenerator(errors.New("An error"))
+	res = instantiate("1", `{}`)
+	test.Equals(t, http.StatusInternalServerError, res.StatusCode)
+
+	r = httptest.NewRequest("POST", "/api/v1-4/events/templates/1/events", strings.NewReader(`{}`))
+	noValidTokenTest(t, r, h, &auth)
+}
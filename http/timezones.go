@@ -1,12 +1,15 @@
 package http
 
 import (
+	"checkin"
 	"encoding/json"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/gorilla/mux"
 )
 
 //Middleware which intercepts the response being written out, (assuming that it is in a JSON format), parses it to find any strings that are meant
@@ -16,48 +19,138 @@ import (
 //If no ?loc aergument is given, ensures timezones are in UTC
 func correctTimezonesOutput(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		locationName := r.FormValue("loc") //no loc will return "", which will be parsed as UTC
-		location, err := time.LoadLocation(locationName)
-		if err != nil {
-			WriteMessage(http.StatusBadRequest, "Could not parse location "+locationName+" in form argument loc. Use IANA time zone database names", w)
-			return
-		}
-		h.ServeHTTP(&timeZoneAdjustedWriter{w: w, loc: location}, r)
+		adjustOutputTimezones(h, w, r, "")
 	})
 }
 
 //Middleware which intercepts the request, assuming that its body is in a JSON format, and parses it to find any strings that are meant to be times
 //and then ensures that the time is interpreted to be of the timezone that is provided in the ?loc form query parameter
 //Timezone must follow the IANA time zone database names
-//If no ?loc argument is given, times will be interpreted as UTC
+//If no ?loc argument is given, times will be interpreted as being in the timezone field at the top level of the body
+//(as events are given in their own timezone), or as UTC if there is none
 func correctTimezonesInput(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		locationName := r.FormValue("loc") //no loc will return "", which will be parsed as UTC
-		location, err := time.LoadLocation(locationName)
-		if err != nil {
-			WriteMessage(http.StatusBadRequest, "Could not parse location "+locationName+" in form argument loc. Use IANA time zone database names", w)
-			return
-		}
-		res, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			log.Println("Could not read request body in correct timezone middleware: " + err.Error())
-			WriteMessage(http.StatusBadRequest, "Could not read request body to correct timezones", w)
-			return
-		}
-		json := string(res)
+		adjustInputTimezones(h, w, r, "")
+	})
+}
 
-		newJSON := correctJSONTimeZone(json, func(old time.Time) time.Time {
-			if old.IsZero() {
-				return old
+//eventTimezonesOutput works like correctTimezonesOutput, but writes times in the timezone of the event given by
+//the eventIDKey in the URL when there is no ?loc argument
+func eventTimezonesOutput(es checkin.EventService, eventIDKey string, logger *log.Logger) Adapter {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if timezone, ok := eventTimezone(es, mux.Vars(r)[eventIDKey], logger, w, r); ok {
+				adjustOutputTimezones(h, w, r, timezone)
 			}
-			return time.Date(old.Year(), old.Month(), old.Day(), old.Hour(), old.Minute(), old.Second(), old.Nanosecond(), location)
 		})
+	}
+}
+
+//eventTimezonesInput works like correctTimezonesInput, but interprets times in the timezone of the event given by
+//the eventIDKey in the URL when there is no ?loc argument or timezone in the body
+func eventTimezonesInput(es checkin.EventService, eventIDKey string, logger *log.Logger) Adapter {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if timezone, ok := eventTimezone(es, mux.Vars(r)[eventIDKey], logger, w, r); ok {
+				adjustInputTimezones(h, w, r, timezone)
+			}
+		})
+	}
+}
+
+//templateTimezonesInput works like eventTimezonesInput, but interprets times in the timezone of the event of the
+//template given by the templateIDKey in the URL. Templates which do not exist are left to the handler to reject
+func templateTimezonesInput(es checkin.EventService, templateIDKey string, logger *log.Logger) Adapter {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.FormValue("loc") != "" {
+				adjustInputTimezones(h, w, r, "")
+				return
+			}
+			template, _, err := es.Template(mux.Vars(r)[templateIDKey])
+			if err != nil {
+				logger.Println("Error fetching template timezone: " + err.Error())
+				WriteMessage(http.StatusInternalServerError, "Error fetching timezone of template", w)
+				return
+			}
+			adjustInputTimezones(h, w, r, template.Event.Timezone)
+		})
+	}
+}
 
-		r.Body = ioutil.NopCloser(strings.NewReader(newJSON))
-		r.ContentLength = int64(len(newJSON))
+//eventTimezone fetches the timezone of the event, unless it would be overridden by the ?loc argument anyway
+//Writes an error and returns false if the event could not be fetched
+func eventTimezone(es checkin.EventService, eventID string, logger *log.Logger, w http.ResponseWriter, r *http.Request) (string, bool) {
+	if r.FormValue("loc") != "" {
+		return "", true
+	}
+	event, err := es.Event(eventID)
+	if err != nil {
+		logger.Println("Error fetching event timezone: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching timezone of event", w)
+		return "", false
+	}
+	return event.Timezone, true
+}
+
+//requestLocation loads the timezone given by the ?loc argument of the request, or defaultName if there is none
+//Writes an error and returns false if it is not in the IANA time zone database
+func requestLocation(w http.ResponseWriter, r *http.Request, defaultName string) (*time.Location, bool) {
+	locationName := r.FormValue("loc")
+	if locationName == "" {
+		locationName = defaultName //"" will be parsed as UTC
+	}
+	location, err := time.LoadLocation(locationName)
+	if err != nil {
+		WriteMessage(http.StatusBadRequest, "Could not parse location "+locationName+" in form argument loc. Use IANA time zone database names", w)
+		return nil, false
+	}
+	return location, true
+}
+
+//adjustOutputTimezones serves the request, writing the times in the response in the timezone of the request
+func adjustOutputTimezones(h http.Handler, w http.ResponseWriter, r *http.Request, defaultName string) {
+	location, ok := requestLocation(w, r, defaultName)
+	if !ok {
+		return
+	}
+	h.ServeHTTP(&timeZoneAdjustedWriter{w: w, loc: location}, r)
+}
 
-		h.ServeHTTP(w, r)
+//adjustInputTimezones serves the request, with the times in its body interpreted in the timezone of the request
+//A timezone field at the top level of the body takes precedence over defaultName
+func adjustInputTimezones(h http.Handler, w http.ResponseWriter, r *http.Request, defaultName string) {
+	res, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		log.Println("Could not read request body in correct timezone middleware: " + err.Error())
+		WriteMessage(http.StatusBadRequest, "Could not read request body to correct timezones", w)
+		return
+	}
+	var body struct {
+		Timezone string `json:"timezone"`
+	}
+	if json.Unmarshal(res, &body) == nil && body.Timezone != "" {
+		if _, err := time.LoadLocation(body.Timezone); err == nil { //invalid ones are left to the handler to reject
+			defaultName = body.Timezone
+		}
+	}
+	location, ok := requestLocation(w, r, defaultName)
+	if !ok {
+		return
+	}
+	json := string(res)
+
+	newJSON := correctJSONTimeZone(json, func(old time.Time) time.Time {
+		if old.IsZero() {
+			return old
+		}
+		return time.Date(old.Year(), old.Month(), old.Day(), old.Hour(), old.Minute(), old.Second(), old.Nanosecond(), location)
 	})
+
+	r.Body = ioutil.NopCloser(strings.NewReader(newJSON))
+	r.ContentLength = int64(len(newJSON))
+
+	h.ServeHTTP(w, r)
 }
 
 //Writer which adjusts timezones being written to it before sending it to the client
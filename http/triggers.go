@@ -0,0 +1,155 @@
+package http
+
+import (
+	"checkin"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/guregu/null"
+)
+
+//webhookSecretBytes is the number of random bytes in the secret webhooks are signed with
+const webhookSecretBytes = 32
+
+//handleActions writes the actions fired by the triggers of the event given by the eventID in the URL
+func (h *EventHandler) handleActions(w http.ResponseWriter, r *http.Request) {
+	actions, err := h.TriggerService.Actions(mux.Vars(r)["eventID"])
+	if err != nil {
+		h.Logger.Println("Error fetching actions: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching actions of event", w)
+		return
+	}
+	reply, _ := json.Marshal(actions)
+	w.Write(reply)
+}
+
+//handleCreateAction adds the action in the body of the request to the event given by the eventID in the URL,
+//to be fired whenever its trigger next elapses, and writes its ID
+//Webhooks need an http(s) URL to be sent to, and are also given a secret to check their signatures with,
+//which is written only this once. Broadcasts need a message to send to the guests of the event
+func (h *EventHandler) handleCreateAction(w http.ResponseWriter, r *http.Request) {
+	var action checkin.TriggerAction
+	var details struct {
+		Trigger string      `json:"trigger"`
+		Type    string      `json:"type"`
+		URL     null.String `json:"url"`
+		Message null.String `json:"message"`
+	}
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&details)
+	if err != nil {
+		h.Logger.Println("Error decoding action JSON: " + err.Error())
+		WriteMessage(http.StatusBadRequest, "Badly formatted JSON in action (Possibly invalid fields)", w)
+		return
+	}
+	action.Trigger = strings.ToLower(details.Trigger)
+	if action.Trigger == "" || len(action.Trigger) > h.MaxLengthTimeTag {
+		WriteMessage(http.StatusBadRequest, "Action needs a trigger name which is not too long", w)
+		return
+	}
+	switch details.Type {
+	case checkin.ActionWebhook:
+		if !validWebhookURL(details.URL) || details.Message.Valid {
+			WriteMessage(http.StatusBadRequest, "Webhooks need an absolute http(s) URL, and no message", w)
+			return
+		}
+		action.Secret, err = createWebhookSecret()
+		if err != nil {
+			h.Logger.Println("Error creating webhook secret: " + err.Error())
+			WriteMessage(http.StatusInternalServerError, "Error creating webhook secret", w)
+			return
+		}
+	case checkin.ActionBroadcast:
+		if details.Message.String == "" || details.URL.Valid {
+			WriteMessage(http.StatusBadRequest, "Broadcasts need a message, and no URL", w)
+			return
+		}
+	default:
+		WriteMessage(http.StatusBadRequest, "Action type must be "+checkin.ActionWebhook+" or "+checkin.ActionBroadcast, w)
+		return
+	}
+
+	action.ID, action.EventID = uuid.New().String(), mux.Vars(r)["eventID"]
+	action.Type, action.URL, action.Message = details.Type, details.URL, details.Message
+	err = h.TriggerService.CreateAction(action)
+	if err != nil {
+		h.Logger.Println("Error creating action: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error creating action", w)
+		return
+	}
+	reply, _ := json.Marshal(struct {
+		ID     string `json:"actionId"`
+		Secret string `json:"secret,omitempty"`
+	}{action.ID, action.Secret})
+	w.WriteHeader(http.StatusCreated)
+	w.Write(reply)
+}
+
+//handleDeleteAction deletes the action given by the actionID in the URL from the event given by the eventID,
+//along with its deliveries
+func (h *EventHandler) handleDeleteAction(w http.ResponseWriter, r *http.Request) {
+	exists, err := h.TriggerService.DeleteAction(mux.Vars(r)["eventID"], mux.Vars(r)["actionID"])
+	if err != nil {
+		h.Logger.Println("Error deleting action: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error deleting action", w)
+		return
+	} else if !exists {
+		WriteMessage(http.StatusNotFound, "No such action for this event", w)
+		return
+	}
+	WriteOKMessage("Action deleted", w)
+}
+
+//handleDeliveries writes a page of the deliveries of the actions of the event given by the eventID in the URL,
+//with the total number of its deliveries in the X-Total-Count header
+//Sorting, searching (by trigger name) and pagination are controlled as in parseListOptions
+func (h *EventHandler) handleDeliveries(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseListOptions(r, checkin.SortByTime)
+	if err != nil {
+		WriteMessage(http.StatusBadRequest, err.Error(), w)
+		return
+	}
+	deliveries, total, err := h.TriggerService.Deliveries(mux.Vars(r)["eventID"], opts)
+	if err != nil {
+		h.Logger.Println("Error fetching deliveries: " + err.Error())
+		WriteMessage(http.StatusInternalServerError, "Error fetching deliveries of event", w)
+		return
+	}
+	writeTotalCount(total, w)
+	reply, _ := json.Marshal(deliveries)
+	w.Write(reply)
+}
+
+//validWebhookURL checks that the URL is an absolute http or https URL, whose host is not localhost or
+//an IP address which is not public
+//Hosts given by name are checked again once they are resolved, when the webhook is sent
+func validWebhookURL(rawURL null.String) bool {
+	if !rawURL.Valid {
+		return false
+	}
+	u, err := url.Parse(rawURL.String)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return false
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		return checkin.IsPublicIP(ip)
+	}
+	return strings.ToLower(strings.TrimSuffix(u.Hostname(), ".")) != "localhost"
+}
+
+//createWebhookSecret returns a random, hex encoded key to sign webhooks with
+func createWebhookSecret() (string, error) {
+	buf := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
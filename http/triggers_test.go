@@ -0,0 +1,287 @@
+package http_test
+
+import (
+	"checkin"
+	myhttp "checkin/http"
+	"checkin/mock"
+	"checkin/test"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+)
+
+func TestHandleActions(t *testing.T) {
+	var es mock.EventService
+	var ts mock.TriggerService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &ts, &auth, &gh, 64, 64, 64)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
+	es.EventFn = eventTimezoneGenerator("300", "")
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleViewer, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	actions := []checkin.TriggerAction{
+		{ID: "1", EventID: "300", Trigger: "release", Type: checkin.ActionWebhook, URL: null.StringFrom("https://example.com/hook"),
+			CreatedAt: time.Date(2019, 3, 1, 9, 0, 0, 0, time.UTC)},
+		{ID: "2", EventID: "300", Trigger: "formrelease", Type: checkin.ActionBroadcast, Message: null.StringFrom("Give us feedback!"),
+			CreatedAt: time.Date(2019, 3, 1, 9, 5, 0, 0, time.UTC)},
+	}
+	actionsGenerator := func(err error) func(string) ([]checkin.TriggerAction, error) {
+		return func(eventID string) ([]checkin.TriggerAction, error) {
+			test.Equals(t, "300", eventID)
+			return actions, err
+		}
+	}
+	ts.ActionsFn = actionsGenerator(nil)
+
+	//test normal functionality
+	r := httptest.NewRequest("GET", "/api/v1-4/events/300/actions", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	var fetched []checkin.TriggerAction
+	err := json.NewDecoder(w.Result().Body).Decode(&fetched)
+	test.Ok(t, err)
+	test.Equals(t, actions, fetched)
+
+	//test error fetching actions
+	ts.ActionsFn = actionsGenerator(errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	ts.ActionsFn = actionsGenerator(nil)
+
+	//access restriction tests
+	roleAccessTest(t, r, h, &es, "testing_username", "300",
+		[]string{checkin.RoleOwner, checkin.RoleCoHost, checkin.RoleUsher, checkin.RoleViewer},
+		func(r *http.Response) {
+			test.Equals(t, http.StatusOK, r.StatusCode)
+		})
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/actions", nil)
+	eventDoesNotExistTest(t, r, h, &es)
+}
+
+func TestHandleCreateAction(t *testing.T) {
+	var es mock.EventService
+	var ts mock.TriggerService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &ts, &auth, &gh, 64, 64, 16)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleCoHost, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	var created checkin.TriggerAction
+	createActionGenerator := func(err error) func(checkin.TriggerAction) error {
+		return func(a checkin.TriggerAction) error {
+			created = a
+			return err
+		}
+	}
+	ts.CreateActionFn = createActionGenerator(nil)
+	createAction := func(body string) *http.Response {
+		r := httptest.NewRequest("POST", "/api/v1-4/events/300/actions", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Result()
+	}
+	var reply struct {
+		ID     string `json:"actionId"`
+		Secret string `json:"secret"`
+	}
+
+	//test creating a webhook, which is given a secret
+	res := createAction(`{"trigger":"Release","type":"webhook","url":"https://example.com/hook"}`)
+	test.Equals(t, http.StatusCreated, res.StatusCode)
+	err := json.NewDecoder(res.Body).Decode(&reply)
+	test.Ok(t, err)
+	test.Equals(t, created.ID, reply.ID)
+	test.Equals(t, created.Secret, reply.Secret)
+	test.Equals(t, 64, len(reply.Secret))
+	test.Equals(t, "300", created.EventID)
+	test.Equals(t, "release", created.Trigger)
+	test.Equals(t, checkin.ActionWebhook, created.Type)
+	test.Equals(t, null.StringFrom("https://example.com/hook"), created.URL)
+	secret := reply.Secret
+	res = createAction(`{"trigger":"release","type":"webhook","url":"http://example.com/hook"}`)
+	test.Equals(t, http.StatusCreated, res.StatusCode)
+	test.Assert(t, created.Secret != secret, "Webhooks given the same secret")
+
+	//test creating a broadcast, which has no secret
+	reply.Secret = ""
+	res = createAction(`{"trigger":"formrelease","type":"broadcast","message":"Give us feedback!"}`)
+	test.Equals(t, http.StatusCreated, res.StatusCode)
+	err = json.NewDecoder(res.Body).Decode(&reply)
+	test.Ok(t, err)
+	test.Equals(t, "", reply.Secret)
+	test.Equals(t, "", created.Secret)
+	test.Equals(t, null.StringFrom("Give us feedback!"), created.Message)
+
+	//test invalid actions
+	ts.CreateActionInvoked = false
+	for _, body := range []string{
+		`{"trigger":"release","type":"email","url":"https://example.com/hook"}`,
+		`{"trigger":"","type":"webhook","url":"https://example.com/hook"}`,
+		`{"trigger":"registrationstart","type":"webhook","url":"https://example.com/hook"}`,
+		`{"trigger":"release","type":"webhook"}`,
+		`{"trigger":"release","type":"webhook","url":"/hook"}`,
+		`{"trigger":"release","type":"webhook","url":"ftp://example.com/hook"}`,
+		`{"trigger":"release","type":"webhook","url":"http://localhost:8080/hook"}`,
+		`{"trigger":"release","type":"webhook","url":"http://10.0.0.5/hook"}`,
+		`{"trigger":"release","type":"webhook","url":"http://169.254.169.254/latest/meta-data"}`,
+		`{"trigger":"release","type":"webhook","url":"http://[::1]/hook"}`,
+		`{"trigger":"release","type":"webhook","url":"https://example.com/hook","message":"Hi"}`,
+		`{"trigger":"release","type":"broadcast"}`,
+		`{"trigger":"release","type":"broadcast","message":""}`,
+		`{"trigger":"release","type":"broadcast","message":"Hi","url":"https://example.com/hook"}`,
+		`{"trigger":"release","type":"broadcast","message":"Hi","secret":"mine"}`,
+		`not json`,
+	} {
+		res = createAction(body)
+		test.Equals(t, http.StatusBadRequest, res.StatusCode)
+	}
+	test.Assert(t, !ts.CreateActionInvoked, "Invalid action created")
+
+	//test error creating action
+	ts.CreateActionFn = createActionGenerator(errors.New("An error"))
+	res = createAction(`{"trigger":"release","type":"webhook","url":"https://example.com/hook"}`)
+	test.Equals(t, http.StatusInternalServerError, res.StatusCode)
+	ts.CreateActionFn = createActionGenerator(nil)
+
+	//access restriction tests
+	r := httptest.NewRequest("POST", "/api/v1-4/events/300/actions",
+		strings.NewReader(`{"trigger":"release","type":"webhook","url":"https://example.com/hook"}`))
+	roleAccessTest(t, r, h, &es, "testing_username", "300", []string{checkin.RoleOwner, checkin.RoleCoHost},
+		func(r *http.Response) {
+			test.Assert(t, r.StatusCode != http.StatusForbidden, "Host forbidden from creating action")
+		})
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+	r = httptest.NewRequest("POST", "/api/v1-4/events/100/actions", strings.NewReader(`{}`))
+	eventDoesNotExistTest(t, r, h, &es)
+}
+
+func TestHandleDeleteAction(t *testing.T) {
+	var es mock.EventService
+	var ts mock.TriggerService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &ts, &auth, &gh, 64, 64, 64)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleOwner, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	deleteActionGenerator := func(err error) func(string, string) (bool, error) {
+		return func(eventID string, actionID string) (bool, error) {
+			test.Equals(t, "300", eventID)
+			return actionID == "1", err
+		}
+	}
+	ts.DeleteActionFn = deleteActionGenerator(nil)
+
+	//test normal functionality
+	r := httptest.NewRequest("DELETE", "/api/v1-4/events/300/actions/1", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Assert(t, ts.DeleteActionInvoked, "Action not deleted")
+
+	//test action does not exist
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/v1-4/events/300/actions/2", nil))
+	test.Equals(t, http.StatusNotFound, w.Result().StatusCode)
+
+	//test error deleting action
+	ts.DeleteActionFn = deleteActionGenerator(errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	ts.DeleteActionFn = deleteActionGenerator(nil)
+
+	//access restriction tests
+	roleAccessTest(t, r, h, &es, "testing_username", "300", []string{checkin.RoleOwner, checkin.RoleCoHost},
+		func(r *http.Response) {
+			test.Equals(t, http.StatusOK, r.StatusCode)
+		})
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+	r = httptest.NewRequest("DELETE", "/api/v1-4/events/100/actions/1", nil)
+	eventDoesNotExistTest(t, r, h, &es)
+}
+
+func TestHandleDeliveries(t *testing.T) {
+	var es mock.EventService
+	var ts mock.TriggerService
+	var auth mock.Authenticator
+	gh := myhttp.GuestHandler{}
+	h := myhttp.NewEventHandler(&es, &mock.UserService{}, &mock.GuestSiteService{}, &ts, &auth, &gh, 64, 64, 64)
+
+	es.CheckIfExistsFn = checkIfExistsGenerator("300", nil)
+	es.EventFn = eventTimezoneGenerator("300", "")
+	es.HostRoleFn = hostRoleGenerator("testing_username", "300", checkin.RoleViewer, nil)
+	auth.AuthenticateFn = authenticateGenerator(true, nil)
+	auth.GetAuthInfoFn = getAuthInfoGenerator("testing_username", false, nil)
+	deliveries := []checkin.Delivery{
+		{ID: "10", ActionID: "1", EventID: "300", Trigger: "release", TriggerTime: time.Date(2019, 3, 1, 9, 0, 0, 0, time.UTC),
+			Status: checkin.DeliveryDelivered, Attempts: 1, DeliveredAt: null.TimeFrom(time.Date(2019, 3, 1, 9, 0, 2, 0, time.UTC))},
+		{ID: "11", ActionID: "1", EventID: "300", Trigger: "release", TriggerTime: time.Date(2019, 3, 2, 9, 0, 0, 0, time.UTC),
+			Status: checkin.DeliveryPending, Attempts: 2, NextAttempt: null.TimeFrom(time.Date(2019, 3, 2, 9, 2, 0, 0, time.UTC)),
+			LastError: null.StringFrom("Status 500")},
+	}
+	var listOpts checkin.ListOptions
+	deliveriesGenerator := func(err error) func(string, checkin.ListOptions) ([]checkin.Delivery, int, error) {
+		return func(eventID string, opts checkin.ListOptions) ([]checkin.Delivery, int, error) {
+			test.Equals(t, "300", eventID)
+			listOpts = opts
+			return deliveries, 7, err
+		}
+	}
+	ts.DeliveriesFn = deliveriesGenerator(nil)
+
+	//test normal functionality, with the total in the header
+	r := httptest.NewRequest("GET", "/api/v1-4/events/300/deliveries?limit=2&order=desc&search=rel", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, "7", w.Result().Header.Get(myhttp.TotalCountHeader))
+	test.Equals(t, checkin.ListOptions{Limit: 2, Descending: true, Search: "rel"}, listOpts)
+	var fetched []checkin.Delivery
+	err := json.NewDecoder(w.Result().Body).Decode(&fetched)
+	test.Ok(t, err)
+	test.Equals(t, deliveries, fetched)
+
+	//test invalid list options and errors
+	ts.DeliveriesInvoked = false
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1-4/events/300/deliveries?sort=name", nil))
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
+	test.Assert(t, !ts.DeliveriesInvoked, "Deliveries fetched with invalid list options")
+	ts.DeliveriesFn = deliveriesGenerator(errors.New("An error"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
+	ts.DeliveriesFn = deliveriesGenerator(nil)
+
+	//access restriction tests
+	roleAccessTest(t, r, h, &es, "testing_username", "300",
+		[]string{checkin.RoleOwner, checkin.RoleCoHost, checkin.RoleUsher, checkin.RoleViewer},
+		func(r *http.Response) {
+			test.Equals(t, http.StatusOK, r.StatusCode)
+		})
+	nonHostAccessTest(t, r, h, &auth, &es, "unauthorized_person")
+	noValidTokenTest(t, r, h, &auth)
+	r = httptest.NewRequest("GET", "/api/v1-4/events/100/deliveries", nil)
+	eventDoesNotExistTest(t, r, h, &es)
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/guregu/null"
 )
 
 //UserHandler An extension of mux.Router which handles all user-related requests
@@ -53,12 +54,19 @@ func NewUserHandler(us checkin.UserService, auth Authenticator) *UserHandler {
 
 //handleUsers Sends JSON array of all users
 func (h *UserHandler) handleUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := h.UserService.Users()
+	opts, err := parseListOptions(r, checkin.SortByUsername, checkin.SortByName, checkin.SortByCreatedAt, checkin.SortByLastLoggedIn)
+	if err != nil {
+		WriteMessage(http.StatusBadRequest, err.Error(), w)
+		return
+	}
+
+	users, total, err := h.UserService.Users(opts)
 	if err != nil {
 		h.Logger.Println("Error fetching all user data: " + err.Error())
 		WriteMessage(http.StatusInternalServerError, "Could not get user data", w)
 		return
 	}
+	writeTotalCount(total, w)
 	reply, _ := json.Marshal(users)
 	w.Write(reply)
 }
@@ -72,6 +80,7 @@ func (h *UserHandler) handleUser(w http.ResponseWriter, r *http.Request) {
 		WriteMessage(http.StatusInternalServerError, "Could not get user data", w)
 		return
 	}
+	w.Header().Set(ETagHeader, etag(user.UpdatedAt))
 	reply, _ := json.Marshal(user)
 	w.Write(reply)
 }
@@ -121,6 +130,7 @@ func validateCreateInputs(u checkin.User) bool {
 
 //handleUpdateUser Reads the JSON as a map, only attributes to be updated need
 //be supplied
+//If the If-Match header is given, the user is only updated if their ETag matches
 func (h *UserHandler) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 	user, err := h.UserService.User(mux.Vars(r)["username"])
 	if err != nil {
@@ -128,6 +138,10 @@ func (h *UserHandler) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 		WriteMessage(http.StatusInternalServerError, "Could not fetch original user data", w)
 		return
 	}
+	if !ifMatch(r, etag(user.UpdatedAt)) {
+		WriteMessage(http.StatusPreconditionFailed, "User has been changed since they were fetched", w)
+		return
+	}
 	original := user
 	dec := json.NewDecoder(r.Body)
 	dec.DisallowUnknownFields()
@@ -157,10 +171,13 @@ func (h *UserHandler) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	err = h.UserService.UpdateUser(original.Username, user)
+	//only update the user as they were read, in case they are changed while this update is made
+	updated, err := h.UserService.UpdateUser(original.Username, user, null.TimeFrom(original.UpdatedAt))
 	if err != nil {
 		h.Logger.Println("Error updating user: " + err.Error())
 		WriteMessage(http.StatusInternalServerError, "Error updating user", w)
+	} else if !updated {
+		WriteMessage(http.StatusPreconditionFailed, "User has been changed since they were fetched", w)
 	} else {
 		WriteOKMessage("User updated", w)
 	}
@@ -168,11 +185,13 @@ func (h *UserHandler) handleUpdateUser(w http.ResponseWriter, r *http.Request) {
 
 func (h *UserHandler) handleDeleteUser(w http.ResponseWriter, r *http.Request) {
 	username := mux.Vars(r)["username"] //user already confirmed to exist through middleware
-	err := h.UserService.DeleteUser(username)
+	deleted, err := h.UserService.DeleteUser(username)
 
 	if err != nil {
 		h.Logger.Println("Error deleting user: " + err.Error())
 		WriteMessage(http.StatusInternalServerError, "Error deleting user", w)
+	} else if !deleted {
+		WriteMessage(http.StatusConflict, "User still owns events; transfer their ownership first", w)
 	} else {
 		WriteOKMessage("Successfully deleted user", w)
 	}
@@ -66,12 +66,14 @@ func TestHandleUsers(t *testing.T) {
 
 	auth.AuthenticateFn = authenticateGenerator(true, nil)
 	auth.GetAuthInfoFn = getAuthInfoGenerator("my_admin", true, nil)
-	usersFnGenerator := func(users []checkin.User, err error) func() ([]checkin.User, error) {
-		return func() ([]checkin.User, error) {
+	var receivedOpts checkin.ListOptions
+	usersFnGenerator := func(users []checkin.User, err error) func(checkin.ListOptions) ([]checkin.User, int, error) {
+		return func(opts checkin.ListOptions) ([]checkin.User, int, error) {
+			receivedOpts = opts
 			if err != nil {
-				return nil, err
+				return nil, 0, err
 			}
-			return users, nil
+			return users, len(users), nil
 		}
 	}
 	us.UsersFn = usersFnGenerator([]checkin.User{checkin.User{Username: "Jim"}, checkin.User{Username: "Bob", CreatedAt: time.Date(2019, 1, 9, 13, 30, 0, 0, time.UTC)},
@@ -85,6 +87,20 @@ func TestHandleUsers(t *testing.T) {
 	json.NewDecoder(w.Result().Body).Decode(&users)
 	test.Equals(t, []checkin.User{checkin.User{Username: "Jim"}, checkin.User{Username: "Bob", CreatedAt: time.Date(2019, 1, 9, 13, 30, 0, 0, time.UTC)},
 		checkin.User{Username: "Smith", LastLoggedIn: null.TimeFrom(time.Date(2019, 2, 14, 14, 30, 0, 0, time.UTC))}}, users)
+	test.Equals(t, "3", w.Result().Header.Get(myhttp.TotalCountHeader))
+	test.Equals(t, checkin.ListOptions{}, receivedOpts)
+
+	//test pagination, sorting and search
+	r = httptest.NewRequest("GET", "/api/v0/users?limit=10&sort=lastLoggedIn&order=desc&search=sm", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	test.Equals(t, checkin.ListOptions{SortBy: checkin.SortByLastLoggedIn, Descending: true, Search: "sm", Limit: 10}, receivedOpts)
+
+	r = httptest.NewRequest("GET", "/api/v0/users?sort=start", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusBadRequest, w.Result().StatusCode)
 
 	//test specifying fields desired
 	r = httptest.NewRequest("GET", "/api/v0/users?field=uSeRName", nil)
@@ -189,6 +205,7 @@ func TestHandleUser(t *testing.T) {
 	var user checkin.User
 	json.NewDecoder(w.Result().Body).Decode(&user)
 	test.Equals(t, checkin.User{Username: "somebody", UpdatedAt: time.Date(2018, 2, 13, 10, 30, 0, 0, time.UTC)}, user)
+	test.Assert(t, w.Result().Header.Get(myhttp.ETagHeader) != "", "No ETag given for user")
 
 	//Test get specific fields only
 	r = httptest.NewRequest("GET", "/api/v0/users/somebody?field=uPDatedat", nil)
@@ -367,24 +384,25 @@ func TestHandleUpdateUser(t *testing.T) {
 		}
 	}
 	us.CheckIfExistsFn = checkIfExistsGenerator("bob", nil, false)
-	updateUserFnGenerator := func(expectedUsername string, expectedUser checkin.User, err error) func(string, checkin.User) error {
-		return func(originalUsername string, u checkin.User) error {
+	updateUserFnGenerator := func(expectedUsername string, expectedUser checkin.User, err error) func(string, checkin.User, null.Time) (bool, error) {
+		return func(originalUsername string, u checkin.User, ifUpdatedAt null.Time) (bool, error) {
 			//all fields are the same and
 			//passwords equal
 			test.Equals(t, expectedUsername, originalUsername)
+			test.Equals(t, null.TimeFrom(expectedUser.UpdatedAt), ifUpdatedAt) //only update the user as they were read
 			if u.PasswordPlaintext == expectedUser.PasswordPlaintext || (u.PasswordPlaintext != nil &&
 				expectedUser.PasswordPlaintext != nil &&
 				*u.PasswordPlaintext == *expectedUser.PasswordPlaintext) {
 				u.PasswordPlaintext, expectedUser.PasswordPlaintext = nil, nil
 				if u == expectedUser {
-					return err
+					return err == nil, err
 				} else {
 					t.Fatal("Unexpected user in create user. Received: ", u, ", expected: ", expectedUser)
 				}
 			} else {
 				t.Fatal("Unexpected user in create user. Received: ", u, ", expected: ", expectedUser)
 			}
-			return err
+			return err == nil, err
 		}
 	}
 	pwd := "5678"
@@ -499,6 +517,41 @@ func TestHandleUpdateUser(t *testing.T) {
 	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
 	us.UpdateUserFn = updateUserFnGenerator("bob", expUser, nil)
 
+	//check updating only if the user has not changed since they were fetched
+	r = httptest.NewRequest("GET", "/api/v0/users/bob", nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	tag := w.Result().Header.Get(myhttp.ETagHeader)
+	r = httptest.NewRequest("PATCH", "/api/v0/users/bob",
+		strings.NewReader("{\"username\":\"max\",\"password\":\"5678\",\"name\":\"Max\"}"))
+	r.Header.Set("If-Match", tag)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusOK, w.Result().StatusCode)
+	changed := original
+	changed.UpdatedAt = original.UpdatedAt.Add(time.Second)
+	us.UserFn = userFnGenerator(changed, nil)
+	us.UpdateUserInvoked = false
+	r = httptest.NewRequest("PATCH", "/api/v0/users/bob",
+		strings.NewReader("{\"username\":\"max\",\"password\":\"5678\",\"name\":\"Max\"}"))
+	r.Header.Set("If-Match", tag)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusPreconditionFailed, w.Result().StatusCode)
+	test.Assert(t, !us.UpdateUserInvoked, "Update user invoked even though user changed since they were fetched")
+	us.UserFn = userFnGenerator(original, nil)
+
+	//check user changed while being updated
+	us.UpdateUserFn = func(originalUsername string, u checkin.User, ifUpdatedAt null.Time) (bool, error) {
+		return false, nil
+	}
+	r = httptest.NewRequest("PATCH", "/api/v0/users/bob",
+		strings.NewReader("{\"username\":\"max\",\"password\":\"5678\",\"name\":\"Max\"}"))
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusPreconditionFailed, w.Result().StatusCode)
+	us.UpdateUserFn = updateUserFnGenerator("bob", expUser, nil)
+
 	//Test access controls: another user should fail to access
 	//Admin should succeed
 	//No valid token should fail to access
@@ -527,15 +580,15 @@ func TestHandleDeleteUser(t *testing.T) {
 	auth.AuthenticateFn = authenticateGenerator(true, nil)
 	auth.GetAuthInfoFn = getAuthInfoGenerator("somebody", false, nil)
 	us.CheckIfExistsFn = checkIfExistsGenerator("somebody", nil)
-	deleteUserFnGenerator := func(err error) func(string) error {
-		return func(username string) error {
+	deleteUserFnGenerator := func(deleted bool, err error) func(string) (bool, error) {
+		return func(username string) (bool, error) {
 			if username != "somebody" {
 				t.Fatal("Expected username somebody instead got: " + username)
 			}
-			return err
+			return deleted, err
 		}
 	}
-	us.DeleteUserFn = deleteUserFnGenerator(nil)
+	us.DeleteUserFn = deleteUserFnGenerator(true, nil)
 
 	//Test normal behavior
 	r := httptest.NewRequest("DELETE", "/api/v0/users/somebody", nil)
@@ -543,12 +596,18 @@ func TestHandleDeleteUser(t *testing.T) {
 	h.ServeHTTP(w, r)
 	test.Equals(t, http.StatusOK, w.Result().StatusCode)
 
+	//Test user who still owns events
+	us.DeleteUserFn = deleteUserFnGenerator(false, nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	test.Equals(t, http.StatusConflict, w.Result().StatusCode)
+
 	//Test error getting user
-	us.DeleteUserFn = deleteUserFnGenerator(errors.New("An error"))
+	us.DeleteUserFn = deleteUserFnGenerator(false, errors.New("An error"))
 	w = httptest.NewRecorder()
 	h.ServeHTTP(w, r)
 	test.Equals(t, http.StatusInternalServerError, w.Result().StatusCode)
-	us.DeleteUserFn = deleteUserFnGenerator(nil)
+	us.DeleteUserFn = deleteUserFnGenerator(true, nil)
 
 	//Test access controls: a *different* user should fail to access
 	//Admin should succeed
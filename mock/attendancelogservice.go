@@ -0,0 +1,26 @@
+package mock
+
+import (
+	"checkin"
+)
+
+//AttendanceLogService represents a mock implementation of the checkin.AttendanceLogService interface
+type AttendanceLogService struct {
+	AttendanceLogFn      func(eventID string, opts checkin.ListOptions) ([]checkin.AttendanceEntry, int, error)
+	AttendanceLogInvoked bool
+
+	CheckInFlagsFn      func(eventID string, opts checkin.ListOptions) ([]checkin.CheckInFlag, int, error)
+	CheckInFlagsInvoked bool
+}
+
+//AttendanceLog invokes the mock implementation and marks the function as invoked
+func (als *AttendanceLogService) AttendanceLog(eventID string, opts checkin.ListOptions) ([]checkin.AttendanceEntry, int, error) {
+	als.AttendanceLogInvoked = true
+	return als.AttendanceLogFn(eventID, opts)
+}
+
+//CheckInFlags invokes the mock implementation and marks the function as invoked
+func (als *AttendanceLogService) CheckInFlags(eventID string, opts checkin.ListOptions) ([]checkin.CheckInFlag, int, error) {
+	als.CheckInFlagsInvoked = true
+	return als.CheckInFlagsFn(eventID, opts)
+}
@@ -15,6 +15,12 @@ type Authenticator struct {
 
 	AuthenticateFn      func(r *http.Request) (bool, error)
 	AuthenticateInvoked bool
+
+	RefreshAuthorizationFn      func(refreshToken string, w http.ResponseWriter) (bool, error)
+	RefreshAuthorizationInvoked bool
+
+	RevokeAuthorizationFn      func(r *http.Request, refreshToken string) error
+	RevokeAuthorizationInvoked bool
 }
 
 //IssueAuthorization calls the injected function and marks it as invoked
@@ -34,3 +40,15 @@ func (a *Authenticator) Authenticate(r *http.Request) (bool, error) {
 	a.AuthenticateInvoked = true
 	return a.AuthenticateFn(r)
 }
+
+//RefreshAuthorization calls the injected function and marks it as invoked
+func (a *Authenticator) RefreshAuthorization(refreshToken string, w http.ResponseWriter) (bool, error) {
+	a.RefreshAuthorizationInvoked = true
+	return a.RefreshAuthorizationFn(refreshToken, w)
+}
+
+//RevokeAuthorization calls the injected function and marks it as invoked
+func (a *Authenticator) RevokeAuthorization(r *http.Request, refreshToken string) error {
+	a.RevokeAuthorizationInvoked = true
+	return a.RevokeAuthorizationFn(r, refreshToken)
+}
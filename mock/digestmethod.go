@@ -0,0 +1,13 @@
+package mock
+
+//DigestMethod is a mock implementation of checkin.DigestMethod
+type DigestMethod struct {
+	DigestFn      func(str string) (string, error)
+	DigestInvoked bool
+}
+
+//Digest invokes the mock function and labels it as such (setting DigestInvoked to true)
+func (dm *DigestMethod) Digest(str string) (string, error) {
+	dm.DigestInvoked = true
+	return dm.DigestFn(str)
+}
@@ -2,6 +2,9 @@ package mock
 
 import (
 	"checkin"
+	"time"
+
+	"github.com/guregu/null"
 )
 
 //EventService represents a mock implementation of the checkin.EventService interface
@@ -12,10 +15,10 @@ type EventService struct {
 	EventByURLFn      func(url string) (checkin.Event, error)
 	EventByURLInvoked bool
 
-	EventsByFn      func(username string) ([]checkin.Event, error)
+	EventsByFn      func(username string, opts checkin.ListOptions) ([]checkin.Event, int, error)
 	EventsByInvoked bool
 
-	EventsFn      func() ([]checkin.Event, error)
+	EventsFn      func(filter checkin.EventFilter, opts checkin.ListOptions) ([]checkin.Event, int, error)
 	EventsInvoked bool
 
 	CreateEventFn      func(e checkin.Event, hostUsername string) error
@@ -24,20 +27,74 @@ type EventService struct {
 	DeleteEventFn      func(ID string) error
 	DeleteEventInvoked bool
 
-	UpdateEventFn      func(event checkin.Event) error
+	ArchiveEventFn      func(ID string) error
+	ArchiveEventInvoked bool
+
+	RestoreEventFn      func(ID string, archivedSince time.Time) (bool, error)
+	RestoreEventInvoked bool
+
+	PurgeEventsFn      func(archivedBefore time.Time) (int, error)
+	PurgeEventsInvoked bool
+
+	UpdateEventFn      func(event checkin.Event, ifUpdatedAt null.Time) (bool, error)
 	UpdateEventInvoked bool
 
+	SetTimeTagFn      func(eventID string, tag string, t time.Time) error
+	SetTimeTagInvoked bool
+
+	RemoveTimeTagFn      func(eventID string, tag string) error
+	RemoveTimeTagInvoked bool
+
 	URLExistsFn      func(url string) (bool, error)
 	URLExistsInvoked bool
 
 	CheckIfExistsFn      func(id string) (bool, error)
 	CheckIfExistsInvoked bool
 
-	AddHostFn      func(eventID string, username string) error
+	AddHostFn      func(eventID string, username string, role string) error
 	AddHostInvoked bool
 
-	CheckHostFn      func(username string, eventID string) (bool, error)
-	CheckHostInvoked bool
+	HostRoleFn      func(username string, eventID string) (string, error)
+	HostRoleInvoked bool
+
+	HostsFn      func(eventID string) ([]checkin.Host, error)
+	HostsInvoked bool
+
+	RemoveHostFn      func(eventID string, username string) error
+	RemoveHostInvoked bool
+
+	TransferOwnershipFn      func(eventID string, username string) error
+	TransferOwnershipInvoked bool
+
+	CloneEventFn      func(ID string, e checkin.Event, hostUsername string, copyGuests bool) error
+	CloneEventInvoked bool
+
+	TemplatesFn      func(username string, opts checkin.ListOptions) ([]checkin.EventTemplate, int, error)
+	TemplatesInvoked bool
+
+	TemplateFn      func(ID string) (checkin.EventTemplate, bool, error)
+	TemplateInvoked bool
+
+	CreateTemplateFn      func(t checkin.EventTemplate) error
+	CreateTemplateInvoked bool
+
+	DeleteTemplateFn      func(ID string) error
+	DeleteTemplateInvoked bool
+
+	SessionsFn      func(eventID string) ([]checkin.Session, error)
+	SessionsInvoked bool
+
+	SessionFn      func(eventID string, sessionID string) (checkin.Session, bool, error)
+	SessionInvoked bool
+
+	CreateSessionFn      func(s checkin.Session) error
+	CreateSessionInvoked bool
+
+	UpdateSessionFn      func(s checkin.Session) error
+	UpdateSessionInvoked bool
+
+	DeleteSessionFn      func(eventID string, sessionID string) (bool, error)
+	DeleteSessionInvoked bool
 
 	FeedbackFormsFn      func(ID string) ([]checkin.FeedbackForm, error)
 	FeedbackFormsInvoked bool
@@ -53,15 +110,15 @@ func (es *EventService) Event(ID string) (checkin.Event, error) {
 }
 
 //EventsBy invokes the mock implementation and marks the function as invoked
-func (es *EventService) EventsBy(username string) ([]checkin.Event, error) {
+func (es *EventService) EventsBy(username string, opts checkin.ListOptions) ([]checkin.Event, int, error) {
 	es.EventsByInvoked = true
-	return es.EventsByFn(username)
+	return es.EventsByFn(username, opts)
 }
 
 //Events invokes the mock implementation and marks the function as invoked
-func (es *EventService) Events() ([]checkin.Event, error) {
+func (es *EventService) Events(filter checkin.EventFilter, opts checkin.ListOptions) ([]checkin.Event, int, error) {
 	es.EventsInvoked = true
-	return es.EventsFn()
+	return es.EventsFn(filter, opts)
 }
 
 //CreateEvent invokes the mock implementation and marks the function as invoked
@@ -76,10 +133,40 @@ func (es *EventService) DeleteEvent(ID string) error {
 	return es.DeleteEventFn(ID)
 }
 
+//ArchiveEvent invokes the mock implementation and marks the function as invoked
+func (es *EventService) ArchiveEvent(ID string) error {
+	es.ArchiveEventInvoked = true
+	return es.ArchiveEventFn(ID)
+}
+
+//RestoreEvent invokes the mock implementation and marks the function as invoked
+func (es *EventService) RestoreEvent(ID string, archivedSince time.Time) (bool, error) {
+	es.RestoreEventInvoked = true
+	return es.RestoreEventFn(ID, archivedSince)
+}
+
+//PurgeEvents invokes the mock implementation and marks the function as invoked
+func (es *EventService) PurgeEvents(archivedBefore time.Time) (int, error) {
+	es.PurgeEventsInvoked = true
+	return es.PurgeEventsFn(archivedBefore)
+}
+
 //UpdateEvent invokes the mock implementation and marks the function as invoked
-func (es *EventService) UpdateEvent(event checkin.Event) error {
+func (es *EventService) UpdateEvent(event checkin.Event, ifUpdatedAt null.Time) (bool, error) {
 	es.UpdateEventInvoked = true
-	return es.UpdateEventFn(event)
+	return es.UpdateEventFn(event, ifUpdatedAt)
+}
+
+//SetTimeTag invokes the mock implementation and marks the function as invoked
+func (es *EventService) SetTimeTag(eventID string, tag string, t time.Time) error {
+	es.SetTimeTagInvoked = true
+	return es.SetTimeTagFn(eventID, tag, t)
+}
+
+//RemoveTimeTag invokes the mock implementation and marks the function as invoked
+func (es *EventService) RemoveTimeTag(eventID string, tag string) error {
+	es.RemoveTimeTagInvoked = true
+	return es.RemoveTimeTagFn(eventID, tag)
 }
 
 //URLExists invokes the mock implementation and marks the function as invoked
@@ -95,15 +182,93 @@ func (es *EventService) CheckIfExists(id string) (bool, error) {
 }
 
 //AddHost invokes the mock implementation and marks the function as invoked
-func (es *EventService) AddHost(eventID string, username string) error {
+func (es *EventService) AddHost(eventID string, username string, role string) error {
 	es.AddHostInvoked = true
-	return es.AddHostFn(eventID, username)
+	return es.AddHostFn(eventID, username, role)
+}
+
+//HostRole invokes the mock implementation and marks the function as invoked
+func (es *EventService) HostRole(username string, eventID string) (string, error) {
+	es.HostRoleInvoked = true
+	return es.HostRoleFn(username, eventID)
+}
+
+//Hosts invokes the mock implementation and marks the function as invoked
+func (es *EventService) Hosts(eventID string) ([]checkin.Host, error) {
+	es.HostsInvoked = true
+	return es.HostsFn(eventID)
+}
+
+//RemoveHost invokes the mock implementation and marks the function as invoked
+func (es *EventService) RemoveHost(eventID string, username string) error {
+	es.RemoveHostInvoked = true
+	return es.RemoveHostFn(eventID, username)
+}
+
+//TransferOwnership invokes the mock implementation and marks the function as invoked
+func (es *EventService) TransferOwnership(eventID string, username string) error {
+	es.TransferOwnershipInvoked = true
+	return es.TransferOwnershipFn(eventID, username)
+}
+
+//CloneEvent invokes the mock implementation and marks the function as invoked
+func (es *EventService) CloneEvent(ID string, e checkin.Event, hostUsername string, copyGuests bool) error {
+	es.CloneEventInvoked = true
+	return es.CloneEventFn(ID, e, hostUsername, copyGuests)
+}
+
+//Templates invokes the mock implementation and marks the function as invoked
+func (es *EventService) Templates(username string, opts checkin.ListOptions) ([]checkin.EventTemplate, int, error) {
+	es.TemplatesInvoked = true
+	return es.TemplatesFn(username, opts)
+}
+
+//Template invokes the mock implementation and marks the function as invoked
+func (es *EventService) Template(ID string) (checkin.EventTemplate, bool, error) {
+	es.TemplateInvoked = true
+	return es.TemplateFn(ID)
+}
+
+//CreateTemplate invokes the mock implementation and marks the function as invoked
+func (es *EventService) CreateTemplate(t checkin.EventTemplate) error {
+	es.CreateTemplateInvoked = true
+	return es.CreateTemplateFn(t)
+}
+
+//DeleteTemplate invokes the mock implementation and marks the function as invoked
+func (es *EventService) DeleteTemplate(ID string) error {
+	es.DeleteTemplateInvoked = true
+	return es.DeleteTemplateFn(ID)
+}
+
+//Sessions invokes the mock implementation and marks the function as invoked
+func (es *EventService) Sessions(eventID string) ([]checkin.Session, error) {
+	es.SessionsInvoked = true
+	return es.SessionsFn(eventID)
+}
+
+//Session invokes the mock implementation and marks the function as invoked
+func (es *EventService) Session(eventID string, sessionID string) (checkin.Session, bool, error) {
+	es.SessionInvoked = true
+	return es.SessionFn(eventID, sessionID)
+}
+
+//CreateSession invokes the mock implementation and marks the function as invoked
+func (es *EventService) CreateSession(s checkin.Session) error {
+	es.CreateSessionInvoked = true
+	return es.CreateSessionFn(s)
+}
+
+//UpdateSession invokes the mock implementation and marks the function as invoked
+func (es *EventService) UpdateSession(s checkin.Session) error {
+	es.UpdateSessionInvoked = true
+	return es.UpdateSessionFn(s)
 }
 
-//CheckHost invokes the mock implementation and marks the function as invoked
-func (es *EventService) CheckHost(username string, eventID string) (bool, error) {
-	es.CheckHostInvoked = true
-	return es.CheckHostFn(username, eventID)
+//DeleteSession invokes the mock implementation and marks the function as invoked
+func (es *EventService) DeleteSession(eventID string, sessionID string) (bool, error) {
+	es.DeleteSessionInvoked = true
+	return es.DeleteSessionFn(eventID, sessionID)
 }
 
 //FeedbackForms invokes the mock implementation and marks the function as invoked
@@ -12,6 +12,8 @@ type GuestMessenger struct {
 	OpenConnectionInvoked  bool
 	SendFn                 func(guestID string, msg myhttp.GuestMessage) error
 	SendInvoked            bool
+	SendAllFn              func(prefix string, msg myhttp.GuestMessage) error
+	SendAllInvoked         bool
 	HasConnectionFn        func(guestID string) bool
 	HasConnectionInvoked   bool
 	CloseConnectionFn      func(guestID string) error
@@ -30,6 +32,12 @@ func (gm *GuestMessenger) Send(guestID string, msg myhttp.GuestMessage) error {
 	return gm.SendFn(guestID, msg)
 }
 
+//SendAll calls the mock function attribute (part of the struct) and marks it as invoked
+func (gm *GuestMessenger) SendAll(prefix string, msg myhttp.GuestMessage) error {
+	gm.SendAllInvoked = true
+	return gm.SendAllFn(prefix, msg)
+}
+
 //HasConnection calls the mock function attribute (part of the struct) and marks it as invoked
 func (gm *GuestMessenger) HasConnection(guestID string) bool {
 	gm.HasConnectionInvoked = true
@@ -6,21 +6,33 @@ import (
 
 //GuestService represents a mock implementation of the checkin.GuestService interface
 type GuestService struct {
-	CheckInFn      func(eventID string, nric string) (string, error)
+	CheckInFn      func(eventID string, sessionID string, nric string, source checkin.AttendanceSource) (string, error)
 	CheckInInvoked bool
 
-	MarkAbsentFn      func(eventID string, nric string) error
+	MarkAbsentFn      func(eventID string, sessionID string, nric string, source checkin.AttendanceSource) (string, error)
 	MarkAbsentInvoked bool
 
-	GuestsFn      func(eventID string, tags []string) ([]string, error)
+	FlagCheckInFn      func(eventID string, nric string, flag checkin.CheckInFlag) error
+	FlagCheckInInvoked bool
+
+	GuestsFn      func(eventID string, filter checkin.TagFilter) ([]string, error)
 	GuestsInvoked bool
 
-	GuestsCheckedInFn      func(eventID string, tags []string) ([]string, error)
+	GuestsCheckedInFn      func(eventID string, filter checkin.TagFilter) ([]string, error)
 	GuestsCheckedInInvoked bool
 
-	GuestsNotCheckedInFn      func(eventID string, tags []string) ([]string, error)
+	GuestsNotCheckedInFn      func(eventID string, filter checkin.TagFilter) ([]string, error)
 	GuestsNotCheckedInInvoked bool
 
+	GuestRecordsFn      func(eventID string, filter checkin.TagFilter, opts checkin.ListOptions) ([]checkin.GuestRecord, int, error)
+	GuestRecordsInvoked bool
+
+	GuestRecordsCheckedInFn      func(eventID string, filter checkin.TagFilter, opts checkin.ListOptions) ([]checkin.GuestRecord, int, error)
+	GuestRecordsCheckedInInvoked bool
+
+	GuestRecordsNotCheckedInFn      func(eventID string, filter checkin.TagFilter, opts checkin.ListOptions) ([]checkin.GuestRecord, int, error)
+	GuestRecordsNotCheckedInInvoked bool
+
 	GuestExistsFn      func(eventID string, nric string) (bool, error)
 	GuestExistsInvoked bool
 
@@ -30,12 +42,27 @@ type GuestService struct {
 	RegisterGuestsFn      func(eventID string, guest []checkin.Guest) error
 	RegisterGuestsInvoked bool
 
+	SyncGuestsFn      func(eventID string, guests []checkin.Guest, opts checkin.SyncOptions) (checkin.GuestSync, error)
+	SyncGuestsInvoked bool
+
 	RemoveGuestFn      func(eventID string, nric string) error
 	RemoveGuestInvoked bool
 
-	CheckInStatsFn      func(eventID string, tags []string) (checkin.GuestStats, error)
+	CheckInStatsFn      func(eventID string, sessionID string, filter checkin.TagFilter) (checkin.GuestStats, error)
 	CheckInStatsInvoked bool
 
+	TagStatsFn      func(eventID string, sessionID string, filter checkin.TagFilter) ([]checkin.TagStats, error)
+	TagStatsInvoked bool
+
+	TagCrossStatsFn      func(eventID string, sessionID string, rows []string, columns []string, filter checkin.TagFilter) (checkin.TagCrossStats, error)
+	TagCrossStatsInvoked bool
+
+	AttendanceMatrixFn      func(eventID string, filter checkin.TagFilter) (checkin.AttendanceMatrix, error)
+	AttendanceMatrixInvoked bool
+
+	ArrivalsFn      func(eventID string, filter checkin.TagFilter) ([]checkin.Arrival, error)
+	ArrivalsInvoked bool
+
 	TagsFn      func(eventID string, nric string) ([]string, error)
 	TagsInvoked bool
 
@@ -44,36 +71,69 @@ type GuestService struct {
 
 	AllTagsFn      func(eventID string) ([]string, error)
 	AllTagsInvoked bool
+
+	AddTagFn      func(eventID string, tag string, filter checkin.GuestFilter) (int, error)
+	AddTagInvoked bool
+
+	RemoveTagFn      func(eventID string, tag string, filter checkin.GuestFilter) (int, error)
+	RemoveTagInvoked bool
+
+	RenameTagFn      func(eventID string, tag string, newTag string) (int, error)
+	RenameTagInvoked bool
 }
 
 //CheckIn invokes the mock implementation and marks the function as invoked
-func (as *GuestService) CheckIn(eventID string, nric string) (string, error) {
+func (as *GuestService) CheckIn(eventID string, sessionID string, nric string, source checkin.AttendanceSource) (string, error) {
 	as.CheckInInvoked = true
-	return as.CheckInFn(eventID, nric)
+	return as.CheckInFn(eventID, sessionID, nric, source)
 }
 
 //MarkAbsent invokes the mock implementation and marks the function as invoked
-func (as *GuestService) MarkAbsent(eventID string, nric string) error {
+func (as *GuestService) MarkAbsent(eventID string, sessionID string, nric string, source checkin.AttendanceSource) (string, error) {
 	as.MarkAbsentInvoked = true
-	return as.MarkAbsentFn(eventID, nric)
+	return as.MarkAbsentFn(eventID, sessionID, nric, source)
+}
+
+//FlagCheckIn invokes the mock implementation and marks the function as invoked
+func (as *GuestService) FlagCheckIn(eventID string, nric string, flag checkin.CheckInFlag) error {
+	as.FlagCheckInInvoked = true
+	return as.FlagCheckInFn(eventID, nric, flag)
 }
 
 //Guests invokes the mock implementation and marks the function as invoked
-func (as *GuestService) Guests(eventID string, tags []string) ([]string, error) {
+func (as *GuestService) Guests(eventID string, filter checkin.TagFilter) ([]string, error) {
 	as.GuestsInvoked = true
-	return as.GuestsFn(eventID, tags)
+	return as.GuestsFn(eventID, filter)
 }
 
 //GuestsCheckedIn invokes the mock implementation and marks the function as invoked
-func (as *GuestService) GuestsCheckedIn(eventID string, tags []string) ([]string, error) {
+func (as *GuestService) GuestsCheckedIn(eventID string, filter checkin.TagFilter) ([]string, error) {
 	as.GuestsCheckedInInvoked = true
-	return as.GuestsCheckedInFn(eventID, tags)
+	return as.GuestsCheckedInFn(eventID, filter)
 }
 
 //GuestsNotCheckedIn invokes the mock implementation and marks the function as invoked
-func (as *GuestService) GuestsNotCheckedIn(eventID string, tags []string) ([]string, error) {
+func (as *GuestService) GuestsNotCheckedIn(eventID string, filter checkin.TagFilter) ([]string, error) {
 	as.GuestsNotCheckedInInvoked = true
-	return as.GuestsNotCheckedInFn(eventID, tags)
+	return as.GuestsNotCheckedInFn(eventID, filter)
+}
+
+//GuestRecords invokes the mock implementation and marks the function as invoked
+func (as *GuestService) GuestRecords(eventID string, filter checkin.TagFilter, opts checkin.ListOptions) ([]checkin.GuestRecord, int, error) {
+	as.GuestRecordsInvoked = true
+	return as.GuestRecordsFn(eventID, filter, opts)
+}
+
+//GuestRecordsCheckedIn invokes the mock implementation and marks the function as invoked
+func (as *GuestService) GuestRecordsCheckedIn(eventID string, filter checkin.TagFilter, opts checkin.ListOptions) ([]checkin.GuestRecord, int, error) {
+	as.GuestRecordsCheckedInInvoked = true
+	return as.GuestRecordsCheckedInFn(eventID, filter, opts)
+}
+
+//GuestRecordsNotCheckedIn invokes the mock implementation and marks the function as invoked
+func (as *GuestService) GuestRecordsNotCheckedIn(eventID string, filter checkin.TagFilter, opts checkin.ListOptions) ([]checkin.GuestRecord, int, error) {
+	as.GuestRecordsNotCheckedInInvoked = true
+	return as.GuestRecordsNotCheckedInFn(eventID, filter, opts)
 }
 
 //GuestExists invokes the mock implementation and marks the function as invoked
@@ -94,6 +154,12 @@ func (as *GuestService) RegisterGuests(eventID string, guests []checkin.Guest) e
 	return as.RegisterGuestsFn(eventID, guests)
 }
 
+//SyncGuests invokes the mock implementation and marks the function as invoked
+func (as *GuestService) SyncGuests(eventID string, guests []checkin.Guest, opts checkin.SyncOptions) (checkin.GuestSync, error) {
+	as.SyncGuestsInvoked = true
+	return as.SyncGuestsFn(eventID, guests, opts)
+}
+
 //RemoveGuest invokes the mock implementation and marks the function as invoked
 func (as *GuestService) RemoveGuest(eventID string, nric string) error {
 	as.RemoveGuestInvoked = true
@@ -101,9 +167,33 @@ func (as *GuestService) RemoveGuest(eventID string, nric string) error {
 }
 
 //CheckInStats invokes the mock implementation and marks the function as invoked
-func (as *GuestService) CheckInStats(eventID string, tags []string) (checkin.GuestStats, error) {
-	as.CheckInInvoked = true
-	return as.CheckInStatsFn(eventID, tags)
+func (as *GuestService) CheckInStats(eventID string, sessionID string, filter checkin.TagFilter) (checkin.GuestStats, error) {
+	as.CheckInStatsInvoked = true
+	return as.CheckInStatsFn(eventID, sessionID, filter)
+}
+
+//TagStats invokes the mock implementation and marks the function as invoked
+func (as *GuestService) TagStats(eventID string, sessionID string, filter checkin.TagFilter) ([]checkin.TagStats, error) {
+	as.TagStatsInvoked = true
+	return as.TagStatsFn(eventID, sessionID, filter)
+}
+
+//TagCrossStats invokes the mock implementation and marks the function as invoked
+func (as *GuestService) TagCrossStats(eventID string, sessionID string, rows []string, columns []string, filter checkin.TagFilter) (checkin.TagCrossStats, error) {
+	as.TagCrossStatsInvoked = true
+	return as.TagCrossStatsFn(eventID, sessionID, rows, columns, filter)
+}
+
+//AttendanceMatrix invokes the mock implementation and marks the function as invoked
+func (as *GuestService) AttendanceMatrix(eventID string, filter checkin.TagFilter) (checkin.AttendanceMatrix, error) {
+	as.AttendanceMatrixInvoked = true
+	return as.AttendanceMatrixFn(eventID, filter)
+}
+
+//Arrivals invokes the mock implementation and marks the function as invoked
+func (as *GuestService) Arrivals(eventID string, filter checkin.TagFilter) ([]checkin.Arrival, error) {
+	as.ArrivalsInvoked = true
+	return as.ArrivalsFn(eventID, filter)
 }
 
 //Tags invokes the mock implementation and marks the function as invoked
@@ -123,3 +213,21 @@ func (as *GuestService) AllTags(eventID string) ([]string, error) {
 	as.AllTagsInvoked = true
 	return as.AllTagsFn(eventID)
 }
+
+//AddTag invokes the mock implementation and marks the function as invoked
+func (as *GuestService) AddTag(eventID string, tag string, filter checkin.GuestFilter) (int, error) {
+	as.AddTagInvoked = true
+	return as.AddTagFn(eventID, tag, filter)
+}
+
+//RemoveTag invokes the mock implementation and marks the function as invoked
+func (as *GuestService) RemoveTag(eventID string, tag string, filter checkin.GuestFilter) (int, error) {
+	as.RemoveTagInvoked = true
+	return as.RemoveTagFn(eventID, tag, filter)
+}
+
+//RenameTag invokes the mock implementation and marks the function as invoked
+func (as *GuestService) RenameTag(eventID string, tag string, newTag string) (int, error) {
+	as.RenameTagInvoked = true
+	return as.RenameTagFn(eventID, tag, newTag)
+}
@@ -0,0 +1,26 @@
+package mock
+
+import (
+	"checkin"
+)
+
+//GuestSiteService represents a mock implementation of the checkin.GuestSiteService interface
+type GuestSiteService struct {
+	GuestSiteFn      func(eventID string) (checkin.GuestSite, error)
+	GuestSiteInvoked bool
+
+	UpdateGuestSiteFn      func(eventID string, site checkin.GuestSite) error
+	UpdateGuestSiteInvoked bool
+}
+
+//GuestSite invokes the mock implementation and marks the function as invoked
+func (ss *GuestSiteService) GuestSite(eventID string) (checkin.GuestSite, error) {
+	ss.GuestSiteInvoked = true
+	return ss.GuestSiteFn(eventID)
+}
+
+//UpdateGuestSite invokes the mock implementation and marks the function as invoked
+func (ss *GuestSiteService) UpdateGuestSite(eventID string, site checkin.GuestSite) error {
+	ss.UpdateGuestSiteInvoked = true
+	return ss.UpdateGuestSiteFn(eventID, site)
+}
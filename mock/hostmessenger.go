@@ -0,0 +1,35 @@
+package mock
+
+import (
+	myhttp "checkin/http"
+	"net/http"
+)
+
+//HostMessenger is a mock implementation of http.HostMessenger, which takes mock functions as attributes
+//and calls them/marks them as invoked when a http.HostMessenger function is called
+type HostMessenger struct {
+	OpenStreamFn      func(eventID string, w http.ResponseWriter, r *http.Request) error
+	OpenStreamInvoked bool
+	SendFn            func(eventID string, msg myhttp.HostMessage) error
+	SendInvoked       bool
+	HasStreamFn       func(eventID string) bool
+	HasStreamInvoked  bool
+}
+
+//OpenStream calls the mock function attribute (part of the struct) and marks it as invoked
+func (hm *HostMessenger) OpenStream(eventID string, w http.ResponseWriter, r *http.Request) error {
+	hm.OpenStreamInvoked = true
+	return hm.OpenStreamFn(eventID, w, r)
+}
+
+//Send calls the mock function attribute (part of the struct) and marks it as invoked
+func (hm *HostMessenger) Send(eventID string, msg myhttp.HostMessage) error {
+	hm.SendInvoked = true
+	return hm.SendFn(eventID, msg)
+}
+
+//HasStream calls the mock function attribute (part of the struct) and marks it as invoked
+func (hm *HostMessenger) HasStream(eventID string) bool {
+	hm.HasStreamInvoked = true
+	return hm.HasStreamFn(eventID)
+}
@@ -0,0 +1,59 @@
+package mock
+
+import (
+	"checkin"
+
+	"github.com/guregu/null"
+)
+
+//LoginAttemptService represents a mock implementation of the checkin.LoginAttemptService interface
+type LoginAttemptService struct {
+	LockedUntilFn        func(username string, ipAddress string) (null.Time, error)
+	LockedUntilInvoked   bool
+	RecordFailureFn      func(failure checkin.LoginFailure) error
+	RecordFailureInvoked bool
+	ClearFailuresFn      func(username string) error
+	ClearFailuresInvoked bool
+	LockoutsFn           func(opts checkin.ListOptions) ([]checkin.Lockout, int, error)
+	LockoutsInvoked      bool
+	ResetLockoutFn       func(kind string, key string) error
+	ResetLockoutInvoked  bool
+	LoginFailuresFn      func(username string, opts checkin.ListOptions) ([]checkin.LoginFailure, int, error)
+	LoginFailuresInvoked bool
+}
+
+//LockedUntil invokes the mock implementation and marks the function as invoked
+func (las *LoginAttemptService) LockedUntil(username string, ipAddress string) (null.Time, error) {
+	las.LockedUntilInvoked = true
+	return las.LockedUntilFn(username, ipAddress)
+}
+
+//RecordFailure invokes the mock implementation and marks the function as invoked
+func (las *LoginAttemptService) RecordFailure(failure checkin.LoginFailure) error {
+	las.RecordFailureInvoked = true
+	return las.RecordFailureFn(failure)
+}
+
+//ClearFailures invokes the mock implementation and marks the function as invoked
+func (las *LoginAttemptService) ClearFailures(username string) error {
+	las.ClearFailuresInvoked = true
+	return las.ClearFailuresFn(username)
+}
+
+//Lockouts invokes the mock implementation and marks the function as invoked
+func (las *LoginAttemptService) Lockouts(opts checkin.ListOptions) ([]checkin.Lockout, int, error) {
+	las.LockoutsInvoked = true
+	return las.LockoutsFn(opts)
+}
+
+//ResetLockout invokes the mock implementation and marks the function as invoked
+func (las *LoginAttemptService) ResetLockout(kind string, key string) error {
+	las.ResetLockoutInvoked = true
+	return las.ResetLockoutFn(kind, key)
+}
+
+//LoginFailures invokes the mock implementation and marks the function as invoked
+func (las *LoginAttemptService) LoginFailures(username string, opts checkin.ListOptions) ([]checkin.LoginFailure, int, error) {
+	las.LoginFailuresInvoked = true
+	return las.LoginFailuresFn(username, opts)
+}
@@ -0,0 +1,51 @@
+package mock
+
+import (
+	"checkin"
+	"time"
+)
+
+//TokenService is a mock implementation of checkin.TokenService, which takes mock functions as attributes
+//and calls them/marks them as invoked when a checkin.TokenService function is called
+type TokenService struct {
+	AddRefreshTokenFn         func(token string, au checkin.AuthorizationInfo, expiresAt time.Time) error
+	AddRefreshTokenInvoked    bool
+	UseRefreshTokenFn         func(token string) (checkin.AuthorizationInfo, bool, error)
+	UseRefreshTokenInvoked    bool
+	RemoveRefreshTokenFn      func(token string) error
+	RemoveRefreshTokenInvoked bool
+	RevokeAccessTokenFn       func(tokenID string, expiresAt time.Time) error
+	RevokeAccessTokenInvoked  bool
+	AccessTokenValidFn        func(tokenID string, au checkin.AuthorizationInfo, issuedAt time.Time) (bool, error)
+	AccessTokenValidInvoked   bool
+}
+
+//AddRefreshToken calls the mock function attribute (part of the struct) and marks it as invoked
+func (ts *TokenService) AddRefreshToken(token string, au checkin.AuthorizationInfo, expiresAt time.Time) error {
+	ts.AddRefreshTokenInvoked = true
+	return ts.AddRefreshTokenFn(token, au, expiresAt)
+}
+
+//UseRefreshToken calls the mock function attribute (part of the struct) and marks it as invoked
+func (ts *TokenService) UseRefreshToken(token string) (checkin.AuthorizationInfo, bool, error) {
+	ts.UseRefreshTokenInvoked = true
+	return ts.UseRefreshTokenFn(token)
+}
+
+//RemoveRefreshToken calls the mock function attribute (part of the struct) and marks it as invoked
+func (ts *TokenService) RemoveRefreshToken(token string) error {
+	ts.RemoveRefreshTokenInvoked = true
+	return ts.RemoveRefreshTokenFn(token)
+}
+
+//RevokeAccessToken calls the mock function attribute (part of the struct) and marks it as invoked
+func (ts *TokenService) RevokeAccessToken(tokenID string, expiresAt time.Time) error {
+	ts.RevokeAccessTokenInvoked = true
+	return ts.RevokeAccessTokenFn(tokenID, expiresAt)
+}
+
+//AccessTokenValid calls the mock function attribute (part of the struct) and marks it as invoked
+func (ts *TokenService) AccessTokenValid(tokenID string, au checkin.AuthorizationInfo, issuedAt time.Time) (bool, error) {
+	ts.AccessTokenValidInvoked = true
+	return ts.AccessTokenValidFn(tokenID, au, issuedAt)
+}
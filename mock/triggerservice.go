@@ -0,0 +1,74 @@
+package mock
+
+import (
+	"checkin"
+	"time"
+)
+
+//TriggerService represents a mock implementation of the checkin.TriggerService interface
+type TriggerService struct {
+	ActionsFn                 func(eventID string) ([]checkin.TriggerAction, error)
+	ActionsInvoked            bool
+	CreateActionFn            func(a checkin.TriggerAction) error
+	CreateActionInvoked       bool
+	DeleteActionFn            func(eventID string, actionID string) (bool, error)
+	DeleteActionInvoked       bool
+	DeliveriesFn              func(eventID string, opts checkin.ListOptions) ([]checkin.Delivery, int, error)
+	DeliveriesInvoked         bool
+	ScheduleDeliveriesFn      func() (int, error)
+	ScheduleDeliveriesInvoked bool
+	ClaimDeliveriesFn         func(limit int, lease time.Duration) ([]checkin.Delivery, error)
+	ClaimDeliveriesInvoked    bool
+	RecordSuccessFn           func(deliveryID string, claimedUntil time.Time) error
+	RecordSuccessInvoked      bool
+	RecordFailureFn           func(deliveryID string, claimedUntil time.Time, reason string) error
+	RecordFailureInvoked      bool
+}
+
+//Actions invokes the mock implementation and marks the function as invoked
+func (ts *TriggerService) Actions(eventID string) ([]checkin.TriggerAction, error) {
+	ts.ActionsInvoked = true
+	return ts.ActionsFn(eventID)
+}
+
+//CreateAction invokes the mock implementation and marks the function as invoked
+func (ts *TriggerService) CreateAction(a checkin.TriggerAction) error {
+	ts.CreateActionInvoked = true
+	return ts.CreateActionFn(a)
+}
+
+//DeleteAction invokes the mock implementation and marks the function as invoked
+func (ts *TriggerService) DeleteAction(eventID string, actionID string) (bool, error) {
+	ts.DeleteActionInvoked = true
+	return ts.DeleteActionFn(eventID, actionID)
+}
+
+//Deliveries invokes the mock implementation and marks the function as invoked
+func (ts *TriggerService) Deliveries(eventID string, opts checkin.ListOptions) ([]checkin.Delivery, int, error) {
+	ts.DeliveriesInvoked = true
+	return ts.DeliveriesFn(eventID, opts)
+}
+
+//ScheduleDeliveries invokes the mock implementation and marks the function as invoked
+func (ts *TriggerService) ScheduleDeliveries() (int, error) {
+	ts.ScheduleDeliveriesInvoked = true
+	return ts.ScheduleDeliveriesFn()
+}
+
+//ClaimDeliveries invokes the mock implementation and marks the function as invoked
+func (ts *TriggerService) ClaimDeliveries(limit int, lease time.Duration) ([]checkin.Delivery, error) {
+	ts.ClaimDeliveriesInvoked = true
+	return ts.ClaimDeliveriesFn(limit, lease)
+}
+
+//RecordSuccess invokes the mock implementation and marks the function as invoked
+func (ts *TriggerService) RecordSuccess(deliveryID string, claimedUntil time.Time) error {
+	ts.RecordSuccessInvoked = true
+	return ts.RecordSuccessFn(deliveryID, claimedUntil)
+}
+
+//RecordFailure invokes the mock implementation and marks the function as invoked
+func (ts *TriggerService) RecordFailure(deliveryID string, claimedUntil time.Time, reason string) error {
+	ts.RecordFailureInvoked = true
+	return ts.RecordFailureFn(deliveryID, claimedUntil, reason)
+}
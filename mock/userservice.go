@@ -2,6 +2,8 @@ package mock
 
 import (
 	"checkin"
+
+	"github.com/guregu/null"
 )
 
 //UserService represents a mock implementation of the checkin.UserService interface
@@ -9,16 +11,16 @@ type UserService struct {
 	UserFn      func(username string) (checkin.User, error)
 	UserInvoked bool
 
-	UsersFn      func() ([]checkin.User, error)
+	UsersFn      func(opts checkin.ListOptions) ([]checkin.User, int, error)
 	UsersInvoked bool
 
 	CreateUserFn      func(u checkin.User) error
 	CreateUserInvoked bool
 
-	DeleteUserFn      func(username string) error
+	DeleteUserFn      func(username string) (bool, error)
 	DeleteUserInvoked bool
 
-	UpdateUserFn      func(originalUsername string, user checkin.User) error
+	UpdateUserFn      func(originalUsername string, user checkin.User, ifUpdatedAt null.Time) (bool, error)
 	UpdateUserInvoked bool
 
 	CheckIfExistsFn      func(username string) (bool, error)
@@ -35,9 +37,9 @@ func (us *UserService) User(username string) (checkin.User, error) {
 }
 
 //Users invokes the mock implementation and marks the function as invoked
-func (us *UserService) Users() ([]checkin.User, error) {
+func (us *UserService) Users(opts checkin.ListOptions) ([]checkin.User, int, error) {
 	us.UsersInvoked = true
-	return us.UsersFn()
+	return us.UsersFn(opts)
 }
 
 //CreateUser invokes the mock implementation and marks the function as invoked
@@ -47,15 +49,15 @@ func (us *UserService) CreateUser(u checkin.User) error {
 }
 
 //DeleteUser invokes the mock implementation and marks the function as invoked
-func (us *UserService) DeleteUser(username string) error {
+func (us *UserService) DeleteUser(username string) (bool, error) {
 	us.DeleteUserInvoked = true
 	return us.DeleteUserFn(username)
 }
 
 //UpdateUser invokes the mock implementation and marks the function as invoked
-func (us *UserService) UpdateUser(originalUsername string, user checkin.User) error {
+func (us *UserService) UpdateUser(originalUsername string, user checkin.User, ifUpdatedAt null.Time) (bool, error) {
 	us.UpdateUserInvoked = true
-	return us.UpdateUserFn(originalUsername, user)
+	return us.UpdateUserFn(originalUsername, user, ifUpdatedAt)
 }
 
 //CheckIfExists invokes the mock implementation and marks the function as invoked
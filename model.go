@@ -1,6 +1,7 @@
 package checkin
 
 import (
+	"math"
 	"time"
 
 	"github.com/guregu/null"
@@ -20,30 +21,206 @@ type User struct {
 	LastLoggedIn null.Time `json:"lastLoggedIn,omitempty"`
 }
 
+//Sort keys which listings can be ordered by. Each listing supports only some of them
+const (
+	SortByName           = "name"
+	SortByUsername       = "username"
+	SortByCheckInTime    = "checkInTime"
+	SortByStart          = "start"
+	SortByCreatedAt      = "createdAt"
+	SortByLastLoggedIn   = "lastLoggedIn"
+	SortByTime           = "time"
+	SortByFailedAttempts = "failedAttempts"
+)
+
+//ListOptions describes which page of a listing to fetch, how to sort it and
+//what name prefix to search for
+//The zero value fetches the entire listing in its default order
+type ListOptions struct {
+	SortBy     string //one of the SortBy constants, "" for the listing's default order
+	Descending bool
+	Search     string //case insensitive name prefix, "" to match everything
+	Offset     int
+	Limit      int //0 for no limit
+}
+
+//IsValid checks that the offset and limit are not negative, and that SortBy is either
+//empty or one of the given sort keys
+func (opts ListOptions) IsValid(sortKeys ...string) bool {
+	if opts.Offset < 0 || opts.Limit < 0 {
+		return false
+	}
+	if opts.SortBy == "" {
+		return true
+	}
+	for _, key := range sortKeys {
+		if opts.SortBy == key {
+			return true
+		}
+	}
+	return false
+}
+
+//PageSize returns the number of items on the page described by these options,
+//given the total number of items in the listing
+func (opts ListOptions) PageSize(total int) int {
+	size := total - opts.Offset
+	if size < 0 {
+		return 0
+	}
+	if opts.Limit > 0 && opts.Limit < size {
+		return opts.Limit
+	}
+	return size
+}
+
 //UserService An interface for functions that modify/fetch user data in the database
 type UserService interface {
 	User(username string) (User, error)
-	Users() ([]User, error)
+	Users(opts ListOptions) ([]User, int, error)
 	CreateUser(u User) error
-	DeleteUser(username string) error
-	UpdateUser(originalUsername string, newUser User) error
+	DeleteUser(username string) (bool, error)
+	UpdateUser(originalUsername string, newUser User, ifUpdatedAt null.Time) (bool, error)
 	CheckIfExists(username string) (bool, error)
 	UpdateLastLoggedIn(username string) error
 }
 
 //Event represents an event which will have an associated website
 type Event struct {
-	ID        string               `json:"eventId" db:"id"`
-	Name      string               `json:"name" db:"name"`
-	TimeTags  map[string]time.Time `json:"triggers" db:"-"`
-	Start     null.Time            `json:"startDateTime" db:"start"`
-	End       null.Time            `json:"endDateTime" db:"end"`
-	Lat       null.Float           `json:"lat" db:"lat"`
-	Long      null.Float           `json:"long" db:"long"`
-	Radius    null.Float           `json:"radius" db:"radius"` //in km
-	URL       null.String          `json:"url" db:"url"`
-	UpdatedAt time.Time            `json:"updatedAt" db:"updatedat"`
-	CreatedAt time.Time            `json:"createdAt" db:"createdat"`
+	ID         string               `json:"eventId" db:"id"`
+	Name       string               `json:"name" db:"name"`
+	TimeTags   map[string]time.Time `json:"triggers" db:"-"`
+	Start      null.Time            `json:"startDateTime" db:"start"`
+	End        null.Time            `json:"endDateTime" db:"end"`
+	Lat        null.Float           `json:"lat" db:"lat"`
+	Long       null.Float           `json:"long" db:"long"`
+	Radius     null.Float           `json:"radius" db:"radius"`     //in km
+	Geofence   string               `json:"geofence" db:"geofence"` //one of the Geofence constants
+	URL        null.String          `json:"url" db:"url"`
+	Timezone   string               `json:"timezone" db:"timezone"` //IANA time zone database name, "" for UTC
+	UpdatedAt  time.Time            `json:"updatedAt" db:"updatedat"`
+	CreatedAt  time.Time            `json:"createdAt" db:"createdat"`
+	ArchivedAt null.Time            `json:"archivedAt" db:"archivedat"` //null unless the event has been deleted
+}
+
+//Geofence modes of an event, which decide what happens when guests check themselves in
+//from further than Radius km away from the event's Lat and Long (or do not say where they are)
+const (
+	GeofenceOff     = ""        //guests can check in from anywhere
+	GeofenceFlag    = "flag"    //guests are checked in, but the check in is flagged for hosts to review
+	GeofenceEnforce = "enforce" //guests are not checked in, and the attempt is flagged for hosts to review
+)
+
+//earthRadius is the mean radius of the earth, in km
+const earthRadius = 6371.0
+
+//HasValidTimezone checks that the event's timezone is in the IANA time zone database
+//"Local" is not allowed, as it depends on where the server is running
+func (e *Event) HasValidTimezone() bool {
+	_, err := time.LoadLocation(e.Timezone)
+	return err == nil && e.Timezone != "Local"
+}
+
+//ValidCoordinates checks that the latitude is between -90 and 90, and the longitude between -180 and 180
+//Either may be null, as for locations which are not known
+func ValidCoordinates(lat null.Float, long null.Float) bool {
+	return (!lat.Valid || (lat.Float64 >= -90 && lat.Float64 <= 90)) &&
+		(!long.Valid || (long.Float64 >= -180 && long.Float64 <= 180))
+}
+
+//HasValidGeofence checks that the event's geofence mode exists and its location (if any) is valid, and if
+//the geofence is on, that the event has a location and radius to check guests against
+func (e *Event) HasValidGeofence() bool {
+	if !ValidCoordinates(e.Lat, e.Long) {
+		return false
+	}
+	switch e.Geofence {
+	case GeofenceOff:
+		return true
+	case GeofenceFlag, GeofenceEnforce:
+		return e.Lat.Valid && e.Long.Valid && e.Radius.Valid && e.Radius.Float64 >= 0
+	}
+	return false
+}
+
+//DistanceFrom returns the distance in km from the event's location to the given coordinates,
+//along the surface of the earth (using the haversine formula)
+func (e *Event) DistanceFrom(lat float64, long float64) float64 {
+	toRadians := math.Pi / 180
+	dLat := (lat - e.Lat.Float64) * toRadians
+	dLong := (long - e.Long.Float64) * toRadians
+	a := math.Pow(math.Sin(dLat/2), 2) +
+		math.Cos(e.Lat.Float64*toRadians)*math.Cos(lat*toRadians)*math.Pow(math.Sin(dLong/2), 2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+//WithinGeofence checks if the given coordinates are within Radius km of the event's location
+//Unknown coordinates are never within the geofence
+func (e *Event) WithinGeofence(lat null.Float, long null.Float) bool {
+	if !lat.Valid || !long.Valid || !e.Lat.Valid || !e.Long.Valid || !e.Radius.Valid {
+		return false
+	}
+	return e.DistanceFrom(lat.Float64, long.Float64) <= e.Radius.Float64
+}
+
+//EventFilter narrows down a listing of every event. The zero value lets every event through
+type EventFilter struct {
+	From     null.Time //only events which end (or start, if they have no end) at or after From
+	To       null.Time //only events which start (or end, if they have no start) at or before To
+	Host     string    //only events hosted by the user with this username
+	Released null.Bool //only events which have (true) or have not (false) been released
+	Archived bool      //only archived events instead of live ones
+}
+
+//ArchiveRetention is how long deleted events are kept archived, and can be restored by their hosts,
+//before they are purged for good
+const ArchiveRetention = 30 * 24 * time.Hour
+
+//CloneOptions describe the event made by cloning an event or instantiating an event template
+type CloneOptions struct {
+	Name   string      `json:"name"`          //"" to keep the name of the original
+	URL    null.String `json:"url"`           //not copied, as URLs are unique
+	Start  null.Time   `json:"startDateTime"` //null to keep the start of the original
+	Guests bool        `json:"guests"`        //whether to copy the guest list, with nobody checked in
+}
+
+//Copy returns the details of a new event made from the event with the clone options
+//The new event has no ID, and its end and triggers are moved along with its start
+func (e Event) Copy(opts CloneOptions) Event {
+	e.ID, e.URL, e.CreatedAt, e.UpdatedAt, e.ArchivedAt = "", opts.URL, time.Time{}, time.Time{}, null.Time{}
+	if opts.Name != "" {
+		e.Name = opts.Name
+	}
+	timeTags := make(map[string]time.Time, len(e.TimeTags)) //do not share the original's map
+	for tag, val := range e.TimeTags {
+		timeTags[tag] = val
+	}
+	e.TimeTags = timeTags
+	if !opts.Start.Valid {
+		return e
+	}
+
+	if e.Start.Valid {
+		shift := opts.Start.Time.Sub(e.Start.Time)
+		if e.End.Valid {
+			e.End.Time = e.End.Time.Add(shift)
+		}
+		for tag, val := range e.TimeTags {
+			e.TimeTags[tag] = val.Add(shift)
+		}
+	}
+	e.Start = opts.Start //events without a start keep their end and triggers, having nothing to move them along with
+	return e
+}
+
+//EventTemplate is a named set of event details, which events can be made from later on
+//Templates belong to the user who saved them, and can only be used by them
+type EventTemplate struct {
+	ID        string    `json:"templateId"`
+	Name      string    `json:"name"`
+	Username  string    `json:"username"`
+	Event     Event     `json:"event"` //without an ID or URL
+	CreatedAt time.Time `json:"createdAt"`
 }
 
 //FeedbackFormItem represents a question/answer pair in a feedback form
@@ -65,30 +242,209 @@ type FeedbackForm struct {
 type EventService interface {
 	Event(ID string) (Event, error)
 	EventByURL(url string) (Event, error)
-	EventsBy(username string) ([]Event, error)
-	Events() ([]Event, error)
+	EventsBy(username string, opts ListOptions) ([]Event, int, error)
+	Events(filter EventFilter, opts ListOptions) ([]Event, int, error)
 	CreateEvent(e Event, hostUsername string) error
 	DeleteEvent(ID string) error
-	UpdateEvent(e Event) error
+	ArchiveEvent(ID string) error
+	RestoreEvent(ID string, archivedSince time.Time) (bool, error)
+	PurgeEvents(archivedBefore time.Time) (int, error)
+	UpdateEvent(e Event, ifUpdatedAt null.Time) (bool, error)
+	SetTimeTag(eventID string, tag string, t time.Time) error
+	RemoveTimeTag(eventID string, tag string) error
 	URLExists(url string) (bool, error)
 	CheckIfExists(id string) (bool, error)
-	AddHost(eventID string, username string) error
-	CheckHost(username string, eventID string) (bool, error)
+	AddHost(eventID string, username string, role string) error
+	HostRole(username string, eventID string) (string, error)
+	Hosts(eventID string) ([]Host, error)
+	RemoveHost(eventID string, username string) error
+	TransferOwnership(eventID string, username string) error
+	CloneEvent(ID string, e Event, hostUsername string, copyGuests bool) error
+	Templates(username string, opts ListOptions) ([]EventTemplate, int, error)
+	Template(ID string) (EventTemplate, bool, error)
+	CreateTemplate(t EventTemplate) error
+	DeleteTemplate(ID string) error
+	Sessions(eventID string) ([]Session, error)
+	Session(eventID string, sessionID string) (Session, bool, error)
+	CreateSession(s Session) error
+	UpdateSession(s Session) error
+	DeleteSession(eventID string, sessionID string) (bool, error)
 	FeedbackForms(ID string) ([]FeedbackForm, error)
 	SubmitFeedback(ID string, ff FeedbackForm) error
 }
 
+//Session is one sitting of an event which runs over several, such as a day of a course
+//Guests of the event are checked in to each session separately from the event as a whole
+type Session struct {
+	ID      string    `json:"sessionId"`
+	EventID string    `json:"eventId"`
+	Name    string    `json:"name"`
+	Start   null.Time `json:"startDateTime"`
+	End     null.Time `json:"endDateTime"`
+}
+
+//Host is a user who hosts an event, with the role they have in it
+type Host struct {
+	Username string `json:"username" db:"username"`
+	Role     string `json:"role" db:"role"`
+}
+
+//Roles a host can have in an event. Each role grants a fixed set of permissions
+//Every event has an owner, which only changes when ownership is transferred
+const (
+	RoleOwner  = "owner"
+	RoleCoHost = "cohost"
+	RoleUsher  = "usher"  //checks guests in and marks them absent at the event
+	RoleViewer = "viewer" //follows the stats and reports of the event
+)
+
+//Permissions to act on an event, which hosts are granted by their role
+//Admins have every permission on every event
+const (
+	PermissionViewEvent    = "viewevent"
+	PermissionEditEvent    = "editevent"
+	PermissionDeleteEvent  = "deleteevent"
+	PermissionViewGuests   = "viewguests"
+	PermissionManageGuests = "manageguests"
+	PermissionCheckIn      = "checkin"
+	PermissionViewStats    = "viewstats"
+	PermissionViewReports  = "viewreports"
+	PermissionManageHosts  = "managehosts"
+)
+
+//rolePermissions maps each role to the permissions it grants
+var rolePermissions = map[string][]string{
+	RoleOwner: {PermissionViewEvent, PermissionEditEvent, PermissionDeleteEvent, PermissionViewGuests,
+		PermissionManageGuests, PermissionCheckIn, PermissionViewStats, PermissionViewReports, PermissionManageHosts},
+	RoleCoHost: {PermissionViewEvent, PermissionEditEvent, PermissionViewGuests,
+		PermissionManageGuests, PermissionCheckIn, PermissionViewStats, PermissionViewReports},
+	RoleUsher:  {PermissionViewEvent, PermissionCheckIn},
+	RoleViewer: {PermissionViewEvent, PermissionViewStats, PermissionViewReports},
+}
+
+//IsValidRole returns true if the role is one of the Role constants
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+//RoleHasPermission returns true if the role grants the permission
+//Returns false for roles which do not exist, including the empty role of users who are not hosts
+func RoleHasPermission(role string, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
 //HashMethod An interface allowing you to hash a string, and confirm if a string matches a given hash
 type HashMethod interface {
 	HashAndSalt(pwd string) (string, error)
 	CompareHashAndPassword(hash string, pwd string) bool
 }
 
+//DigestMethod An interface allowing you to compute a keyed digest of a string
+//Unlike a HashMethod, the same string always produces the same digest, so a digest
+//can be looked up directly instead of being compared against every hash
+type DigestMethod interface {
+	Digest(str string) (string, error)
+}
+
 //AuthenticationService An interface for functions to perform authentication
 type AuthenticationService interface {
 	Authenticate(username string, pwdPlaintext string, isAdmin bool) (bool, error)
 }
 
+//What failed login attempts are counted by, to lock out further attempts
+const (
+	LockoutByUsername = "username"
+	LockoutByIP       = "ip"
+)
+
+//LoginFailure is a failed attempt to log in, kept for auditing
+type LoginFailure struct {
+	ID        int64     `json:"id"`
+	Username  string    `json:"username"`
+	IsAdmin   bool      `json:"isAdmin"`
+	IPAddress string    `json:"ipAddress"`
+	UserAgent string    `json:"userAgent"`
+	Time      time.Time `json:"time"`
+}
+
+//Lockout counts the recent failed login attempts for a username or from an IP address
+//LockedUntil is null if the attempts have not (yet) locked out further attempts
+type Lockout struct {
+	Kind           string    `json:"kind"` //one of the LockoutBy constants
+	Key            string    `json:"key"`  //the username or IP address
+	FailedAttempts int       `json:"failedAttempts"`
+	LastFailedAt   time.Time `json:"lastFailedAt"`
+	LockedUntil    null.Time `json:"lockedUntil"`
+}
+
+//LockoutPolicy decides how long logins are locked out for after repeated failed attempts
+type LockoutPolicy struct {
+	Threshold   int           //number of failed attempts at which logins are locked out
+	BaseLockout time.Duration //lockout once the threshold is reached, doubled for every further failure
+	MaxLockout  time.Duration
+	ResetAfter  time.Duration //failed attempts are forgotten after this long without another one
+}
+
+//LockoutDuration returns how long logins should be locked out for after the given number of failed attempts
+//Returns 0 if they should not be locked out
+func (p LockoutPolicy) LockoutDuration(failedAttempts int) time.Duration {
+	if failedAttempts < p.Threshold {
+		return 0
+	}
+	lockout := p.BaseLockout
+	for i := p.Threshold; i < failedAttempts && lockout < p.MaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > p.MaxLockout {
+		return p.MaxLockout
+	}
+	return lockout
+}
+
+//LoginAttemptService tracks failed login attempts by username and by IP address, locking out
+//further attempts once there are too many
+type LoginAttemptService interface {
+	//LockedUntil returns the time until which logins for the username or from the IP address are locked out
+	//Returns null if neither is locked out
+	LockedUntil(username string, ipAddress string) (null.Time, error)
+	//RecordFailure records the failed attempt for auditing, and counts it against its username and IP address
+	RecordFailure(failure LoginFailure) error
+	//ClearFailures forgets the failed attempts of the username, after it logs in successfully
+	//Those of IP addresses are kept, as one address could be attempting to log in to several usernames
+	ClearFailures(username string) error
+	//Lockouts returns a page of the failed attempt counts, sorted by time (the default) or
+	//number of failed attempts and searched by key prefix, along with the total number of counts
+	Lockouts(opts ListOptions) ([]Lockout, int, error)
+	//ResetLockout forgets the failed attempts of the given kind and key, lifting any lockout
+	ResetLockout(kind string, key string) error
+	//LoginFailures returns a page of the failed attempts to log in to the username, sorted by time,
+	//along with the total number of failed attempts
+	LoginFailures(username string, opts ListOptions) ([]LoginFailure, int, error)
+}
+
+//TokenService keeps track of the refresh tokens issued to users and admins, and of the access tokens
+//which have been revoked before they expire
+type TokenService interface {
+	//AddRefreshToken stores a refresh token issued to the given user or admin, valid until expiresAt
+	AddRefreshToken(token string, au AuthorizationInfo, expiresAt time.Time) error
+	//UseRefreshToken removes the refresh token so it cannot be used again, returning who it was issued to
+	//Returns false if the token does not exist, has expired, or its user has been updated or deleted since it was issued
+	UseRefreshToken(token string) (AuthorizationInfo, bool, error)
+	//RemoveRefreshToken removes the refresh token, if it exists
+	RemoveRefreshToken(token string) error
+	//RevokeAccessToken revokes the access token with the given ID, which expires at expiresAt anyway
+	RevokeAccessToken(tokenID string, expiresAt time.Time) error
+	//AccessTokenValid returns false if the access token with the given ID has been revoked, or if the user
+	//it was issued to has been updated or deleted since it was issued
+	AccessTokenValid(tokenID string, au AuthorizationInfo, issuedAt time.Time) (bool, error)
+}
+
 //GuestStats are statistics relating to attendance of the event
 type GuestStats struct {
 	TotalGuests      int     `json:"total"`
@@ -96,6 +452,35 @@ type GuestStats struct {
 	PercentCheckedIn float64 `json:"percentCheckedIn"`
 }
 
+//TagStats are statistics relating to attendance of the guests of the event with a tag
+//Tag is "" for the guests without any tags
+type TagStats struct {
+	Tag string `json:"tag"`
+	GuestStats
+}
+
+//TagCrossStats are statistics relating to attendance of the guests of the event with a tag of each of
+//two dimensions, such as their role and the day they registered for
+//Cells[i][j] are the statistics of the guests with both Rows[i] and Columns[j]
+type TagCrossStats struct {
+	Rows    []string       `json:"rows"`
+	Columns []string       `json:"columns"`
+	Cells   [][]GuestStats `json:"cells"`
+}
+
+//AttendanceMatrix is the attendance of every guest of an event at each of its sessions
+type AttendanceMatrix struct {
+	Sessions []Session           `json:"sessions"`
+	Guests   []SessionAttendance `json:"guests"`
+}
+
+//SessionAttendance is the attendance of a guest at each session of an event
+//Present is in the same order as the sessions of the AttendanceMatrix it is part of
+type SessionAttendance struct {
+	Name    string `json:"name"`
+	Present []bool `json:"present"`
+}
+
 //Guest is all the information related to a particular guest
 type Guest struct {
 	Name string   `json:"name,omitempty"`
@@ -109,21 +494,125 @@ func (g *Guest) IsEmpty() bool {
 	return g.Name == "" && g.NRIC == "" && g.Tags == nil
 }
 
+//GuestRecord is the attendance record of a guest, without their NRIC
+//CheckInTime is null if the guest has not checked in
+type GuestRecord struct {
+	Name        string    `json:"name"`
+	Tags        []string  `json:"tags"`
+	CheckedIn   bool      `json:"checkedIn"`
+	CheckInTime null.Time `json:"checkInTime"`
+}
+
+//GuestFilter picks out the guests of an event which a tag is added to or removed from at once
+type GuestFilter struct {
+	Tags      TagFilter `json:"tags"`      //only guests matching the filter, the zero TagFilter for every guest
+	CheckedIn null.Bool `json:"checkedIn"` //only guests who have (true) or have not (false) checked in
+}
+
+//SyncOptions changes how the guests of an event are synced with a list of guests
+type SyncOptions struct {
+	RemoveMissing bool //remove guests of the event who are not in the list
+	DryRun        bool //only work out the changes, without making them
+}
+
+//GuestUpdate is a change to the name or tags of a guest made by a sync
+type GuestUpdate struct {
+	NRIC    string   `json:"nric"` //as given in the list
+	Name    string   `json:"name"`
+	Tags    []string `json:"tags"`
+	OldName string   `json:"oldName"`
+	OldTags []string `json:"oldTags"`
+}
+
+//GuestSync is the changes made (or, for a dry run, which would be made) by syncing the guests of an event
+//with a list of guests. Updated guests keep their check in status and attendance
+type GuestSync struct {
+	Added     []Guest       `json:"added"`
+	Updated   []GuestUpdate `json:"updated"`
+	Removed   []GuestRecord `json:"removed"` //guests are only removed if SyncOptions.RemoveMissing is set
+	Unchanged int           `json:"unchanged"`
+	DryRun    bool          `json:"dryRun"`
+}
+
+//Actions recorded in the attendance log
+const (
+	ActionCheckIn    = "checkin"
+	ActionMarkAbsent = "markabsent"
+)
+
+//ActorSelf is the actor recorded when guests check themselves in, without logging in
+const ActorSelf = "self"
+
+//AttendanceSource describes who changed a guest's attendance, and from what client
+type AttendanceSource struct {
+	Actor     string `json:"actor"` //username of the host or admin, or ActorSelf
+	IPAddress string `json:"ipAddress"`
+	UserAgent string `json:"userAgent"`
+}
+
+//AttendanceEntry is one change to a guest's attendance, as recorded in an event's
+//append-only attendance log
+type AttendanceEntry struct {
+	ID        int64       `json:"id"`
+	EventID   string      `json:"eventId"`
+	GuestHash string      `json:"guestHash"` //identifies the guest without revealing their NRIC
+	GuestName string      `json:"guestName"` //name of the guest at the time of the change
+	Action    string      `json:"action"`    //one of the Action constants
+	SessionID null.String `json:"sessionId"` //null if the change was to the event as a whole
+	Time      time.Time   `json:"time"`
+	AttendanceSource
+}
+
+//CheckInFlag is a guest's attempt to check themselves in from outside the geofence of an event,
+//recorded for the hosts of the event to review
+type CheckInFlag struct {
+	ID        int64      `json:"id"`
+	EventID   string     `json:"eventId"`
+	GuestHash string     `json:"guestHash"` //identifies the guest without revealing their NRIC
+	GuestName string     `json:"guestName"` //name of the guest at the time of the attempt
+	Lat       null.Float `json:"lat"`       //null if the guest did not say where they were
+	Long      null.Float `json:"long"`
+	Distance  null.Float `json:"distance"` //from the event's location in km, null if the location is unknown
+	Rejected  bool       `json:"rejected"` //true if the guest was not checked in, false if checked in anyway
+	Time      time.Time  `json:"time"`
+	AttendanceSource
+}
+
+//AttendanceLogService is for reading the attendance log of an event, and the check ins flagged by its geofence
+//Entries and flags are written by the GuestService whenever a guest is checked in or marked absent,
+//or a check in is flagged
+type AttendanceLogService interface {
+	AttendanceLog(eventID string, opts ListOptions) ([]AttendanceEntry, int, error)
+	CheckInFlags(eventID string, opts ListOptions) ([]CheckInFlag, int, error)
+}
+
 //GuestService is for checking in guests at a specific event
 type GuestService interface {
-	CheckIn(eventID string, nric string) (string, error)
-	MarkAbsent(eventID string, nric string) error
-	Guests(eventID string, tags []string) ([]string, error)
-	GuestsCheckedIn(eventID string, tags []string) ([]string, error)
-	GuestsNotCheckedIn(eventID string, tags []string) ([]string, error)
+	CheckIn(eventID string, sessionID string, nric string, source AttendanceSource) (string, error)
+	MarkAbsent(eventID string, sessionID string, nric string, source AttendanceSource) (string, error)
+	FlagCheckIn(eventID string, nric string, flag CheckInFlag) error
+	Guests(eventID string, filter TagFilter) ([]string, error)
+	GuestsCheckedIn(eventID string, filter TagFilter) ([]string, error)
+	GuestsNotCheckedIn(eventID string, filter TagFilter) ([]string, error)
+	GuestRecords(eventID string, filter TagFilter, opts ListOptions) ([]GuestRecord, int, error)
+	GuestRecordsCheckedIn(eventID string, filter TagFilter, opts ListOptions) ([]GuestRecord, int, error)
+	GuestRecordsNotCheckedIn(eventID string, filter TagFilter, opts ListOptions) ([]GuestRecord, int, error)
 	GuestExists(eventID string, nric string) (bool, error)
 	RegisterGuest(eventID string, guest Guest) error
 	RegisterGuests(eventID string, guests []Guest) error
+	SyncGuests(eventID string, guests []Guest, opts SyncOptions) (GuestSync, error)
 	Tags(eventID string, nric string) ([]string, error)
 	SetTags(eventID string, nric string, tags []string) error
 	AllTags(eventID string) ([]string, error)
+	AddTag(eventID string, tag string, filter GuestFilter) (int, error)
+	RemoveTag(eventID string, tag string, filter GuestFilter) (int, error)
+	RenameTag(eventID string, tag string, newTag string) (int, error)
 	RemoveGuest(eventID string, nric string) error
-	CheckInStats(eventID string, tags []string) (GuestStats, error)
+	CheckInStats(eventID string, sessionID string, filter TagFilter) (GuestStats, error)
+	TagStats(eventID string, sessionID string, filter TagFilter) ([]TagStats, error)
+	TagCrossStats(eventID string, sessionID string, rows []string, columns []string, filter TagFilter) (TagCrossStats, error)
+	AttendanceMatrix(eventID string, filter TagFilter) (AttendanceMatrix, error)
+	Arrivals(eventID string, filter TagFilter) ([]Arrival, error)
 }
 
 //AuthorizationInfo stores critical information about a particular request's authorizations
@@ -3,7 +3,11 @@ package checkin_test
 import (
 	"checkin"
 	"checkin/test"
+	"math"
 	"testing"
+	"time"
+
+	"github.com/guregu/null"
 )
 
 func TestIsEmpty(t *testing.T) {
@@ -13,3 +17,155 @@ func TestIsEmpty(t *testing.T) {
 	guest.Tags = make([]string, 0)
 	test.Equals(t, false, guest.IsEmpty())
 }
+
+func TestListOptionsIsValid(t *testing.T) {
+	var opts checkin.ListOptions
+	test.Equals(t, true, opts.IsValid())
+	test.Equals(t, true, opts.IsValid(checkin.SortByName))
+
+	opts.SortBy = checkin.SortByName
+	test.Equals(t, false, opts.IsValid())
+	test.Equals(t, true, opts.IsValid(checkin.SortByCheckInTime, checkin.SortByName))
+	test.Equals(t, false, opts.IsValid(checkin.SortByCheckInTime))
+
+	opts = checkin.ListOptions{Offset: -1}
+	test.Equals(t, false, opts.IsValid())
+	opts = checkin.ListOptions{Limit: -1}
+	test.Equals(t, false, opts.IsValid())
+}
+
+func TestListOptionsPageSize(t *testing.T) {
+	var opts checkin.ListOptions
+	test.Equals(t, 0, opts.PageSize(0))
+	test.Equals(t, 15, opts.PageSize(15))
+
+	opts = checkin.ListOptions{Offset: 10, Limit: 10}
+	test.Equals(t, 10, opts.PageSize(25))
+	test.Equals(t, 5, opts.PageSize(15))
+	test.Equals(t, 0, opts.PageSize(10))
+	test.Equals(t, 0, opts.PageSize(3))
+
+	opts = checkin.ListOptions{Offset: 10}
+	test.Equals(t, 15, opts.PageSize(25))
+}
+
+func TestLockoutDuration(t *testing.T) {
+	p := checkin.LockoutPolicy{Threshold: 3, BaseLockout: 30 * time.Second, MaxLockout: 5 * time.Minute}
+	test.Equals(t, time.Duration(0), p.LockoutDuration(0))
+	test.Equals(t, time.Duration(0), p.LockoutDuration(2))
+	test.Equals(t, 30*time.Second, p.LockoutDuration(3))
+	test.Equals(t, time.Minute, p.LockoutDuration(4))
+	test.Equals(t, 4*time.Minute, p.LockoutDuration(6))
+	test.Equals(t, 5*time.Minute, p.LockoutDuration(7))
+	test.Equals(t, 5*time.Minute, p.LockoutDuration(1000))
+}
+
+func TestRetryDelay(t *testing.T) {
+	p := checkin.RetryPolicy{MaxAttempts: 6, BaseDelay: 30 * time.Second, MaxDelay: 5 * time.Minute}
+	delay, ok := p.RetryDelay(1)
+	test.Equals(t, 30*time.Second, delay)
+	test.Equals(t, true, ok)
+	delay, ok = p.RetryDelay(3)
+	test.Equals(t, 2*time.Minute, delay)
+	test.Equals(t, true, ok)
+	delay, ok = p.RetryDelay(5)
+	test.Equals(t, 5*time.Minute, delay)
+	test.Equals(t, true, ok)
+	_, ok = p.RetryDelay(6)
+	test.Equals(t, false, ok)
+}
+
+func TestRoleHasPermission(t *testing.T) {
+	for _, role := range []string{checkin.RoleOwner, checkin.RoleCoHost, checkin.RoleUsher, checkin.RoleViewer} {
+		test.Assert(t, checkin.IsValidRole(role), "Role "+role+" is not valid")
+		test.Assert(t, checkin.RoleHasPermission(role, checkin.PermissionViewEvent), "Role "+role+" cannot view its event")
+	}
+	test.Equals(t, false, checkin.IsValidRole("admin"))
+	test.Equals(t, false, checkin.RoleHasPermission("admin", checkin.PermissionViewEvent))
+
+	test.Equals(t, true, checkin.RoleHasPermission(checkin.RoleOwner, checkin.PermissionDeleteEvent))
+	test.Equals(t, false, checkin.RoleHasPermission(checkin.RoleCoHost, checkin.PermissionDeleteEvent))
+	test.Equals(t, true, checkin.RoleHasPermission(checkin.RoleCoHost, checkin.PermissionManageGuests))
+	test.Equals(t, true, checkin.RoleHasPermission(checkin.RoleUsher, checkin.PermissionCheckIn))
+	test.Equals(t, false, checkin.RoleHasPermission(checkin.RoleUsher, checkin.PermissionViewGuests))
+	test.Equals(t, false, checkin.RoleHasPermission(checkin.RoleViewer, checkin.PermissionCheckIn))
+	test.Equals(t, true, checkin.RoleHasPermission(checkin.RoleViewer, checkin.PermissionViewReports))
+	test.Equals(t, true, checkin.RoleHasPermission(checkin.RoleOwner, checkin.PermissionManageHosts))
+	test.Equals(t, false, checkin.RoleHasPermission(checkin.RoleCoHost, checkin.PermissionManageHosts))
+}
+
+func TestGeofence(t *testing.T) {
+	e := checkin.Event{Lat: null.FloatFrom(1.335932), Long: null.FloatFrom(103.744708), Radius: null.FloatFrom(0.5)}
+	test.Assert(t, e.DistanceFrom(1.335932, 103.744708) < 0.000001, "Distance from the event's location is not 0")
+	test.Assert(t, math.Abs(e.DistanceFrom(1.344932, 103.744708)-1.0008) < 0.001, "Distance of 0.009 degrees latitude is not 1km")
+	test.Equals(t, true, e.WithinGeofence(null.FloatFrom(1.3360), null.FloatFrom(103.7450)))
+	test.Equals(t, false, e.WithinGeofence(null.FloatFrom(1.4), null.FloatFrom(103.8)))
+	test.Equals(t, false, e.WithinGeofence(null.Float{}, null.FloatFrom(103.7450)))
+
+	test.Equals(t, true, e.HasValidGeofence())
+	e.Geofence = checkin.GeofenceEnforce
+	test.Equals(t, true, e.HasValidGeofence())
+	e.Geofence = "always"
+	test.Equals(t, false, e.HasValidGeofence())
+	e.Geofence = checkin.GeofenceFlag
+	e.Radius = null.Float{}
+	test.Equals(t, false, e.HasValidGeofence())
+	test.Equals(t, false, e.WithinGeofence(null.FloatFrom(1.3360), null.FloatFrom(103.7450)))
+
+	//test locations out of range are invalid, with or without a geofence
+	e.Radius, e.Lat = null.FloatFrom(0.5), null.FloatFrom(91)
+	test.Equals(t, false, e.HasValidGeofence())
+	e.Geofence, e.Lat, e.Long = checkin.GeofenceOff, null.FloatFrom(1.335932), null.FloatFrom(-180.5)
+	test.Equals(t, false, e.HasValidGeofence())
+	test.Equals(t, true, checkin.ValidCoordinates(null.FloatFrom(-90), null.FloatFrom(180)))
+	test.Equals(t, true, checkin.ValidCoordinates(null.Float{}, null.Float{}))
+	test.Equals(t, false, checkin.ValidCoordinates(null.FloatFrom(-90.1), null.Float{}))
+	test.Equals(t, false, checkin.ValidCoordinates(null.Float{}, null.FloatFrom(181)))
+}
+
+func TestHasValidTimezone(t *testing.T) {
+	for timezone, valid := range map[string]bool{"": true, "UTC": true, "Asia/Singapore": true,
+		"Asia/Gotham": false, "+08:00": false, "Local": false} {
+		e := checkin.Event{Timezone: timezone}
+		test.Equals(t, valid, e.HasValidTimezone())
+	}
+}
+
+func TestEventCopy(t *testing.T) {
+	e := checkin.Event{
+		ID:        "100",
+		Name:      "Monthly Parade",
+		URL:       null.StringFrom("parade"),
+		Start:     null.TimeFrom(time.Date(2019, 3, 4, 8, 0, 0, 0, time.UTC)),
+		End:       null.TimeFrom(time.Date(2019, 3, 4, 10, 0, 0, 0, time.UTC)),
+		TimeTags:  map[string]time.Time{"release": time.Date(2019, 3, 1, 9, 0, 0, 0, time.UTC)},
+		Lat:       null.FloatFrom(1.335932),
+		CreatedAt: time.Date(2019, 2, 1, 0, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2019, 2, 2, 0, 0, 0, 0, time.UTC),
+	}
+
+	//the end and triggers move along with the start
+	c := e.Copy(checkin.CloneOptions{Start: null.TimeFrom(time.Date(2019, 4, 1, 8, 0, 0, 0, time.UTC))})
+	test.Equals(t, checkin.Event{
+		Name:     "Monthly Parade",
+		Start:    null.TimeFrom(time.Date(2019, 4, 1, 8, 0, 0, 0, time.UTC)),
+		End:      null.TimeFrom(time.Date(2019, 4, 1, 10, 0, 0, 0, time.UTC)),
+		TimeTags: map[string]time.Time{"release": time.Date(2019, 3, 29, 9, 0, 0, 0, time.UTC)},
+		Lat:      null.FloatFrom(1.335932),
+	}, c)
+	test.Equals(t, time.Date(2019, 3, 1, 9, 0, 0, 0, time.UTC), e.TimeTags["release"])
+
+	//without a new start, only the name and URL change
+	c = e.Copy(checkin.CloneOptions{Name: "April Parade", URL: null.StringFrom("aprilparade")})
+	test.Equals(t, "April Parade", c.Name)
+	test.Equals(t, null.StringFrom("aprilparade"), c.URL)
+	test.Equals(t, e.Start, c.Start)
+	test.Equals(t, e.TimeTags, c.TimeTags)
+
+	//events without a start keep their end and triggers
+	e.Start = null.Time{}
+	c = e.Copy(checkin.CloneOptions{Start: null.TimeFrom(time.Date(2019, 4, 1, 8, 0, 0, 0, time.UTC))})
+	test.Equals(t, null.TimeFrom(time.Date(2019, 4, 1, 8, 0, 0, 0, time.UTC)), c.Start)
+	test.Equals(t, e.End, c.End)
+	test.Equals(t, e.TimeTags, c.TimeTags)
+}
@@ -0,0 +1,38 @@
+package postgres
+
+import (
+	"checkin"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+//Arrivals returns the check in times of the guests of the event who have checked in, along with their tags,
+//in order of check in
+//Can filter the guests down to those matching the tag filter
+//No error thrown if event does not exist - just gives empty array, so check existence before calling method
+func (gs *GuestService) Arrivals(eventID string, filter checkin.TagFilter) ([]checkin.Arrival, error) {
+	condition, args := tagCondition(filter, "tags", []interface{}{eventID})
+	rows, err := gs.DB.Query(`SELECT checkInTime, tags from guest where eventID = $1 and checkedIn = TRUE
+	and checkInTime IS NOT NULL and `+condition+" ORDER BY checkInTime", args...)
+	if err != nil {
+		return nil, errors.New("Cannot fetch arrivals: " + err.Error())
+	}
+	defer rows.Close()
+
+	arrivals := make([]checkin.Arrival, 0)
+	for rows.Next() {
+		var arrival checkin.Arrival
+		err = rows.Scan(&arrival.Time, pq.Array(&arrival.Tags))
+		if err != nil {
+			return nil, errors.New("Could not extract arrival: " + err.Error())
+		}
+		if arrival.Tags == nil {
+			arrival.Tags = []string{} //no nils allowed
+		}
+		arrival.Time = arrival.Time.In(time.UTC)
+		arrivals = append(arrivals, arrival)
+	}
+	return arrivals, nil
+}
@@ -0,0 +1,131 @@
+package postgres
+
+import (
+	"checkin"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//AttendanceLogService is a postgres implementation of checkin.AttendanceLogService
+//Needs to be supplied with a database connection
+//The log itself is written to by GuestService, as guests are checked in and marked absent, as are check in flags
+type AttendanceLogService struct {
+	DB *sqlx.DB
+}
+
+//attendanceSortColumns maps the sort keys attendance entries can be listed by to their columns
+var attendanceSortColumns = map[string]string{
+	checkin.SortByTime: "time",
+	checkin.SortByName: "guestName",
+}
+
+//AttendanceLog returns a page of the attendance log of the event with the given ID, along with
+//the total number of entries across all pages
+//opts may sort by time (the default) or guest name, and search by guest name prefix
+//No error thrown if event does not exist - just gives empty array, so check existence before calling method
+func (als *AttendanceLogService) AttendanceLog(eventID string, opts checkin.ListOptions) ([]checkin.AttendanceEntry, int, error) {
+	clauses, err := orderAndPaginate(opts, attendanceSortColumns, checkin.SortByTime, "ID")
+	if err != nil {
+		return nil, 0, err
+	}
+	pattern := searchPattern(opts.Search)
+
+	tx, err := listingTx(als.DB)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	var total int
+	err = tx.QueryRow("SELECT count(*) from attendancelog where eventID = $1 and guestName ILIKE $2",
+		eventID, pattern).Scan(&total)
+	if err != nil {
+		return nil, 0, errors.New("Cannot fetch number of attendance log entries: " + err.Error())
+	}
+	rows, err := tx.Query("SELECT ID, eventID, nricHash, guestName, action, sessionID, time, actor, ipAddress, userAgent from attendancelog where eventID = $1 and guestName ILIKE $2"+clauses,
+		eventID, pattern)
+	if err != nil {
+		return nil, 0, errors.New("Cannot fetch attendance log: " + err.Error())
+	}
+	defer rows.Close()
+
+	entries, err := als.scanRowsIntoAttendanceEntries(rows, opts.PageSize(total))
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+//CheckInFlags returns a page of the check ins flagged by the geofence of the event with the given ID,
+//along with the total number of flags across all pages
+//opts may sort by time (the default) or guest name, and search by guest name prefix
+//No error thrown if event does not exist - just gives empty array, so check existence before calling method
+func (als *AttendanceLogService) CheckInFlags(eventID string, opts checkin.ListOptions) ([]checkin.CheckInFlag, int, error) {
+	clauses, err := orderAndPaginate(opts, attendanceSortColumns, checkin.SortByTime, "ID")
+	if err != nil {
+		return nil, 0, err
+	}
+	pattern := searchPattern(opts.Search)
+
+	tx, err := listingTx(als.DB)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	var total int
+	err = tx.QueryRow("SELECT count(*) from checkinflag where eventID = $1 and guestName ILIKE $2",
+		eventID, pattern).Scan(&total)
+	if err != nil {
+		return nil, 0, errors.New("Cannot fetch number of flagged check ins: " + err.Error())
+	}
+	rows, err := tx.Query("SELECT ID, eventID, nricHash, guestName, lat, long, distance, rejected, time, actor, ipAddress, userAgent from checkinflag where eventID = $1 and guestName ILIKE $2"+clauses,
+		eventID, pattern)
+	if err != nil {
+		return nil, 0, errors.New("Cannot fetch flagged check ins: " + err.Error())
+	}
+	defer rows.Close()
+
+	flags, err := als.scanRowsIntoCheckInFlags(rows, opts.PageSize(total))
+	if err != nil {
+		return nil, 0, err
+	}
+	return flags, total, nil
+}
+
+func (als *AttendanceLogService) scanRowsIntoAttendanceEntries(rows *sql.Rows, rowCount int) ([]checkin.AttendanceEntry, error) {
+	entries := make([]checkin.AttendanceEntry, 0, rowCount)
+
+	for thereAreMore := rows.Next(); thereAreMore; thereAreMore = rows.Next() {
+		var entry checkin.AttendanceEntry
+		err := rows.Scan(&entry.ID, &entry.EventID, &entry.GuestHash, &entry.GuestName, &entry.Action, &entry.SessionID, &entry.Time,
+			&entry.Actor, &entry.IPAddress, &entry.UserAgent)
+		if err != nil {
+			return nil, errors.New("Could not extract attendance log entry: " + err.Error())
+		}
+		entry.Time = entry.Time.In(time.UTC) //make sure all times are in UTC
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func (als *AttendanceLogService) scanRowsIntoCheckInFlags(rows *sql.Rows, rowCount int) ([]checkin.CheckInFlag, error) {
+	flags := make([]checkin.CheckInFlag, 0, rowCount)
+
+	for thereAreMore := rows.Next(); thereAreMore; thereAreMore = rows.Next() {
+		var flag checkin.CheckInFlag
+		err := rows.Scan(&flag.ID, &flag.EventID, &flag.GuestHash, &flag.GuestName, &flag.Lat, &flag.Long, &flag.Distance,
+			&flag.Rejected, &flag.Time, &flag.Actor, &flag.IPAddress, &flag.UserAgent)
+		if err != nil {
+			return nil, errors.New("Could not extract flagged check in: " + err.Error())
+		}
+		flag.Time = flag.Time.In(time.UTC) //make sure all times are in UTC
+		flags = append(flags, flag)
+	}
+
+	return flags, nil
+}
@@ -0,0 +1,127 @@
+package postgres_test
+
+import (
+	"checkin"
+	"checkin/mock"
+	"checkin/postgres"
+	"checkin/test"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+)
+
+func TestAttendanceLog(t *testing.T) {
+	als := postgres.AttendanceLogService{DB: db}
+	eventID := "2c59b54d-3422-4bdb-824c-4125775b44c8"
+
+	//test normal functionality, which defaults to sorting by time
+	entries, total, err := als.AttendanceLog(eventID, checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, 4, total)
+	test.Equals(t, 4, len(entries))
+	test.Equals(t, checkin.AttendanceEntry{
+		ID:        entries[0].ID, //assigned by the database
+		EventID:   eventID,
+		GuestHash: "B7234",
+		GuestName: "P",
+		Action:    checkin.ActionCheckIn,
+		Time:      time.Date(2019, 4, 12, 9, 1, 0, 0, time.UTC),
+		AttendanceSource: checkin.AttendanceSource{
+			Actor:     checkin.ActorSelf,
+			IPAddress: "203.0.113.5",
+			UserAgent: "Mozilla/5.0",
+		},
+	}, entries[0])
+	test.Equals(t, "Q", entries[1].GuestName)
+	test.Equals(t, checkin.ActionMarkAbsent, entries[2].Action)
+	test.Equals(t, "TestUser", entries[2].Actor)
+	test.Equals(t, checkin.ActionCheckIn, entries[3].Action)
+
+	//test pagination, most recent first
+	entries, total, err = als.AttendanceLog(eventID, checkin.ListOptions{Descending: true, Limit: 2})
+	test.Ok(t, err)
+	test.Equals(t, 4, total)
+	test.Equals(t, 2, len(entries))
+	test.Equals(t, time.Date(2019, 4, 12, 9, 6, 0, 0, time.UTC), entries[0].Time)
+	test.Equals(t, time.Date(2019, 4, 12, 9, 5, 0, 0, time.UTC), entries[1].Time)
+
+	//test search by guest name
+	entries, total, err = als.AttendanceLog(eventID, checkin.ListOptions{Search: "q"})
+	test.Ok(t, err)
+	test.Equals(t, 1, total)
+	test.Equals(t, "C9648", entries[0].GuestHash)
+
+	//test sort by guest name
+	entries, _, err = als.AttendanceLog(eventID, checkin.ListOptions{SortBy: checkin.SortByName, Descending: true})
+	test.Ok(t, err)
+	test.Equals(t, "Q", entries[0].GuestName)
+
+	//test unsupported sort key
+	_, _, err = als.AttendanceLog(eventID, checkin.ListOptions{SortBy: checkin.SortByStart})
+	test.Assert(t, err != nil, "Expected error sorting attendance log by start")
+
+	//test event with no log entries
+	entries, total, err = als.AttendanceLog("c14a592c-950d-44ba-b173-bbb9e4f5c8b4", checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, 0, total)
+	test.Equals(t, []checkin.AttendanceEntry{}, entries)
+}
+
+func TestCheckInFlags(t *testing.T) {
+	var hm mock.HashMethod
+	hm.CompareHashAndPasswordFn = compareHashAndPasswordGenerator()
+	gs := postgres.GuestService{HM: &hm, DB: db, HashCache: make(map[string]string)}
+	als := postgres.AttendanceLogService{DB: db}
+	eventID := "2c59b54d-3422-4bdb-824c-4125775b44c8"
+	source := checkin.AttendanceSource{Actor: checkin.ActorSelf, IPAddress: "203.0.113.5", UserAgent: "Mozilla/5.0"}
+
+	//test no flags yet
+	flags, total, err := als.CheckInFlags(eventID, checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, 0, total)
+	test.Equals(t, []checkin.CheckInFlag{}, flags)
+
+	//test flags are recorded against the guest, with their location (if any)
+	err = gs.FlagCheckIn(eventID, "2234A", checkin.CheckInFlag{Lat: null.FloatFrom(1.4), Long: null.FloatFrom(103.8),
+		Distance: null.FloatFrom(9.5), AttendanceSource: source})
+	test.Ok(t, err)
+	err = gs.FlagCheckIn(eventID, "3678B", checkin.CheckInFlag{Rejected: true, AttendanceSource: source})
+	test.Ok(t, err)
+	flags, total, err = als.CheckInFlags(eventID, checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, 2, total)
+	test.Assert(t, time.Since(flags[0].Time) < 2*time.Second, "Flag time not set to now")
+	test.Equals(t, checkin.CheckInFlag{
+		ID:               flags[0].ID, //assigned by the database
+		EventID:          eventID,
+		GuestHash:        "A2234",
+		GuestName:        "K",
+		Lat:              null.FloatFrom(1.4),
+		Long:             null.FloatFrom(103.8),
+		Distance:         null.FloatFrom(9.5),
+		Rejected:         false,
+		Time:             flags[0].Time,
+		AttendanceSource: source,
+	}, flags[0])
+	test.Equals(t, "L", flags[1].GuestName)
+	test.Equals(t, true, flags[1].Rejected)
+	test.Equals(t, null.Float{}, flags[1].Lat)
+	test.Equals(t, null.Float{}, flags[1].Distance)
+
+	//test search and sort by guest name
+	flags, total, err = als.CheckInFlags(eventID, checkin.ListOptions{Search: "l"})
+	test.Ok(t, err)
+	test.Equals(t, 1, total)
+	test.Equals(t, "B3678", flags[0].GuestHash)
+	flags, _, err = als.CheckInFlags(eventID, checkin.ListOptions{SortBy: checkin.SortByName, Descending: true})
+	test.Ok(t, err)
+	test.Equals(t, "L", flags[0].GuestName)
+
+	//test guest does not exist
+	err = gs.FlagCheckIn(eventID, "9999Z", checkin.CheckInFlag{AttendanceSource: source})
+	test.Assert(t, err != nil, "No error flagging check in of guest who does not exist")
+
+	_, err = db.Exec("DELETE from checkinflag where eventID = $1", eventID)
+	test.Ok(t, err)
+}
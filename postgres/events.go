@@ -6,10 +6,12 @@ import (
 	"encoding/json"
 	"errors"
 	"log"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/guregu/null"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -61,57 +63,124 @@ func (es *EventService) EventByURL(url string) (checkin.Event, error) {
 	return event, nil
 }
 
-//Events Returns every event, by every user
-func (es *EventService) Events() ([]checkin.Event, error) {
-	rows, err := es.DB.Queryx(
-		"SELECT * from event")
+//Events Returns a page of every event, by every user, which match the filter, along with the total
+//number of such events
+//opts may sort by name (the default), start time or creation time, and search by name prefix
+func (es *EventService) Events(filter checkin.EventFilter, opts checkin.ListOptions) ([]checkin.Event, int, error) {
+	clauses, err := orderAndPaginate(opts, eventSortColumns, checkin.SortByName, "id")
 	if err != nil {
-		return nil, errors.New("Error fetching all events: " + err.Error())
+		return nil, 0, err
+	}
+	conditions, args := eventFilterConditions(filter, searchPattern(opts.Search))
+	tx, err := listingTx(es.DB)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	var numEvents int
+	err = tx.QueryRow("SELECT count(*) from event where "+conditions, args...).Scan(&numEvents)
+	if err != nil {
+		return nil, 0, errors.New("Error fetching number of events: " + err.Error())
+	}
+	rows, err := tx.Queryx("SELECT * from event where "+conditions+clauses, args...)
+	if err != nil {
+		return nil, 0, errors.New("Error fetching all events: " + err.Error())
 	}
 	defer rows.Close()
-	numEvents, err := es.getNumberOfEvents()
+
+	events, err := es.scanRowsIntoEvents(rows, opts.PageSize(numEvents))
 	if err != nil {
-		return nil, errors.New("Error fetching number of events:" + err.Error())
+		return nil, 0, errors.New("Error scanning rows into events:" + err.Error())
 	}
 
-	events, err := es.scanRowsIntoEvents(rows, numEvents)
-	if err == sql.ErrNoRows {
-		return make([]checkin.Event, 0), nil
-	} else if err != nil {
-		return nil, errors.New("Error scanning rows into events:" + err.Error())
+	return events, numEvents, nil
+}
+
+//eventFilterConditions builds the conditions of a where clause on the event table, matching events
+//which pass the filter and whose names match the ILIKE pattern, along with the arguments for their placeholders
+//Events without a start or end time are compared using the time they do have, and left out if they have neither
+//Archived events are only matched if the filter asks for them, in which case live events are not
+func eventFilterConditions(filter checkin.EventFilter, pattern string) (string, []interface{}) {
+	conditions := []string{"name ILIKE $1"}
+	args := []interface{}{pattern}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.Replace(condition, "?", "$"+strconv.Itoa(len(args)), -1))
+	}
+
+	if filter.From.Valid {
+		addCondition("coalesce(\"end\", \"start\") >= ?", filter.From.Time.In(time.UTC))
+	}
+	if filter.To.Valid {
+		addCondition("coalesce(\"start\", \"end\") <= ?", filter.To.Time.In(time.UTC))
+	}
+	if filter.Host != "" {
+		addCondition("ID in (SELECT eventID from hosts where username = ?)", filter.Host)
+	}
+	if filter.Archived {
+		conditions = append(conditions, "archivedAt IS NOT NULL")
+	} else {
+		conditions = append(conditions, "archivedAt IS NULL")
+	}
+	if filter.Released.Valid {
+		//events without a release time are released straight away
+		released := "coalesce((timetags->>'release')::timestamptz <= ?, TRUE)"
+		if !filter.Released.Bool {
+			released = "NOT " + released
+		}
+		addCondition(released, time.Now())
 	}
+	return strings.Join(conditions, " and "), args
+}
 
-	return events, nil
+//eventSortColumns maps the sort keys events can be listed by to their columns
+var eventSortColumns = map[string]string{
+	checkin.SortByName:      "name",
+	checkin.SortByStart:     "\"start\"",
+	checkin.SortByCreatedAt: "createdAt",
 }
 
 //EventsBy Given a username as an argument
-//Returns an array of all the events hosted by that user
+//Returns a page of the live (not archived) events hosted by that user, along with the total number of such events
+//across all pages. opts may sort by name (the default), start or createdAt, and search by name prefix
 //Will return an empty array (with no error) if that user hosts no events
 //If the user does not exist, will return an empty array (with no error)
 //as it is not the job of an EventService to perform user validation
 //Error only if there are issues fetching events from the database or scanning them
 //into structs
-func (es *EventService) EventsBy(username string) ([]checkin.Event, error) {
-	//need to list out columns instead of * as hosts is used in the query
-	rows, err := es.DB.Queryx("SELECT id, name, \"start\", \"end\", lat, long, radius, url, updatedat, createdat, timetags from event, hosts where hosts.username = $1 and hosts.eventID = event.ID",
-		username)
+func (es *EventService) EventsBy(username string, opts checkin.ListOptions) ([]checkin.Event, int, error) {
+	clauses, err := orderAndPaginate(opts, eventSortColumns, checkin.SortByName, "id")
 	if err != nil {
-		return nil, errors.New("Error fetching all events for user: " + err.Error())
+		return nil, 0, err
 	}
-	defer rows.Close()
-	numEvents, err := es.getNumberOfEventsBy(username)
+	pattern := searchPattern(opts.Search)
+	tx, err := listingTx(es.DB)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	numEvents, err := es.getNumberOfEventsBy(tx, username, pattern)
+	if err != nil {
+		return nil, 0, errors.New("Error fetching number of events for user:" + err.Error())
+	}
+	//need to list out columns instead of * as hosts is used in the query
+	rows, err := tx.Queryx("SELECT id, name, \"start\", \"end\", lat, long, radius, geofence, timezone, url, updatedat, createdat, timetags from event, hosts where hosts.username = $1 and hosts.eventID = event.ID and name ILIKE $2 and archivedAt IS NULL"+clauses,
+		username, pattern)
 	if err != nil {
-		return nil, errors.New("Error fetching number of events for user:" + err.Error())
+		return nil, 0, errors.New("Error fetching all events for user: " + err.Error())
 	}
+	defer rows.Close()
 
-	events, err := es.scanRowsIntoEvents(rows, numEvents)
+	events, err := es.scanRowsIntoEvents(rows, opts.PageSize(numEvents))
 	if err == sql.ErrNoRows {
-		return make([]checkin.Event, 0), nil
+		return make([]checkin.Event, 0), numEvents, nil
 	} else if err != nil {
-		return nil, errors.New("Error scanning rows into events:" + err.Error())
+		return nil, 0, errors.New("Error scanning rows into events:" + err.Error())
 	}
 
-	return events, nil
+	return events, numEvents, nil
 }
 
 //CreateEvent creates a new event in the database given its contents
@@ -133,15 +202,13 @@ func (es *EventService) CreateEvent(e checkin.Event, hostUsername string) error
 		}
 	}()
 
-	rawEvent := es.marshalEvent(e)
-
-	_, err = tx.NamedExec("INSERT INTO event(id, name, url, start, \"end\", timetags, lat, long, radius) VALUES (:id, :name, :url, :start, :end, :timetags,:lat, :long, :radius)", rawEvent)
+	err = es.insertEvent(tx, e)
 	if err != nil {
 		tx.Rollback()
-		return errors.New("Error inserting event data: " + err.Error())
+		return err
 	}
 
-	_, err = tx.Exec("INSERT into hosts(eventID, username) VALUES ($1, $2)", rawEvent.ID, hostUsername)
+	_, err = tx.Exec("INSERT into hosts(eventID, username, role) VALUES ($1, $2, $3)", e.ID, hostUsername, checkin.RoleOwner)
 	if err != nil {
 		tx.Rollback()
 		return errors.New("Error creating host relationship: " + err.Error())
@@ -156,36 +223,135 @@ func (es *EventService) CreateEvent(e checkin.Event, hostUsername string) error
 	return nil
 }
 
+//insertEvent inserts the details of a new event within the transaction
+func (es *EventService) insertEvent(tx *sqlx.Tx, e checkin.Event) error {
+	_, err := tx.NamedExec("INSERT INTO event(id, name, url, start, \"end\", timetags, lat, long, radius, geofence, timezone) VALUES (:id, :name, :url, :start, :end, :timetags,:lat, :long, :radius, :geofence, :timezone)", es.marshalEvent(e))
+	if err != nil {
+		return errors.New("Error inserting event data: " + err.Error())
+	}
+	return nil
+}
+
 //UpdateEvent updates a particular event given an event object encapsulating
 //ALL THE NEW FIELDS of the object
 //It will use the eventID as the key to know which row in the DB to update
 //So note that eventID cannot be mutated
 //All columns in the database will be set to the fields of the event object
 //Except for the ID, createdAt and updatedAt fields, which are not editable
+//If ifUpdatedAt is given, the event is only updated if it was last updated then, so that
+//changes made since it was read are not overwritten - returns false without updating if it was not
 //Returns an error if error in executing update, or no event with that ID exists
-func (es *EventService) UpdateEvent(event checkin.Event) error {
-	rawEvent := es.marshalEvent(event)
+func (es *EventService) UpdateEvent(event checkin.Event, ifUpdatedAt null.Time) (bool, error) {
+	conditionalEvent := struct {
+		rawEvent
+		IfUpdatedAt null.Time `db:"ifupdatedat"`
+	}{es.marshalEvent(event), null.NewTime(ifUpdatedAt.Time.In(time.UTC), ifUpdatedAt.Valid)}
 	res, err := es.DB.NamedExec("UPDATE event SET name = :name, timetags = :timetags, \"start\" = :start, "+
-		"\"end\" = :end, lat = :lat, long= :long, radius = :radius, url = :url, updatedAt = (NOW() at time zone 'utc') where id = :id",
-		&rawEvent)
+		"\"end\" = :end, lat = :lat, long= :long, radius = :radius, geofence = :geofence, timezone = :timezone, url = :url, updatedAt = (NOW() at time zone 'utc') "+
+		"where id = :id and (CAST(:ifupdatedat AS TIMESTAMP) IS NULL or updatedAt = :ifupdatedat)",
+		&conditionalEvent)
 	if err != nil {
-		return errors.New("Error when updating event: " + err.Error())
+		return false, errors.New("Error when updating event: " + err.Error())
+	}
+	if rows, err := res.RowsAffected(); err != nil {
+		return false, errors.New("Error checking if rows were affected: " + err.Error())
+	} else if rows == 0 {
+		if exists, err := es.CheckIfExists(event.ID); err != nil {
+			return false, errors.New("Error checking if event exists: " + err.Error())
+		} else if !exists {
+			return false, errors.New("No event exists with that UUID")
+		}
+		return false, nil //updated since ifUpdatedAt
+	}
+
+	return true, nil
+}
+
+//SetTimeTag sets the time of a single time tag of the event, adding it if the event does not have it yet
+//The other time tags are left as they are, even if they are changed at the same time
+//Returns an error if no event with that ID exists
+func (es *EventService) SetTimeTag(eventID string, tag string, t time.Time) error {
+	res, err := es.DB.Exec("UPDATE event SET timetags = (timetags::jsonb || jsonb_build_object($1::text, $2::text))::json, "+
+		"updatedAt = (NOW() at time zone 'utc') where ID = $3", strings.ToLower(tag), t.In(time.UTC).Format(time.RFC3339Nano), eventID)
+	if err != nil {
+		return errors.New("Error setting time tag: " + err.Error())
 	}
 	if rows, err := res.RowsAffected(); err != nil {
 		return errors.New("Error checking if rows were affected: " + err.Error())
 	} else if rows == 0 {
 		return errors.New("No event exists with that UUID")
 	}
+	return nil
+}
 
+//RemoveTimeTag removes a single time tag of the event, leaving the others as they are
+//No error thrown if the event does not have the time tag
+func (es *EventService) RemoveTimeTag(eventID string, tag string) error {
+	_, err := es.DB.Exec("UPDATE event SET timetags = (timetags::jsonb - $1::text)::json, "+
+		"updatedAt = (NOW() at time zone 'utc') where ID = $2", strings.ToLower(tag), eventID)
+	if err != nil {
+		return errors.New("Error removing time tag: " + err.Error())
+	}
 	return nil
 }
 
-//DeleteEvent removes an event (if it exists) from the database
+//DeleteEvent removes an event (if it exists) from the database for good, along with everything of it
+//Events deleted by hosts are archived instead, see ArchiveEvent
 func (es *EventService) DeleteEvent(eventID string) error {
 	_, err := es.DB.Exec("DELETE FROM event where ID = $1", eventID)
 	return err
 }
 
+//ArchiveEvent hides the event as though it was deleted, keeping it until it is restored or purged
+//Archived events do not exist as far as CheckIfExists is concerned, so nothing can be done with them,
+//but their URLs stay taken in case they are restored
+//Archiving an event which is already archived keeps the time it was first archived
+//Returns an error if no event with that ID exists
+func (es *EventService) ArchiveEvent(eventID string) error {
+	res, err := es.DB.Exec("UPDATE event SET archivedAt = coalesce(archivedAt, (NOW() at time zone 'utc')) where ID = $1",
+		eventID)
+	if err != nil {
+		return errors.New("Error archiving event: " + err.Error())
+	}
+	if rows, err := res.RowsAffected(); err != nil {
+		return errors.New("Error checking if rows were affected: " + err.Error())
+	} else if rows == 0 {
+		return errors.New("No event exists with that UUID")
+	}
+	return nil
+}
+
+//RestoreEvent brings back the event if it was archived at or after archivedSince
+//Returns false if there is no such archived event, including events archived before archivedSince
+func (es *EventService) RestoreEvent(eventID string, archivedSince time.Time) (bool, error) {
+	if _, err := uuid.Parse(eventID); err != nil {
+		return false, nil //as the query would fail on an ID which is not a UUID
+	}
+	res, err := es.DB.Exec("UPDATE event SET archivedAt = NULL where ID = $1 and archivedAt >= $2",
+		eventID, archivedSince.In(time.UTC))
+	if err != nil {
+		return false, errors.New("Error restoring event: " + err.Error())
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.New("Error checking if rows were affected: " + err.Error())
+	}
+	return rows > 0, nil
+}
+
+//PurgeEvents permanently deletes every event archived before archivedBefore, and returns how many there were
+func (es *EventService) PurgeEvents(archivedBefore time.Time) (int, error) {
+	res, err := es.DB.Exec("DELETE FROM event where archivedAt < $1", archivedBefore.In(time.UTC))
+	if err != nil {
+		return 0, errors.New("Error purging archived events: " + err.Error())
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.New("Error checking if rows were affected: " + err.Error())
+	}
+	return int(rows), nil
+}
+
 //URLExists checks if the given URL is already used
 //Returns true if it is already used
 //Returns false otherwise
@@ -195,7 +361,7 @@ func (es *EventService) URLExists(url string) (bool, error) {
 	return numURL == 1, err
 }
 
-//CheckIfExists checks if an event exists with that eventID
+//CheckIfExists checks if an event exists with that eventID, and has not been archived
 //Returns a boolean flag indicating if the event exists
 //Return a non-nil error if there is an error in querying the database
 func (es *EventService) CheckIfExists(id string) (bool, error) {
@@ -206,25 +372,161 @@ func (es *EventService) CheckIfExists(id string) (bool, error) {
 		//so this function will return an error instead of false for the event not existing
 		return false, nil
 	}
-	err := es.DB.QueryRow("SELECT count(*) from event where id = $1", id).Scan(&num)
+	err := es.DB.QueryRow("SELECT count(*) from event where id = $1 and archivedAt IS NULL", id).Scan(&num)
 	return num == 1, err
 }
 
-//AddHost creates a new host relationship between a user and an event
-func (es *EventService) AddHost(eventID string, username string) error {
-	_, err := es.DB.Exec("INSERT INTO hosts(eventID, username) VALUES ($1, $2)", eventID, username)
+//AddHost creates a new host relationship between a user and an event, where the user has the given role
+func (es *EventService) AddHost(eventID string, username string, role string) error {
+	if !checkin.IsValidRole(role) {
+		return errors.New("No such role: " + role)
+	}
+	_, err := es.DB.Exec("INSERT INTO hosts(eventID, username, role) VALUES ($1, $2, $3)", eventID, username, role)
 	return err
 }
 
-//CheckHost returns true if that user is a host of the given event
-func (es *EventService) CheckHost(username string, eventID string) (bool, error) {
-	var numHosts int
-	err := es.DB.QueryRow("SELECT count(*) from hosts where hosts.eventID = $1 and hosts.username = $2",
-		eventID, username).Scan(&numHosts)
+//HostRole returns the role the user has in the given event
+//Returns an empty string if the user is not a host of the event
+func (es *EventService) HostRole(username string, eventID string) (string, error) {
+	var role string
+	err := es.DB.QueryRow("SELECT role from hosts where hosts.eventID = $1 and hosts.username = $2",
+		eventID, username).Scan(&role)
+	if err == sql.ErrNoRows {
+		return "", nil
+	} else if err != nil {
+		return "", errors.New("Error checking if host relationship exists: " + err.Error())
+	}
+	return role, nil
+}
+
+//Hosts returns the hosts of the given event and their roles, sorted by username
+//No error thrown if event does not exist - just gives empty array, so check existence before calling method
+func (es *EventService) Hosts(eventID string) ([]checkin.Host, error) {
+	rows, err := es.DB.Queryx("SELECT username, role from hosts where eventID = $1 ORDER BY username", eventID)
 	if err != nil {
-		return false, errors.New("Error checking if host relationship exists: " + err.Error())
+		return nil, errors.New("Error fetching hosts of event: " + err.Error())
+	}
+	defer rows.Close()
+
+	return es.scanRowsIntoHosts(rows)
+}
+
+func (es *EventService) scanRowsIntoHosts(rows *sqlx.Rows) ([]checkin.Host, error) {
+	hosts := make([]checkin.Host, 0)
+
+	for thereAreMore := rows.Next(); thereAreMore; thereAreMore = rows.Next() {
+		var host checkin.Host
+		err := rows.StructScan(&host)
+		if err != nil {
+			return nil, errors.New("Could not extract host: " + err.Error())
+		}
+		hosts = append(hosts, host)
+	}
+
+	return hosts, nil
+}
+
+//RemoveHost removes the user from the hosts of the given event
+//Returns an error if the user is not a host of the event, or is its owner, as every
+//event must keep an owner (ownership has to be transferred before the owner can be removed)
+func (es *EventService) RemoveHost(eventID string, username string) error {
+	res, err := es.DB.Exec("DELETE from hosts where eventID = $1 and username = $2 and role <> $3",
+		eventID, username, checkin.RoleOwner)
+	if err != nil {
+		return errors.New("Error removing host: " + err.Error())
+	}
+	if rows, err := res.RowsAffected(); err != nil {
+		return errors.New("Error checking if rows were affected: " + err.Error())
+	} else if rows == 0 {
+		return errors.New("No host who is not the owner with that username: " + username)
 	}
-	return numHosts == 1, nil
+	return nil
+}
+
+//TransferOwnership makes the user the owner of the given event, adding them as a host if they are not one,
+//and makes the previous owner a co-host
+//The event is locked while its ownership is transferred, so transfers made at the same time happen one after the other
+func (es *EventService) TransferOwnership(eventID string, username string) error {
+	tx, err := es.DB.Beginx()
+	if err != nil {
+		return errors.New("Error opening transaction: " + err.Error())
+	}
+	var locked string
+	err = tx.QueryRow("SELECT ID from event where ID = $1 FOR UPDATE", eventID).Scan(&locked)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return errors.New("Event does not exist: " + eventID)
+	} else if err != nil {
+		tx.Rollback()
+		return errors.New("Error locking event: " + err.Error())
+	}
+	_, err = tx.Exec("UPDATE hosts SET role = $1 where eventID = $2 and role = $3 and username <> $4",
+		checkin.RoleCoHost, eventID, checkin.RoleOwner, username)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("Error demoting previous owner: " + err.Error())
+	}
+	_, err = tx.Exec(`INSERT into hosts(eventID, username, role) VALUES ($1, $2, $3)
+	ON CONFLICT (username, eventID) DO UPDATE SET role = $3`, eventID, username, checkin.RoleOwner)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("Error making user owner: " + err.Error())
+	}
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("Error committing transfer of ownership: " + err.Error())
+	}
+	return nil
+}
+
+//CloneEvent creates the event e as a clone of the event with the given ID, copying its hosts with their roles
+//The user cloning the event becomes the owner of the clone, and the owner of the original becomes a co-host
+//The sessions of the original are copied too, moved along with the start of the event
+//If copyGuests is set, the guest list is copied over too, with nobody checked in
+//Only the hosts, sessions and guests are copied, so the details of e should already be copied from the original
+func (es *EventService) CloneEvent(ID string, e checkin.Event, hostUsername string, copyGuests bool) error {
+	tx, err := es.DB.Beginx()
+	if err != nil {
+		return errors.New("Error opening transaction: " + err.Error())
+	}
+	err = es.insertEvent(tx, e)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	_, err = tx.Exec(`INSERT into hosts(eventID, username, role) SELECT $1, username,
+	CASE WHEN role = $2 THEN $3 ELSE role END from hosts where eventID = $4 and username <> $5`,
+		e.ID, checkin.RoleOwner, checkin.RoleCoHost, ID, hostUsername)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("Error copying hosts: " + err.Error())
+	}
+	_, err = tx.Exec("INSERT into hosts(eventID, username, role) VALUES ($1, $2, $3)", e.ID, hostUsername, checkin.RoleOwner)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("Error creating host relationship: " + err.Error())
+	}
+	_, err = tx.Exec(`INSERT into session(eventID, name, "start", "end")
+	SELECT n.ID, s.name, s."start" + coalesce(n."start" - o."start", interval '0'), s."end" + coalesce(n."start" - o."start", interval '0')
+	from session s join event o on o.ID = s.eventID join event n on n.ID = $1 where s.eventID = $2`, e.ID, ID)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("Error copying sessions: " + err.Error())
+	}
+	if copyGuests {
+		//guests are copied as they are stored, as their NRICs cannot be recovered from their hashes to register them again
+		_, err = tx.Exec(`INSERT into guest(nricHash, nricDigest, eventID, name, tags, checkedIn)
+		SELECT nricHash, nricDigest, $1, name, tags, FALSE from guest where eventID = $2`, e.ID, ID)
+		if err != nil {
+			tx.Rollback()
+			return errors.New("Error copying guests: " + err.Error())
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("Error committing clone of event: " + err.Error())
+	}
+	return nil
 }
 
 //SubmitFeedback adds a feedback form to the database
@@ -309,9 +611,8 @@ func (es *EventService) getNumberOfFeedbackForms(eventID string) (int, error) {
 }
 
 func (es *EventService) scanRowsIntoEvents(rows *sqlx.Rows, numRows int) ([]checkin.Event, error) {
-	events := make([]checkin.Event, numRows)
+	events := make([]checkin.Event, 0, numRows)
 
-	index := 0
 	for thereAreMore := rows.Next(); thereAreMore; thereAreMore = rows.Next() {
 		var rawEvent rawEvent
 		err := rows.StructScan(&rawEvent)
@@ -322,27 +623,17 @@ func (es *EventService) scanRowsIntoEvents(rows *sqlx.Rows, numRows int) ([]chec
 		if err != nil {
 			return nil, errors.New("Could not unmarshal time tag data from JSON: " + err.Error())
 		}
-		events[index] = event
-		index++
+		events = append(events, event)
 	}
 
 	return events, nil
 }
 
-func (es *EventService) getNumberOfEvents() (int, error) {
-	var numEvents int
-	err := es.DB.QueryRow("SELECT count(*) from event").Scan(&numEvents)
-
-	if err != nil {
-		return 0, errors.New("Cannot fetch event count: " + err.Error())
-	}
-
-	return numEvents, nil
-}
-
-func (es *EventService) getNumberOfEventsBy(username string) (int, error) {
+//getNumberOfEventsBy counts the events hosted by the user whose names match the given ILIKE pattern
+func (es *EventService) getNumberOfEventsBy(q sqlx.Queryer, username string, pattern string) (int, error) {
 	var numEvents int
-	err := es.DB.QueryRow("SELECT count(*) from event, hosts where hosts.username = $1 and hosts.eventID = event.ID", username).Scan(&numEvents)
+	err := q.QueryRowx("SELECT count(*) from event, hosts where hosts.username = $1 and hosts.eventID = event.ID and name ILIKE $2 and archivedAt IS NULL",
+		username, pattern).Scan(&numEvents)
 
 	if err != nil {
 		return 0, errors.New("Cannot fetch event count for user: " + err.Error())
@@ -366,6 +657,7 @@ func (es *EventService) marshalEvent(event checkin.Event) rawEvent {
 	event.End.Time = event.End.Time.In(time.UTC)
 	event.CreatedAt = event.CreatedAt.In(time.UTC)
 	event.UpdatedAt = event.UpdatedAt.In(time.UTC)
+	event.ArchivedAt.Time = event.ArchivedAt.Time.In(time.UTC)
 	timetags, _ := json.Marshal(event.TimeTags)
 	return rawEvent{Event: event, TimetagJSON: timetags}
 }
@@ -383,6 +675,7 @@ func (es *EventService) unmarshalEvent(re rawEvent) (checkin.Event, error) {
 	event.End.Time = event.End.Time.In(time.UTC)
 	event.CreatedAt = event.CreatedAt.In(time.UTC)
 	event.UpdatedAt = event.UpdatedAt.In(time.UTC)
+	event.ArchivedAt.Time = event.ArchivedAt.Time.In(time.UTC)
 
 	return event, err
 }
@@ -82,12 +82,70 @@ func TestEventByURL(t *testing.T) {
 	test.Assert(t, err != nil, "No error thrown trying to fetch by url event that does not exist")
 }
 
+func TestEvents(t *testing.T) {
+	es := postgres.EventService{DB: db}
+
+	//test filtering by host, which works the same as EventsBy
+	events, total, err := es.Events(checkin.EventFilter{Host: "TestUser"}, checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, 2, total)
+	test.Equals(t, "Data Science Department Talk", events[0].Name)
+	test.Equals(t, "SDB Cohesion", events[1].Name)
+
+	//test filtering by time, which leaves out events without a start or end time
+	events, total, err = es.Events(checkin.EventFilter{
+		From: null.TimeFrom(time.Date(2019, 1, 10, 17, 0, 0, 0, time.UTC)),
+		To:   null.TimeFrom(time.Date(2019, 1, 11, 0, 0, 0, 0, time.UTC)),
+		Host: "TestUser",
+	}, checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, 1, total)
+	test.Equals(t, "aa19239f-f9f5-4935-b1f7-0edfdceabba7", events[0].ID)
+
+	events, total, err = es.Events(checkin.EventFilter{From: null.TimeFrom(time.Date(2019, 1, 10, 18, 30, 0, 0, time.UTC)),
+		Host: "TestUser"}, checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, 0, total)
+	test.Equals(t, []checkin.Event{}, events)
+
+	events, total, err = es.Events(checkin.EventFilter{To: null.TimeFrom(time.Date(2019, 1, 10, 14, 0, 0, 0, time.UTC)),
+		Host: "TestUser"}, checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, 0, total)
+
+	//test filtering by release, where events without a release time are released
+	_, total, err = es.Events(checkin.EventFilter{Host: "TestUser", Released: null.BoolFrom(true)}, checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, 2, total)
+	_, total, err = es.Events(checkin.EventFilter{Host: "TestUser", Released: null.BoolFrom(false)}, checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, 0, total)
+
+	//test no filter lists every event, sorted and paginated
+	events, total, err = es.Events(checkin.EventFilter{}, checkin.ListOptions{Search: "data science"})
+	test.Ok(t, err)
+	test.Equals(t, 2, total)
+	test.Equals(t, "Data Science CoP", events[0].Name)
+	test.Equals(t, "Data Science Department Talk", events[1].Name)
+
+	events, total, err = es.Events(checkin.EventFilter{}, checkin.ListOptions{Search: "data science", Descending: true, Limit: 1})
+	test.Ok(t, err)
+	test.Equals(t, 2, total)
+	test.Equals(t, 1, len(events))
+	test.Equals(t, "Data Science Department Talk", events[0].Name)
+
+	//test unsupported sort key
+	_, _, err = es.Events(checkin.EventFilter{}, checkin.ListOptions{SortBy: checkin.SortByCheckInTime})
+	test.Assert(t, err != nil, "Expected error sorting events by check in time")
+}
+
 func TestEventsBy(t *testing.T) {
 	es := postgres.EventService{DB: db}
 
 	//test normal functionality
-	events, err := es.EventsBy("TestUser")
+	events, total, err := es.EventsBy("TestUser", checkin.ListOptions{})
 	test.Ok(t, err)
+	test.Equals(t, 2, total)
 	sort.Slice(events, func(i, j int) bool {
 		return events[i].Name < events[j].Name
 	})
@@ -114,13 +172,54 @@ func TestEventsBy(t *testing.T) {
 		},
 	}, events)
 
+	//test sorting and pagination
+	events, total, err = es.EventsBy("TestUser", checkin.ListOptions{SortBy: checkin.SortByCreatedAt, Descending: true, Limit: 1})
+	test.Ok(t, err)
+	test.Equals(t, 2, total)
+	test.Equals(t, 1, len(events))
+	test.Equals(t, "SDB Cohesion", events[0].Name)
+
+	events, total, err = es.EventsBy("TestUser", checkin.ListOptions{SortBy: checkin.SortByCreatedAt, Descending: true, Offset: 1, Limit: 1})
+	test.Ok(t, err)
+	test.Equals(t, 2, total)
+	test.Equals(t, 1, len(events))
+	test.Equals(t, "Data Science Department Talk", events[0].Name)
+
+	//events without a start time are sorted last
+	events, _, err = es.EventsBy("TestUser", checkin.ListOptions{SortBy: checkin.SortByStart, Descending: true})
+	test.Ok(t, err)
+	test.Equals(t, "Data Science Department Talk", events[0].Name)
+	test.Equals(t, "SDB Cohesion", events[1].Name)
+
+	events, total, err = es.EventsBy("TestUser", checkin.ListOptions{Offset: 5})
+	test.Ok(t, err)
+	test.Equals(t, 2, total)
+	test.Equals(t, []checkin.Event{}, events)
+
+	//test case insensitive name prefix search
+	events, total, err = es.EventsBy("TestUser", checkin.ListOptions{Search: "sdb"})
+	test.Ok(t, err)
+	test.Equals(t, 1, total)
+	test.Equals(t, "SDB Cohesion", events[0].Name)
+
+	//wildcards in the search are taken literally
+	events, total, err = es.EventsBy("TestUser", checkin.ListOptions{Search: "%"})
+	test.Ok(t, err)
+	test.Equals(t, 0, total)
+	test.Equals(t, []checkin.Event{}, events)
+
+	//test unsupported sort key
+	_, _, err = es.EventsBy("TestUser", checkin.ListOptions{SortBy: checkin.SortByCheckInTime})
+	test.Assert(t, err != nil, "Expected error sorting events by check in time")
+
 	//test user hosts no events
-	events, err = es.EventsBy("ME6Alice")
+	events, total, err = es.EventsBy("ME6Alice", checkin.ListOptions{})
 	test.Ok(t, err)
+	test.Equals(t, 0, total)
 	test.Equals(t, []checkin.Event{}, events)
 
 	//test user does not exist
-	events, err = es.EventsBy("wdqdwqd")
+	events, _, err = es.EventsBy("wdqdwqd", checkin.ListOptions{})
 	test.Ok(t, err)
 	test.Equals(t, []checkin.Event{}, events)
 }
@@ -210,24 +309,27 @@ func TestUpdateEvent(t *testing.T) {
 	originalCreatedAt := event.CreatedAt
 
 	event.Radius.Float64 = 5
+	event.Timezone = "Asia/Singapore"
 	event.CreatedAt = time.Now()                                                  //this should not actually be processed as an updatable field
 	event.TimeTags["ReLeaSe"] = time.Date(2019, 10, 3, 2, 5, 10, 0, time.UTC)     //testing adding a time tag, make sure that its not case sensitive (should be set to all lowercase)
 	event.TimeTags["formrelease"] = time.Date(2019, 10, 3, 12, 15, 30, 0, asmara) //test non-UTC time
-	err = es.UpdateEvent(event)
+	_, err = es.UpdateEvent(event, null.Time{})
 	test.Ok(t, err)
 
 	event, err = es.Event("aa19239f-f9f5-4935-b1f7-0edfdceabba7")
 	test.Ok(t, err)
 	test.Assert(t, math.Abs(5-event.Radius.Float64) < 0.0001, "Radius was not successfully updated")
+	test.Equals(t, "Asia/Singapore", event.Timezone)
 	test.Assert(t, math.Abs(event.UpdatedAt.Sub(time.Now().UTC()).Seconds()) < 2, "Event last updated not within 2 seconds of now; i.e. not updated")
 	test.Assert(t, event.CreatedAt == originalCreatedAt, "Event created at time was modified; this should not be allowed")
 	test.Assert(t, event.TimeTags["release"] == time.Date(2019, 10, 3, 2, 5, 10, 0, time.UTC) && len(event.TimeTags) == 2, "Time tags were not properly updated")
 	test.Assert(t, event.TimeTags["formrelease"] == time.Date(2019, 10, 3, 9, 15, 30, 0, time.UTC) && len(event.TimeTags) == 2, "Time tags were not properly updated (non-UTC timezone issue)")
 
 	event.Radius = originalRadius
+	event.Timezone = ""
 	event.TimeTags = nil
 
-	err = es.UpdateEvent(event)
+	_, err = es.UpdateEvent(event, null.Time{})
 	test.Ok(t, err)
 
 	event, err = es.Event("aa19239f-f9f5-4935-b1f7-0edfdceabba7")
@@ -235,14 +337,30 @@ func TestUpdateEvent(t *testing.T) {
 	test.Equals(t, make(map[string]time.Time, 0), event.TimeTags) //should not ever have TimeTags set to nil
 	//nil should be equivalent to an empty map
 	event.TimeTags = make(map[string]time.Time)
-	err = es.UpdateEvent(event)
+	_, err = es.UpdateEvent(event, null.Time{})
 	test.Ok(t, err)
 	test.Equals(t, make(map[string]time.Time), event.TimeTags)
 
+	//test updating only if the event has not been updated since it was read
+	event, err = es.Event("aa19239f-f9f5-4935-b1f7-0edfdceabba7")
+	test.Ok(t, err)
+	updated, err := es.UpdateEvent(event, null.TimeFrom(event.UpdatedAt))
+	test.Ok(t, err)
+	test.Equals(t, true, updated)
+	event.Radius.Float64 = 5
+	updated, err = es.UpdateEvent(event, null.TimeFrom(event.UpdatedAt)) //updated since it was read, by the update above
+	test.Ok(t, err)
+	test.Equals(t, false, updated)
+	event, err = es.Event("aa19239f-f9f5-4935-b1f7-0edfdceabba7")
+	test.Ok(t, err)
+	test.Equals(t, originalRadius, event.Radius)
+
 	//test no such event with that event ID
 	event = checkin.Event{ID: "a6db3963-5389-4dbe-8fc6-bbd7f7ce66b8"}
-	err = es.UpdateEvent(event)
+	_, err = es.UpdateEvent(event, null.Time{})
 	test.Assert(t, err != nil, "No event with given UUID fails to throw error")
+	_, err = es.UpdateEvent(event, null.TimeFrom(time.Now()))
+	test.Assert(t, err != nil, "No event with given UUID fails to throw error when updating conditionally")
 
 }
 
@@ -392,3 +510,266 @@ func TestCheckIfExists(t *testing.T) {
 	test.Equals(t, false, exists)
 
 }
+
+func TestArchiveEvent(t *testing.T) {
+	es := postgres.EventService{DB: db}
+	event := checkin.Event{ID: uuid.New().String(), Name: "Archived Parade", URL: null.StringFrom("archivedparade")}
+	test.Ok(t, es.CreateEvent(event, "TestUser"))
+
+	//test archived events no longer exist, and are left out of listings, but keep their URL
+	test.Ok(t, es.ArchiveEvent(event.ID))
+	exists, err := es.CheckIfExists(event.ID)
+	test.Ok(t, err)
+	test.Equals(t, false, exists)
+	events, _, err := es.EventsBy("TestUser", checkin.ListOptions{Search: "Archived"})
+	test.Ok(t, err)
+	test.Equals(t, 0, len(events))
+	events, _, err = es.Events(checkin.EventFilter{}, checkin.ListOptions{Search: "Archived"})
+	test.Ok(t, err)
+	test.Equals(t, 0, len(events))
+	taken, err := es.URLExists("archivedparade")
+	test.Ok(t, err)
+	test.Equals(t, true, taken)
+
+	//test archived events can be listed, with when they were archived
+	events, total, err := es.Events(checkin.EventFilter{Host: "TestUser", Archived: true}, checkin.ListOptions{Search: "Archived"})
+	test.Ok(t, err)
+	test.Equals(t, 1, total)
+	test.Equals(t, event.ID, events[0].ID)
+	test.Assert(t, events[0].ArchivedAt.Valid, "Archived event has no archive time")
+	archivedAt := events[0].ArchivedAt
+
+	//test archiving again keeps the original archive time
+	test.Ok(t, es.ArchiveEvent(event.ID))
+	fetched, err := es.Event(event.ID)
+	test.Ok(t, err)
+	test.Equals(t, archivedAt, fetched.ArchivedAt)
+	test.Assert(t, es.ArchiveEvent("aa19239f-f9f5-4935-b1f7-0edfdceabba7") != nil, "No error archiving event which does not exist")
+
+	//test events archived before the retention window cannot be restored
+	restored, err := es.RestoreEvent(event.ID, archivedAt.Time.Add(time.Minute))
+	test.Ok(t, err)
+	test.Equals(t, false, restored)
+	restored, err = es.RestoreEvent("helloworld", time.Time{})
+	test.Ok(t, err)
+	test.Equals(t, false, restored)
+
+	//test restoring events
+	restored, err = es.RestoreEvent(event.ID, archivedAt.Time.Add(-time.Minute))
+	test.Ok(t, err)
+	test.Equals(t, true, restored)
+	exists, err = es.CheckIfExists(event.ID)
+	test.Ok(t, err)
+	test.Equals(t, true, exists)
+	restored, err = es.RestoreEvent(event.ID, time.Time{}) //no longer archived
+	test.Ok(t, err)
+	test.Equals(t, false, restored)
+
+	//test only events archived before the time given are purged
+	test.Ok(t, es.ArchiveEvent(event.ID))
+	purged, err := es.PurgeEvents(time.Now().Add(-time.Hour))
+	test.Ok(t, err)
+	test.Equals(t, 0, purged)
+	_, err = es.Event(event.ID)
+	test.Ok(t, err)
+	purged, err = es.PurgeEvents(time.Now().Add(time.Minute))
+	test.Ok(t, err)
+	test.Equals(t, 1, purged)
+	_, err = es.Event(event.ID)
+	test.Assert(t, err != nil, "Purged event still exists")
+	taken, err = es.URLExists("archivedparade")
+	test.Ok(t, err)
+	test.Equals(t, false, taken)
+}
+
+func TestHostRole(t *testing.T) {
+	es := postgres.EventService{DB: db}
+
+	//test roles of hosts
+	role, err := es.HostRole("ME5Bob", "2c59b54d-3422-4bdb-824c-4125775b44c8")
+	test.Ok(t, err)
+	test.Equals(t, checkin.RoleOwner, role)
+	role, err = es.HostRole("ME5Bob", "aa19239f-f9f5-4935-b1f7-0edfdceabba7")
+	test.Ok(t, err)
+	test.Equals(t, checkin.RoleCoHost, role)
+
+	//test user is not a host of the event
+	role, err = es.HostRole("TestUser", "2c59b54d-3422-4bdb-824c-4125775b44c8")
+	test.Ok(t, err)
+	test.Equals(t, "", role)
+
+	//test adding a host with a role
+	err = es.AddHost("2c59b54d-3422-4bdb-824c-4125775b44c8", "TestUser", checkin.RoleUsher)
+	test.Ok(t, err)
+	role, err = es.HostRole("TestUser", "2c59b54d-3422-4bdb-824c-4125775b44c8")
+	test.Ok(t, err)
+	test.Equals(t, checkin.RoleUsher, role)
+	_, err = db.Exec("DELETE from hosts where username = 'TestUser' and eventID = '2c59b54d-3422-4bdb-824c-4125775b44c8'")
+	test.Ok(t, err)
+
+	//test adding a host with a role which does not exist
+	err = es.AddHost("2c59b54d-3422-4bdb-824c-4125775b44c8", "TestUser", "admin")
+	test.Assert(t, err != nil, "No error when adding a host with an invalid role")
+}
+
+func TestHosts(t *testing.T) {
+	es := postgres.EventService{DB: db}
+	eventID := "aa19239f-f9f5-4935-b1f7-0edfdceabba7"
+
+	//test normal functionality, sorted by username
+	hosts, err := es.Hosts(eventID)
+	test.Ok(t, err)
+	test.Equals(t, []checkin.Host{
+		{Username: "ME5Bob", Role: checkin.RoleCoHost},
+		{Username: "TestUser", Role: checkin.RoleOwner},
+	}, hosts)
+
+	//test event does not exist
+	hosts, err = es.Hosts("812d513d-8bb1-4216-93f5-17bd3056fff4")
+	test.Ok(t, err)
+	test.Equals(t, []checkin.Host{}, hosts)
+}
+
+func TestRemoveHostAndTransferOwnership(t *testing.T) {
+	es := postgres.EventService{DB: db}
+	eventID := "2c59b54d-3422-4bdb-824c-4125775b44c8"
+
+	//test transferring ownership to a user who is not a host adds them, and keeps the previous owner as a co-host
+	err := es.TransferOwnership(eventID, "TestUser")
+	test.Ok(t, err)
+	hosts, err := es.Hosts(eventID)
+	test.Ok(t, err)
+	test.Equals(t, []checkin.Host{
+		{Username: "ME5Bob", Role: checkin.RoleCoHost},
+		{Username: "TestUser", Role: checkin.RoleOwner},
+	}, hosts)
+
+	//test owner cannot be removed
+	err = es.RemoveHost(eventID, "TestUser")
+	test.Assert(t, err != nil, "No error when removing the owner of an event")
+
+	//test transferring ownership back to a host
+	err = es.TransferOwnership(eventID, "ME5Bob")
+	test.Ok(t, err)
+	role, err := es.HostRole("ME5Bob", eventID)
+	test.Ok(t, err)
+	test.Equals(t, checkin.RoleOwner, role)
+
+	//test removing a host who is not the owner
+	err = es.RemoveHost(eventID, "TestUser")
+	test.Ok(t, err)
+	hosts, err = es.Hosts(eventID)
+	test.Ok(t, err)
+	test.Equals(t, []checkin.Host{{Username: "ME5Bob", Role: checkin.RoleOwner}}, hosts)
+
+	//test user is not a host
+	err = es.RemoveHost(eventID, "TestUser")
+	test.Assert(t, err != nil, "No error when removing a user who is not a host")
+
+	//test transferring ownership to a user who does not exist
+	err = es.TransferOwnership(eventID, "Notauser")
+	test.Assert(t, err != nil, "No error when transferring ownership to a user who does not exist")
+	role, err = es.HostRole("ME5Bob", eventID)
+	test.Ok(t, err)
+	test.Equals(t, checkin.RoleOwner, role)
+
+	//test events cannot have a second owner, or their ownership transferred if they do not exist
+	err = es.AddHost(eventID, "TestUser", checkin.RoleOwner)
+	test.Assert(t, err != nil, "No error when adding a second owner to an event")
+	err = es.TransferOwnership("812d513d-8bb1-4216-93f5-17bd3056fff4", "TestUser")
+	test.Assert(t, err != nil, "No error when transferring ownership of an event which does not exist")
+}
+
+func TestCloneEvent(t *testing.T) {
+	es := postgres.EventService{DB: db}
+	eventID := "aa19239f-f9f5-4935-b1f7-0edfdceabba7"
+	original, err := es.Event(eventID)
+	test.Ok(t, err)
+
+	//test cloning with the guest list, where the user cloning becomes owner and nobody is checked in
+	clone := original.Copy(checkin.CloneOptions{Name: "Data Science Department Talk II",
+		Start: null.TimeFrom(time.Date(2019, 2, 10, 15, 0, 0, 0, time.UTC))})
+	clone.ID = uuid.New().String()
+	err = es.CloneEvent(eventID, clone, "ME5Bob", true)
+	test.Ok(t, err)
+	fetched, err := es.Event(clone.ID)
+	test.Ok(t, err)
+	test.Equals(t, "Data Science Department Talk II", fetched.Name)
+	test.Equals(t, null.String{}, fetched.URL)
+	test.Equals(t, null.TimeFrom(time.Date(2019, 2, 10, 18, 0, 0, 0, time.UTC)), fetched.End)
+	test.Equals(t, original.Radius, fetched.Radius)
+	hosts, err := es.Hosts(clone.ID)
+	test.Ok(t, err)
+	test.Equals(t, []checkin.Host{
+		{Username: "ME5Bob", Role: checkin.RoleOwner},
+		{Username: "TestUser", Role: checkin.RoleCoHost},
+	}, hosts)
+	var guests, originalGuests, checkedIn int
+	err = db.QueryRow("SELECT count(*), count(*) filter (where checkedIn) from guest where eventID = $1", clone.ID).Scan(&guests, &checkedIn)
+	test.Ok(t, err)
+	err = db.QueryRow("SELECT count(*) from guest where eventID = $1", eventID).Scan(&originalGuests)
+	test.Ok(t, err)
+	test.Equals(t, originalGuests, guests)
+	test.Equals(t, 0, checkedIn)
+	test.Ok(t, es.DeleteEvent(clone.ID))
+
+	//test cloning without the guest list
+	clone.ID = uuid.New().String()
+	err = es.CloneEvent(eventID, clone, "TestUser", false)
+	test.Ok(t, err)
+	hosts, err = es.Hosts(clone.ID)
+	test.Ok(t, err)
+	test.Equals(t, []checkin.Host{
+		{Username: "ME5Bob", Role: checkin.RoleCoHost},
+		{Username: "TestUser", Role: checkin.RoleOwner},
+	}, hosts)
+	err = db.QueryRow("SELECT count(*) from guest where eventID = $1", clone.ID).Scan(&guests)
+	test.Ok(t, err)
+	test.Equals(t, 0, guests)
+	test.Ok(t, es.DeleteEvent(clone.ID))
+
+	//test cloning with a URL already in use leaves nothing behind
+	clone.ID, clone.URL = uuid.New().String(), original.URL
+	err = es.CloneEvent(eventID, clone, "TestUser", true)
+	test.Assert(t, err != nil, "No error when cloning an event with a URL already in use")
+	exists, err := es.CheckIfExists(clone.ID)
+	test.Ok(t, err)
+	test.Equals(t, false, exists)
+}
+
+func TestSetAndRemoveTimeTag(t *testing.T) {
+	es := postgres.EventService{DB: db}
+	eventID := "c14a592c-950d-44ba-b173-bbb9e4f5c8b4"
+	original, err := es.Event(eventID)
+	test.Ok(t, err)
+
+	//test adding a trigger leaves the others alone, and stores the time in UTC
+	loc, _ := time.LoadLocation("Asia/Singapore")
+	err = es.SetTimeTag(eventID, "DoorsOpen", time.Date(2019, 6, 8, 19, 0, 0, 0, loc))
+	test.Ok(t, err)
+	event, err := es.Event(eventID)
+	test.Ok(t, err)
+	test.Equals(t, map[string]time.Time{
+		"testlabel": time.Date(2019, 6, 8, 20, 30, 0, 0, time.UTC),
+		"doorsopen": time.Date(2019, 6, 8, 11, 0, 0, 0, time.UTC),
+	}, event.TimeTags)
+	test.Assert(t, event.UpdatedAt.After(original.UpdatedAt), "Updated time not changed when trigger added")
+
+	//test changing a trigger
+	err = es.SetTimeTag(eventID, "doorsopen", time.Date(2019, 6, 8, 12, 0, 0, 0, time.UTC))
+	test.Ok(t, err)
+	event, err = es.Event(eventID)
+	test.Ok(t, err)
+	test.Equals(t, time.Date(2019, 6, 8, 12, 0, 0, 0, time.UTC), event.TimeTags["doorsopen"])
+
+	//test removing a trigger
+	err = es.RemoveTimeTag(eventID, "doorsopen")
+	test.Ok(t, err)
+	event, err = es.Event(eventID)
+	test.Ok(t, err)
+	test.Equals(t, original.TimeTags, event.TimeTags)
+
+	//test event does not exist
+	err = es.SetTimeTag("a2a1b6a4-1b8d-4e4b-9e8b-0c9e6d0b8e11", "release", time.Now())
+	test.Assert(t, err != nil, "No error when setting trigger of event which does not exist")
+}
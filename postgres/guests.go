@@ -11,7 +11,9 @@ import (
 	"strings"
 
 	"sync"
+	"time"
 
+	"github.com/guregu/null"
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 )
@@ -19,21 +21,29 @@ import (
 //GuestService an implementation of checkin.GuestService using postgres
 //Needs a HashMethod as all NRICs are stored internally as hashes for
 //security purposes
+//If a DigestMethod is supplied, a keyed digest of each NRIC is also stored, so
+//guests can be found with one indexed query instead of comparing against every hash
+//Guests registered before the DigestMethod was supplied have their digest filled in
+//the first time they are found (e.g. when they check in)
 type GuestService struct {
 	DB        *sqlx.DB
 	HM        checkin.HashMethod
+	DM        checkin.DigestMethod
 	HashCache map[string]string
 	cacheLock sync.RWMutex
 }
 
 //CheckIn marks a guest (indicated by the last 5 digits of the nric)
-//of a particular event as having attended the event
+//of a particular event as having attended the event, and records the change
+//(and its source) in the event's attendance log
+//If a sessionID is given, the guest is instead marked as having attended that session of the event,
+//which does not change whether they are checked in to the event as a whole
 //Returns the name of the guest who was checked in
-//Will return an error if said guest does not exist, or event with
+//Will return an error if said guest does not exist, or event (or session of it) with
 //that ID does not exist
 //Will not throw an error if the guest is already checked in
 //If any error occurs, check in status of the guest will not be edited
-func (gs *GuestService) CheckIn(eventID string, nric string) (string, error) {
+func (gs *GuestService) CheckIn(eventID string, sessionID string, nric string, source checkin.AttendanceSource) (string, error) {
 	guest, err := gs.getGuestWithNRIC(eventID, nric)
 	if err != nil {
 		return "", errors.New("Error getting guest with that NRIC: " + err.Error())
@@ -48,8 +58,12 @@ func (gs *GuestService) CheckIn(eventID string, nric string) (string, error) {
 		return "", errors.New("Error starting transaction: " + err.Error())
 	}
 
-	_, err = tx.Exec("UPDATE guest SET checkedIn = TRUE, checkInTime = (NOW() at time zone 'utc') WHERE eventID = $1 and nricHash = $2",
-		eventID, nricHash)
+	if sessionID != "" {
+		err = gs.setSessionAttendance(tx, eventID, sessionID, nricHash, true)
+	} else {
+		_, err = tx.Exec("UPDATE guest SET checkedIn = TRUE, checkInTime = (NOW() at time zone 'utc') WHERE eventID = $1 and nricHash = $2",
+			eventID, nricHash)
+	}
 	if err != nil {
 		tx.Rollback()
 		return "", errors.New("Error updating check in status: " + err.Error())
@@ -63,6 +77,12 @@ func (gs *GuestService) CheckIn(eventID string, nric string) (string, error) {
 		return "", errors.New("Error updating fetching name: " + err.Error())
 	}
 
+	err = gs.logAttendance(tx, eventID, sessionID, nricHash, name, checkin.ActionCheckIn, source)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
 	err = tx.Commit()
 	if err != nil {
 		tx.Rollback()
@@ -73,10 +93,61 @@ func (gs *GuestService) CheckIn(eventID string, nric string) (string, error) {
 }
 
 //MarkAbsent marks a guest of a particular event as being absent, the opposite of check in
+//and records the change (and its source) in the event's attendance log
+//If a sessionID is given, the guest is only marked absent from that session of the event
 //Will return an error if said guest does not exist, or even with that
 //ID does not exist
+//Returns the name of the guest who was marked absent
 //Will not throw an error if the guest is already not checked in
-func (gs *GuestService) MarkAbsent(eventID string, nric string) error {
+//If any error occurs, check in status of the guest will not be edited
+func (gs *GuestService) MarkAbsent(eventID string, sessionID string, nric string, source checkin.AttendanceSource) (string, error) {
+	guest, err := gs.getGuestWithNRIC(eventID, nric)
+	if err != nil {
+		return "", errors.New("Error getting guest with that NRIC: " + err.Error())
+	}
+	if guest.IsEmpty() {
+		return "", errors.New("Guest with that NRIC does not exist: " + nric)
+	}
+	nricHash := guest.NRIC
+
+	tx, err := gs.DB.Begin()
+	if err != nil {
+		return "", errors.New("Error starting transaction: " + err.Error())
+	}
+
+	var name string
+	if sessionID != "" {
+		err = gs.setSessionAttendance(tx, eventID, sessionID, nricHash, false)
+		if err == nil {
+			err = tx.QueryRow("SELECT name FROM guest WHERE eventID = $1 and nricHash = $2", eventID, nricHash).Scan(&name)
+		}
+	} else {
+		err = tx.QueryRow("UPDATE guest SET checkedIn = False, checkInTime = (NOW() at time zone 'utc') WHERE eventID = $1 and nricHash = $2 RETURNING name",
+			eventID, nricHash).Scan(&name)
+	}
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("Error updating check in status: " + err.Error())
+	}
+
+	err = gs.logAttendance(tx, eventID, sessionID, nricHash, name, checkin.ActionMarkAbsent, source)
+	if err != nil {
+		tx.Rollback()
+		return "", err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		tx.Rollback()
+		return "", errors.New("Error committing changes to the database: " + err.Error())
+	}
+	return name, nil
+}
+
+//FlagCheckIn records a guest's attempt to check themselves in from outside the geofence of an event,
+//for the hosts of the event to review. The flag's time is set to now
+//Will return an error if said guest does not exist
+func (gs *GuestService) FlagCheckIn(eventID string, nric string, flag checkin.CheckInFlag) error {
 	guest, err := gs.getGuestWithNRIC(eventID, nric)
 	if err != nil {
 		return errors.New("Error getting guest with that NRIC: " + err.Error())
@@ -84,29 +155,70 @@ func (gs *GuestService) MarkAbsent(eventID string, nric string) error {
 	if guest.IsEmpty() {
 		return errors.New("Guest with that NRIC does not exist: " + nric)
 	}
-	nricHash := guest.NRIC
 
-	_, err = gs.DB.Exec("UPDATE guest SET checkedIn = False, checkInTime = (NOW() at time zone 'utc') WHERE eventID = $1 and nricHash = $2",
-		eventID, nricHash)
-	return err
+	_, err = gs.DB.Exec(`INSERT into checkinflag (eventID, nricHash, guestName, lat, long, distance, rejected, time, actor, ipAddress, userAgent)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, (NOW() at time zone 'utc'), $8, $9, $10)`,
+		eventID, guest.NRIC, guest.Name, flag.Lat, flag.Long, flag.Distance, flag.Rejected, flag.Actor, flag.IPAddress, flag.UserAgent)
+	if err != nil {
+		return errors.New("Error flagging check in: " + err.Error())
+	}
+	return nil
+}
+
+//setSessionAttendance marks a guest as having attended (present) or not attended a session of an event,
+//as part of the transaction which changes their attendance. The check in time is the start of the transaction
+//Returns an error if the event has no such session
+func (gs *GuestService) setSessionAttendance(tx *sql.Tx, eventID string, sessionID string, nricHash string, present bool) error {
+	var exists bool
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return errors.New("Session of that event does not exist: " + sessionID)
+	}
+	err := tx.QueryRow("SELECT EXISTS(SELECT 1 from session where ID = $1 and eventID = $2)", sessionID, eventID).Scan(&exists)
+	if err != nil {
+		return errors.New("Error checking if session exists: " + err.Error())
+	} else if !exists {
+		return errors.New("Session of that event does not exist: " + sessionID)
+	}
+
+	if present {
+		_, err = tx.Exec(`INSERT into sessionattendance (sessionID, eventID, nricHash, checkInTime) VALUES ($1, $2, $3, (NOW() at time zone 'utc'))
+		ON CONFLICT (sessionID, nricHash) DO UPDATE SET checkInTime = EXCLUDED.checkInTime`, sessionID, eventID, nricHash)
+	} else {
+		_, err = tx.Exec("DELETE from sessionattendance where sessionID = $1 and nricHash = $2", sessionID, nricHash)
+	}
+	if err != nil {
+		return errors.New("Error updating attendance at session: " + err.Error())
+	}
+	return nil
+}
+
+//logAttendance appends an entry to the attendance log of an event, as part of the transaction
+//which changed the guest's attendance. The entry's time is the start of the transaction
+//i.e. the same as the check in time written by the transaction
+//sessionID is "" for changes to the attendance of the event as a whole
+func (gs *GuestService) logAttendance(tx *sql.Tx, eventID string, sessionID string, nricHash string, name string, action string,
+	source checkin.AttendanceSource) error {
+	_, err := tx.Exec("INSERT into attendancelog (eventID, sessionID, nricHash, guestName, action, time, actor, ipAddress, userAgent) VALUES ($1, $2, $3, $4, $5, (NOW() at time zone 'utc'), $6, $7, $8)",
+		eventID, null.NewString(sessionID, sessionID != ""), nricHash, name, action, source.Actor, source.IPAddress, source.UserAgent)
+	if err != nil {
+		return errors.New("Error adding entry to attendance log: " + err.Error())
+	}
+	return nil
 }
 
 //Guests returns an array of names of the guests who are registered/signed up for
 //an event given by the eventID
-//Can filter the list down to guests which have *all* the tags specified in tags
-//A nil tags, or empty string array, will fetch all guests
+//Can filter the list down to guests matching the tag filter
+//An empty filter will fetch all guests
 //No error thrown if event does not exist - just gives empty array, so check existence before calling method
-func (gs *GuestService) Guests(eventID string, tags []string) ([]string, error) {
-	if tags == nil {
-		tags = []string{}
-	}
-	tags = gs.capitalizeTags(tags)
-	rows, err := gs.DB.Query("SELECT name from guest where eventID = $1 and $2 <@ tags", eventID, pq.Array(tags))
+func (gs *GuestService) Guests(eventID string, filter checkin.TagFilter) ([]string, error) {
+	condition, args := tagCondition(filter, "tags", []interface{}{eventID})
+	rows, err := gs.DB.Query("SELECT name from guest where eventID = $1 and "+condition, args...)
 	if err != nil {
 		return nil, errors.New("Cannot fetch guest names: " + err.Error())
 	}
 	defer rows.Close() //make sure this is after checking for an error, or this will be a nil pointer dereference
-	numGuests, err := gs.getNumberOfGuests(eventID, tags)
+	numGuests, err := gs.getNumberOfGuests(eventID, filter)
 	if err != nil {
 		return nil, errors.New("Cannot fetch number of guests: " + err.Error())
 	}
@@ -116,20 +228,17 @@ func (gs *GuestService) Guests(eventID string, tags []string) ([]string, error)
 
 //GuestsCheckedIn return an array of names of the guests who have checked in
 //to the event given by the eventID
-//Can filter the list down to guests which have *all* the tags specified in tags
-//A nil tags, or empty string array, will fetch all guests
+//Can filter the list down to guests matching the tag filter
+//An empty filter will fetch all guests
 //No error thrown if event does not exist - just gives empty array, so check existence before calling method
-func (gs *GuestService) GuestsCheckedIn(eventID string, tags []string) ([]string, error) {
-	if tags == nil {
-		tags = []string{}
-	}
-	tags = gs.capitalizeTags(tags)
-	rows, err := gs.DB.Query("SELECT name from guest where eventID = $1 and checkedIn = TRUE and $2 <@ tags", eventID, pq.Array(tags))
+func (gs *GuestService) GuestsCheckedIn(eventID string, filter checkin.TagFilter) ([]string, error) {
+	condition, args := tagCondition(filter, "tags", []interface{}{eventID})
+	rows, err := gs.DB.Query("SELECT name from guest where eventID = $1 and checkedIn = TRUE and "+condition, args...)
 	if err != nil {
 		return nil, errors.New("Cannot fetch checked in guest names: " + err.Error())
 	}
 	defer rows.Close() //make sure this is after checking for an error, or this will be a nil pointer dereference
-	numGuests, err := gs.getNumberOfGuestsCheckInStatus(eventID, true, tags)
+	numGuests, err := gs.getNumberOfGuestsCheckInStatus(eventID, true, filter)
 	if err != nil {
 		return nil, errors.New("Cannot fetch number of guests checked in: " + err.Error())
 	}
@@ -139,20 +248,17 @@ func (gs *GuestService) GuestsCheckedIn(eventID string, tags []string) ([]string
 
 //GuestsNotCheckedIn returns an array of guests who haven't checked into the
 //event
-//Can filter the list down to guests which have *all* the tags specified in tags
-//A nil tags, or empty string array, will fetch all guests
+//Can filter the list down to guests matching the tag filter
+//An empty filter will fetch all guests
 //No error thrown if event does not exist - just gives empty array, so check existence before calling method
-func (gs *GuestService) GuestsNotCheckedIn(eventID string, tags []string) ([]string, error) {
-	if tags == nil {
-		tags = []string{}
-	}
-	tags = gs.capitalizeTags(tags)
-	rows, err := gs.DB.Query("SELECT name from guest where eventID = $1 and checkedIn = FALSE and $2 <@ tags", eventID, pq.Array(tags))
+func (gs *GuestService) GuestsNotCheckedIn(eventID string, filter checkin.TagFilter) ([]string, error) {
+	condition, args := tagCondition(filter, "tags", []interface{}{eventID})
+	rows, err := gs.DB.Query("SELECT name from guest where eventID = $1 and checkedIn = FALSE and "+condition, args...)
 	if err != nil {
 		return nil, errors.New("Cannot fetch not checked in guest names: " + err.Error())
 	}
 	defer rows.Close() //make sure this is after checking for an error, or this will be a nil pointer dereference
-	numGuests, err := gs.getNumberOfGuestsCheckInStatus(eventID, false, tags)
+	numGuests, err := gs.getNumberOfGuestsCheckInStatus(eventID, false, filter)
 	if err != nil {
 		return nil, errors.New("Cannot fetch number of guests not checked in: " + err.Error())
 	}
@@ -160,6 +266,76 @@ func (gs *GuestService) GuestsNotCheckedIn(eventID string, tags []string) ([]str
 	return gs.scanRowsIntoStrings(rows, numGuests)
 }
 
+//guestSortColumns maps the sort keys guest records can be listed by to their columns
+var guestSortColumns = map[string]string{
+	checkin.SortByName:        "name",
+	checkin.SortByCheckInTime: "checkInTime",
+}
+
+//GuestRecords returns a page of the attendance records of the guests who are registered for
+//an event given by the eventID, along with the total number of records across all pages
+//Can filter the list down to guests matching the tag filter
+//An empty filter will fetch all guests
+//opts may sort by name (the default) or check in time, and search by name prefix
+//No error thrown if event does not exist - just gives empty array, so check existence before calling method
+func (gs *GuestService) GuestRecords(eventID string, filter checkin.TagFilter, opts checkin.ListOptions) ([]checkin.GuestRecord, int, error) {
+	return gs.guestRecords(eventID, "", filter, opts)
+}
+
+//GuestRecordsCheckedIn returns a page of the attendance records of the guests who have checked in
+//to the event given by the eventID, along with the total number of records across all pages
+//Can filter the list down to guests matching the tag filter
+//An empty filter will fetch all guests
+//opts may sort by name (the default) or check in time, and search by name prefix
+//No error thrown if event does not exist - just gives empty array, so check existence before calling method
+func (gs *GuestService) GuestRecordsCheckedIn(eventID string, filter checkin.TagFilter, opts checkin.ListOptions) ([]checkin.GuestRecord, int, error) {
+	return gs.guestRecords(eventID, " and checkedIn = TRUE", filter, opts)
+}
+
+//GuestRecordsNotCheckedIn returns a page of the attendance records of the guests who have not checked in
+//to the event given by the eventID, along with the total number of records across all pages
+//Can filter the list down to guests matching the tag filter
+//An empty filter will fetch all guests
+//opts may sort by name (the default) or check in time, and search by name prefix
+//No error thrown if event does not exist - just gives empty array, so check existence before calling method
+func (gs *GuestService) GuestRecordsNotCheckedIn(eventID string, filter checkin.TagFilter, opts checkin.ListOptions) ([]checkin.GuestRecord, int, error) {
+	return gs.guestRecords(eventID, " and checkedIn = FALSE", filter, opts)
+}
+
+//guestRecords fetches a page of guest records, where statusCondition is an extra (constant) condition
+//on the guest's check in status, or "" for all guests
+func (gs *GuestService) guestRecords(eventID string, statusCondition string, filter checkin.TagFilter, opts checkin.ListOptions) ([]checkin.GuestRecord, int, error) {
+	clauses, err := orderAndPaginate(opts, guestSortColumns, checkin.SortByName, "nricHash")
+	if err != nil {
+		return nil, 0, err
+	}
+	tagged, args := tagCondition(filter, "tags", []interface{}{eventID, searchPattern(opts.Search)})
+	condition := "eventID = $1 and name ILIKE $2" + statusCondition + " and " + tagged
+
+	tx, err := listingTx(gs.DB)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	var total int
+	err = tx.QueryRow("SELECT count(*) from guest where "+condition, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, errors.New("Cannot fetch number of guests: " + err.Error())
+	}
+	rows, err := tx.Query("SELECT name, tags, checkedIn, checkInTime from guest where "+condition+clauses, args...)
+	if err != nil {
+		return nil, 0, errors.New("Cannot fetch guest records: " + err.Error())
+	}
+	defer rows.Close()
+
+	records, err := gs.scanRowsIntoGuestRecords(rows, opts.PageSize(total))
+	if err != nil {
+		return nil, 0, err
+	}
+	return records, total, nil
+}
+
 //GuestExists returns true if a Guest with the given NRIC identifier (last 5 digits of NRIC)
 //and attending the given event exists
 //Returns false if the event does not exist in the first place (NOT an error), so check for event existence
@@ -177,16 +353,17 @@ func (gs *GuestService) GuestExists(eventID string, nric string) (bool, error) {
 //to the database, i.e. "registers" them for the event
 func (gs *GuestService) RegisterGuest(eventID string, guest checkin.Guest) error {
 	nricHash, err := gs.HM.HashAndSalt(strings.ToUpper(guest.NRIC))
-	if guest.Tags == nil {
-		guest.Tags = []string{} //no nils allowed
-	}
-	guest.Tags = gs.capitalizeTags(guest.Tags)
+	guest.Tags = gs.guestTags(guest.Tags)
 	if err != nil {
 		return errors.New("Error hashing NRIC: " + err.Error())
 	}
+	nricDigest, err := gs.digest(guest.NRIC)
+	if err != nil {
+		return errors.New("Error computing NRIC digest: " + err.Error())
+	}
 
-	_, err = gs.DB.Exec("INSERT into guest(nricHash,eventID,name,tags,checkedIn) VALUES($1,$2,$3,$4,FALSE)",
-		nricHash, eventID, guest.Name, pq.Array(guest.Tags))
+	_, err = gs.DB.Exec("INSERT into guest(nricHash,nricDigest,eventID,name,tags,checkedIn) VALUES($1,$2,$3,$4,$5,FALSE)",
+		nricHash, nricDigest, eventID, guest.Name, pq.Array(guest.Tags))
 
 	if err == nil {
 		gs.SetCache(eventID, guest.NRIC, nricHash)
@@ -215,24 +392,27 @@ func (gs *GuestService) RegisterGuests(eventID string, guests []checkin.Guest) e
 		}
 	}()
 
-	stmt, err := tx.Prepare("INSERT into guest(nrichash, eventid, name, tags, checkedin) VALUES($1, $2, $3, $4, FALSE)")
+	stmt, err := tx.Prepare("INSERT into guest(nrichash, nricdigest, eventid, name, tags, checkedin) VALUES($1, $2, $3, $4, $5, FALSE)")
 	if err != nil {
 		return errors.New("Error preparing statement: " + err.Error())
 	}
 
 	for _, guest := range guests {
 		nricHash, err := gs.HM.HashAndSalt(strings.ToUpper(guest.NRIC))
-		if guest.Tags == nil {
-			guest.Tags = []string{} //no nils allowed
-		}
-		guest.Tags = gs.capitalizeTags(guest.Tags)
+		guest.Tags = gs.guestTags(guest.Tags)
 		if err != nil {
 			tx.Rollback()
 			stmt.Close()
 			return errors.New("Error hashing NRIC: " + err.Error())
 		}
+		nricDigest, err := gs.digest(guest.NRIC)
+		if err != nil {
+			tx.Rollback()
+			stmt.Close()
+			return errors.New("Error computing NRIC digest: " + err.Error())
+		}
 
-		_, err = stmt.Exec(nricHash, eventID, guest.Name, pq.Array(guest.Tags))
+		_, err = stmt.Exec(nricHash, nricDigest, eventID, guest.Name, pq.Array(guest.Tags))
 		if err != nil {
 			tx.Rollback()
 			stmt.Close()
@@ -283,7 +463,7 @@ func (gs *GuestService) Tags(eventID string, nric string) ([]string, error) {
 //SetTags sets the tags of a given guest; it overwrites all previous tags on that guest
 //nil tags treated as empty array tags
 //Error if guest does not exist or error updating/fetching the guest
-//tags automatically capitalized by the function
+//tags automatically capitalized by the function, and repeated tags only set once
 func (gs *GuestService) SetTags(eventID string, nric string, tags []string) error {
 	guest, err := gs.getGuestWithNRIC(eventID, nric)
 	if err != nil {
@@ -292,10 +472,7 @@ func (gs *GuestService) SetTags(eventID string, nric string, tags []string) erro
 	if guest.IsEmpty() {
 		return errors.New("Guest does not exist")
 	}
-	if tags == nil { //treat nils as empty arrays
-		tags = []string{}
-	}
-	tags = gs.capitalizeTags(tags)
+	tags = gs.guestTags(tags)
 
 	_, err = gs.DB.Exec("UPDATE guest SET tags = $1 where eventID = $2 and nricHash = $3", pq.Array(tags), eventID, guest.NRIC)
 	return err
@@ -329,6 +506,64 @@ func (gs *GuestService) AllTags(eventID string) ([]string, error) {
 	return uniqueTags, nil
 }
 
+//AddTag adds the tag to every guest of the event who passes the filter and does not have it yet,
+//and returns how many guests it was added to
+//The guests are changed in a single statement, so either all of them are or none are
+//No error thrown if event does not exist - just changes no guests
+func (gs *GuestService) AddTag(eventID string, tag string, filter checkin.GuestFilter) (int, error) {
+	return gs.changeTag(eventID, "tags = array_append(tags, $1)", "NOT ($1 = ANY(tags))", tag, filter)
+}
+
+//RemoveTag removes the tag from every guest of the event who passes the filter and has it,
+//and returns how many guests it was removed from
+//The guests are changed in a single statement, so either all of them are or none are
+//No error thrown if event does not exist - just changes no guests
+func (gs *GuestService) RemoveTag(eventID string, tag string, filter checkin.GuestFilter) (int, error) {
+	return gs.changeTag(eventID, "tags = array_remove(tags, $1)", "$1 = ANY(tags)", tag, filter)
+}
+
+//changeTag makes the (constant) change to the tags of the guests who pass the filter and the (constant) condition
+//on their tags, where $1 in either is the tag
+func (gs *GuestService) changeTag(eventID string, change string, condition string, tag string, filter checkin.GuestFilter) (int, error) {
+	if _, err := uuid.Parse(eventID); err != nil {
+		return 0, nil
+	}
+	tagged, args := tagCondition(filter.Tags, "tags", []interface{}{strings.ToUpper(tag), eventID, filter.CheckedIn})
+	res, err := gs.DB.Exec("UPDATE guest SET "+change+" where eventID = $2 and "+condition+
+		" and (CAST($3 AS BOOLEAN) IS NULL or checkedIn = $3) and "+tagged, args...)
+	if err != nil {
+		return 0, errors.New("Error changing tags of guests: " + err.Error())
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.New("Error checking if rows were affected: " + err.Error())
+	}
+	return int(rows), nil
+}
+
+//RenameTag renames the tag to newTag for every guest of the event who has it, and returns how many guests that was
+//Guests who already have newTag as well are left with just one of it, so this also merges tags
+//The guests are changed in a single statement, so either all of them are or none are
+func (gs *GuestService) RenameTag(eventID string, tag string, newTag string) (int, error) {
+	tag, newTag = strings.ToUpper(tag), strings.ToUpper(newTag)
+	if tag == newTag {
+		return 0, nil //as guests with the tag would otherwise lose it
+	}
+	if _, err := uuid.Parse(eventID); err != nil {
+		return 0, nil
+	}
+	res, err := gs.DB.Exec(`UPDATE guest SET tags = CASE WHEN $2 = ANY(tags) THEN array_remove(tags, $1)
+	ELSE array_replace(tags, $1, $2) END where eventID = $3 and $1 = ANY(tags)`, tag, newTag, eventID)
+	if err != nil {
+		return 0, errors.New("Error renaming tag: " + err.Error())
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.New("Error checking if rows were affected: " + err.Error())
+	}
+	return int(rows), nil
+}
+
 //RemoveGuest removes a given guest (indicated by nric) from the database
 //will not return an error if guest does not exist, will merely delete no one
 func (gs *GuestService) RemoveGuest(eventID string, nric string) error {
@@ -353,30 +588,26 @@ func (gs *GuestService) RemoveGuest(eventID string, nric string) error {
 
 //CheckInStats returns statistics relating to the attendance of the given endedvent
 //See checkin.CheckinStats for the exact information returned
-//Can filter the stats down to counting only guests which have *all* the tags specified in tags
-//A nil tags, or empty string array, will use all guests
-//No error thrown if event does not exist - just gives empty stats, so check existence before calling method
-func (gs *GuestService) CheckInStats(eventID string, tags []string) (checkin.GuestStats, error) {
-	total, err := gs.getNumberOfGuests(eventID, tags)
+//Can filter the stats down to counting only guests matching the tag filter
+//An empty filter will use all guests
+//If a sessionID is given, the guests counted as checked in are those who attended that session
+//No error thrown if event (or session) does not exist - just gives empty stats, so check existence before calling method
+func (gs *GuestService) CheckInStats(eventID string, sessionID string, filter checkin.TagFilter) (checkin.GuestStats, error) {
+	total, err := gs.getNumberOfGuests(eventID, filter)
 	if err != nil {
 		return checkin.GuestStats{}, errors.New("Error fetching total number of guests: " + err.Error())
 	}
 
-	checkedIn, err := gs.getNumberOfGuestsCheckInStatus(eventID, true, tags)
+	var checkedIn int
+	if sessionID != "" {
+		checkedIn, err = gs.getNumberOfGuestsAtSession(eventID, sessionID, filter)
+	} else {
+		checkedIn, err = gs.getNumberOfGuestsCheckInStatus(eventID, true, filter)
+	}
 	if err != nil {
 		return checkin.GuestStats{}, errors.New("Error fetching checked in count:" + err.Error())
 	}
-	var percent float64
-	if total == 0 {
-		percent = 0
-	} else {
-		percent = float64(checkedIn) / float64(total)
-	}
-	return checkin.GuestStats{
-		TotalGuests:      total,
-		CheckedIn:        checkedIn,
-		PercentCheckedIn: percent,
-	}, nil
+	return newGuestStats(total, checkedIn), nil
 }
 
 func (gs *GuestService) getNumberOfUniqueTags(eventID string) (int, error) {
@@ -386,21 +617,21 @@ func (gs *GuestService) getNumberOfUniqueTags(eventID string) (int, error) {
 	return i, err
 }
 
-//if tags is nil OR an empty array, looks for all guests, ignoring tags
-//in general, looks for guests who have all the tags specified in tags
-//they could possibly have more
-func (gs *GuestService) getNumberOfGuests(eventID string, tags []string) (int, error) {
+//counts the guests of the event matching the tag filter, where an empty filter counts all guests
+func (gs *GuestService) getNumberOfGuests(eventID string, filter checkin.TagFilter) (int, error) {
 	var i int
-	var err error
-	if tags != nil {
-		tags = gs.capitalizeTags(tags)
-		err = gs.DB.QueryRow("SELECT count(*) from guest where eventID = $1 and $2 <@ tags",
-			eventID, pq.Array(tags)).Scan(&i)
-	} else {
-		err = gs.DB.QueryRow("SELECT count(*) from guest where eventID = $1",
-			eventID).Scan(&i)
+	condition, args := tagCondition(filter, "tags", []interface{}{eventID})
+	err := gs.DB.QueryRow("SELECT count(*) from guest where eventID = $1 and "+condition, args...).Scan(&i)
+	if err != nil {
+		return 0, errors.New("Cannot fetch guest count: " + err.Error())
 	}
 
+	return i, nil
+}
+
+func (gs *GuestService) getNumberOfGuestsWithoutDigest(eventID string) (int, error) {
+	var i int
+	err := gs.DB.QueryRow("SELECT count(*) from guest where eventID = $1 and nricDigest IS NULL", eventID).Scan(&i)
 	if err != nil {
 		return 0, errors.New("Cannot fetch guest count: " + err.Error())
 	}
@@ -408,17 +639,10 @@ func (gs *GuestService) getNumberOfGuests(eventID string, tags []string) (int, e
 	return i, nil
 }
 
-func (gs *GuestService) getNumberOfGuestsCheckInStatus(eventID string, checkInStatus bool, tags []string) (int, error) {
+func (gs *GuestService) getNumberOfGuestsCheckInStatus(eventID string, checkInStatus bool, filter checkin.TagFilter) (int, error) {
 	var i int
-	var err error
-	if tags != nil {
-		tags = gs.capitalizeTags(tags)
-		err = gs.DB.QueryRow("SELECT count(*) from guest where eventID = $1 and checkedIn = $2 and $3 <@ tags",
-			eventID, checkInStatus, pq.Array(tags)).Scan(&i)
-	} else {
-		err = gs.DB.QueryRow("SELECT count(*) from guest where eventID = $1 and checkedIn = $2",
-			eventID, checkInStatus).Scan(&i)
-	}
+	condition, args := tagCondition(filter, "tags", []interface{}{eventID, checkInStatus})
+	err := gs.DB.QueryRow("SELECT count(*) from guest where eventID = $1 and checkedIn = $2 and "+condition, args...).Scan(&i)
 	if err != nil {
 		return 0, errors.New("Cannot fetch guest count: " + err.Error())
 	}
@@ -426,6 +650,64 @@ func (gs *GuestService) getNumberOfGuestsCheckInStatus(eventID string, checkInSt
 	return i, nil
 }
 
+//getNumberOfGuestsAtSession counts the guests matching the tag filter who attended the session of the event
+func (gs *GuestService) getNumberOfGuestsAtSession(eventID string, sessionID string, filter checkin.TagFilter) (int, error) {
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return 0, nil //no such session, so nobody attended it
+	}
+	var i int
+	condition, args := tagCondition(filter, "g.tags", []interface{}{sessionID, eventID})
+	err := gs.DB.QueryRow(`SELECT count(*) from sessionattendance a join guest g on g.eventID = a.eventID and g.nricHash = a.nricHash
+	where a.sessionID = $1 and a.eventID = $2 and `+condition, args...).Scan(&i)
+	if err != nil {
+		return 0, errors.New("Cannot fetch guest count: " + err.Error())
+	}
+	return i, nil
+}
+
+//AttendanceMatrix returns the sessions of the event, and the guests of the event in order of name,
+//along with which of the sessions each of them attended
+//Can filter the guests down to those matching the tag filter
+//An empty filter will include all guests
+//No error thrown if event does not exist - just gives an empty matrix, so check existence before calling method
+func (gs *GuestService) AttendanceMatrix(eventID string, filter checkin.TagFilter) (checkin.AttendanceMatrix, error) {
+	sessions, err := querySessions(gs.DB, eventID)
+	if err != nil {
+		return checkin.AttendanceMatrix{}, err
+	}
+	columns := make(map[string]int, len(sessions))
+	for i, s := range sessions {
+		columns[s.ID] = i
+	}
+
+	condition, args := tagCondition(filter, "g.tags", []interface{}{eventID})
+	rows, err := gs.DB.Query(`SELECT g.name, array_remove(array_agg(a.sessionID::text), NULL) from guest g
+	left join sessionattendance a on a.eventID = g.eventID and a.nricHash = g.nricHash
+	where g.eventID = $1 and `+condition+` GROUP BY g.nricHash, g.name ORDER BY g.name, g.nricHash`, args...)
+	if err != nil {
+		return checkin.AttendanceMatrix{}, errors.New("Cannot fetch attendance at sessions: " + err.Error())
+	}
+	defer rows.Close()
+
+	guests := make([]checkin.SessionAttendance, 0)
+	for rows.Next() {
+		var name string
+		var attended []string
+		err = rows.Scan(&name, pq.Array(&attended))
+		if err != nil {
+			return checkin.AttendanceMatrix{}, errors.New("Could not extract attendance of guest: " + err.Error())
+		}
+		present := make([]bool, len(sessions))
+		for _, sessionID := range attended {
+			if i, ok := columns[sessionID]; ok { //sessions created since they were fetched are left out
+				present[i] = true
+			}
+		}
+		guests = append(guests, checkin.SessionAttendance{Name: name, Present: present})
+	}
+	return checkin.AttendanceMatrix{Sessions: sessions, Guests: guests}, nil
+}
+
 func (gs *GuestService) scanRowsIntoStrings(rows *sql.Rows, rowCount int) ([]string, error) {
 	strings := make([]string, rowCount)
 
@@ -477,6 +759,8 @@ func (gs *GuestService) FlushCache() {
 //The guest will have its name and nricHash filled out only
 //Returns an empty guest object (and no error) if the guest could not be found
 //Returns an error if there is an error getting a guest
+//If the GuestService has a DigestMethod, looks the guest up by digest first, and only compares
+//hashes of guests who have no digest yet; such a guest, once found, has its digest filled in
 func (gs *GuestService) getGuestWithNRIC(eventID string, nric string) (checkin.Guest, error) {
 	if _, err := uuid.Parse(eventID); err != nil {
 		//attempting to search for a guest associated with an event with an invalid UUID will throw an error
@@ -490,13 +774,26 @@ func (gs *GuestService) getGuestWithNRIC(eventID string, nric string) (checkin.G
 	} else if hash == "" && ok {
 		return checkin.Guest{}, nil
 	}
-	rows, err := gs.DB.Queryx("SELECT name, nricHash from guest where eventID = $1", eventID)
+
+	if gs.DM != nil {
+		guest, err := gs.getGuestWithDigest(eventID, nric)
+		if err != nil {
+			return checkin.Guest{}, errors.New("Error looking up guest by digest: " + err.Error())
+		}
+		if !guest.IsEmpty() {
+			gs.SetCache(eventID, nric, guest.NRIC)
+			return guest, nil
+		}
+	}
+
+	//the guest is either not registered, or registered without a digest, so compare against every hash
+	rows, err := gs.DB.Queryx("SELECT name, nricHash from guest where eventID = $1 and nricDigest IS NULL", eventID)
 	if err != nil {
 		return checkin.Guest{}, errors.New("Cannot fetch all guests: " + err.Error())
 	}
 	defer rows.Close()
 
-	numGuests, err := gs.getNumberOfGuests(eventID, nil)
+	numGuests, err := gs.getNumberOfGuestsWithoutDigest(eventID)
 	if err != nil {
 		return checkin.Guest{}, errors.New("Error fetching number of guests: " + err.Error())
 	}
@@ -506,10 +803,59 @@ func (gs *GuestService) getGuestWithNRIC(eventID string, nric string) (checkin.G
 	}
 
 	guest := gs.findGuest(nric, guests)
+	if !guest.IsEmpty() && gs.DM != nil {
+		err = gs.setDigest(eventID, guest.NRIC, nric)
+		if err != nil {
+			return checkin.Guest{}, errors.New("Error filling in digest of guest: " + err.Error())
+		}
+	}
 	gs.SetCache(eventID, nric, guest.NRIC) //puts an empty string if guest not found
 	return guest, nil
 }
 
+//Returns the guest with that nric (given in plaintext) and eventID, found using the digest of the nric
+//The guest will have its name and nricHash filled out only
+//Returns an empty guest object (and no error) if no guest has that digest
+func (gs *GuestService) getGuestWithDigest(eventID string, nric string) (checkin.Guest, error) {
+	nricDigest, err := gs.digest(nric)
+	if err != nil {
+		return checkin.Guest{}, errors.New("Error computing NRIC digest: " + err.Error())
+	}
+	var guest checkin.Guest
+	err = gs.DB.QueryRowx("SELECT name, nricHash from guest where eventID = $1 and nricDigest = $2",
+		eventID, nricDigest).StructScan(&guest)
+	if err == sql.ErrNoRows {
+		return checkin.Guest{}, nil
+	} else if err != nil {
+		return checkin.Guest{}, errors.New("Error fetching guest: " + err.Error())
+	}
+	return guest, nil
+}
+
+//setDigest stores the digest of the nric (given in plaintext) of the guest with that nricHash
+func (gs *GuestService) setDigest(eventID string, nricHash string, nric string) error {
+	nricDigest, err := gs.digest(nric)
+	if err != nil {
+		return errors.New("Error computing NRIC digest: " + err.Error())
+	}
+	_, err = gs.DB.Exec("UPDATE guest SET nricDigest = $1 where eventID = $2 and nricHash = $3",
+		nricDigest, eventID, nricHash)
+	return err
+}
+
+//digest returns the digest of the nric (case insensitive) using the DigestMethod
+//Returns a null string if the GuestService has no DigestMethod
+func (gs *GuestService) digest(nric string) (sql.NullString, error) {
+	if gs.DM == nil {
+		return sql.NullString{}, nil
+	}
+	nricDigest, err := gs.DM.Digest(strings.ToUpper(nric))
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: nricDigest, Valid: true}, nil
+}
+
 func (gs *GuestService) findGuest(nric string, hashedGuests []checkin.Guest) checkin.Guest {
 	result := make(chan checkin.Guest) //channel to send a found guest
 	quit := make(chan bool)            //channel to signal that all goroutines have finished execution
@@ -573,6 +919,45 @@ func (gs *GuestService) scanRowsIntoGuests(rows *sqlx.Rows, rowCount int) ([]che
 	return guests, nil
 }
 
+//scans rows of name, tags, checkedIn, checkInTime into guest records
+//the check in time is left null for guests who are not checked in
+//(marking a guest absent also updates the check in time)
+func (gs *GuestService) scanRowsIntoGuestRecords(rows *sql.Rows, rowCount int) ([]checkin.GuestRecord, error) {
+	records := make([]checkin.GuestRecord, 0, rowCount)
+
+	for thereAreMore := rows.Next(); thereAreMore; thereAreMore = rows.Next() {
+		var record checkin.GuestRecord
+		err := rows.Scan(&record.Name, pq.Array(&record.Tags), &record.CheckedIn, &record.CheckInTime)
+		if err != nil {
+			return nil, errors.New("Could not extract guest record: " + err.Error())
+		}
+		if record.Tags == nil {
+			record.Tags = []string{} //no nils allowed
+		}
+		if record.CheckedIn {
+			record.CheckInTime.Time = record.CheckInTime.Time.In(time.UTC) //make sure all times are in UTC
+		} else {
+			record.CheckInTime = null.Time{}
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
+
+//guestTags returns the tags to store for a guest: capitalized, with repeated tags left out, and empty if nil
+func (gs *GuestService) guestTags(tags []string) []string {
+	unique := make([]string, 0, len(tags)) //no nils allowed
+	seen := make(map[string]bool)
+	for _, tag := range gs.capitalizeTags(tags) {
+		if !seen[tag] {
+			seen[tag] = true
+			unique = append(unique, tag)
+		}
+	}
+	return unique
+}
+
 func (gs *GuestService) capitalizeTags(tags []string) []string {
 	if tags == nil {
 		return nil
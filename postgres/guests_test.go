@@ -15,6 +15,10 @@ import (
 	"strconv"
 	"testing"
 	"time"
+
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+	"github.com/lib/pq"
 )
 
 func hashFnGenerator(err error) func(string) (string, error) {
@@ -40,7 +44,7 @@ func TestGuests(t *testing.T) {
 	var hm mock.HashMethod
 	gs := postgres.GuestService{DB: db, HM: &hm, HashCache: make(map[string]string)}
 
-	names, err := gs.Guests("aa19239f-f9f5-4935-b1f7-0edfdceabba7", []string{})
+	names, err := gs.Guests("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.TagFilter{})
 	test.Ok(t, err)
 	expectedNames := make([]string, 10)
 	for i := range expectedNames { // see testData.sql for why
@@ -50,82 +54,168 @@ func TestGuests(t *testing.T) {
 	test.Equals(t, expectedNames, names)
 
 	//nil or empty string array do the same thing
-	names2, err := gs.Guests("aa19239f-f9f5-4935-b1f7-0edfdceabba7", nil)
+	names2, err := gs.Guests("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.TagFilter{})
 	test.Ok(t, err)
 	test.Equals(t, names, names2)
 
 	//check that tag searching works as expected
-	names, err = gs.Guests("aa19239f-f9f5-4935-b1f7-0edfdceabba7", []string{"VIP"})
+	names, err = gs.Guests("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.HasAllTags([]string{"VIP"}))
 	test.Ok(t, err)
 	expectedNames = []string{"C", "D", "H", "I"}
 	test.Equals(t, expectedNames, names)
 
-	names, err = gs.Guests("aa19239f-f9f5-4935-b1f7-0edfdceabba7", []string{"attenDING"}) //check case insensitivity
+	names, err = gs.Guests("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.HasAllTags([]string{"attenDING"})) //check case insensitivity
 	test.Ok(t, err)
 	expectedNames = []string{"C", "E", "H", "J"}
 	test.Equals(t, expectedNames, names)
 
-	names, err = gs.Guests("aa19239f-f9f5-4935-b1f7-0edfdceabba7", []string{"ATTENDING", "VIP"})
+	names, err = gs.Guests("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.HasAllTags([]string{"ATTENDING", "VIP"}))
 	test.Ok(t, err)
 	expectedNames = []string{"C", "H"}
 	test.Equals(t, expectedNames, names)
 
 	//empty array, not nil, if no guests fetched
-	names, err = gs.Guests("aa19239f-f9f5-4935-b1f7-0edfdceabba7", []string{"UNKNOWNTAG"})
+	names, err = gs.Guests("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.HasAllTags([]string{"UNKNOWNTAG"}))
 	test.Ok(t, err)
 	expectedNames = []string{}
 	test.Equals(t, expectedNames, names)
 
-	names, err = gs.Guests("3820a980-a207-4738-b82b-45808fe7aba8", []string{})
+	names, err = gs.Guests("3820a980-a207-4738-b82b-45808fe7aba8", checkin.TagFilter{})
 	test.Ok(t, err)
 	expectedNames = []string{}
 	test.Equals(t, expectedNames, names)
 }
 
+func TestTagFilterExpressions(t *testing.T) {
+	var hm mock.HashMethod
+	gs := postgres.GuestService{DB: db, HM: &hm, HashCache: make(map[string]string)}
+	parse := func(expression string) checkin.TagFilter {
+		filter, err := checkin.ParseTagFilter(expression)
+		test.Ok(t, err)
+		return filter
+	}
+
+	names, err := gs.Guests("aa19239f-f9f5-4935-b1f7-0edfdceabba7", parse("vip or attending"))
+	test.Ok(t, err)
+	test.Equals(t, []string{"C", "D", "E", "H", "I", "J"}, names)
+	names, err = gs.Guests("aa19239f-f9f5-4935-b1f7-0edfdceabba7", parse("not (VIP or ATTENDING)"))
+	test.Ok(t, err)
+	test.Equals(t, []string{"A", "B", "F", "G"}, names)
+	names, err = gs.GuestsCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", parse("VIP and not ATTENDING"))
+	test.Ok(t, err)
+	test.Equals(t, []string{"I"}, names)
+	names, err = gs.GuestsNotCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", parse("VIP and not ATTENDING"))
+	test.Ok(t, err)
+	test.Equals(t, []string{"D"}, names)
+
+	stats, err := gs.CheckInStats("aa19239f-f9f5-4935-b1f7-0edfdceabba7", "", parse("VIP or ATTENDING"))
+	test.Ok(t, err)
+	test.Equals(t, checkin.GuestStats{TotalGuests: 6, CheckedIn: 3, PercentCheckedIn: 0.5}, stats)
+	_, total, err := gs.GuestRecords("aa19239f-f9f5-4935-b1f7-0edfdceabba7", parse("not VIP"), checkin.ListOptions{Search: "a"})
+	test.Ok(t, err)
+	test.Equals(t, 1, total)
+
+	//tags are only ever passed as parameters
+	names, err = gs.Guests("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.HasTag("VIP') or TRUE or ('"))
+	test.Ok(t, err)
+	test.Equals(t, []string{}, names)
+}
+
+func TestTagStats(t *testing.T) {
+	var hm mock.HashMethod
+	gs := postgres.GuestService{DB: db, HM: &hm, HashCache: make(map[string]string)}
+
+	//test every tag is listed in order, followed by guests without tags
+	stats, err := gs.TagStats("aa19239f-f9f5-4935-b1f7-0edfdceabba7", "", checkin.TagFilter{})
+	test.Ok(t, err)
+	test.Equals(t, []checkin.TagStats{
+		{Tag: "ATTENDING", GuestStats: checkin.GuestStats{TotalGuests: 4, CheckedIn: 2, PercentCheckedIn: 0.5}},
+		{Tag: "VIP", GuestStats: checkin.GuestStats{TotalGuests: 4, CheckedIn: 2, PercentCheckedIn: 0.5}},
+		{Tag: "", GuestStats: checkin.GuestStats{TotalGuests: 4, CheckedIn: 2, PercentCheckedIn: 0.5}},
+	}, stats)
+
+	//test tags are still listed when none of their guests match the filter
+	filter, err := checkin.ParseTagFilter("not attending")
+	test.Ok(t, err)
+	stats, err = gs.TagStats("aa19239f-f9f5-4935-b1f7-0edfdceabba7", "", filter)
+	test.Ok(t, err)
+	test.Equals(t, []checkin.TagStats{
+		{Tag: "ATTENDING", GuestStats: checkin.GuestStats{}},
+		{Tag: "VIP", GuestStats: checkin.GuestStats{TotalGuests: 2, CheckedIn: 1, PercentCheckedIn: 0.5}},
+		{Tag: "", GuestStats: checkin.GuestStats{TotalGuests: 4, CheckedIn: 2, PercentCheckedIn: 0.5}},
+	}, stats)
+
+	//test events without guests, and invalid IDs
+	stats, err = gs.TagStats("3820a980-a207-4738-b82b-45808fe7aba8", "", checkin.TagFilter{})
+	test.Ok(t, err)
+	test.Equals(t, []checkin.TagStats{{Tag: ""}}, stats)
+	stats, err = gs.TagStats("1", "", checkin.TagFilter{})
+	test.Ok(t, err)
+	test.Equals(t, []checkin.TagStats{{Tag: ""}}, stats)
+
+	//test cross tabulation, where tags are not case sensitive
+	crossStats, err := gs.TagCrossStats("aa19239f-f9f5-4935-b1f7-0edfdceabba7", "",
+		[]string{"VIP", "attending", "UNKNOWNTAG"}, []string{"Attending"}, checkin.TagFilter{})
+	test.Ok(t, err)
+	test.Equals(t, checkin.TagCrossStats{
+		Rows:    []string{"VIP", "ATTENDING", "UNKNOWNTAG"},
+		Columns: []string{"ATTENDING"},
+		Cells: [][]checkin.GuestStats{
+			{{TotalGuests: 2, CheckedIn: 1, PercentCheckedIn: 0.5}},
+			{{TotalGuests: 4, CheckedIn: 2, PercentCheckedIn: 0.5}},
+			{{}},
+		},
+	}, crossStats)
+	crossStats, err = gs.TagCrossStats("aa19239f-f9f5-4935-b1f7-0edfdceabba7", "",
+		[]string{"VIP"}, []string{"ATTENDING"}, checkin.HasTag("UNKNOWNTAG"))
+	test.Ok(t, err)
+	test.Equals(t, [][]checkin.GuestStats{{{}}}, crossStats.Cells)
+}
+
 func TestGuestsCheckedIn(t *testing.T) {
 	var hm mock.HashMethod
 	gs := postgres.GuestService{DB: db, HM: &hm, HashCache: make(map[string]string)}
 
-	names, err := gs.GuestsCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", []string{})
+	names, err := gs.GuestsCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.TagFilter{})
 	test.Ok(t, err)
 	expectedNames := []string{"F", "G", "H", "I", "J"}
 	test.Equals(t, expectedNames, names)
 
 	//nil or empty string array do the same thing
-	names2, err := gs.GuestsCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", nil)
+	names2, err := gs.GuestsCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.TagFilter{})
 	test.Ok(t, err)
 	test.Equals(t, names, names2)
 
 	//check that tag searching works as expected
-	names, err = gs.GuestsCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", []string{"vip"}) //check case insensitivity
+	names, err = gs.GuestsCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.HasAllTags([]string{"vip"})) //check case insensitivity
 	test.Ok(t, err)
 	expectedNames = []string{"H", "I"}
 	test.Equals(t, expectedNames, names)
 
-	names, err = gs.GuestsCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", []string{"ATTENDING"})
+	names, err = gs.GuestsCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.HasAllTags([]string{"ATTENDING"}))
 	test.Ok(t, err)
 	expectedNames = []string{"H", "J"}
 	test.Equals(t, expectedNames, names)
 
-	names, err = gs.GuestsCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", []string{"ATTENDING", "VIP"})
+	names, err = gs.GuestsCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.HasAllTags([]string{"ATTENDING", "VIP"}))
 	test.Ok(t, err)
 	expectedNames = []string{"H"}
 	test.Equals(t, expectedNames, names)
 
 	//empty array, not nil, if no guests fetched
-	names, err = gs.GuestsCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", []string{"UNKNOWNTAG"})
+	names, err = gs.GuestsCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.HasAllTags([]string{"UNKNOWNTAG"}))
 	test.Ok(t, err)
 	expectedNames = []string{}
 	test.Equals(t, expectedNames, names)
 
 	//this event has no people straight up
-	names, err = gs.GuestsCheckedIn("3820a980-a207-4738-b82b-45808fe7aba8", []string{})
+	names, err = gs.GuestsCheckedIn("3820a980-a207-4738-b82b-45808fe7aba8", checkin.TagFilter{})
 	test.Ok(t, err)
 	expectedNames = []string{}
 	test.Equals(t, expectedNames, names)
 
 	//this event has no checked in people
-	names, err = gs.GuestsCheckedIn("03293b3b-df83-407e-b836-fb7d4a3c4966", []string{})
+	names, err = gs.GuestsCheckedIn("03293b3b-df83-407e-b836-fb7d4a3c4966", checkin.TagFilter{})
 	test.Ok(t, err)
 	expectedNames = []string{}
 	test.Equals(t, expectedNames, names)
@@ -135,50 +225,142 @@ func TestGuestsNotCheckedIn(t *testing.T) {
 	var hm mock.HashMethod
 	gs := postgres.GuestService{DB: db, HM: &hm, HashCache: make(map[string]string)}
 
-	names, err := gs.GuestsNotCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", []string{})
+	names, err := gs.GuestsNotCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.TagFilter{})
 	test.Ok(t, err)
 	expectedNames := []string{"A", "B", "C", "D", "E"}
 	test.Equals(t, expectedNames, names)
 
 	//nil or empty string array do the same thing
-	names2, err := gs.GuestsNotCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", nil)
+	names2, err := gs.GuestsNotCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.TagFilter{})
 	test.Ok(t, err)
 	test.Equals(t, names, names2)
 
 	//check that tag searching works as expected
-	names, err = gs.GuestsNotCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", []string{"vip"}) //check case insensitivity
+	names, err = gs.GuestsNotCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.HasAllTags([]string{"vip"})) //check case insensitivity
 	test.Ok(t, err)
 	expectedNames = []string{"C", "D"}
 	test.Equals(t, expectedNames, names)
 
-	names, err = gs.GuestsNotCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", []string{"ATTENDING"})
+	names, err = gs.GuestsNotCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.HasAllTags([]string{"ATTENDING"}))
 	test.Ok(t, err)
 	expectedNames = []string{"C", "E"}
 	test.Equals(t, expectedNames, names)
 
-	names, err = gs.GuestsNotCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", []string{"attending", "VIP"}) //check case insenstivity
+	names, err = gs.GuestsNotCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.HasAllTags([]string{"attending", "VIP"})) //check case insenstivity
 	test.Ok(t, err)
 	expectedNames = []string{"C"}
 	test.Equals(t, expectedNames, names)
 
 	//empty array, not nil, if no guests fetched
-	names, err = gs.GuestsNotCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", []string{"UNKNOWNTAG"})
+	names, err = gs.GuestsNotCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.HasAllTags([]string{"UNKNOWNTAG"}))
 	test.Ok(t, err)
 	expectedNames = []string{}
 	test.Equals(t, expectedNames, names)
 
 	//this event has no guests
-	names, err = gs.GuestsNotCheckedIn("3820a980-a207-4738-b82b-45808fe7aba8", []string{})
+	names, err = gs.GuestsNotCheckedIn("3820a980-a207-4738-b82b-45808fe7aba8", checkin.TagFilter{})
 	test.Ok(t, err)
 	expectedNames = []string{}
 	test.Equals(t, expectedNames, names)
 }
 
+func TestGuestRecords(t *testing.T) {
+	var hm mock.HashMethod
+	gs := postgres.GuestService{DB: db, HM: &hm, HashCache: make(map[string]string)}
+	recordNames := func(records []checkin.GuestRecord) []string {
+		names := make([]string, len(records))
+		for i, record := range records {
+			names[i] = record.Name
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	records, total, err := gs.GuestRecords("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.TagFilter{}, checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, 10, total)
+	test.Equals(t, []string{"A", "B", "C", "D", "E", "F", "G", "H", "I", "J"}, recordNames(records))
+	for _, record := range records {
+		//guests F through J are checked in in the test data, and only checked in guests have a check in time
+		checkedIn := record.Name >= "F"
+		test.Equals(t, checkedIn, record.CheckedIn)
+		test.Equals(t, checkedIn, record.CheckInTime.Valid)
+		if checkedIn {
+			test.Equals(t, time.UTC, record.CheckInTime.Time.Location())
+		}
+		test.Assert(t, record.Tags != nil, "Tags should be an empty array, not nil")
+	}
+
+	//nil or empty string array do the same thing
+	records2, _, err := gs.GuestRecords("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.TagFilter{}, checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, recordNames(records), recordNames(records2))
+
+	records, _, err = gs.GuestRecords("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.HasAllTags([]string{"attending", "VIP"}), checkin.ListOptions{}) //check case insensitivity
+	test.Ok(t, err)
+	test.Equals(t, []string{"C", "H"}, recordNames(records))
+	for _, record := range records {
+		test.Equals(t, []string{"VIP", "ATTENDING"}, record.Tags)
+	}
+
+	records, total, err = gs.GuestRecordsCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.HasAllTags([]string{"VIP"}), checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, 2, total)
+	test.Equals(t, []string{"H", "I"}, recordNames(records))
+
+	records, _, err = gs.GuestRecordsNotCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.HasAllTags([]string{"VIP"}), checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, []string{"C", "D"}, recordNames(records))
+
+	//empty array, not nil, if no guests fetched
+	records, _, err = gs.GuestRecords("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.HasAllTags([]string{"UNKNOWNTAG"}), checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, []checkin.GuestRecord{}, records)
+
+	//this event has no checked in people
+	records, _, err = gs.GuestRecordsCheckedIn("03293b3b-df83-407e-b836-fb7d4a3c4966", checkin.TagFilter{}, checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, []checkin.GuestRecord{}, records)
+
+	//test pagination, which defaults to sorting by name
+	records, total, err = gs.GuestRecords("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.TagFilter{}, checkin.ListOptions{Offset: 2, Limit: 3})
+	test.Ok(t, err)
+	test.Equals(t, 10, total)
+	test.Equals(t, 3, len(records))
+	test.Equals(t, "C", records[0].Name)
+	test.Equals(t, "D", records[1].Name)
+	test.Equals(t, "E", records[2].Name)
+
+	records, total, err = gs.GuestRecordsNotCheckedIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.TagFilter{},
+		checkin.ListOptions{Descending: true, Offset: 3, Limit: 3})
+	test.Ok(t, err)
+	test.Equals(t, 5, total)
+	test.Equals(t, 2, len(records))
+	test.Equals(t, "B", records[0].Name)
+	test.Equals(t, "A", records[1].Name)
+
+	//guests who have not checked in have no check in time, so are sorted last
+	records, _, err = gs.GuestRecords("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.TagFilter{},
+		checkin.ListOptions{SortBy: checkin.SortByCheckInTime, Limit: 5})
+	test.Ok(t, err)
+	test.Equals(t, []string{"F", "G", "H", "I", "J"}, recordNames(records))
+
+	//test case insensitive name prefix search
+	records, total, err = gs.GuestRecords("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.TagFilter{}, checkin.ListOptions{Search: "h"})
+	test.Ok(t, err)
+	test.Equals(t, 1, total)
+	test.Equals(t, []string{"H"}, recordNames(records))
+
+	//test unsupported sort key
+	_, _, err = gs.GuestRecords("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.TagFilter{}, checkin.ListOptions{SortBy: checkin.SortByStart})
+	test.Assert(t, err != nil, "Expected error sorting guests by start")
+}
+
 func TestCheckInStats(t *testing.T) {
 	var hm mock.HashMethod
 	gs := postgres.GuestService{DB: db, HM: &hm, HashCache: make(map[string]string)}
 
-	stats, err := gs.CheckInStats("aa19239f-f9f5-4935-b1f7-0edfdceabba7", []string{})
+	stats, err := gs.CheckInStats("aa19239f-f9f5-4935-b1f7-0edfdceabba7", "", checkin.TagFilter{})
 	test.Ok(t, err)
 	expectedStats := checkin.GuestStats{
 		TotalGuests:      10,
@@ -188,12 +370,12 @@ func TestCheckInStats(t *testing.T) {
 	test.Equals(t, expectedStats, stats)
 
 	//nil or empty string array do the same thing
-	stats2, err := gs.CheckInStats("aa19239f-f9f5-4935-b1f7-0edfdceabba7", nil)
+	stats2, err := gs.CheckInStats("aa19239f-f9f5-4935-b1f7-0edfdceabba7", "", checkin.TagFilter{})
 	test.Ok(t, err)
 	test.Equals(t, stats, stats2)
 
 	//check that tag searching works as expected
-	stats, err = gs.CheckInStats("aa19239f-f9f5-4935-b1f7-0edfdceabba7", []string{"VIP"})
+	stats, err = gs.CheckInStats("aa19239f-f9f5-4935-b1f7-0edfdceabba7", "", checkin.HasAllTags([]string{"VIP"}))
 	test.Ok(t, err)
 	expectedStats = checkin.GuestStats{
 		TotalGuests:      4,
@@ -202,7 +384,7 @@ func TestCheckInStats(t *testing.T) {
 	}
 	test.Equals(t, expectedStats, stats)
 
-	stats, err = gs.CheckInStats("aa19239f-f9f5-4935-b1f7-0edfdceabba7", []string{"ATTENDING"})
+	stats, err = gs.CheckInStats("aa19239f-f9f5-4935-b1f7-0edfdceabba7", "", checkin.HasAllTags([]string{"ATTENDING"}))
 	test.Ok(t, err)
 	expectedStats = checkin.GuestStats{
 		TotalGuests:      4,
@@ -211,7 +393,7 @@ func TestCheckInStats(t *testing.T) {
 	}
 	test.Equals(t, expectedStats, stats)
 
-	stats, err = gs.CheckInStats("aa19239f-f9f5-4935-b1f7-0edfdceabba7", []string{"AttENDiNG", "vip"}) //random capitalization check
+	stats, err = gs.CheckInStats("aa19239f-f9f5-4935-b1f7-0edfdceabba7", "", checkin.HasAllTags([]string{"AttENDiNG", "vip"})) //random capitalization check
 	test.Ok(t, err)
 	expectedStats = checkin.GuestStats{
 		TotalGuests:      2,
@@ -221,7 +403,7 @@ func TestCheckInStats(t *testing.T) {
 	test.Equals(t, expectedStats, stats)
 
 	//empty stats, not nil, if no guests fetched
-	stats, err = gs.CheckInStats("aa19239f-f9f5-4935-b1f7-0edfdceabba7", []string{"UNKNOWNTAG"})
+	stats, err = gs.CheckInStats("aa19239f-f9f5-4935-b1f7-0edfdceabba7", "", checkin.HasAllTags([]string{"UNKNOWNTAG"}))
 	test.Ok(t, err)
 	expectedStats = checkin.GuestStats{
 		TotalGuests:      0,
@@ -231,7 +413,7 @@ func TestCheckInStats(t *testing.T) {
 	test.Equals(t, expectedStats, stats)
 
 	//this event has no people straight up
-	stats, err = gs.CheckInStats("3820a980-a207-4738-b82b-45808fe7aba8", []string{})
+	stats, err = gs.CheckInStats("3820a980-a207-4738-b82b-45808fe7aba8", "", checkin.TagFilter{})
 	test.Ok(t, err)
 	expectedStats = checkin.GuestStats{
 		TotalGuests:      0,
@@ -241,7 +423,7 @@ func TestCheckInStats(t *testing.T) {
 	test.Equals(t, expectedStats, stats)
 
 	//this event has no checked in people
-	stats, err = gs.CheckInStats("03293b3b-df83-407e-b836-fb7d4a3c4966", []string{})
+	stats, err = gs.CheckInStats("03293b3b-df83-407e-b836-fb7d4a3c4966", "", checkin.TagFilter{})
 	test.Ok(t, err)
 	expectedStats = checkin.GuestStats{
 		TotalGuests:      1,
@@ -272,7 +454,7 @@ func TestRegisterGuest(t *testing.T) {
 
 	err := gs.RegisterGuest("3820a980-a207-4738-b82b-45808fe7aba8", checkin.Guest{NRIC: "1234A", Name: "Jim Bob", Tags: []string{"newlyREGISTERED"}})
 	test.Ok(t, err)
-	names, err := gs.Guests("3820a980-a207-4738-b82b-45808fe7aba8", []string{"NEWLYregistered"}) //check case insensitivity of tag while you're at it
+	names, err := gs.Guests("3820a980-a207-4738-b82b-45808fe7aba8", checkin.HasAllTags([]string{"NEWLYregistered"})) //check case insensitivity of tag while you're at it
 	test.Ok(t, err)
 	test.Equals(t, []string{"Jim Bob"}, names)
 	test.Equals(t, true, testCache("3820a980-a207-4738-b82b-45808fe7aba8", "1234A", &gs, &hm))
@@ -295,7 +477,7 @@ func TestRegisterGuest(t *testing.T) {
 	//check nil tag and empty tag do the same thing
 	err = gs.RegisterGuest("3820a980-a207-4738-b82b-45808fe7aba8", checkin.Guest{NRIC: "1234A", Name: "Jim Bob", Tags: nil})
 	test.Ok(t, err)
-	names, err = gs.Guests("3820a980-a207-4738-b82b-45808fe7aba8", []string{})
+	names, err = gs.Guests("3820a980-a207-4738-b82b-45808fe7aba8", checkin.TagFilter{})
 	test.Ok(t, err)
 	test.Equals(t, []string{"Jim Bob"}, names)
 	test.Equals(t, true, testCache("3820a980-a207-4738-b82b-45808fe7aba8", "1234A", &gs, &hm))
@@ -305,7 +487,7 @@ func TestRegisterGuest(t *testing.T) {
 
 	err = gs.RegisterGuest("3820a980-a207-4738-b82b-45808fe7aba8", checkin.Guest{NRIC: "1234A", Name: "Jim Bob", Tags: []string{}})
 	test.Ok(t, err)
-	names, err = gs.Guests("3820a980-a207-4738-b82b-45808fe7aba8", []string{})
+	names, err = gs.Guests("3820a980-a207-4738-b82b-45808fe7aba8", checkin.TagFilter{})
 	test.Ok(t, err)
 	test.Equals(t, []string{"Jim Bob"}, names)
 	err = gs.RemoveGuest("3820a980-a207-4738-b82b-45808fe7aba8", "1234A")
@@ -324,7 +506,7 @@ func TestRegisterGuest(t *testing.T) {
 	test.Equals(t, gs.HashCache, map[string]string{}) //cache should be empty
 
 	//check that its empty now, before moving on to next test
-	names, err = gs.Guests("3820a980-a207-4738-b82b-45808fe7aba8", []string{})
+	names, err = gs.Guests("3820a980-a207-4738-b82b-45808fe7aba8", checkin.TagFilter{})
 	test.Ok(t, err)
 	test.Equals(t, []string{}, names)
 
@@ -352,7 +534,7 @@ func TestRegisterGuests(t *testing.T) {
 	test.Equals(t, true, testCache("3820a980-a207-4738-b82b-45808fe7aba8", "1234B", &gs, &hm))
 	test.Equals(t, true, testCache("3820a980-a207-4738-b82b-45808fe7aba8", "1234C", &gs, &hm))
 	test.Equals(t, true, testCache("3820a980-a207-4738-b82b-45808fe7aba8", "1234D", &gs, &hm))
-	names, err := gs.Guests("3820a980-a207-4738-b82b-45808fe7aba8", nil)
+	names, err := gs.Guests("3820a980-a207-4738-b82b-45808fe7aba8", checkin.TagFilter{})
 	sort.Strings(names)
 	test.Equals(t, []string{"Eugene", "Jim Bob", "Mayank", "Ya wei"}, names)
 	for _, guest := range guests {
@@ -388,13 +570,13 @@ func TestRegisterGuests(t *testing.T) {
 	}
 	err = gs.RegisterGuests("a6db3963-5389-4dbe-8fc6-bbd7f7ce66b8", guests)
 	test.Assert(t, err != nil, "Registering two identical guests (with only NRIC case differing) for non-existent event does not throw an error")
-	names, err = gs.Guests("a6db3963-5389-4dbe-8fc6-bbd7f7ce66b8", nil)
+	names, err = gs.Guests("a6db3963-5389-4dbe-8fc6-bbd7f7ce66b8", checkin.TagFilter{})
 	test.Ok(t, err)
 	test.Equals(t, []string{}, names)                 //test that an error in registering one guest, for example 1234a, means neither are registered
 	test.Equals(t, gs.HashCache, map[string]string{}) //cache should be empty
 
 	//check that its empty now, before moving on to next test
-	names, err = gs.Guests("3820a980-a207-4738-b82b-45808fe7aba8", []string{})
+	names, err = gs.Guests("3820a980-a207-4738-b82b-45808fe7aba8", checkin.TagFilter{})
 	test.Ok(t, err)
 	test.Equals(t, []string{}, names)
 
@@ -458,6 +640,13 @@ func TestSetTags(t *testing.T) {
 	test.Ok(t, err)
 	test.Equals(t, []string{"HELLO", "WORLD"}, tags)
 
+	//test repeated tags are only set once, without regard to case
+	err = gs.SetTags("aa19239f-f9f5-4935-b1f7-0edfdceabba7", "2346C", []string{"HELLO", "world", "hello", "WORLD"})
+	test.Ok(t, err)
+	tags, err = gs.Tags("aa19239f-f9f5-4935-b1f7-0edfdceabba7", "2346C")
+	test.Ok(t, err)
+	test.Equals(t, []string{"HELLO", "WORLD"}, tags)
+
 	//make sure unrelated guests not affected
 	newUnaffectedTags, err := gs.Tags("aa19239f-f9f5-4935-b1f7-0edfdceabba7", "5678B")
 	test.Ok(t, err)
@@ -487,6 +676,79 @@ func TestSetTags(t *testing.T) {
 
 }
 
+func TestChangeTags(t *testing.T) {
+	es := postgres.EventService{DB: db}
+	gs := postgres.GuestService{DB: db, HashCache: make(map[string]string)}
+	event := checkin.Event{ID: uuid.New().String(), Name: "Retagged Parade"}
+	test.Ok(t, es.CreateEvent(event, "TestUser"))
+	_, err := db.Exec(`INSERT into guest(nricHash, eventID, name, tags, checkedIn) VALUES
+	('A1234', $1, 'Alice', '{"PENDING", "VIP"}', FALSE), ('B1234', $1, 'Bob', '{"PENDING"}', TRUE),
+	('C1234', $1, 'Carol', '{"CONFIRMED"}', FALSE)`, event.ID)
+	test.Ok(t, err)
+	tagsOf := func() map[string][]string {
+		rows, err := db.Query("SELECT name, tags from guest where eventID = $1", event.ID)
+		test.Ok(t, err)
+		defer rows.Close()
+		tags := make(map[string][]string)
+		for rows.Next() {
+			var name string
+			var guestTags []string
+			test.Ok(t, rows.Scan(&name, pq.Array(&guestTags)))
+			tags[name] = guestTags
+		}
+		return tags
+	}
+
+	//test adding a tag to the guests who pass the filter and do not have it, case insensitive
+	updated, err := gs.AddTag(event.ID, "confirmed", checkin.GuestFilter{Tags: checkin.HasTag("pending"), CheckedIn: null.BoolFrom(false)})
+	test.Ok(t, err)
+	test.Equals(t, 1, updated)
+	updated, err = gs.AddTag(event.ID, "CONFIRMED", checkin.GuestFilter{})
+	test.Ok(t, err)
+	test.Equals(t, 1, updated)
+	test.Equals(t, map[string][]string{"Alice": {"PENDING", "VIP", "CONFIRMED"}, "Bob": {"PENDING", "CONFIRMED"},
+		"Carol": {"CONFIRMED"}}, tagsOf())
+
+	//test removing a tag
+	updated, err = gs.RemoveTag(event.ID, "PENDING", checkin.GuestFilter{CheckedIn: null.BoolFrom(true)})
+	test.Ok(t, err)
+	test.Equals(t, 1, updated)
+	test.Equals(t, []string{"PENDING", "VIP", "CONFIRMED"}, tagsOf()["Alice"])
+	test.Equals(t, []string{"CONFIRMED"}, tagsOf()["Bob"])
+
+	//test the guests can be picked out by any tag filter
+	filter, err := checkin.ParseTagFilter("VIP or not CONFIRMED")
+	test.Ok(t, err)
+	updated, err = gs.AddTag(event.ID, "REMINDED", checkin.GuestFilter{Tags: filter})
+	test.Ok(t, err)
+	test.Equals(t, 1, updated)
+	updated, err = gs.RemoveTag(event.ID, "REMINDED", checkin.GuestFilter{})
+	test.Ok(t, err)
+	test.Equals(t, 1, updated)
+
+	//test renaming a tag, merging it into the new one for guests who have both
+	updated, err = gs.RenameTag(event.ID, "vip", "GUEST OF HONOUR")
+	test.Ok(t, err)
+	test.Equals(t, 1, updated)
+	updated, err = gs.RenameTag(event.ID, "PENDING", "CONFIRMED")
+	test.Ok(t, err)
+	test.Equals(t, 1, updated)
+	test.Equals(t, []string{"GUEST OF HONOUR", "CONFIRMED"}, tagsOf()["Alice"])
+	updated, err = gs.RenameTag(event.ID, "CONFIRMED", "confirmed")
+	test.Ok(t, err)
+	test.Equals(t, 0, updated)
+	test.Equals(t, []string{"CONFIRMED"}, tagsOf()["Carol"])
+
+	//test events which do not exist
+	updated, err = gs.AddTag("notevenauuid", "VIP", checkin.GuestFilter{})
+	test.Ok(t, err)
+	test.Equals(t, 0, updated)
+	updated, err = gs.RenameTag("notevenauuid", "VIP", "GUEST")
+	test.Ok(t, err)
+	test.Equals(t, 0, updated)
+	test.Ok(t, es.DeleteEvent(event.ID))
+}
+
 func TestAllTags(t *testing.T) {
 	var hm mock.HashMethod
 	gs := postgres.GuestService{DB: db, HM: &hm, HashCache: make(map[string]string)}
@@ -575,41 +837,65 @@ func TestCheckIn(t *testing.T) {
 	var hm mock.HashMethod
 	hm.CompareHashAndPasswordFn = compareHashAndPasswordGenerator()
 	gs := postgres.GuestService{HM: &hm, DB: db, HashCache: make(map[string]string)}
+	source := checkin.AttendanceSource{Actor: checkin.ActorSelf}
 
 	//test normal functionality
-	name, err := gs.CheckIn("03293b3b-df83-407e-b836-fb7d4a3c4966", "1234A")
+	name, err := gs.CheckIn("03293b3b-df83-407e-b836-fb7d4a3c4966", "", "1234A", source)
 	test.Ok(t, err)
 	test.Equals(t, "A", name)
 	test.Equals(t, true, testCache("03293b3b-df83-407e-b836-fb7d4a3c4966", "1234A", &gs, &hm))
-	names, err := gs.GuestsCheckedIn("03293b3b-df83-407e-b836-fb7d4a3c4966", nil)
+	names, err := gs.GuestsCheckedIn("03293b3b-df83-407e-b836-fb7d4a3c4966", checkin.TagFilter{})
 	test.Ok(t, err)
 	test.Equals(t, []string{"A"}, names)
 
 	//test guest already checked in (should work fine)
-	name, err = gs.CheckIn("03293b3b-df83-407e-b836-fb7d4a3c4966", "1234A")
+	name, err = gs.CheckIn("03293b3b-df83-407e-b836-fb7d4a3c4966", "", "1234A", source)
 	test.Ok(t, err)
 	test.Equals(t, "A", name)
 	test.Equals(t, true, testCache("03293b3b-df83-407e-b836-fb7d4a3c4966", "1234A", &gs, &hm))
-	names, err = gs.GuestsCheckedIn("03293b3b-df83-407e-b836-fb7d4a3c4966", nil)
+	names, err = gs.GuestsCheckedIn("03293b3b-df83-407e-b836-fb7d4a3c4966", checkin.TagFilter{})
 	test.Ok(t, err)
 	test.Equals(t, []string{"A"}, names)
 
-	err = gs.MarkAbsent("03293b3b-df83-407e-b836-fb7d4a3c4966", "1234A")
+	name, err = gs.MarkAbsent("03293b3b-df83-407e-b836-fb7d4a3c4966", "", "1234A",
+		checkin.AttendanceSource{Actor: "TestUser", IPAddress: "198.51.100.1", UserAgent: "curl/7.64.0"})
 	test.Ok(t, err)
+	test.Equals(t, "A", name)
+
+	//test every check in and mark absent is recorded in the attendance log, in order
+	als := postgres.AttendanceLogService{DB: db}
+	entries, total, err := als.AttendanceLog("03293b3b-df83-407e-b836-fb7d4a3c4966", checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, 3, total)
+	test.Equals(t, []string{checkin.ActionCheckIn, checkin.ActionCheckIn, checkin.ActionMarkAbsent},
+		[]string{entries[0].Action, entries[1].Action, entries[2].Action})
+	for _, entry := range entries {
+		test.Equals(t, "A1234", entry.GuestHash)
+		test.Equals(t, "A", entry.GuestName)
+		test.Assert(t, time.Since(entry.Time) < 2*time.Second && time.Since(entry.Time) > 0, "Log entry time not within 2 seconds of now")
+	}
+	test.Equals(t, source, entries[0].AttendanceSource)
+	test.Equals(t, checkin.AttendanceSource{Actor: "TestUser", IPAddress: "198.51.100.1", UserAgent: "curl/7.64.0"},
+		entries[2].AttendanceSource)
 
 	//test guest does not exist (eventID for different event) (should throw error)
-	name, err = gs.CheckIn("2c59b54d-3422-4bdb-824c-4125775b44c8", "1234A")
+	name, err = gs.CheckIn("2c59b54d-3422-4bdb-824c-4125775b44c8", "", "1234A", source)
 	test.Equals(t, true, testCacheGuestNotFound("2c59b54d-3422-4bdb-824c-4125775b44c8", "1234A", &gs, &hm))
 	test.Assert(t, err != nil, "No error thrown when check in called with non-existent guest")
 
 	//test guest does not exist (NRIC wrong) (should throw error)
-	name, err = gs.CheckIn("03293b3b-df83-407e-b836-fb7d4a3c4966", "3118B")
+	name, err = gs.CheckIn("03293b3b-df83-407e-b836-fb7d4a3c4966", "", "3118B", source)
 	test.Equals(t, true, testCacheGuestNotFound("03293b3b-df83-407e-b836-fb7d4a3c4966", "3118B", &gs, &hm))
 	test.Assert(t, err != nil, "No error thrown when check in called with non-existent guest")
 
 	//test invalid UUID eventID (should throw error)
-	name, err = gs.CheckIn("1312312312", "3118B")
+	name, err = gs.CheckIn("1312312312", "", "3118B", source)
 	test.Assert(t, err != nil, "No error thrown when check in called with non-existent guest (invalid UUID)")
+
+	//failed check ins are not logged
+	_, total, err = als.AttendanceLog("03293b3b-df83-407e-b836-fb7d4a3c4966", checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, 3, total)
 }
 
 func TestGuestExistsTime(t *testing.T) {
@@ -655,13 +941,14 @@ func TestCaching(t *testing.T) {
 	hm.HashAndSaltFn = hashFnGenerator(nil)
 	hm.CompareHashAndPasswordFn = compareHashAndPasswordGenerator()
 	gs := postgres.GuestService{DB: db, HM: &hm, HashCache: make(map[string]string)}
+	source := checkin.AttendanceSource{Actor: checkin.ActorSelf}
 
 	ok, err := gs.GuestExists("aa19239f-f9f5-4935-b1f7-0edfdceabba7", "1234C")
 	test.Ok(t, err)
 	test.Equals(t, false, ok)
 	err = gs.RegisterGuest("aa19239f-f9f5-4935-b1f7-0edfdceabba7", checkin.Guest{Name: "Hello", NRIC: "1234C"})
 	test.Ok(t, err)
-	name, err := gs.CheckIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", "1234C")
+	name, err := gs.CheckIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", "", "1234C", source)
 	test.Ok(t, err)
 	test.Equals(t, "Hello", name)
 
@@ -670,6 +957,187 @@ func TestCaching(t *testing.T) {
 	test.Equals(t, true, ok)
 	err = gs.RemoveGuest("aa19239f-f9f5-4935-b1f7-0edfdceabba7", "1234C")
 	test.Ok(t, err)
-	name, err = gs.CheckIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", "1234C")
+	name, err = gs.CheckIn("aa19239f-f9f5-4935-b1f7-0edfdceabba7", "", "1234C", source)
 	test.Assert(t, err != nil, "No error thrown when trying to check in a non-existent guest")
 }
+
+func digestFnGenerator(err error) func(string) (string, error) {
+	return func(str string) (string, error) {
+		if err != nil {
+			return "", err
+		}
+		return "digest" + str, nil
+	}
+}
+
+func TestDigestLookup(t *testing.T) {
+	var hm mock.HashMethod
+	var dm mock.DigestMethod
+	hm.HashAndSaltFn = hashFnGenerator(nil)
+	hm.CompareHashAndPasswordFn = compareHashAndPasswordGenerator()
+	dm.DigestFn = digestFnGenerator(nil)
+	gs := postgres.GuestService{DB: db, HM: &hm, DM: &dm, HashCache: make(map[string]string)}
+	source := checkin.AttendanceSource{Actor: checkin.ActorSelf}
+	eventID := "c14a592c-950d-44ba-b173-bbb9e4f5c8b4"
+
+	//test guest registered without a digest is found by comparing hashes, and has its digest filled in
+	exists, err := gs.GuestExists(eventID, "2834b")
+	test.Ok(t, err)
+	test.Equals(t, true, exists)
+	var nricDigest string
+	err = db.QueryRow("SELECT nricDigest from guest where eventID = $1 and nricHash = $2", eventID, "B2834").Scan(&nricDigest)
+	test.Ok(t, err)
+	test.Equals(t, "digest2834B", nricDigest)
+
+	//test guest with a digest is found without comparing hashes
+	gs.FlushCache()
+	hm.CompareHashAndPasswordInvoked = false
+	name, err := gs.CheckIn(eventID, "", "2834B", source)
+	test.Ok(t, err)
+	test.Equals(t, "B", name)
+	test.Equals(t, false, hm.CompareHashAndPasswordInvoked)
+
+	//test newly registered guests have their digest stored
+	err = gs.RegisterGuest(eventID, checkin.Guest{Name: "New", NRIC: "9999z"})
+	test.Ok(t, err)
+	err = db.QueryRow("SELECT nricDigest from guest where eventID = $1 and nricHash = $2", eventID, "z9999").Scan(&nricDigest)
+	test.Ok(t, err)
+	test.Equals(t, "digest9999Z", nricDigest)
+	gs.FlushCache()
+	hm.CompareHashAndPasswordInvoked = false
+	exists, err = gs.GuestExists(eventID, "9999Z")
+	test.Ok(t, err)
+	test.Equals(t, true, exists)
+	test.Equals(t, false, hm.CompareHashAndPasswordInvoked)
+
+	//test guest that does not exist
+	exists, err = gs.GuestExists(eventID, "0000A")
+	test.Ok(t, err)
+	test.Equals(t, false, exists)
+
+	//test error computing digest
+	gs.FlushCache()
+	dm.DigestFn = digestFnGenerator(errors.New("An error"))
+	_, err = gs.GuestExists(eventID, "2834B")
+	test.Assert(t, err != nil, "No error thrown when digest could not be computed")
+	err = gs.RegisterGuest(eventID, checkin.Guest{Name: "Another", NRIC: "8888Y"})
+	test.Assert(t, err != nil, "No error thrown when registering guest whose digest could not be computed")
+
+	//clean up
+	dm.DigestFn = digestFnGenerator(nil)
+	err = gs.RemoveGuest(eventID, "9999Z")
+	test.Ok(t, err)
+	_, err = db.Exec("UPDATE guest SET nricDigest = NULL, checkedIn = TRUE where eventID = $1 and nricHash = $2", eventID, "B2834")
+	test.Ok(t, err)
+}
+
+func TestArrivals(t *testing.T) {
+	var hm mock.HashMethod
+	gs := postgres.GuestService{DB: db, HM: &hm, HashCache: make(map[string]string)}
+
+	event := checkin.Event{ID: uuid.New().String(), Name: "Arrivals"}
+	es := postgres.EventService{DB: db}
+	test.Ok(t, es.CreateEvent(event, "TestUser"))
+	first := time.Date(2019, 4, 10, 9, 0, 0, 0, time.UTC)
+	_, err := db.Exec(`INSERT into guest(nricHash, eventID, name, tags, checkedIn, checkInTime) VALUES
+	('A1234', $1, 'Alice', '{"VIP"}', TRUE, $2), ('B1234', $1, 'Bob', '{}', TRUE, $3), ('C1234', $1, 'Carol', '{"VIP"}', FALSE, NULL)`,
+		event.ID, first.Add(time.Hour), first)
+	test.Ok(t, err)
+
+	//test arrivals are in order of check in, without guests who have not checked in
+	arrivals, err := gs.Arrivals(event.ID, checkin.TagFilter{})
+	test.Ok(t, err)
+	test.Equals(t, []checkin.Arrival{
+		{Time: first, Tags: []string{}},
+		{Time: first.Add(time.Hour), Tags: []string{"VIP"}},
+	}, arrivals)
+	arrivals, err = gs.Arrivals(event.ID, checkin.HasTag("vip"))
+	test.Ok(t, err)
+	test.Equals(t, 1, len(arrivals))
+	arrivals, err = gs.Arrivals("3820a980-a207-4738-b82b-45808fe7aba8", checkin.TagFilter{})
+	test.Ok(t, err)
+	test.Equals(t, []checkin.Arrival{}, arrivals)
+	test.Ok(t, es.DeleteEvent(event.ID))
+}
+
+func TestSyncGuests(t *testing.T) {
+	var hm mock.HashMethod
+	var dm mock.DigestMethod
+	hm.HashAndSaltFn = hashFnGenerator(nil)
+	compare := compareHashAndPasswordGenerator()
+	comparisons := 0
+	hm.CompareHashAndPasswordFn = func(hash string, pwd string) bool {
+		comparisons++
+		return compare(hash, pwd)
+	}
+	dm.DigestFn = digestFnGenerator(nil)
+	gs := postgres.GuestService{DB: db, HM: &hm, DM: &dm, HashCache: make(map[string]string)}
+
+	event := checkin.Event{ID: uuid.New().String(), Name: "Sync"}
+	es := postgres.EventService{DB: db}
+	test.Ok(t, es.CreateEvent(event, "TestUser"))
+	checkInTime := time.Date(2019, 4, 10, 9, 0, 0, 0, time.UTC)
+	_, err := db.Exec(`INSERT into guest(nricHash, nricDigest, eventID, name, tags, checkedIn, checkInTime) VALUES
+	('A1234', NULL, $1, 'Alice', '{"VIP"}', TRUE, $2), ('B1234', 'digest1234B', $1, 'Bob', '{}', TRUE, $2),
+	('C1234', NULL, $1, 'Carol', '{}', FALSE, NULL)`, event.ID, checkInTime)
+	test.Ok(t, err)
+	guests := []checkin.Guest{
+		{NRIC: "1234a", Name: "Alice", Tags: []string{"vip"}},
+		{NRIC: "1234B", Name: "Robert", Tags: []string{"staff"}},
+		{NRIC: "9999Z", Name: "Zed"},
+	}
+	expected := checkin.GuestSync{
+		Added:     []checkin.Guest{{NRIC: "9999Z", Name: "Zed", Tags: []string{}}},
+		Updated:   []checkin.GuestUpdate{{NRIC: "1234B", Name: "Robert", Tags: []string{"STAFF"}, OldName: "Bob", OldTags: []string{}}},
+		Removed:   []checkin.GuestRecord{{Name: "Carol", Tags: []string{}}},
+		Unchanged: 1,
+		DryRun:    true,
+	}
+
+	//test dry runs work out the changes without making them
+	sync, err := gs.SyncGuests(event.ID, guests, checkin.SyncOptions{RemoveMissing: true, DryRun: true})
+	test.Ok(t, err)
+	test.Equals(t, expected, sync)
+	test.Equals(t, 2, comparisons) //Alice is not compared against again once she is matched
+	names, err := gs.Guests(event.ID, checkin.TagFilter{})
+	test.Ok(t, err)
+	test.Equals(t, 3, len(names))
+	exists, err := gs.GuestExists(event.ID, "9999Z")
+	test.Ok(t, err)
+	test.Equals(t, false, exists)
+
+	//test guests are added and updated, keeping their check in status, and missing guests are kept by default
+	sync, err = gs.SyncGuests(event.ID, guests, checkin.SyncOptions{})
+	test.Ok(t, err)
+	expected.Removed, expected.DryRun = []checkin.GuestRecord{}, false
+	test.Equals(t, expected, sync)
+	records, _, err := gs.GuestRecords(event.ID, checkin.TagFilter{}, checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, []checkin.GuestRecord{
+		{Name: "Alice", Tags: []string{"VIP"}, CheckedIn: true, CheckInTime: null.TimeFrom(checkInTime)},
+		{Name: "Carol", Tags: []string{}},
+		{Name: "Robert", Tags: []string{"STAFF"}, CheckedIn: true, CheckInTime: null.TimeFrom(checkInTime)},
+		{Name: "Zed", Tags: []string{}},
+	}, records)
+	var nricDigest string
+	err = db.QueryRow("SELECT nricDigest from guest where eventID = $1 and nricHash = $2", event.ID, "A1234").Scan(&nricDigest)
+	test.Ok(t, err)
+	test.Equals(t, "digest1234A", nricDigest)
+
+	//test syncing again changes nothing, and removes missing guests if asked to
+	sync, err = gs.SyncGuests(event.ID, guests, checkin.SyncOptions{RemoveMissing: true})
+	test.Ok(t, err)
+	test.Equals(t, 0, len(sync.Added)+len(sync.Updated))
+	test.Equals(t, 3, sync.Unchanged)
+	test.Equals(t, []checkin.GuestRecord{{Name: "Carol", Tags: []string{}}}, sync.Removed)
+	exists, err = gs.GuestExists(event.ID, "1234C")
+	test.Ok(t, err)
+	test.Equals(t, false, exists)
+
+	//test NRICs listed more than once, and events that do not exist
+	_, err = gs.SyncGuests(event.ID, append(guests, checkin.Guest{NRIC: "9999z", Name: "Zee"}), checkin.SyncOptions{})
+	test.Assert(t, err != nil, "No error thrown when an NRIC is listed more than once")
+	_, err = gs.SyncGuests("not-an-event", guests, checkin.SyncOptions{})
+	test.Assert(t, err != nil, "No error thrown when syncing guests of an event that does not exist")
+	test.Ok(t, es.DeleteEvent(event.ID))
+}
@@ -0,0 +1,57 @@
+package postgres
+
+import (
+	"checkin"
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//listingTx opens a read only transaction to read the total count and a page of a listing from the same snapshot,
+//so that rows added or removed in between cannot make them disagree. Roll it back once the listing is read
+func listingTx(db *sqlx.DB) (*sqlx.Tx, error) {
+	tx, err := db.BeginTxx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, errors.New("Error opening transaction: " + err.Error())
+	}
+	return tx, nil
+}
+
+//orderAndPaginate builds the ORDER BY, LIMIT and OFFSET clauses of a listing query from its options
+//sortColumns maps the sort keys the listing supports to the columns they sort by, and defaultKey is
+//used if no sort key is given. tieBreaker should be unique, so that the order (and hence pages) are stable
+//Rows with a null sort column are always placed last
+func orderAndPaginate(opts checkin.ListOptions, sortColumns map[string]string, defaultKey string, tieBreaker string) (string, error) {
+	sortKey := opts.SortBy
+	if sortKey == "" {
+		sortKey = defaultKey
+	}
+	column, ok := sortColumns[sortKey]
+	if !ok {
+		return "", errors.New("Cannot sort listing by " + sortKey)
+	}
+	direction := " ASC"
+	if opts.Descending {
+		direction = " DESC"
+	}
+
+	clauses := " ORDER BY " + column + direction + " NULLS LAST, " + tieBreaker + direction
+	if opts.Limit > 0 {
+		clauses += " LIMIT " + strconv.Itoa(opts.Limit)
+	}
+	if opts.Offset > 0 {
+		clauses += " OFFSET " + strconv.Itoa(opts.Offset)
+	}
+	return clauses, nil
+}
+
+//searchPattern converts a name prefix into a pattern for use with ILIKE
+//escaping any wildcards in the prefix itself. An empty prefix matches everything
+func searchPattern(prefix string) string {
+	escaper := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return escaper.Replace(prefix) + "%"
+}
@@ -0,0 +1,207 @@
+package postgres
+
+import (
+	"checkin"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/guregu/null"
+	"github.com/jmoiron/sqlx"
+)
+
+//LoginAttemptService is a postgres implementation of checkin.LoginAttemptService
+//Needs to be supplied with a database connection, and the lockout policies for usernames and IP addresses
+type LoginAttemptService struct {
+	DB             *sqlx.DB
+	UsernamePolicy checkin.LockoutPolicy
+	IPPolicy       checkin.LockoutPolicy
+}
+
+//lockoutSortColumns maps the sort keys lockouts can be listed by to their columns
+var lockoutSortColumns = map[string]string{
+	checkin.SortByTime:           "lastFailedAt",
+	checkin.SortByFailedAttempts: "failedAttempts",
+}
+
+//LockedUntil returns the time until which logins for the username or from the IP address are locked out
+//Returns null if neither is locked out
+func (las *LoginAttemptService) LockedUntil(username string, ipAddress string) (null.Time, error) {
+	var lockedUntil null.Time
+	err := las.DB.QueryRow(`SELECT max(lockedUntil) from loginlockout where lockedUntil > $1
+	and ((kind = $2 and key = $3) or (kind = $4 and key = $5))`, time.Now().In(time.UTC),
+		checkin.LockoutByUsername, username, checkin.LockoutByIP, ipAddress).Scan(&lockedUntil)
+	if err != nil {
+		return null.Time{}, errors.New("Error checking for lockout: " + err.Error())
+	}
+	if lockedUntil.Valid {
+		lockedUntil.Time = lockedUntil.Time.In(time.UTC)
+	}
+	return lockedUntil, nil
+}
+
+//RecordFailure records the failed attempt for auditing, and counts it against its username and IP address,
+//locking them out if their policy says so
+func (las *LoginAttemptService) RecordFailure(failure checkin.LoginFailure) error {
+	tx, err := las.DB.Beginx()
+	if err != nil {
+		return errors.New("Error opening transaction: " + err.Error())
+	}
+	now := time.Now().In(time.UTC)
+	_, err = tx.Exec("INSERT into loginfailure (username, isAdmin, ipAddress, userAgent, time) VALUES ($1, $2, $3, $4, $5)",
+		failure.Username, failure.IsAdmin, failure.IPAddress, failure.UserAgent, now)
+	if err != nil {
+		tx.Rollback()
+		return errors.New("Error recording failed login: " + err.Error())
+	}
+	err = countFailure(tx, checkin.LockoutByUsername, failure.Username, las.UsernamePolicy, now)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = countFailure(tx, checkin.LockoutByIP, failure.IPAddress, las.IPPolicy, now)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("Error committing failed login: " + err.Error())
+	}
+	return nil
+}
+
+//countFailure adds a failed attempt to the count of the given kind and key within the transaction,
+//starting the count afresh if the last failed attempt was long enough ago, then locks it out as the policy decides
+func countFailure(tx *sqlx.Tx, kind string, key string, policy checkin.LockoutPolicy, now time.Time) error {
+	var failedAttempts int
+	err := tx.QueryRow(`INSERT into loginlockout (kind, key, failedAttempts, lastFailedAt) VALUES ($1, $2, 1, $3)
+	ON CONFLICT (kind, key) DO UPDATE SET lastFailedAt = $3, failedAttempts =
+	CASE WHEN loginlockout.lastFailedAt < $4 THEN 1 ELSE loginlockout.failedAttempts + 1 END
+	RETURNING failedAttempts`, kind, key, now, now.Add(-policy.ResetAfter)).Scan(&failedAttempts)
+	if err != nil {
+		return errors.New("Error counting failed login: " + err.Error())
+	}
+	if lockout := policy.LockoutDuration(failedAttempts); lockout > 0 {
+		_, err = tx.Exec("UPDATE loginlockout SET lockedUntil = $1 where kind = $2 and key = $3", now.Add(lockout), kind, key)
+		if err != nil {
+			return errors.New("Error locking out logins: " + err.Error())
+		}
+	}
+	return nil
+}
+
+//ClearFailures forgets the failed attempts of the username, after it logs in successfully
+func (las *LoginAttemptService) ClearFailures(username string) error {
+	return las.ResetLockout(checkin.LockoutByUsername, username)
+}
+
+//Lockouts returns a page of the failed attempt counts, sorted by time of the last failed attempt (the default)
+//or number of failed attempts and searched by key prefix, along with the total number of counts
+func (las *LoginAttemptService) Lockouts(opts checkin.ListOptions) ([]checkin.Lockout, int, error) {
+	clauses, err := orderAndPaginate(opts, lockoutSortColumns, checkin.SortByTime, "kind, key")
+	if err != nil {
+		return nil, 0, err
+	}
+	pattern := searchPattern(opts.Search)
+
+	tx, err := listingTx(las.DB)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	var total int
+	err = tx.QueryRow("SELECT count(*) from loginlockout where key ILIKE $1", pattern).Scan(&total)
+	if err != nil {
+		return nil, 0, errors.New("Cannot fetch number of lockouts: " + err.Error())
+	}
+	rows, err := tx.Query("SELECT kind, key, failedAttempts, lastFailedAt, lockedUntil from loginlockout where key ILIKE $1"+clauses, pattern)
+	if err != nil {
+		return nil, 0, errors.New("Cannot fetch lockouts: " + err.Error())
+	}
+	defer rows.Close()
+
+	lockouts, err := las.scanRowsIntoLockouts(rows, opts.PageSize(total))
+	if err != nil {
+		return nil, 0, err
+	}
+	return lockouts, total, nil
+}
+
+//ResetLockout forgets the failed attempts of the given kind and key, lifting any lockout
+//No error thrown if there are no failed attempts to forget
+func (las *LoginAttemptService) ResetLockout(kind string, key string) error {
+	_, err := las.DB.Exec("DELETE from loginlockout where kind = $1 and key = $2", kind, key)
+	if err != nil {
+		return errors.New("Error resetting lockout: " + err.Error())
+	}
+	return nil
+}
+
+//LoginFailures returns a page of the failed attempts to log in to the username, sorted by time,
+//along with the total number of failed attempts
+func (las *LoginAttemptService) LoginFailures(username string, opts checkin.ListOptions) ([]checkin.LoginFailure, int, error) {
+	clauses, err := orderAndPaginate(opts, map[string]string{checkin.SortByTime: "time"}, checkin.SortByTime, "ID")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tx, err := listingTx(las.DB)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	var total int
+	err = tx.QueryRow("SELECT count(*) from loginfailure where username = $1", username).Scan(&total)
+	if err != nil {
+		return nil, 0, errors.New("Cannot fetch number of failed logins: " + err.Error())
+	}
+	rows, err := tx.Query("SELECT ID, username, isAdmin, ipAddress, userAgent, time from loginfailure where username = $1"+clauses, username)
+	if err != nil {
+		return nil, 0, errors.New("Cannot fetch failed logins: " + err.Error())
+	}
+	defer rows.Close()
+
+	failures, err := las.scanRowsIntoLoginFailures(rows, opts.PageSize(total))
+	if err != nil {
+		return nil, 0, err
+	}
+	return failures, total, nil
+}
+
+func (las *LoginAttemptService) scanRowsIntoLockouts(rows *sql.Rows, rowCount int) ([]checkin.Lockout, error) {
+	lockouts := make([]checkin.Lockout, 0, rowCount)
+
+	for thereAreMore := rows.Next(); thereAreMore; thereAreMore = rows.Next() {
+		var lockout checkin.Lockout
+		err := rows.Scan(&lockout.Kind, &lockout.Key, &lockout.FailedAttempts, &lockout.LastFailedAt, &lockout.LockedUntil)
+		if err != nil {
+			return nil, errors.New("Could not extract lockout: " + err.Error())
+		}
+		lockout.LastFailedAt = lockout.LastFailedAt.In(time.UTC) //make sure all times are in UTC
+		if lockout.LockedUntil.Valid {
+			lockout.LockedUntil.Time = lockout.LockedUntil.Time.In(time.UTC)
+		}
+		lockouts = append(lockouts, lockout)
+	}
+
+	return lockouts, nil
+}
+
+func (las *LoginAttemptService) scanRowsIntoLoginFailures(rows *sql.Rows, rowCount int) ([]checkin.LoginFailure, error) {
+	failures := make([]checkin.LoginFailure, 0, rowCount)
+
+	for thereAreMore := rows.Next(); thereAreMore; thereAreMore = rows.Next() {
+		var failure checkin.LoginFailure
+		err := rows.Scan(&failure.ID, &failure.Username, &failure.IsAdmin, &failure.IPAddress, &failure.UserAgent, &failure.Time)
+		if err != nil {
+			return nil, errors.New("Could not extract failed login: " + err.Error())
+		}
+		failure.Time = failure.Time.In(time.UTC) //make sure all times are in UTC
+		failures = append(failures, failure)
+	}
+
+	return failures, nil
+}
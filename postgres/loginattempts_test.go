@@ -0,0 +1,80 @@
+package postgres_test
+
+import (
+	"checkin"
+	"checkin/postgres"
+	"checkin/test"
+	"testing"
+	"time"
+)
+
+func TestLoginAttempts(t *testing.T) {
+	las := postgres.LoginAttemptService{DB: db,
+		UsernamePolicy: checkin.LockoutPolicy{Threshold: 2, BaseLockout: time.Minute, MaxLockout: time.Hour, ResetAfter: time.Hour},
+		IPPolicy:       checkin.LockoutPolicy{Threshold: 3, BaseLockout: time.Minute, MaxLockout: time.Hour, ResetAfter: time.Hour}}
+
+	//test not locked out before any failures
+	lockedUntil, err := las.LockedUntil("ME5Bob", "198.51.100.2")
+	test.Ok(t, err)
+	test.Equals(t, false, lockedUntil.Valid)
+
+	//test username locked out once it reaches its threshold
+	failure := checkin.LoginFailure{Username: "ME5Bob", IPAddress: "198.51.100.2", UserAgent: "Bot/1.0"}
+	err = las.RecordFailure(failure)
+	test.Ok(t, err)
+	lockedUntil, err = las.LockedUntil("ME5Bob", "198.51.100.2")
+	test.Ok(t, err)
+	test.Equals(t, false, lockedUntil.Valid)
+	err = las.RecordFailure(failure)
+	test.Ok(t, err)
+	lockedUntil, err = las.LockedUntil("ME5Bob", "192.0.2.1")
+	test.Ok(t, err)
+	test.Assert(t, lockedUntil.Valid && lockedUntil.Time.After(time.Now().Add(50*time.Second)),
+		"Expected username to be locked out for a minute")
+
+	//test IP address locked out once it reaches its threshold, even for other usernames
+	err = las.RecordFailure(checkin.LoginFailure{Username: "ME6Alice", IPAddress: "198.51.100.2", IsAdmin: true})
+	test.Ok(t, err)
+	lockedUntil, err = las.LockedUntil("TestUser", "198.51.100.2")
+	test.Ok(t, err)
+	test.Equals(t, true, lockedUntil.Valid)
+
+	//test listing the counts
+	lockouts, total, err := las.Lockouts(checkin.ListOptions{SortBy: checkin.SortByFailedAttempts, Descending: true})
+	test.Ok(t, err)
+	test.Equals(t, 3, total)
+	test.Equals(t, checkin.Lockout{Kind: checkin.LockoutByIP, Key: "198.51.100.2", FailedAttempts: 3,
+		LastFailedAt: lockouts[0].LastFailedAt, LockedUntil: lockouts[0].LockedUntil}, lockouts[0])
+	test.Equals(t, "ME5Bob", lockouts[1].Key)
+	test.Equals(t, 2, lockouts[1].FailedAttempts)
+	lockouts, total, err = las.Lockouts(checkin.ListOptions{Search: "me6"})
+	test.Ok(t, err)
+	test.Equals(t, 1, total)
+	test.Equals(t, false, lockouts[0].LockedUntil.Valid)
+
+	//test audit entries
+	failures, total, err := las.LoginFailures("ME5Bob", checkin.ListOptions{Descending: true, Limit: 1})
+	test.Ok(t, err)
+	test.Equals(t, 2, total)
+	test.Equals(t, 1, len(failures))
+	test.Equals(t, "198.51.100.2", failures[0].IPAddress)
+	test.Equals(t, "Bot/1.0", failures[0].UserAgent)
+
+	//test clearing and resetting lifts lockouts, but keeps audit entries
+	err = las.ClearFailures("ME5Bob")
+	test.Ok(t, err)
+	lockedUntil, err = las.LockedUntil("ME5Bob", "192.0.2.1")
+	test.Ok(t, err)
+	test.Equals(t, false, lockedUntil.Valid)
+	err = las.ResetLockout(checkin.LockoutByIP, "198.51.100.2")
+	test.Ok(t, err)
+	lockedUntil, err = las.LockedUntil("TestUser", "198.51.100.2")
+	test.Ok(t, err)
+	test.Equals(t, false, lockedUntil.Valid)
+	_, total, err = las.LoginFailures("ME5Bob", checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, 2, total)
+
+	err = las.ResetLockout(checkin.LockoutByUsername, "nobody") //no error if nothing to reset
+	test.Ok(t, err)
+}
@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"checkin"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+)
+
+//Sessions returns the sessions of the event, in order of their start (sessions without one last), then name
+//No error thrown if event does not exist - just gives empty array, so check existence before calling method
+func (es *EventService) Sessions(eventID string) ([]checkin.Session, error) {
+	return querySessions(es.DB, eventID)
+}
+
+//querySessions fetches the sessions of the event in the order they are listed in, for both the EventService
+//and the GuestService
+func querySessions(db *sqlx.DB, eventID string) ([]checkin.Session, error) {
+	rows, err := db.Query(`SELECT ID, eventID, name, "start", "end" from session where eventID = $1
+	ORDER BY "start" NULLS LAST, name, ID`, eventID)
+	if err != nil {
+		return nil, errors.New("Cannot fetch sessions: " + err.Error())
+	}
+	defer rows.Close()
+
+	sessions := make([]checkin.Session, 0)
+	for rows.Next() {
+		var s checkin.Session
+		err = rows.Scan(&s.ID, &s.EventID, &s.Name, &s.Start, &s.End)
+		if err != nil {
+			return nil, errors.New("Could not extract session: " + err.Error())
+		}
+		sessions = append(sessions, normalizeSession(s))
+	}
+	return sessions, nil
+}
+
+//Session fetches the session of the event with the given ID, and whether it exists
+func (es *EventService) Session(eventID string, sessionID string) (checkin.Session, bool, error) {
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return checkin.Session{}, false, nil //as the query would fail on an ID which is not a UUID
+	}
+	var s checkin.Session
+	err := es.DB.QueryRow(`SELECT ID, eventID, name, "start", "end" from session where ID = $1 and eventID = $2`,
+		sessionID, eventID).Scan(&s.ID, &s.EventID, &s.Name, &s.Start, &s.End)
+	if err == sql.ErrNoRows {
+		return checkin.Session{}, false, nil
+	} else if err != nil {
+		return checkin.Session{}, false, errors.New("Error fetching session: " + err.Error())
+	}
+	return normalizeSession(s), true, nil
+}
+
+//CreateSession saves the session, with the ID it is given, as part of its event
+func (es *EventService) CreateSession(s checkin.Session) error {
+	s = normalizeSession(s)
+	_, err := es.DB.Exec(`INSERT into session(ID, eventID, name, "start", "end") VALUES ($1, $2, $3, $4, $5)`,
+		s.ID, s.EventID, s.Name, s.Start, s.End)
+	if err != nil {
+		return errors.New("Error creating session: " + err.Error())
+	}
+	return nil
+}
+
+//UpdateSession replaces the name, start and end of the session with those given
+//Returns an error if the event has no session with its ID
+func (es *EventService) UpdateSession(s checkin.Session) error {
+	s = normalizeSession(s)
+	res, err := es.DB.Exec(`UPDATE session SET name = $1, "start" = $2, "end" = $3 where ID = $4 and eventID = $5`,
+		s.Name, s.Start, s.End, s.ID, s.EventID)
+	if err != nil {
+		return errors.New("Error updating session: " + err.Error())
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return errors.New("No session of that event with that ID")
+	}
+	return nil
+}
+
+//DeleteSession deletes the session of the event with the given ID, along with the attendance of guests at it
+//Entries of the attendance log about it are kept
+//Returns false if the event has no such session
+func (es *EventService) DeleteSession(eventID string, sessionID string) (bool, error) {
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return false, nil
+	}
+	res, err := es.DB.Exec("DELETE from session where ID = $1 and eventID = $2", sessionID, eventID)
+	if err != nil {
+		return false, errors.New("Error deleting session: " + err.Error())
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.New("Error checking if rows were affected: " + err.Error())
+	}
+	return rows > 0, nil
+}
+
+//normalizeSession makes sure the start and end of the session are in UTC
+func normalizeSession(s checkin.Session) checkin.Session {
+	if s.Start.Valid {
+		s.Start.Time = s.Start.Time.In(time.UTC)
+	}
+	if s.End.Valid {
+		s.End.Time = s.End.Time.In(time.UTC)
+	}
+	return s
+}
@@ -0,0 +1,194 @@
+package postgres_test
+
+import (
+	"checkin"
+	"checkin/mock"
+	"checkin/postgres"
+	"checkin/test"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+)
+
+func TestSessions(t *testing.T) {
+	es := postgres.EventService{DB: db}
+	event := checkin.Event{
+		ID:    uuid.New().String(),
+		Name:  "Leadership Course",
+		Start: null.TimeFrom(time.Date(2019, 3, 1, 9, 0, 0, 0, time.UTC)),
+	}
+	test.Ok(t, es.CreateEvent(event, "TestUser"))
+	day1 := checkin.Session{ID: uuid.New().String(), EventID: event.ID, Name: "Day 1",
+		Start: null.TimeFrom(time.Date(2019, 3, 1, 9, 0, 0, 0, time.UTC)), End: null.TimeFrom(time.Date(2019, 3, 1, 17, 0, 0, 0, time.UTC))}
+	day2 := checkin.Session{ID: uuid.New().String(), EventID: event.ID, Name: "Day 2",
+		Start: null.TimeFrom(time.Date(2019, 3, 2, 9, 0, 0, 0, time.UTC))}
+	dinner := checkin.Session{ID: uuid.New().String(), EventID: event.ID, Name: "Dinner"}
+
+	//test creating sessions, listed in order of their start, with those without one last
+	test.Ok(t, es.CreateSession(dinner))
+	test.Ok(t, es.CreateSession(day2))
+	test.Ok(t, es.CreateSession(day1))
+	sessions, err := es.Sessions(event.ID)
+	test.Ok(t, err)
+	test.Equals(t, []checkin.Session{day1, day2, dinner}, sessions)
+	fetched, exists, err := es.Session(event.ID, day1.ID)
+	test.Ok(t, err)
+	test.Equals(t, true, exists)
+	test.Equals(t, day1, fetched)
+
+	//test sessions of other events, and invalid IDs, do not exist
+	_, exists, err = es.Session("aa19239f-f9f5-4935-b1f7-0edfdceabba7", day1.ID)
+	test.Ok(t, err)
+	test.Equals(t, false, exists)
+	_, exists, err = es.Session(event.ID, "1")
+	test.Ok(t, err)
+	test.Equals(t, false, exists)
+
+	//test updating sessions
+	day2.Name, day2.End = "Second day", null.TimeFrom(time.Date(2019, 3, 2, 12, 0, 0, 0, time.UTC))
+	test.Ok(t, es.UpdateSession(day2))
+	fetched, _, err = es.Session(event.ID, day2.ID)
+	test.Ok(t, err)
+	test.Equals(t, day2, fetched)
+	err = es.UpdateSession(checkin.Session{ID: day2.ID, EventID: "aa19239f-f9f5-4935-b1f7-0edfdceabba7", Name: "Day 2"})
+	test.Assert(t, err != nil, "No error updating session of another event")
+
+	//test cloning the event copies its sessions, moved along with its start
+	clone := event.Copy(checkin.CloneOptions{Start: null.TimeFrom(time.Date(2019, 4, 1, 9, 0, 0, 0, time.UTC))})
+	clone.ID = uuid.New().String()
+	test.Ok(t, es.CloneEvent(event.ID, clone, "TestUser", false))
+	sessions, err = es.Sessions(clone.ID)
+	test.Ok(t, err)
+	test.Equals(t, 3, len(sessions))
+	test.Assert(t, sessions[0].ID != day1.ID, "Session not copied with a new ID")
+	test.Equals(t, clone.ID, sessions[0].EventID)
+	test.Equals(t, "Day 1", sessions[0].Name)
+	test.Equals(t, null.TimeFrom(time.Date(2019, 4, 1, 9, 0, 0, 0, time.UTC)), sessions[0].Start)
+	test.Equals(t, null.TimeFrom(time.Date(2019, 4, 1, 17, 0, 0, 0, time.UTC)), sessions[0].End)
+	test.Equals(t, null.Time{}, sessions[2].Start)
+	test.Ok(t, es.DeleteEvent(clone.ID))
+
+	//test deleting sessions
+	deleted, err := es.DeleteSession(event.ID, dinner.ID)
+	test.Ok(t, err)
+	test.Equals(t, true, deleted)
+	deleted, err = es.DeleteSession(event.ID, dinner.ID)
+	test.Ok(t, err)
+	test.Equals(t, false, deleted)
+	deleted, err = es.DeleteSession(event.ID, "1")
+	test.Ok(t, err)
+	test.Equals(t, false, deleted)
+	sessions, err = es.Sessions(event.ID)
+	test.Ok(t, err)
+	test.Equals(t, []checkin.Session{day1, day2}, sessions)
+
+	//test sessions are deleted with their event
+	test.Ok(t, es.DeleteEvent(event.ID))
+	sessions, err = es.Sessions(event.ID)
+	test.Ok(t, err)
+	test.Equals(t, []checkin.Session{}, sessions)
+}
+
+func TestSessionAttendance(t *testing.T) {
+	var hm mock.HashMethod
+	hm.CompareHashAndPasswordFn = compareHashAndPasswordGenerator()
+	es := postgres.EventService{DB: db}
+	gs := postgres.GuestService{HM: &hm, DB: db, HashCache: make(map[string]string)}
+	als := postgres.AttendanceLogService{DB: db}
+	source := checkin.AttendanceSource{Actor: checkin.ActorSelf}
+
+	event := checkin.Event{ID: uuid.New().String(), Name: "Leadership Course"}
+	test.Ok(t, es.CreateEvent(event, "TestUser"))
+	_, err := db.Exec(`INSERT into guest(nricHash, eventID, name, tags) VALUES
+	('A1234', $1, 'Alice', '{"VIP"}'), ('B1234', $1, 'Bob', '{}'), ('C1234', $1, 'Carol', '{"VIP"}')`, event.ID)
+	test.Ok(t, err)
+	day1 := checkin.Session{ID: uuid.New().String(), EventID: event.ID, Name: "Day 1",
+		Start: null.TimeFrom(time.Date(2019, 3, 1, 9, 0, 0, 0, time.UTC))}
+	day2 := checkin.Session{ID: uuid.New().String(), EventID: event.ID, Name: "Day 2",
+		Start: null.TimeFrom(time.Date(2019, 3, 2, 9, 0, 0, 0, time.UTC))}
+	test.Ok(t, es.CreateSession(day1))
+	test.Ok(t, es.CreateSession(day2))
+
+	//test checking in to sessions does not check guests in to the event as a whole
+	name, err := gs.CheckIn(event.ID, day1.ID, "1234A", source)
+	test.Ok(t, err)
+	test.Equals(t, "Alice", name)
+	_, err = gs.CheckIn(event.ID, day1.ID, "1234A", source) //already checked in
+	test.Ok(t, err)
+	_, err = gs.CheckIn(event.ID, day1.ID, "1234B", source)
+	test.Ok(t, err)
+	_, err = gs.CheckIn(event.ID, day2.ID, "1234C", source)
+	test.Ok(t, err)
+	names, err := gs.GuestsCheckedIn(event.ID, checkin.TagFilter{})
+	test.Ok(t, err)
+	test.Equals(t, 0, len(names))
+
+	//test stats of sessions, filtered by tags
+	stats, err := gs.CheckInStats(event.ID, day1.ID, checkin.TagFilter{})
+	test.Ok(t, err)
+	test.Equals(t, checkin.GuestStats{TotalGuests: 3, CheckedIn: 2, PercentCheckedIn: 2.0 / 3}, stats)
+	stats, err = gs.CheckInStats(event.ID, day1.ID, checkin.HasAllTags([]string{"vip"}))
+	test.Ok(t, err)
+	test.Equals(t, checkin.GuestStats{TotalGuests: 2, CheckedIn: 1, PercentCheckedIn: 0.5}, stats)
+	stats, err = gs.CheckInStats(event.ID, "", checkin.TagFilter{})
+	test.Ok(t, err)
+	test.Equals(t, 0, stats.CheckedIn)
+	tagStats, err := gs.TagStats(event.ID, day1.ID, checkin.TagFilter{})
+	test.Ok(t, err)
+	test.Equals(t, []checkin.TagStats{
+		{Tag: "VIP", GuestStats: checkin.GuestStats{TotalGuests: 2, CheckedIn: 1, PercentCheckedIn: 0.5}},
+		{Tag: "", GuestStats: checkin.GuestStats{TotalGuests: 1, CheckedIn: 1, PercentCheckedIn: 1}},
+	}, tagStats)
+
+	//test marking absent from a session
+	name, err = gs.MarkAbsent(event.ID, day1.ID, "1234B", source)
+	test.Ok(t, err)
+	test.Equals(t, "Bob", name)
+	stats, err = gs.CheckInStats(event.ID, day1.ID, checkin.TagFilter{})
+	test.Ok(t, err)
+	test.Equals(t, 1, stats.CheckedIn)
+
+	//test attendance matrix, in order of guest name
+	matrix, err := gs.AttendanceMatrix(event.ID, checkin.TagFilter{})
+	test.Ok(t, err)
+	test.Equals(t, []checkin.Session{day1, day2}, matrix.Sessions)
+	test.Equals(t, []checkin.SessionAttendance{
+		{Name: "Alice", Present: []bool{true, false}},
+		{Name: "Bob", Present: []bool{false, false}},
+		{Name: "Carol", Present: []bool{false, true}},
+	}, matrix.Guests)
+	matrix, err = gs.AttendanceMatrix(event.ID, checkin.HasAllTags([]string{"VIP"}))
+	test.Ok(t, err)
+	test.Equals(t, 2, len(matrix.Guests))
+
+	//test the attendance log records the session of each change
+	entries, total, err := als.AttendanceLog(event.ID, checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, 5, total)
+	test.Equals(t, null.StringFrom(day1.ID), entries[0].SessionID)
+	test.Equals(t, null.StringFrom(day2.ID), entries[3].SessionID)
+	_, err = gs.CheckIn(event.ID, "", "1234A", source)
+	test.Ok(t, err)
+	entries, _, err = als.AttendanceLog(event.ID, checkin.ListOptions{Descending: true, Limit: 1})
+	test.Ok(t, err)
+	test.Equals(t, null.String{}, entries[0].SessionID)
+
+	//test sessions of other events, and invalid session IDs
+	_, err = gs.CheckIn(event.ID, uuid.New().String(), "1234A", source)
+	test.Assert(t, err != nil, "No error checking in to session which does not exist")
+	_, err = gs.MarkAbsent(event.ID, "1", "1234A", source)
+	test.Assert(t, err != nil, "No error marking absent from invalid session")
+	stats, err = gs.CheckInStats(event.ID, "1", checkin.TagFilter{})
+	test.Ok(t, err)
+	test.Equals(t, 0, stats.CheckedIn)
+
+	//test deleting a session removes attendance at it
+	_, err = es.DeleteSession(event.ID, day2.ID)
+	test.Ok(t, err)
+	matrix, err = gs.AttendanceMatrix(event.ID, checkin.TagFilter{})
+	test.Ok(t, err)
+	test.Equals(t, []bool{false}, matrix.Guests[2].Present)
+	test.Ok(t, es.DeleteEvent(event.ID))
+}
@@ -0,0 +1,70 @@
+package postgres
+
+import (
+	"checkin"
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//GuestSiteService is a postgres implementation of checkin.GuestSiteService
+//Needs to be supplied with a database connection
+//The guest site is stored as JSON, one per event
+type GuestSiteService struct {
+	DB *sqlx.DB
+}
+
+//GuestSite fetches the guest website of the event with the given ID
+//Returns an empty GuestSite (with empty, not nil, details and buttons) and no error
+//if the event does not have a website stored yet
+//Does not check if the event exists, so check existence before calling method
+func (ss *GuestSiteService) GuestSite(eventID string) (checkin.GuestSite, error) {
+	var siteJSON []byte
+	err := ss.DB.QueryRow("SELECT site from guestsite where eventID = $1", eventID).Scan(&siteJSON)
+	if err == sql.ErrNoRows {
+		return emptyGuestSite(), nil
+	} else if err != nil {
+		return checkin.GuestSite{}, errors.New("Error fetching guest site: " + err.Error())
+	}
+
+	var site checkin.GuestSite
+	err = json.Unmarshal(siteJSON, &site)
+	if err != nil {
+		return checkin.GuestSite{}, errors.New("Error unmarshalling guest site from JSON: " + err.Error())
+	}
+	if site.Details == nil { //no nils allowed
+		site.Details = []checkin.DetailsElement{}
+	}
+	if site.Buttons == nil {
+		site.Buttons = []checkin.ButtonRow{}
+	}
+
+	return site, nil
+}
+
+//UpdateGuestSite overwrites the guest website of the event with the given ID
+//with the site provided, creating it if the event did not have one yet
+//Returns an error if the event does not exist
+func (ss *GuestSiteService) UpdateGuestSite(eventID string, site checkin.GuestSite) error {
+	siteJSON, err := json.Marshal(site)
+	if err != nil {
+		return errors.New("Error marshalling guest site into JSON: " + err.Error())
+	}
+
+	_, err = ss.DB.Exec("INSERT INTO guestsite(eventID, site) VALUES ($1, $2) ON CONFLICT (eventID) DO UPDATE "+
+		"SET site = EXCLUDED.site, updatedAt = (NOW() at time zone 'utc')", eventID, siteJSON)
+	if err != nil {
+		return errors.New("Error updating guest site: " + err.Error())
+	}
+
+	return nil
+}
+
+func emptyGuestSite() checkin.GuestSite {
+	return checkin.GuestSite{
+		Details: []checkin.DetailsElement{},
+		Buttons: []checkin.ButtonRow{},
+	}
+}
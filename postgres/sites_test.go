@@ -0,0 +1,68 @@
+package postgres_test
+
+import (
+	"checkin"
+	"checkin/postgres"
+	"checkin/test"
+	"testing"
+)
+
+func TestGuestSite(t *testing.T) {
+	ss := postgres.GuestSiteService{DB: db}
+
+	//test normal functionality
+	site, err := ss.GuestSite("aa19239f-f9f5-4935-b1f7-0edfdceabba7")
+	test.Ok(t, err)
+	test.Equals(t, checkin.GuestSite{
+		Title:   checkin.TextElement{Content: "DSD Talk", Size: 5},
+		Tagline: checkin.TextElement{Content: "Data for all", Size: 2},
+		LogoURL: "https://logo.com/dsd.png",
+		Details: []checkin.DetailsElement{{Title: "Venue", Content: "KC3"}},
+		Buttons: []checkin.ButtonRow{{{Size: 1, Title: "Map", Type: checkin.Link, Content: "https://maps.google.com"}}},
+	}, site)
+
+	//test event with no site yet
+	site, err = ss.GuestSite("2c59b54d-3422-4bdb-824c-4125775b44c8")
+	test.Ok(t, err)
+	test.Equals(t, checkin.GuestSite{Details: []checkin.DetailsElement{}, Buttons: []checkin.ButtonRow{}}, site)
+
+	//test invalid UUID
+	_, err = ss.GuestSite("1231")
+	test.Assert(t, err != nil, "No error thrown when fetching guest site with invalid UUID")
+}
+
+func TestUpdateGuestSite(t *testing.T) {
+	ss := postgres.GuestSiteService{DB: db}
+
+	//test creating a site for an event without one
+	newSite := checkin.GuestSite{
+		Title:   checkin.TextElement{Content: "Cohesion", Size: 7},
+		Details: []checkin.DetailsElement{{Title: "Dress", Content: "Smart casual"}},
+		Buttons: []checkin.ButtonRow{
+			{{Size: 1, Title: "Menu", Type: checkin.Modal, Content: "Chicken rice"}},
+			{{Size: 2, Title: "Map", Type: checkin.Link, Content: "https://maps.google.com"}},
+		},
+	}
+	err := ss.UpdateGuestSite("3820a980-a207-4738-b82b-45808fe7aba8", newSite)
+	test.Ok(t, err)
+	site, err := ss.GuestSite("3820a980-a207-4738-b82b-45808fe7aba8")
+	test.Ok(t, err)
+	test.Equals(t, newSite, site)
+
+	//test overwriting an existing site
+	newSite.Title.Content = "SDB Cohesion"
+	newSite.Details = nil
+	err = ss.UpdateGuestSite("3820a980-a207-4738-b82b-45808fe7aba8", newSite)
+	test.Ok(t, err)
+	site, err = ss.GuestSite("3820a980-a207-4738-b82b-45808fe7aba8")
+	test.Ok(t, err)
+	newSite.Details = []checkin.DetailsElement{}
+	test.Equals(t, newSite, site)
+
+	//test event does not exist
+	err = ss.UpdateGuestSite("810b6bf0-a29b-405b-82ee-e482924f8faa", newSite)
+	test.Assert(t, err != nil, "No error thrown when updating guest site of non-existent event")
+
+	_, err = db.Exec("DELETE from guestsite where eventID = $1", "3820a980-a207-4738-b82b-45808fe7aba8")
+	test.Ok(t, err)
+}
@@ -0,0 +1,290 @@
+package postgres
+
+import (
+	"checkin"
+	"database/sql"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+	"github.com/lib/pq"
+)
+
+//syncedGuest is a guest of an event as stored, to be matched against a list of guests being synced
+type syncedGuest struct {
+	checkin.GuestRecord
+	nricHash   string
+	nricDigest sql.NullString
+}
+
+//guestSyncPlan is a GuestSync along with what is needed to make its changes to the database
+type guestSyncPlan struct {
+	checkin.GuestSync
+	updated []string          //nricHashes of the guests in Updated, in order
+	removed []string          //nricHashes of the guests in Removed, in order
+	matched map[string]string //nricHashes of the listed guests already registered, by (upper case) NRIC
+	digests map[string]string //NRICs of the matched guests who have no digest yet, by nricHash
+}
+
+//SyncGuests makes the guests of the event the same as the list of guests, matching them by NRIC (without regard
+//to case). Listed guests not yet registered are added, and those registered have their names and tags updated,
+//keeping their check in status and attendance. Guests of the event who are not listed are only removed
+//if opts.RemoveMissing is set
+//Returns the changes, which are worked out but not made if opts.DryRun is set. Otherwise, either all of the
+//changes are made or (if there is an error) none of them
+//Returns an error if an NRIC is listed more than once, or the event does not exist
+func (gs *GuestService) SyncGuests(eventID string, guests []checkin.Guest, opts checkin.SyncOptions) (checkin.GuestSync, error) {
+	if _, err := uuid.Parse(eventID); err != nil {
+		return checkin.GuestSync{}, errors.New("Event does not exist: " + eventID)
+	}
+	tx, err := gs.DB.Begin()
+	if err != nil {
+		return checkin.GuestSync{}, errors.New("Error opening transaction: " + err.Error())
+	}
+	//the plan is worked out in the same transaction it is applied in, so the guests cannot change in between
+	plan, err := gs.planSync(tx, eventID, guests, opts)
+	if err != nil {
+		tx.Rollback()
+		return checkin.GuestSync{}, err
+	}
+	if opts.DryRun {
+		tx.Rollback()
+		return plan.GuestSync, nil
+	}
+	added, err := gs.applySync(tx, eventID, plan)
+	if err != nil {
+		tx.Rollback()
+		return checkin.GuestSync{}, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return checkin.GuestSync{}, errors.New("Error committing sync of guests: " + err.Error())
+	}
+
+	for nric, nricHash := range plan.matched {
+		gs.SetCache(eventID, nric, nricHash)
+	}
+	for nric, nricHash := range added {
+		gs.SetCache(eventID, nric, nricHash)
+	}
+	gs.deleteCachedHashes(plan.removed)
+	return plan.GuestSync, nil
+}
+
+//planSync matches the listed guests against the guests of the event, and works out the changes to sync them
+//The guests of the event are locked until the transaction ends
+func (gs *GuestService) planSync(tx *sql.Tx, eventID string, guests []checkin.Guest, opts checkin.SyncOptions) (guestSyncPlan, error) {
+	existing, err := gs.syncedGuests(tx, eventID)
+	if err != nil {
+		return guestSyncPlan{}, err
+	}
+	byHash, byDigest := make(map[string]syncedGuest), make(map[string]syncedGuest)
+	undigested := make([]checkin.Guest, 0) //to compare the hashes of, as they cannot be looked up by digest
+	for _, guest := range existing {
+		byHash[guest.nricHash] = guest
+		if guest.nricDigest.Valid {
+			byDigest[guest.nricDigest.String] = guest
+		} else {
+			undigested = append(undigested, checkin.Guest{Name: guest.Name, NRIC: guest.nricHash})
+		}
+	}
+
+	plan := guestSyncPlan{
+		GuestSync: checkin.GuestSync{
+			Added:   make([]checkin.Guest, 0),
+			Updated: make([]checkin.GuestUpdate, 0),
+			Removed: make([]checkin.GuestRecord, 0),
+			DryRun:  opts.DryRun,
+		},
+		updated: make([]string, 0),
+		removed: make([]string, 0),
+		matched: make(map[string]string),
+		digests: make(map[string]string),
+	}
+	listed := make(map[string]bool)
+	for _, guest := range guests {
+		nric := strings.ToUpper(guest.NRIC)
+		if listed[nric] {
+			return guestSyncPlan{}, errors.New("NRIC listed more than once: " + guest.NRIC)
+		}
+		listed[nric] = true
+		tags := gs.guestTags(guest.Tags)
+
+		nricDigest, err := gs.digest(nric)
+		if err != nil {
+			return guestSyncPlan{}, errors.New("Error computing NRIC digest: " + err.Error())
+		}
+		match, ok := byDigest[nricDigest.String]
+		if (!nricDigest.Valid || !ok) && len(undigested) > 0 {
+			match, ok = byHash[gs.findGuest(nric, undigested).NRIC]
+			if ok {
+				//matched guests are no longer compared against, so each guest is only hashed until it is found
+				undigested = withoutHash(undigested, match.nricHash)
+				if nricDigest.Valid {
+					plan.digests[match.nricHash] = nric
+				}
+			}
+		}
+		if !ok {
+			plan.Added = append(plan.Added, checkin.Guest{Name: guest.Name, NRIC: guest.NRIC, Tags: tags})
+			continue
+		}
+
+		plan.matched[nric] = match.nricHash
+		if match.Name == guest.Name && sameTags(match.Tags, tags) {
+			plan.Unchanged++
+			continue
+		}
+		plan.Updated = append(plan.Updated, checkin.GuestUpdate{
+			NRIC:    guest.NRIC,
+			Name:    guest.Name,
+			Tags:    tags,
+			OldName: match.Name,
+			OldTags: match.Tags,
+		})
+		plan.updated = append(plan.updated, match.nricHash)
+	}
+
+	if opts.RemoveMissing {
+		matched := make(map[string]bool)
+		for _, nricHash := range plan.matched {
+			matched[nricHash] = true
+		}
+		for _, guest := range existing {
+			if !matched[guest.nricHash] {
+				plan.Removed = append(plan.Removed, guest.GuestRecord)
+				plan.removed = append(plan.removed, guest.nricHash)
+			}
+		}
+	}
+	return plan, nil
+}
+
+//applySync makes the changes of the plan as part of the transaction
+//Returns the nricHashes of the guests added, by NRIC
+func (gs *GuestService) applySync(tx *sql.Tx, eventID string, plan guestSyncPlan) (map[string]string, error) {
+	added := make(map[string]string)
+	for _, guest := range plan.Added {
+		nricHash, err := gs.HM.HashAndSalt(strings.ToUpper(guest.NRIC))
+		if err != nil {
+			return nil, errors.New("Error hashing NRIC: " + err.Error())
+		}
+		nricDigest, err := gs.digest(guest.NRIC)
+		if err != nil {
+			return nil, errors.New("Error computing NRIC digest: " + err.Error())
+		}
+		_, err = tx.Exec("INSERT into guest(nricHash, nricDigest, eventID, name, tags, checkedIn) VALUES($1, $2, $3, $4, $5, FALSE)",
+			nricHash, nricDigest, eventID, guest.Name, pq.Array(guest.Tags))
+		if err != nil {
+			return nil, errors.New("Error adding one of the guests: " + err.Error())
+		}
+		added[guest.NRIC] = nricHash
+	}
+
+	for i, update := range plan.Updated {
+		_, err := tx.Exec("UPDATE guest SET name = $1, tags = $2 where eventID = $3 and nricHash = $4",
+			update.Name, pq.Array(update.Tags), eventID, plan.updated[i])
+		if err != nil {
+			return nil, errors.New("Error updating one of the guests: " + err.Error())
+		}
+	}
+
+	for nricHash, nric := range plan.digests {
+		nricDigest, err := gs.digest(nric)
+		if err != nil {
+			return nil, errors.New("Error computing NRIC digest: " + err.Error())
+		}
+		_, err = tx.Exec("UPDATE guest SET nricDigest = $1 where eventID = $2 and nricHash = $3", nricDigest, eventID, nricHash)
+		if err != nil {
+			return nil, errors.New("Error filling in digest of guest: " + err.Error())
+		}
+	}
+
+	if len(plan.removed) > 0 {
+		_, err := tx.Exec("DELETE from guest where eventID = $1 and nricHash = ANY($2)", eventID, pq.Array(plan.removed))
+		if err != nil {
+			return nil, errors.New("Error removing guests: " + err.Error())
+		}
+	}
+	return added, nil
+}
+
+//syncedGuests fetches and locks every guest of the event in order of name, with their check in time left null
+//if they are not checked in
+func (gs *GuestService) syncedGuests(tx *sql.Tx, eventID string) ([]syncedGuest, error) {
+	rows, err := tx.Query(`SELECT nricHash, nricDigest, name, tags, checkedIn, checkInTime from guest
+	where eventID = $1 ORDER BY name, nricHash FOR UPDATE`, eventID)
+	if err != nil {
+		return nil, errors.New("Cannot fetch guests to sync: " + err.Error())
+	}
+	defer rows.Close()
+
+	guests := make([]syncedGuest, 0)
+	for rows.Next() {
+		var guest syncedGuest
+		err = rows.Scan(&guest.nricHash, &guest.nricDigest, &guest.Name, pq.Array(&guest.Tags), &guest.CheckedIn,
+			&guest.CheckInTime)
+		if err != nil {
+			return nil, errors.New("Could not extract guest to sync: " + err.Error())
+		}
+		if guest.Tags == nil {
+			guest.Tags = []string{} //no nils allowed
+		}
+		if guest.CheckedIn {
+			guest.CheckInTime.Time = guest.CheckInTime.Time.In(time.UTC)
+		} else {
+			guest.CheckInTime = null.Time{}
+		}
+		guests = append(guests, guest)
+	}
+	return guests, nil
+}
+
+//deleteCachedHashes removes every NRIC cached with one of the nricHashes, e.g. of guests who were removed
+//without knowing their NRICs
+func (gs *GuestService) deleteCachedHashes(nricHashes []string) {
+	if len(nricHashes) == 0 {
+		return
+	}
+	removed := make(map[string]bool)
+	for _, nricHash := range nricHashes {
+		removed[nricHash] = true
+	}
+	gs.cacheLock.Lock()
+	defer gs.cacheLock.Unlock()
+	for key, nricHash := range gs.HashCache {
+		if removed[nricHash] {
+			delete(gs.HashCache, key)
+		}
+	}
+}
+
+//withoutHash removes the guest whose NRIC is the nricHash from the guests, which are not kept in order
+func withoutHash(guests []checkin.Guest, nricHash string) []checkin.Guest {
+	for i, guest := range guests {
+		if guest.NRIC == nricHash {
+			guests[i] = guests[len(guests)-1]
+			return guests[:len(guests)-1]
+		}
+	}
+	return guests
+}
+
+//sameTags checks if both lists have the same tags, in any order
+func sameTags(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sortedA, sortedB := append([]string{}, a...), append([]string{}, b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+	for i := range sortedA {
+		if sortedA[i] != sortedB[i] {
+			return false
+		}
+	}
+	return true
+}
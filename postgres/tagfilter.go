@@ -0,0 +1,29 @@
+package postgres
+
+import (
+	"checkin"
+	"strconv"
+	"strings"
+)
+
+//tagCondition compiles the tag filter into a condition on the tags column given, for the where clause of a query
+//The tags tested for are appended to args as parameters, numbered after those already in args, so are never
+//part of the SQL itself. The returned args are those to run the query with
+//An empty filter compiles to TRUE, matching every guest
+func tagCondition(filter checkin.TagFilter, column string, args []interface{}) (string, []interface{}) {
+	switch filter.Op {
+	case checkin.TagOpHas:
+		args = append(args, strings.ToUpper(filter.Tag))
+		return "$" + strconv.Itoa(len(args)) + " = ANY(" + column + ")", args
+	case checkin.TagOpAnd, checkin.TagOpOr:
+		conditions := make([]string, len(filter.Operands))
+		for i, operand := range filter.Operands {
+			conditions[i], args = tagCondition(operand, column, args)
+		}
+		return "(" + strings.Join(conditions, " "+strings.ToUpper(filter.Op)+" ") + ")", args
+	case checkin.TagOpNot:
+		condition, args := tagCondition(filter.Operands[0], column, args)
+		return "NOT (" + condition + ")", args
+	}
+	return "TRUE", args
+}
@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"checkin"
+	"errors"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+//TagStats returns the attendance statistics of the guests of the event with each of its tags, in order of tag,
+//followed by those of the guests without any tags
+//Only guests matching the tag filter are counted, though every tag of the event is listed
+//If a sessionID is given, the guests counted as checked in are those who attended that session
+//No error thrown if event (or session) does not exist - just gives empty stats, so check existence before calling method
+func (gs *GuestService) TagStats(eventID string, sessionID string, filter checkin.TagFilter) ([]checkin.TagStats, error) {
+	untagged := checkin.TagStats{Tag: ""}
+	if _, err := uuid.Parse(eventID); err != nil {
+		return []checkin.TagStats{untagged}, nil
+	}
+	attended, args := attendedCondition(sessionID, []interface{}{eventID})
+	matches, args := tagCondition(filter, "g.tags", args)
+	rows, err := gs.DB.Query(`SELECT tag, count(*) FILTER (WHERE `+matches+`),
+	count(*) FILTER (WHERE `+matches+` and `+attended+`) from guest g
+	left join lateral unnest(g.tags) as tag on TRUE where g.eventID = $1 GROUP BY tag ORDER BY tag NULLS LAST`, args...)
+	if err != nil {
+		return nil, errors.New("Cannot fetch statistics of tags: " + err.Error())
+	}
+	defer rows.Close()
+
+	stats := make([]checkin.TagStats, 0)
+	for rows.Next() {
+		var tag *string
+		var total, checkedIn int
+		err = rows.Scan(&tag, &total, &checkedIn)
+		if err != nil {
+			return nil, errors.New("Could not extract statistics of tag: " + err.Error())
+		}
+		if tag == nil {
+			untagged.GuestStats = newGuestStats(total, checkedIn)
+		} else {
+			stats = append(stats, checkin.TagStats{Tag: *tag, GuestStats: newGuestStats(total, checkedIn)})
+		}
+	}
+	return append(stats, untagged), nil
+}
+
+//TagCrossStats returns the attendance statistics of the guests of the event with each pair of a tag of the rows
+//and a tag of the columns, which are compared without regard to case
+//Only guests matching the tag filter are counted
+//If a sessionID is given, the guests counted as checked in are those who attended that session
+//No error thrown if event (or session) does not exist - just gives empty stats, so check existence before calling method
+func (gs *GuestService) TagCrossStats(eventID string, sessionID string, rowTags []string, columnTags []string,
+	filter checkin.TagFilter) (checkin.TagCrossStats, error) {
+	stats := checkin.TagCrossStats{
+		Rows:    gs.capitalizeTags(rowTags),
+		Columns: gs.capitalizeTags(columnTags),
+		Cells:   make([][]checkin.GuestStats, len(rowTags)),
+	}
+	for i := range stats.Cells {
+		stats.Cells[i] = make([]checkin.GuestStats, len(columnTags))
+	}
+	if _, err := uuid.Parse(eventID); err != nil {
+		return stats, nil
+	}
+
+	attended, args := attendedCondition(sessionID, []interface{}{eventID, pq.Array(stats.Rows), pq.Array(stats.Columns)})
+	matches, args := tagCondition(filter, "g.tags", args)
+	rows, err := gs.DB.Query(`SELECT r, c, count(*), count(*) FILTER (WHERE `+attended+`)
+	from guest g, unnest(g.tags) as r, unnest(g.tags) as c
+	where g.eventID = $1 and r = ANY($2) and c = ANY($3) and `+matches+` GROUP BY r, c`, args...)
+	if err != nil {
+		return checkin.TagCrossStats{}, errors.New("Cannot fetch cross tabulated statistics of tags: " + err.Error())
+	}
+	defer rows.Close()
+
+	cells := make(map[[2]string]checkin.GuestStats)
+	for rows.Next() {
+		var row, column string
+		var total, checkedIn int
+		err = rows.Scan(&row, &column, &total, &checkedIn)
+		if err != nil {
+			return checkin.TagCrossStats{}, errors.New("Could not extract statistics of tags: " + err.Error())
+		}
+		cells[[2]string{row, column}] = newGuestStats(total, checkedIn)
+	}
+	for i, row := range stats.Rows {
+		for j, column := range stats.Columns {
+			stats.Cells[i][j] = cells[[2]string{row, column}]
+		}
+	}
+	return stats, nil
+}
+
+//attendedCondition returns the condition for a guest g to count as checked in: checking in to the event,
+//or attending the session if one is given. The sessionID is appended to args as a parameter if needed
+func attendedCondition(sessionID string, args []interface{}) (string, []interface{}) {
+	if sessionID == "" {
+		return "g.checkedIn", args
+	}
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return "FALSE", args //no such session, so nobody attended it
+	}
+	args = append(args, sessionID)
+	return `EXISTS (SELECT 1 from sessionattendance a where a.eventID = g.eventID and a.nricHash = g.nricHash
+	and a.sessionID = $` + strconv.Itoa(len(args)) + ")", args
+}
+
+//newGuestStats returns the statistics of the given number of guests, of whom checkedIn checked in
+func newGuestStats(total int, checkedIn int) checkin.GuestStats {
+	var percent float64
+	if total != 0 {
+		percent = float64(checkedIn) / float64(total)
+	}
+	return checkin.GuestStats{
+		TotalGuests:      total,
+		CheckedIn:        checkedIn,
+		PercentCheckedIn: percent,
+	}
+}
@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"checkin"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+//templateSortColumns maps the sort keys event templates can be listed by to their columns
+var templateSortColumns = map[string]string{
+	checkin.SortByName:      "name",
+	checkin.SortByCreatedAt: "createdAt",
+}
+
+//Templates returns a page of the event templates saved by the user, sorted by name (the default) or creation time
+//and searched by name prefix, along with the total number of their templates
+func (es *EventService) Templates(username string, opts checkin.ListOptions) ([]checkin.EventTemplate, int, error) {
+	clauses, err := orderAndPaginate(opts, templateSortColumns, checkin.SortByName, "ID")
+	if err != nil {
+		return nil, 0, err
+	}
+	pattern := searchPattern(opts.Search)
+
+	tx, err := listingTx(es.DB)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	var total int
+	err = tx.QueryRow("SELECT count(*) from eventtemplate where username = $1 and name ILIKE $2", username, pattern).Scan(&total)
+	if err != nil {
+		return nil, 0, errors.New("Cannot fetch number of templates: " + err.Error())
+	}
+	rows, err := tx.Query("SELECT ID, name, username, event, createdAt from eventtemplate where username = $1 and name ILIKE $2"+clauses,
+		username, pattern)
+	if err != nil {
+		return nil, 0, errors.New("Cannot fetch templates: " + err.Error())
+	}
+	defer rows.Close()
+
+	templates, err := es.scanRowsIntoTemplates(rows, opts.PageSize(total))
+	if err != nil {
+		return nil, 0, err
+	}
+	return templates, total, nil
+}
+
+//Template fetches the event template with the given ID, and whether it exists
+func (es *EventService) Template(ID string) (checkin.EventTemplate, bool, error) {
+	row := es.DB.QueryRow("SELECT ID, name, username, event, createdAt from eventtemplate where ID = $1", ID)
+	t, err := scanTemplate(row)
+	if err == sql.ErrNoRows {
+		return checkin.EventTemplate{}, false, nil
+	} else if err != nil {
+		return checkin.EventTemplate{}, false, errors.New("Error fetching template: " + err.Error())
+	}
+	return t, true, nil
+}
+
+//CreateTemplate saves the event template, with the ID it is given
+//The event of the template is stored as JSON
+func (es *EventService) CreateTemplate(t checkin.EventTemplate) error {
+	eventJSON, err := json.Marshal(t.Event)
+	if err != nil {
+		return errors.New("Error marshalling event of template into JSON: " + err.Error())
+	}
+	_, err = es.DB.Exec("INSERT into eventtemplate(ID, name, username, event) VALUES ($1, $2, $3, $4)",
+		t.ID, t.Name, t.Username, eventJSON)
+	if err != nil {
+		return errors.New("Error creating template: " + err.Error())
+	}
+	return nil
+}
+
+//DeleteTemplate deletes the event template with the given ID
+//Returns an error if no such template exists
+func (es *EventService) DeleteTemplate(ID string) error {
+	res, err := es.DB.Exec("DELETE from eventtemplate where ID = $1", ID)
+	if err != nil {
+		return errors.New("Error deleting template: " + err.Error())
+	}
+	if rows, _ := res.RowsAffected(); rows == 0 {
+		return errors.New("No template with that ID")
+	}
+	return nil
+}
+
+func (es *EventService) scanRowsIntoTemplates(rows *sql.Rows, rowCount int) ([]checkin.EventTemplate, error) {
+	templates := make([]checkin.EventTemplate, 0, rowCount)
+
+	for thereAreMore := rows.Next(); thereAreMore; thereAreMore = rows.Next() {
+		t, err := scanTemplate(rows)
+		if err != nil {
+			return nil, errors.New("Could not extract template: " + err.Error())
+		}
+		templates = append(templates, t)
+	}
+
+	return templates, nil
+}
+
+//scanTemplate scans a row of ID, name, username, event and createdAt into an event template
+func scanTemplate(row interface{ Scan(...interface{}) error }) (checkin.EventTemplate, error) {
+	var t checkin.EventTemplate
+	var eventJSON []byte
+	err := row.Scan(&t.ID, &t.Name, &t.Username, &eventJSON, &t.CreatedAt)
+	if err != nil {
+		return checkin.EventTemplate{}, err
+	}
+	err = json.Unmarshal(eventJSON, &t.Event)
+	if err != nil {
+		return checkin.EventTemplate{}, errors.New("Error unmarshalling event of template from JSON: " + err.Error())
+	}
+	t.CreatedAt = t.CreatedAt.In(time.UTC) //make sure all times are in UTC
+	if t.Event.TimeTags == nil {
+		t.Event.TimeTags = map[string]time.Time{} //no nils allowed
+	}
+	return t, nil
+}
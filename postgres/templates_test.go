@@ -0,0 +1,80 @@
+package postgres_test
+
+import (
+	"checkin"
+	"checkin/postgres"
+	"checkin/test"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+)
+
+func TestTemplates(t *testing.T) {
+	es := postgres.EventService{DB: db}
+	parade := checkin.EventTemplate{
+		ID:       uuid.New().String(),
+		Name:     "Parade",
+		Username: "TestUser",
+		Event: checkin.Event{
+			Name:     "Monthly Parade",
+			Start:    null.TimeFrom(time.Date(2019, 3, 4, 8, 0, 0, 0, time.UTC)),
+			TimeTags: map[string]time.Time{"release": time.Date(2019, 3, 1, 9, 0, 0, 0, time.UTC)},
+			Lat:      null.FloatFrom(1.335932),
+			Geofence: checkin.GeofenceFlag,
+		},
+	}
+	talk := checkin.EventTemplate{
+		ID:       uuid.New().String(),
+		Name:     "Department Talk",
+		Username: "TestUser",
+		Event:    checkin.Event{Name: "Talk"},
+	}
+
+	//test creating and fetching templates
+	test.Ok(t, es.CreateTemplate(parade))
+	test.Ok(t, es.CreateTemplate(talk))
+	fetched, exists, err := es.Template(parade.ID)
+	test.Ok(t, err)
+	test.Equals(t, true, exists)
+	test.Assert(t, time.Since(fetched.CreatedAt) < time.Minute, "Template not created just now")
+	parade.CreatedAt = fetched.CreatedAt
+	test.Equals(t, parade, fetched)
+	fetched, _, err = es.Template(talk.ID)
+	test.Ok(t, err)
+	test.Equals(t, map[string]time.Time{}, fetched.Event.TimeTags)
+
+	//test listing templates, sorted by name by default
+	templates, total, err := es.Templates("TestUser", checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, 2, total)
+	test.Equals(t, "Department Talk", templates[0].Name)
+	test.Equals(t, "Parade", templates[1].Name)
+
+	templates, total, err = es.Templates("TestUser", checkin.ListOptions{Search: "par"})
+	test.Ok(t, err)
+	test.Equals(t, 1, total)
+	test.Equals(t, parade, templates[0])
+
+	templates, total, err = es.Templates("ME5Bob", checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, 0, total)
+	test.Equals(t, []checkin.EventTemplate{}, templates)
+
+	_, _, err = es.Templates("TestUser", checkin.ListOptions{SortBy: checkin.SortByStart})
+	test.Assert(t, err != nil, "Expected error sorting templates by start")
+
+	//test template of a user who does not exist
+	err = es.CreateTemplate(checkin.EventTemplate{ID: uuid.New().String(), Name: "Parade", Username: "Notauser"})
+	test.Assert(t, err != nil, "No error when creating a template for a user who does not exist")
+
+	//test deleting templates
+	test.Ok(t, es.DeleteTemplate(parade.ID))
+	test.Ok(t, es.DeleteTemplate(talk.ID))
+	_, exists, err = es.Template(parade.ID)
+	test.Ok(t, err)
+	test.Equals(t, false, exists)
+	err = es.DeleteTemplate(parade.ID)
+	test.Assert(t, err != nil, "No error when deleting a template which does not exist")
+}
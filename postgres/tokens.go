@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"checkin"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//TokenService is a postgres implementation of checkin.TokenService
+//Needs to be supplied with a database connection
+//Refresh tokens are stored as digests, so the table cannot be used to log in if leaked
+type TokenService struct {
+	DB *sqlx.DB
+}
+
+//AddRefreshToken stores a refresh token issued to the given user or admin, valid until expiresAt
+//Also clears out refresh tokens which have expired
+func (ts *TokenService) AddRefreshToken(token string, au checkin.AuthorizationInfo, expiresAt time.Time) error {
+	_, err := ts.DB.Exec("DELETE from refreshtoken where expiresAt < (NOW() at time zone 'utc')")
+	if err != nil {
+		return errors.New("Error clearing expired refresh tokens: " + err.Error())
+	}
+	_, err = ts.DB.Exec("INSERT into refreshtoken (tokenDigest, username, isAdmin, issuedAt, expiresAt) VALUES ($1, $2, $3, (NOW() at time zone 'utc'), $4)",
+		tokenDigest(token), au.Username, au.IsAdmin, expiresAt.In(time.UTC))
+	if err != nil {
+		return errors.New("Error adding refresh token: " + err.Error())
+	}
+	return nil
+}
+
+//UseRefreshToken removes the refresh token so it cannot be used again, returning who it was issued to
+//Returns false if the token does not exist, has expired, or its user has been updated or deleted since it was issued
+func (ts *TokenService) UseRefreshToken(token string) (checkin.AuthorizationInfo, bool, error) {
+	var au checkin.AuthorizationInfo
+	var issuedAt, expiresAt time.Time
+	err := ts.DB.QueryRow("DELETE from refreshtoken where tokenDigest = $1 RETURNING username, isAdmin, issuedAt, expiresAt",
+		tokenDigest(token)).Scan(&au.Username, &au.IsAdmin, &issuedAt, &expiresAt)
+	if err == sql.ErrNoRows {
+		return checkin.AuthorizationInfo{}, false, nil
+	} else if err != nil {
+		return checkin.AuthorizationInfo{}, false, errors.New("Error using refresh token: " + err.Error())
+	}
+	if !expiresAt.After(time.Now().In(time.UTC)) {
+		return checkin.AuthorizationInfo{}, false, nil
+	}
+	ok, err := ts.unchangedSince(au, issuedAt)
+	if err != nil || !ok {
+		return checkin.AuthorizationInfo{}, false, err
+	}
+	return au, true, nil
+}
+
+//RemoveRefreshToken removes the refresh token, if it exists
+func (ts *TokenService) RemoveRefreshToken(token string) error {
+	_, err := ts.DB.Exec("DELETE from refreshtoken where tokenDigest = $1", tokenDigest(token))
+	if err != nil {
+		return errors.New("Error removing refresh token: " + err.Error())
+	}
+	return nil
+}
+
+//RevokeAccessToken revokes the access token with the given ID, which expires at expiresAt anyway
+//Also clears out revoked tokens which have since expired, as they are rejected regardless
+func (ts *TokenService) RevokeAccessToken(tokenID string, expiresAt time.Time) error {
+	_, err := ts.DB.Exec("DELETE from revokedtoken where expiresAt < (NOW() at time zone 'utc')")
+	if err != nil {
+		return errors.New("Error clearing expired revoked tokens: " + err.Error())
+	}
+	_, err = ts.DB.Exec("INSERT into revokedtoken (tokenID, expiresAt) VALUES ($1, $2) ON CONFLICT (tokenID) DO NOTHING",
+		tokenID, expiresAt.In(time.UTC))
+	if err != nil {
+		return errors.New("Error revoking access token: " + err.Error())
+	}
+	return nil
+}
+
+//AccessTokenValid returns false if the access token with the given ID has been revoked, or if the user
+//it was issued to has been updated or deleted since it was issued
+func (ts *TokenService) AccessTokenValid(tokenID string, au checkin.AuthorizationInfo, issuedAt time.Time) (bool, error) {
+	var revoked bool
+	err := ts.DB.QueryRow("SELECT EXISTS (SELECT 1 from revokedtoken where tokenID = $1)", tokenID).Scan(&revoked)
+	if err != nil {
+		return false, errors.New("Error checking for revoked token: " + err.Error())
+	}
+	if revoked {
+		return false, nil
+	}
+	return ts.unchangedSince(au, issuedAt)
+}
+
+//unchangedSince returns true if the user (or admin) still exists, and for users, has not been updated since the given time
+//Token issue times only have a precision of seconds, so updates are compared to the second
+func (ts *TokenService) unchangedSince(au checkin.AuthorizationInfo, t time.Time) (bool, error) {
+	var ok bool
+	var err error
+	if au.IsAdmin {
+		err = ts.DB.QueryRow("SELECT EXISTS (SELECT 1 from app_admin where username = $1)", au.Username).Scan(&ok)
+	} else {
+		err = ts.DB.QueryRow("SELECT EXISTS (SELECT 1 from app_user where username = $1 and date_trunc('second', updatedAt) <= $2)",
+			au.Username, t.In(time.UTC)).Scan(&ok)
+	}
+	if err != nil {
+		return false, errors.New("Error checking if user was updated: " + err.Error())
+	}
+	return ok, nil
+}
+
+//tokenDigest returns the digest a refresh token is stored as
+//Refresh tokens are long and random, so unlike passwords, do not need a slow or salted hash
+func tokenDigest(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,95 @@
+package postgres_test
+
+import (
+	"checkin"
+	"checkin/postgres"
+	"checkin/test"
+	"testing"
+	"time"
+)
+
+func TestRefreshTokens(t *testing.T) {
+	ts := postgres.TokenService{DB: db}
+	user := checkin.AuthorizationInfo{Username: "safosscholar", IsAdmin: false}
+	admin := checkin.AuthorizationInfo{Username: "Hackerman", IsAdmin: true}
+
+	//test normal behavior, tokens can only be used once
+	err := ts.AddRefreshToken("usertoken", user, time.Now().Add(time.Hour))
+	test.Ok(t, err)
+	err = ts.AddRefreshToken("admintoken", admin, time.Now().Add(time.Hour))
+	test.Ok(t, err)
+	au, ok, err := ts.UseRefreshToken("usertoken")
+	test.Ok(t, err)
+	test.Equals(t, true, ok)
+	test.Equals(t, user, au)
+	_, ok, err = ts.UseRefreshToken("usertoken")
+	test.Ok(t, err)
+	test.Equals(t, false, ok)
+	au, ok, err = ts.UseRefreshToken("admintoken")
+	test.Ok(t, err)
+	test.Equals(t, true, ok)
+	test.Equals(t, admin, au)
+
+	//test tokens which do not exist, have expired, or were removed
+	_, ok, err = ts.UseRefreshToken("nosuchtoken")
+	test.Ok(t, err)
+	test.Equals(t, false, ok)
+	err = ts.AddRefreshToken("expiredtoken", user, time.Now().Add(-time.Minute))
+	test.Ok(t, err)
+	_, ok, err = ts.UseRefreshToken("expiredtoken")
+	test.Ok(t, err)
+	test.Equals(t, false, ok)
+	err = ts.AddRefreshToken("removedtoken", user, time.Now().Add(time.Hour))
+	test.Ok(t, err)
+	err = ts.RemoveRefreshToken("removedtoken")
+	test.Ok(t, err)
+	_, ok, err = ts.UseRefreshToken("removedtoken")
+	test.Ok(t, err)
+	test.Equals(t, false, ok)
+
+	//test tokens of users who do not exist
+	err = ts.AddRefreshToken("ghosttoken", checkin.AuthorizationInfo{Username: "nobody"}, time.Now().Add(time.Hour))
+	test.Ok(t, err)
+	_, ok, err = ts.UseRefreshToken("ghosttoken")
+	test.Ok(t, err)
+	test.Equals(t, false, ok)
+}
+
+func TestAccessTokenValid(t *testing.T) {
+	ts := postgres.TokenService{DB: db}
+	user := checkin.AuthorizationInfo{Username: "AirForceMan", IsAdmin: false}
+	admin := checkin.AuthorizationInfo{Username: "Hackerman", IsAdmin: true}
+
+	//test normal behavior
+	ok, err := ts.AccessTokenValid("token1", user, time.Now())
+	test.Ok(t, err)
+	test.Equals(t, true, ok)
+	ok, err = ts.AccessTokenValid("token2", admin, time.Now())
+	test.Ok(t, err)
+	test.Equals(t, true, ok)
+
+	//test revoked token
+	err = ts.RevokeAccessToken("token1", time.Now().Add(time.Hour))
+	test.Ok(t, err)
+	err = ts.RevokeAccessToken("token1", time.Now().Add(time.Hour)) //revoking twice is fine
+	test.Ok(t, err)
+	ok, err = ts.AccessTokenValid("token1", user, time.Now())
+	test.Ok(t, err)
+	test.Equals(t, false, ok)
+
+	//test token issued before the user was last updated (2019-03-18 01:05:36 in the test data)
+	ok, err = ts.AccessTokenValid("token3", user, time.Date(2019, 3, 18, 1, 5, 35, 0, time.UTC))
+	test.Ok(t, err)
+	test.Equals(t, false, ok)
+	ok, err = ts.AccessTokenValid("token3", user, time.Date(2019, 3, 18, 1, 5, 36, 0, time.UTC))
+	test.Ok(t, err)
+	test.Equals(t, true, ok)
+
+	//test token of users and admins who do not exist
+	ok, err = ts.AccessTokenValid("token4", checkin.AuthorizationInfo{Username: "nobody"}, time.Now())
+	test.Ok(t, err)
+	test.Equals(t, false, ok)
+	ok, err = ts.AccessTokenValid("token4", checkin.AuthorizationInfo{Username: "AirForceMan", IsAdmin: true}, time.Now())
+	test.Ok(t, err)
+	test.Equals(t, false, ok)
+}
@@ -0,0 +1,244 @@
+package postgres
+
+import (
+	"checkin"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//TriggerService is a postgres implementation of checkin.TriggerService
+//Needs to be supplied with a database connection, and the policy failed deliveries are retried with
+type TriggerService struct {
+	DB          *sqlx.DB
+	RetryPolicy checkin.RetryPolicy
+}
+
+//deliverySortColumns maps the sort keys deliveries can be listed by to their columns
+var deliverySortColumns = map[string]string{
+	checkin.SortByTime: "d.triggerTime",
+}
+
+//deliveryColumns are the columns scanned into a delivery by scanDelivery
+const deliveryColumns = "d.ID, d.actionID, a.eventID, a.tag, d.triggerTime, d.status, d.attempts, d.nextAttempt, d.lastError, d.deliveredAt"
+
+//triggerTimeOf is the time the tag of the action is set to in its event, in UTC, or null if the event does not have the tag
+const triggerTimeOf = "((e.timetags->>a.tag)::timestamptz at time zone 'utc')"
+
+//Actions returns the actions of the event, oldest first, without their secrets
+//No error thrown if event does not exist - just gives empty array, so check existence before calling method
+func (ts *TriggerService) Actions(eventID string) ([]checkin.TriggerAction, error) {
+	rows, err := ts.DB.Query("SELECT ID, eventID, tag, type, url, message, createdAt from triggeraction where eventID = $1 ORDER BY createdAt, ID",
+		eventID)
+	if err != nil {
+		return nil, errors.New("Cannot fetch actions: " + err.Error())
+	}
+	defer rows.Close()
+
+	actions := make([]checkin.TriggerAction, 0)
+	for rows.Next() {
+		var a checkin.TriggerAction
+		err = rows.Scan(&a.ID, &a.EventID, &a.Trigger, &a.Type, &a.URL, &a.Message, &a.CreatedAt)
+		if err != nil {
+			return nil, errors.New("Could not extract action: " + err.Error())
+		}
+		a.CreatedAt = a.CreatedAt.In(time.UTC) //make sure all times are in UTC
+		actions = append(actions, a)
+	}
+	return actions, nil
+}
+
+//CreateAction saves the action, with the ID and secret it is given
+//Its trigger only fires it once it is next reached, even if the trigger has already passed
+func (ts *TriggerService) CreateAction(a checkin.TriggerAction) error {
+	_, err := ts.DB.Exec("INSERT into triggeraction (ID, eventID, tag, type, url, message, secret, createdAt) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)",
+		a.ID, a.EventID, a.Trigger, a.Type, a.URL, a.Message, a.Secret, time.Now().In(time.UTC))
+	if err != nil {
+		return errors.New("Error creating action: " + err.Error())
+	}
+	return nil
+}
+
+//DeleteAction deletes the action of the event with the given ID, along with its deliveries
+//Returns false if the event has no such action
+func (ts *TriggerService) DeleteAction(eventID string, actionID string) (bool, error) {
+	res, err := ts.DB.Exec("DELETE from triggeraction where ID = $1 and eventID = $2", actionID, eventID)
+	if err != nil {
+		return false, errors.New("Error deleting action: " + err.Error())
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return false, errors.New("Error checking if rows were affected: " + err.Error())
+	}
+	return rows > 0, nil
+}
+
+//Deliveries returns a page of the deliveries of the actions of the event, sorted by trigger time
+//and searched by trigger prefix, along with the total number of their deliveries
+//No error thrown if event does not exist - just gives empty array, so check existence before calling method
+func (ts *TriggerService) Deliveries(eventID string, opts checkin.ListOptions) ([]checkin.Delivery, int, error) {
+	clauses, err := orderAndPaginate(opts, deliverySortColumns, checkin.SortByTime, "d.ID")
+	if err != nil {
+		return nil, 0, err
+	}
+	pattern := searchPattern(opts.Search)
+
+	tx, err := listingTx(ts.DB)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	var total int
+	err = tx.QueryRow("SELECT count(*) from triggerdelivery d join triggeraction a on a.ID = d.actionID where a.eventID = $1 and a.tag ILIKE $2",
+		eventID, pattern).Scan(&total)
+	if err != nil {
+		return nil, 0, errors.New("Cannot fetch number of deliveries: " + err.Error())
+	}
+	rows, err := tx.Query("SELECT "+deliveryColumns+" from triggerdelivery d join triggeraction a on a.ID = d.actionID where a.eventID = $1 and a.tag ILIKE $2"+clauses,
+		eventID, pattern)
+	if err != nil {
+		return nil, 0, errors.New("Cannot fetch deliveries: " + err.Error())
+	}
+	defer rows.Close()
+
+	deliveries := make([]checkin.Delivery, 0, opts.PageSize(total))
+	for thereAreMore := rows.Next(); thereAreMore; thereAreMore = rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, 0, errors.New("Could not extract delivery: " + err.Error())
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, total, nil
+}
+
+//ScheduleDeliveries queues a delivery of every action whose trigger has elapsed since the action was created,
+//due straight away
+//The time each trigger is set to is part of its deliveries, so moving a trigger queues another delivery
+//once it elapses again, while calling this repeatedly or from several instances queues nothing twice
+//Actions of archived events are left out
+func (ts *TriggerService) ScheduleDeliveries() (int, error) {
+	now := time.Now().In(time.UTC)
+	res, err := ts.DB.Exec(`INSERT into triggerdelivery (actionID, triggerTime, nextAttempt)
+	SELECT a.ID, `+triggerTimeOf+`, $1 from triggeraction a join event e on e.ID = a.eventID
+	where `+triggerTimeOf+` > a.createdAt and `+triggerTimeOf+` <= $1 and e.archivedAt IS NULL
+	ON CONFLICT (actionID, triggerTime) DO NOTHING`, now)
+	if err != nil {
+		return 0, errors.New("Error scheduling deliveries: " + err.Error())
+	}
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.New("Error checking if rows were affected: " + err.Error())
+	}
+	return int(rows), nil
+}
+
+//ClaimDeliveries returns up to limit pending deliveries which are due, longest overdue first, with their actions
+//Their next attempt is pushed back by lease, so no other caller claims them while they are being attempted,
+//but they are attempted again if the attempt is never recorded. The next attempt returned is the time they are
+//claimed until, which their attempt is recorded with
+func (ts *TriggerService) ClaimDeliveries(limit int, lease time.Duration) ([]checkin.Delivery, error) {
+	now := time.Now().In(time.UTC)
+	rows, err := ts.DB.Query(`UPDATE triggerdelivery d SET nextAttempt = $2 from triggeraction a
+	where a.ID = d.actionID and d.ID in (SELECT ID from triggerdelivery where status = 'pending' and nextAttempt <= $1
+	ORDER BY nextAttempt LIMIT $3 FOR UPDATE SKIP LOCKED)
+	RETURNING `+deliveryColumns+`, a.type, a.url, a.message, a.secret, a.createdAt`, now, now.Add(lease), limit)
+	if err != nil {
+		return nil, errors.New("Error claiming deliveries: " + err.Error())
+	}
+	defer rows.Close()
+
+	deliveries := make([]checkin.Delivery, 0, limit)
+	for rows.Next() {
+		var d checkin.Delivery
+		var a checkin.TriggerAction
+		err = rows.Scan(&d.ID, &d.ActionID, &d.EventID, &d.Trigger, &d.TriggerTime, &d.Status, &d.Attempts, &d.NextAttempt,
+			&d.LastError, &d.DeliveredAt, &a.Type, &a.URL, &a.Message, &a.Secret, &a.CreatedAt)
+		if err != nil {
+			return nil, errors.New("Could not extract claimed delivery: " + err.Error())
+		}
+		normalizeDelivery(&d)
+		a.ID, a.EventID, a.Trigger, a.CreatedAt = d.ActionID, d.EventID, d.Trigger, a.CreatedAt.In(time.UTC)
+		d.Action = a
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+//RecordSuccess marks the delivery claimed until claimedUntil as delivered
+//Nothing is recorded if the lease passed and the delivery was claimed again, as the new claim records its attempt
+func (ts *TriggerService) RecordSuccess(deliveryID string, claimedUntil time.Time) error {
+	res, err := ts.DB.Exec(`UPDATE triggerdelivery SET status = $1, attempts = attempts + 1, nextAttempt = NULL, deliveredAt = $2
+	where ID = $3 and status = $4 and nextAttempt = $5`, checkin.DeliveryDelivered, time.Now().In(time.UTC), deliveryID,
+		checkin.DeliveryPending, claimedUntil.In(time.UTC))
+	if err != nil {
+		return errors.New("Error recording delivery: " + err.Error())
+	}
+	if rows, err := res.RowsAffected(); err != nil {
+		return errors.New("Error checking if rows were affected: " + err.Error())
+	} else if rows == 0 {
+		return errors.New("No pending delivery with that ID claimed until that time")
+	}
+	return nil
+}
+
+//RecordFailure records a failed attempt at the delivery, with the reason it failed, and schedules
+//the next attempt as the retry policy decides, or gives up on it
+//As with RecordSuccess, nothing is recorded if the delivery is no longer claimed until claimedUntil
+func (ts *TriggerService) RecordFailure(deliveryID string, claimedUntil time.Time, reason string) error {
+	tx, err := ts.DB.Beginx()
+	if err != nil {
+		return errors.New("Error opening transaction: " + err.Error())
+	}
+	var attempts int
+	err = tx.QueryRow(`UPDATE triggerdelivery SET attempts = attempts + 1, lastError = $1 where ID = $2 and status = $3
+	and nextAttempt = $4 RETURNING attempts`, reason, deliveryID, checkin.DeliveryPending, claimedUntil.In(time.UTC)).Scan(&attempts)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return errors.New("No pending delivery with that ID claimed until that time")
+	} else if err != nil {
+		tx.Rollback()
+		return errors.New("Error recording failed delivery: " + err.Error())
+	}
+
+	if delay, retry := ts.RetryPolicy.RetryDelay(attempts); retry {
+		_, err = tx.Exec("UPDATE triggerdelivery SET nextAttempt = $1 where ID = $2", time.Now().In(time.UTC).Add(delay), deliveryID)
+	} else {
+		_, err = tx.Exec("UPDATE triggerdelivery SET status = $1, nextAttempt = NULL where ID = $2", checkin.DeliveryFailed, deliveryID)
+	}
+	if err != nil {
+		tx.Rollback()
+		return errors.New("Error scheduling retry of delivery: " + err.Error())
+	}
+	err = tx.Commit()
+	if err != nil {
+		return errors.New("Error committing failed delivery: " + err.Error())
+	}
+	return nil
+}
+
+//scanDelivery scans a row of the deliveryColumns into a delivery
+func scanDelivery(rows *sql.Rows) (checkin.Delivery, error) {
+	var d checkin.Delivery
+	err := rows.Scan(&d.ID, &d.ActionID, &d.EventID, &d.Trigger, &d.TriggerTime, &d.Status, &d.Attempts, &d.NextAttempt,
+		&d.LastError, &d.DeliveredAt)
+	if err != nil {
+		return checkin.Delivery{}, err
+	}
+	normalizeDelivery(&d)
+	return d, nil
+}
+
+//normalizeDelivery makes sure all the times of the delivery are in UTC
+func normalizeDelivery(d *checkin.Delivery) {
+	d.TriggerTime = d.TriggerTime.In(time.UTC)
+	if d.NextAttempt.Valid {
+		d.NextAttempt.Time = d.NextAttempt.Time.In(time.UTC)
+	}
+	if d.DeliveredAt.Valid {
+		d.DeliveredAt.Time = d.DeliveredAt.Time.In(time.UTC)
+	}
+}
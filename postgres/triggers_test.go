@@ -0,0 +1,145 @@
+package postgres_test
+
+import (
+	"checkin"
+	"checkin/postgres"
+	"checkin/test"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/guregu/null"
+)
+
+func TestTriggerActions(t *testing.T) {
+	ts := postgres.TriggerService{DB: db}
+	eventID := "03293b3b-df83-407e-b836-fb7d4a3c4966"
+	webhook := checkin.TriggerAction{ID: uuid.New().String(), EventID: eventID, Trigger: "release", Type: checkin.ActionWebhook,
+		URL: null.StringFrom("https://example.com/hook"), Secret: "shh"}
+	broadcast := checkin.TriggerAction{ID: uuid.New().String(), EventID: eventID, Trigger: "formrelease", Type: checkin.ActionBroadcast,
+		Message: null.StringFrom("Feedback forms are open")}
+
+	//test creating and listing actions, without their secrets
+	test.Ok(t, ts.CreateAction(webhook))
+	test.Ok(t, ts.CreateAction(broadcast))
+	actions, err := ts.Actions(eventID)
+	test.Ok(t, err)
+	test.Equals(t, 2, len(actions))
+	test.Equals(t, webhook.ID, actions[0].ID)
+	test.Equals(t, "", actions[0].Secret)
+	test.Equals(t, webhook.URL, actions[0].URL)
+	test.Equals(t, broadcast.Message, actions[1].Message)
+	actions, err = ts.Actions("2c59b54d-3422-4bdb-824c-4125775b44c8")
+	test.Ok(t, err)
+	test.Equals(t, 0, len(actions))
+
+	//test deleting actions, only from their own event
+	exists, err := ts.DeleteAction("2c59b54d-3422-4bdb-824c-4125775b44c8", broadcast.ID)
+	test.Ok(t, err)
+	test.Equals(t, false, exists)
+	exists, err = ts.DeleteAction(eventID, broadcast.ID)
+	test.Ok(t, err)
+	test.Equals(t, true, exists)
+	exists, err = ts.DeleteAction(eventID, broadcast.ID)
+	test.Ok(t, err)
+	test.Equals(t, false, exists)
+	_, err = ts.DeleteAction(eventID, webhook.ID)
+	test.Ok(t, err)
+}
+
+func TestDeliveries(t *testing.T) {
+	ts := postgres.TriggerService{DB: db, RetryPolicy: checkin.RetryPolicy{MaxAttempts: 2}}
+	es := postgres.EventService{DB: db}
+	eventID := "03293b3b-df83-407e-b836-fb7d4a3c4966"
+	webhook := checkin.TriggerAction{ID: uuid.New().String(), EventID: eventID, Trigger: "release", Type: checkin.ActionWebhook,
+		URL: null.StringFrom("https://example.com/hook"), Secret: "shh"}
+
+	//test triggers which elapsed before the action was created do not fire it
+	test.Ok(t, es.SetTimeTag(eventID, "release", time.Now().Add(-time.Hour)))
+	test.Ok(t, ts.CreateAction(webhook))
+	queued, err := ts.ScheduleDeliveries()
+	test.Ok(t, err)
+	test.Equals(t, 0, queued)
+
+	//test an elapsed trigger queues a delivery once
+	test.Ok(t, es.SetTimeTag(eventID, "release", time.Now()))
+	queued, err = ts.ScheduleDeliveries()
+	test.Ok(t, err)
+	test.Equals(t, 1, queued)
+	queued, err = ts.ScheduleDeliveries()
+	test.Ok(t, err)
+	test.Equals(t, 0, queued)
+
+	//test claiming deliveries, which cannot be claimed again until the lease passes
+	claimed, err := ts.ClaimDeliveries(10, time.Hour)
+	test.Ok(t, err)
+	test.Equals(t, 1, len(claimed))
+	test.Equals(t, webhook.ID, claimed[0].ActionID)
+	test.Equals(t, eventID, claimed[0].EventID)
+	test.Equals(t, "release", claimed[0].Trigger)
+	test.Equals(t, "shh", claimed[0].Action.Secret)
+	test.Equals(t, webhook.URL, claimed[0].Action.URL)
+	again, err := ts.ClaimDeliveries(10, time.Hour)
+	test.Ok(t, err)
+	test.Equals(t, 0, len(again))
+
+	//test failed deliveries are retried, then given up on
+	_, err = db.Exec("UPDATE triggerdelivery SET nextAttempt = NOW() at time zone 'utc' where ID = $1", claimed[0].ID) //as if the lease passed
+	test.Ok(t, err)
+	claimed, err = ts.ClaimDeliveries(10, 0)
+	test.Ok(t, err)
+	test.Equals(t, 1, len(claimed))
+	test.Ok(t, ts.RecordFailure(claimed[0].ID, claimed[0].NextAttempt.Time, "Connection refused"))
+	claimed, err = ts.ClaimDeliveries(10, 0)
+	test.Ok(t, err)
+	test.Equals(t, 1, len(claimed))
+	test.Equals(t, 1, claimed[0].Attempts)
+	test.Equals(t, null.StringFrom("Connection refused"), claimed[0].LastError)
+	test.Ok(t, ts.RecordFailure(claimed[0].ID, claimed[0].NextAttempt.Time, "Status 500"))
+	claimed, err = ts.ClaimDeliveries(10, 0)
+	test.Ok(t, err)
+	test.Equals(t, 0, len(claimed))
+
+	//test moving the trigger queues another delivery, which succeeds
+	test.Ok(t, es.SetTimeTag(eventID, "release", time.Now()))
+	queued, err = ts.ScheduleDeliveries()
+	test.Ok(t, err)
+	test.Equals(t, 1, queued)
+	claimed, err = ts.ClaimDeliveries(10, time.Hour)
+	test.Ok(t, err)
+	test.Equals(t, 1, len(claimed))
+	claimedUntil := claimed[0].NextAttempt.Time
+	test.Assert(t, ts.RecordSuccess(claimed[0].ID, claimedUntil.Add(-time.Minute)) != nil,
+		"No error recording delivery under a lease which is no longer held")
+	test.Assert(t, ts.RecordFailure(claimed[0].ID, claimedUntil.Add(-time.Minute), "Late") != nil,
+		"No error recording failure of delivery under a lease which is no longer held")
+	test.Ok(t, ts.RecordSuccess(claimed[0].ID, claimedUntil))
+	test.Assert(t, ts.RecordSuccess(claimed[0].ID, claimedUntil) != nil, "No error recording delivery which is no longer pending")
+	test.Assert(t, ts.RecordFailure(claimed[0].ID, claimedUntil, "Late") != nil, "No error recording failure of delivery which is no longer pending")
+
+	//test listing deliveries
+	deliveries, total, err := ts.Deliveries(eventID, checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, 2, total)
+	test.Equals(t, checkin.DeliveryFailed, deliveries[0].Status)
+	test.Equals(t, 2, deliveries[0].Attempts)
+	test.Equals(t, null.StringFrom("Status 500"), deliveries[0].LastError)
+	test.Equals(t, false, deliveries[0].NextAttempt.Valid)
+	test.Equals(t, checkin.DeliveryDelivered, deliveries[1].Status)
+	test.Equals(t, true, deliveries[1].DeliveredAt.Valid)
+	deliveries, total, err = ts.Deliveries(eventID, checkin.ListOptions{Search: "form"})
+	test.Ok(t, err)
+	test.Equals(t, 0, total)
+	test.Equals(t, 0, len(deliveries))
+	_, _, err = ts.Deliveries(eventID, checkin.ListOptions{SortBy: checkin.SortByName})
+	test.Assert(t, err != nil, "No error when sorting deliveries by an invalid key")
+
+	//test deleting the action deletes its deliveries
+	exists, err := ts.DeleteAction(eventID, webhook.ID)
+	test.Ok(t, err)
+	test.Equals(t, true, exists)
+	_, total, err = ts.Deliveries(eventID, checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, 0, total)
+	test.Ok(t, es.RemoveTimeTag(eventID, "release"))
+}
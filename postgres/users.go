@@ -4,7 +4,9 @@ import (
 	"checkin"
 	"errors"
 	"log"
+	"time"
 
+	"github.com/guregu/null"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -25,19 +27,45 @@ func (us *UserService) User(username string) (checkin.User, error) {
 	return u, err
 }
 
-//Users Returns the details of all users
-func (us *UserService) Users() ([]checkin.User, error) {
-	rows, err := us.DB.Queryx("SELECT username, name, passwordHash, createdAt, updatedAt, lastLoggedIn from app_user")
+//userSortColumns maps the sort keys users can be listed by to their columns
+var userSortColumns = map[string]string{
+	checkin.SortByUsername:     "username",
+	checkin.SortByName:         "name",
+	checkin.SortByCreatedAt:    "createdAt",
+	checkin.SortByLastLoggedIn: "lastLoggedIn",
+}
+
+//Users Returns the details of a page of users, along with the total number of users across all pages
+//opts may sort by username (the default), name, createdAt or lastLoggedIn, and searches for
+//users whose username or name start with the given prefix
+func (us *UserService) Users(opts checkin.ListOptions) ([]checkin.User, int, error) {
+	clauses, err := orderAndPaginate(opts, userSortColumns, checkin.SortByUsername, "username")
 	if err != nil {
-		return nil, errors.New("Cannot fetch user details: " + err.Error())
+		return nil, 0, err
 	}
-	defer rows.Close() //make sure this is after checking for an error, or this will be a nil pointer dereference
-	numUsers, err := us.getNumberOfUsers()
+	pattern := searchPattern(opts.Search)
+	tx, err := listingTx(us.DB)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer tx.Rollback()
+
+	numUsers, err := us.getNumberOfUsers(tx, pattern)
+	if err != nil {
+		return nil, 0, errors.New("Cannot fetch number of users: " + err.Error())
+	}
+	rows, err := tx.Queryx("SELECT username, name, passwordHash, createdAt, updatedAt, lastLoggedIn from app_user where username ILIKE $1 or name ILIKE $1"+clauses,
+		pattern)
 	if err != nil {
-		return nil, errors.New("Cannot fetch number of users: " + err.Error())
+		return nil, 0, errors.New("Cannot fetch user details: " + err.Error())
 	}
+	defer rows.Close() //make sure this is after checking for an error, or this will be a nil pointer dereference
 
-	return us.scanRowsIntoUserDetails(rows, numUsers)
+	users, err := us.scanRowsIntoUserDetails(rows, opts.PageSize(numUsers))
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, numUsers, nil
 }
 
 //CreateUser Adds a user with the given username, password (will hash it) and name to the records
@@ -51,19 +79,50 @@ func (us *UserService) CreateUser(u checkin.User) error {
 	return err
 }
 
-//DeleteUser Deletes the records of the user with the given username
-func (us *UserService) DeleteUser(username string) error {
-	_, err := us.DB.Exec("DELETE from app_user where username = $1", username)
-	return err
+//DeleteUser Deletes the records of the user with the given username, along with their roles as hosts
+//Returns false without deleting the user if they own any events, as those would be left without an owner
+//(ownership has to be transferred first)
+func (us *UserService) DeleteUser(username string) (bool, error) {
+	tx, err := us.DB.Beginx()
+	if err != nil {
+		return false, errors.New("Error opening transaction: " + err.Error())
+	}
+	//locking the user keeps them from being made an owner until they are deleted
+	_, err = tx.Exec("SELECT username from app_user where username = $1 FOR UPDATE", username)
+	if err != nil {
+		tx.Rollback()
+		return false, errors.New("Error locking user: " + err.Error())
+	}
+	var owner bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 from hosts where username = $1 and role = $2)", username, checkin.RoleOwner).Scan(&owner)
+	if err != nil {
+		tx.Rollback()
+		return false, errors.New("Error checking if user owns events: " + err.Error())
+	} else if owner {
+		tx.Rollback()
+		return false, nil
+	}
+	_, err = tx.Exec("DELETE from app_user where username = $1", username)
+	if err != nil {
+		tx.Rollback()
+		return false, errors.New("Error deleting user: " + err.Error())
+	}
+	err = tx.Commit()
+	if err != nil {
+		return false, errors.New("Error committing deletion of user: " + err.Error())
+	}
+	return true, nil
 }
 
 //UpdateUser updates a particular user given their username, and a map of attributes to new values
-//Returns a boolean flag indicating if the arguments were valid
-//Returns a non-nil error if there was an error updating the user
-func (us *UserService) UpdateUser(originalUsername string, user checkin.User) error {
+//If ifUpdatedAt is given, the user is only updated if they were last updated then, so that
+//changes made since they were read are not overwritten
+//Returns a boolean flag indicating if the user was updated
+//Returns a non-nil error if there was an error updating the user, or no user with that username exists
+func (us *UserService) UpdateUser(originalUsername string, user checkin.User, ifUpdatedAt null.Time) (bool, error) {
 	tx, err := us.DB.Beginx()
 	if err != nil {
-		return errors.New("Error opening transaction:" + err.Error())
+		return false, errors.New("Error opening transaction:" + err.Error())
 	}
 
 	defer func() {
@@ -79,29 +138,43 @@ func (us *UserService) UpdateUser(originalUsername string, user checkin.User) er
 	if user.PasswordPlaintext != nil { //if a new password plain text is set
 		user.PasswordHash, err = us.HM.HashAndSalt(*user.PasswordPlaintext)
 		if err != nil {
-			return errors.New("Error hashing new password: " + err.Error())
+			tx.Rollback()
+			return false, errors.New("Error hashing new password: " + err.Error())
 		}
 	}
 
-	_, err = tx.Exec("UPDATE app_user SET username = $1, passwordHash = $2, "+
-		"name = $3 WHERE username = $4", user.Username, user.PasswordHash, user.Name, originalUsername)
+	res, err := tx.Exec("UPDATE app_user SET username = $1, passwordHash = $2, "+
+		"name = $3 WHERE username = $4 and (CAST($5 AS TIMESTAMP) IS NULL or updatedAt = $5)", user.Username, user.PasswordHash, user.Name,
+		originalUsername, null.NewTime(ifUpdatedAt.Time.In(time.UTC), ifUpdatedAt.Valid))
 	if err != nil {
 		tx.Rollback()
-		return errors.New("Error while updating database: " + err.Error())
+		return false, errors.New("Error while updating database: " + err.Error())
+	}
+	if rows, err := res.RowsAffected(); err != nil {
+		tx.Rollback()
+		return false, errors.New("Error checking if rows were affected: " + err.Error())
+	} else if rows == 0 {
+		tx.Rollback()
+		if exists, err := us.CheckIfExists(originalUsername); err != nil {
+			return false, errors.New("Error checking if user exists: " + err.Error())
+		} else if !exists {
+			return false, errors.New("No user exists with that username")
+		}
+		return false, nil //updated since ifUpdatedAt
 	}
 
 	_, err = tx.Exec("UPDATE app_user SET updatedAt = (NOW() at time zone 'utc') where username = $1", user.Username)
 	if err != nil {
 		tx.Rollback()
-		return errors.New("Error when updating updated field in app_user: " + err.Error())
+		return false, errors.New("Error when updating updated field in app_user: " + err.Error())
 	}
 
 	err = tx.Commit()
 	if err != nil {
-		return errors.New("Error committing changes to database: " + err.Error())
+		return false, errors.New("Error committing changes to database: " + err.Error())
 	}
 
-	return nil
+	return true, nil
 }
 
 //CheckIfExists sees if the username is already used
@@ -124,9 +197,10 @@ func (us *UserService) UpdateLastLoggedIn(username string) error {
 	return err
 }
 
-func (us *UserService) getNumberOfUsers() (int, error) {
+//getNumberOfUsers counts the users whose username or name match the given ILIKE pattern
+func (us *UserService) getNumberOfUsers(q sqlx.Queryer, pattern string) (int, error) {
 	var i int
-	err := us.DB.QueryRow("SELECT count(*) from app_user").Scan(&i)
+	err := q.QueryRowx("SELECT count(*) from app_user where username ILIKE $1 or name ILIKE $1", pattern).Scan(&i)
 
 	if err != nil {
 		return 0, errors.New("Cannot fetch user count: " + err.Error())
@@ -136,17 +210,15 @@ func (us *UserService) getNumberOfUsers() (int, error) {
 }
 
 func (us *UserService) scanRowsIntoUserDetails(rows *sqlx.Rows, rowCount int) ([]checkin.User, error) {
-	users := make([]checkin.User, rowCount)
+	users := make([]checkin.User, 0, rowCount)
 
-	index := 0
 	for ok := rows.Next(); ok; ok = rows.Next() {
 		var u checkin.User
 		err := rows.StructScan(&u)
 		if err != nil {
 			return nil, errors.New("Could not extract user details: " + err.Error())
 		}
-		users[index] = u
-		index++
+		users = append(users, u)
 	}
 
 	return users, nil
@@ -12,6 +12,49 @@ import (
 	"github.com/guregu/null"
 )
 
+func TestUsers(t *testing.T) {
+	us := postgres.UserService{DB: db}
+
+	users, total, err := us.Users(checkin.ListOptions{})
+	test.Ok(t, err)
+	test.Equals(t, total, len(users))
+	test.Assert(t, total >= 5, "Expected at least the 5 users in the test data")
+
+	//test case insensitive search on username prefix, default sort by username
+	users, total, err = us.Users(checkin.ListOptions{Search: "me"})
+	test.Ok(t, err)
+	test.Equals(t, 2, total)
+	test.Equals(t, "ME5Bob", users[0].Username)
+	test.Equals(t, "ME6Alice", users[1].Username)
+
+	//test search on name prefix
+	users, total, err = us.Users(checkin.ListOptions{Search: "jonathan"})
+	test.Ok(t, err)
+	test.Equals(t, 1, total)
+	test.Equals(t, "safosscholar", users[0].Username)
+
+	//test sorting and pagination
+	users, total, err = us.Users(checkin.ListOptions{Search: "me", Descending: true, Limit: 1})
+	test.Ok(t, err)
+	test.Equals(t, 2, total)
+	test.Equals(t, 1, len(users))
+	test.Equals(t, "ME6Alice", users[0].Username)
+
+	users, _, err = us.Users(checkin.ListOptions{SortBy: checkin.SortByLastLoggedIn, Descending: true, Limit: 2})
+	test.Ok(t, err)
+	test.Equals(t, "safosscholar", users[0].Username)
+	test.Equals(t, "AirForceMan", users[1].Username)
+
+	users, total, err = us.Users(checkin.ListOptions{Search: "nobodyhasthisname"})
+	test.Ok(t, err)
+	test.Equals(t, 0, total)
+	test.Equals(t, []checkin.User{}, users)
+
+	//test unsupported sort key
+	_, _, err = us.Users(checkin.ListOptions{SortBy: checkin.SortByCheckInTime})
+	test.Assert(t, err != nil, "Expected error sorting users by check in time")
+}
+
 func TestUpdateLastLoggedIn(t *testing.T) {
 	us := postgres.UserService{DB: db}
 
@@ -75,8 +118,9 @@ func TestCreateUser(t *testing.T) {
 	test.Assert(t, time.Since(fetched.UpdatedAt) < 2*time.Second && time.Since(fetched.UpdatedAt) > 0, "Created at time is not within 2 seconds of now")
 	test.Equals(t, false, fetched.LastLoggedIn.Valid) //last logged should be null
 
-	err = us.DeleteUser("ElvenAshwin")
+	deleted, err := us.DeleteUser("ElvenAshwin")
 	test.Ok(t, err)
+	test.Equals(t, true, deleted)
 
 	//test supplying only the fields needed
 	user = checkin.User{
@@ -98,8 +142,17 @@ func TestCreateUser(t *testing.T) {
 	test.Assert(t, time.Now().Sub(fetched.UpdatedAt) < 2*time.Second, "Created at time is not within 2 seconds of now")
 	test.Equals(t, false, fetched.LastLoggedIn.Valid) //last logged should be null
 
-	err = us.DeleteUser("ElvenAshwin")
+	deleted, err = us.DeleteUser("ElvenAshwin")
+	test.Ok(t, err)
+	test.Equals(t, true, deleted)
+
+	//test users who own events are not deleted, as the events would be left without an owner
+	deleted, err = us.DeleteUser("TestUser")
+	test.Ok(t, err)
+	test.Equals(t, false, deleted)
+	exists, err := us.CheckIfExists("TestUser")
 	test.Ok(t, err)
+	test.Equals(t, true, exists)
 
 	//test hash fails
 	hm.HashAndSaltFn = hashFnGenerator(errors.New("An error"))
@@ -123,7 +176,7 @@ func TestUpdateUser(t *testing.T) {
 		UpdatedAt:    time.Date(2018, 12, 31, 6, 0, 0, 0, time.UTC),                 //should be ignored
 		LastLoggedIn: null.TimeFrom(time.Date(2019, 5, 31, 15, 30, 0, 0, time.UTC)), //should be ignored
 	}
-	err = us.UpdateUser(originalUser.Username, newUser)
+	_, err = us.UpdateUser(originalUser.Username, newUser, null.Time{})
 	test.Ok(t, err)
 	updated, err := us.User("Jasmine")
 	test.Ok(t, err)
@@ -142,7 +195,7 @@ func TestUpdateUser(t *testing.T) {
 	newUser = updated
 	newUser.PasswordPlaintext = &password
 	newUser.PasswordHash = "ahash" //should be ignored
-	err = us.UpdateUser(updated.Username, newUser)
+	_, err = us.UpdateUser(updated.Username, newUser, null.Time{})
 	test.Ok(t, err)
 	updated2, err := us.User("Jasmine")
 	test.Ok(t, err)
@@ -150,6 +203,25 @@ func TestUpdateUser(t *testing.T) {
 
 	//test hashing fails
 	hm.HashAndSaltFn = hashFnGenerator(errors.New("An error"))
-	err = us.UpdateUser(updated2.Username, newUser)
+	_, err = us.UpdateUser(updated2.Username, newUser, null.Time{})
 	test.Assert(t, err != nil, "No error returned even though hashing of plaintext password failed")
+
+	//test updating only if the user has not been updated since they were read
+	hm.HashAndSaltFn = hashFnGenerator(nil)
+	newUser = updated2
+	newUser.Name = "Jasmine Tan"
+	ok, err := us.UpdateUser(updated2.Username, newUser, null.TimeFrom(updated2.UpdatedAt))
+	test.Ok(t, err)
+	test.Equals(t, true, ok)
+	newUser.Name = "Someone Else"
+	ok, err = us.UpdateUser(updated2.Username, newUser, null.TimeFrom(updated2.UpdatedAt))
+	test.Ok(t, err)
+	test.Equals(t, false, ok)
+	updated3, err := us.User("Jasmine")
+	test.Ok(t, err)
+	test.Equals(t, "Jasmine Tan", updated3.Name)
+
+	//test user does not exist
+	_, err = us.UpdateUser("Notauser", newUser, null.Time{})
+	test.Assert(t, err != nil, "No error returned when updating a user who does not exist")
 }
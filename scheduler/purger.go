@@ -0,0 +1,62 @@
+package scheduler
+
+import (
+	"checkin"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+//Purger permanently deletes events which were archived more than Retention ago
+//Every period, it purges those which have come to the end of their retention since it last ran
+type Purger struct {
+	EventService checkin.EventService
+	Logger       *log.Logger
+	Retention    time.Duration
+	period       time.Duration
+	done         chan bool
+}
+
+//NewPurger creates a Purger which purges events archived more than retention ago every period
+//Call Start to start it
+func NewPurger(es checkin.EventService, retention time.Duration, period time.Duration) *Purger {
+	return &Purger{
+		EventService: es,
+		Logger:       log.New(os.Stderr, "", log.LstdFlags),
+		Retention:    retention,
+		period:       period,
+		done:         make(chan bool),
+	}
+}
+
+//Start runs the Purger in a new goroutine until it is closed
+func (p *Purger) Start() {
+	go func() {
+		ticker := time.NewTicker(p.period)
+		defer ticker.Stop()
+		for {
+			p.Run()
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+//Close stops the Purger
+func (p *Purger) Close() {
+	close(p.done)
+}
+
+//Run purges the events which were archived more than Retention ago
+func (p *Purger) Run() {
+	purged, err := p.EventService.PurgeEvents(time.Now().Add(-p.Retention))
+	if err != nil {
+		p.Logger.Println("Error purging archived events: " + err.Error())
+	} else if purged > 0 {
+		p.Logger.Println("Purged " + strconv.Itoa(purged) + " archived events")
+	}
+}
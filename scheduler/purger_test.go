@@ -0,0 +1,53 @@
+package scheduler_test
+
+import (
+	"checkin/mock"
+	"checkin/scheduler"
+	"checkin/test"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPurgerRun(t *testing.T) {
+	var es mock.EventService
+	p := scheduler.NewPurger(&es, time.Hour, time.Minute)
+	var purgeErr error
+	es.PurgeEventsFn = func(archivedBefore time.Time) (int, error) {
+		test.Assert(t, time.Since(archivedBefore) >= time.Hour && time.Since(archivedBefore) < time.Hour+time.Minute,
+			"Events purged before the end of their retention")
+		return 2, purgeErr
+	}
+
+	//test events archived more than the retention ago are purged
+	p.Run()
+	test.Equals(t, true, es.PurgeEventsInvoked)
+
+	//test errors purging events are only logged, to be tried again next period
+	purgeErr = errors.New("An error")
+	p.Run()
+}
+
+func TestPurgerStart(t *testing.T) {
+	var es mock.EventService
+	p := scheduler.NewPurger(&es, time.Hour, 10*time.Millisecond)
+	runs := make(chan bool, 10)
+	es.PurgeEventsFn = func(archivedBefore time.Time) (int, error) {
+		select {
+		case runs <- true:
+		default:
+		}
+		return 0, nil
+	}
+
+	//test the purger runs straight away, and again every period
+	p.Start()
+	for i := 0; i < 3; i++ {
+		select {
+		case <-runs:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Purger did not run")
+		}
+	}
+	p.Close()
+}
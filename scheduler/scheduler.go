@@ -0,0 +1,202 @@
+package scheduler
+
+import (
+	"bytes"
+	"checkin"
+	"checkin/hmac"
+	myhttp "checkin/http"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+//SignatureHeader is the header webhooks carry their signature in, as sha256= followed by the hex encoded
+//HMAC-SHA256 of the body, keyed with the secret of the action
+const SignatureHeader = "X-Checkin-Signature"
+
+//WebhookPayload is the body of the request sent by a webhook
+//Receivers can use the delivery ID to ignore repeats, as deliveries are retried if no success response is received
+type WebhookPayload struct {
+	DeliveryID  string    `json:"deliveryId"`
+	ActionID    string    `json:"actionId"`
+	EventID     string    `json:"eventId"`
+	Trigger     string    `json:"trigger"`
+	TriggerTime time.Time `json:"triggerTime"`
+	SentAt      time.Time `json:"sentAt"`
+}
+
+//Scheduler fires the actions of events as their triggers elapse
+//Every period, it queues deliveries for the triggers which have elapsed, then attempts those which are due
+//As deliveries are kept by the TriggerService, those missed while no instance of the server was running
+//are made once one starts, and several instances can run a Scheduler at once without repeating them
+type Scheduler struct {
+	TriggerService checkin.TriggerService
+	GuestMessenger myhttp.GuestMessenger
+	Client         *http.Client
+	Logger         *log.Logger
+	BatchSize      int           //maximum number of deliveries attempted each period
+	Lease          time.Duration //how long a delivery is left to its attempt before it may be attempted again, longer than Client.Timeout
+	period         time.Duration
+	done           chan bool
+}
+
+//New creates a Scheduler which checks for elapsed triggers every period
+//Call Start to start it
+func New(ts checkin.TriggerService, gm myhttp.GuestMessenger, period time.Duration) *Scheduler {
+	return &Scheduler{
+		TriggerService: ts,
+		GuestMessenger: gm,
+		Client:         NewWebhookClient(10 * time.Second),
+		Logger:         log.New(os.Stderr, "", log.LstdFlags),
+		BatchSize:      50,
+		Lease:          time.Minute,
+		period:         period,
+		done:           make(chan bool),
+	}
+}
+
+//NewWebhookClient returns a client to send webhooks with, which does not follow redirects and only connects to
+//public IP addresses (see checkin.IsPublicIP). Addresses are checked once they are resolved, so a host name
+//which resolves to an address of the server's own network is refused too
+func NewWebhookClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network string, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !checkin.IsPublicIP(net.ParseIP(host)) {
+				return errors.New("Webhooks cannot be sent to address " + host + " as it is not public")
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: timeout}, //no proxy, as it would be dialled instead
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse //so the redirect fails as a non 2xx response
+		},
+	}
+}
+
+//Start runs the Scheduler in a new goroutine until it is closed
+func (s *Scheduler) Start() {
+	go func() {
+		ticker := time.NewTicker(s.period)
+		defer ticker.Stop()
+		for {
+			s.Run()
+			select {
+			case <-s.done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+//Close stops the Scheduler once it finishes the deliveries it is attempting
+func (s *Scheduler) Close() {
+	close(s.done)
+}
+
+//Run queues deliveries for the triggers which have elapsed, then attempts a batch of those which are due,
+//recording whether each succeeded so failures are retried
+//Each delivery is claimed just before it is attempted, so the lease only has to outlast a single attempt
+//rather than the whole batch, and other instances do not attempt it again while it is being attempted
+func (s *Scheduler) Run() {
+	if _, err := s.TriggerService.ScheduleDeliveries(); err != nil {
+		s.Logger.Println("Error scheduling deliveries: " + err.Error())
+		//still attempt those already queued
+	}
+	for i := 0; i < s.BatchSize; i++ {
+		deliveries, err := s.TriggerService.ClaimDeliveries(1, s.Lease)
+		if err != nil {
+			s.Logger.Println("Error claiming deliveries: " + err.Error())
+			return
+		} else if len(deliveries) == 0 {
+			return
+		}
+
+		d := deliveries[0]
+		err = s.deliver(d)
+		if err != nil {
+			err = s.TriggerService.RecordFailure(d.ID, d.NextAttempt.Time, err.Error())
+		} else {
+			err = s.TriggerService.RecordSuccess(d.ID, d.NextAttempt.Time)
+		}
+		if err != nil {
+			s.Logger.Println("Error recording delivery " + d.ID + ": " + err.Error())
+		}
+	}
+}
+
+func (s *Scheduler) deliver(d checkin.Delivery) error {
+	switch d.Action.Type {
+	case checkin.ActionWebhook:
+		return s.sendWebhook(d)
+	case checkin.ActionBroadcast:
+		return s.GuestMessenger.SendAll(myhttp.EventGuestsPrefix(d.EventID), myhttp.GuestMessage{
+			Title:   "trigger/" + d.Trigger,
+			Content: d.Action.Message.String,
+		})
+	default:
+		return errors.New("Unknown action type " + d.Action.Type)
+	}
+}
+
+//sendWebhook POSTs the signed payload of the delivery to the URL of its action, succeeding on any 2xx response
+func (s *Scheduler) sendWebhook(d checkin.Delivery) error {
+	body, err := json.Marshal(WebhookPayload{
+		DeliveryID:  d.ID,
+		ActionID:    d.ActionID,
+		EventID:     d.EventID,
+		Trigger:     d.Trigger,
+		TriggerTime: d.TriggerTime,
+		SentAt:      time.Now().In(time.UTC),
+	})
+	if err != nil {
+		return errors.New("Error marshalling webhook payload: " + err.Error())
+	}
+	signature, err := Sign(d.Action.Secret, body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", d.Action.URL.String, bytes.NewReader(body))
+	if err != nil {
+		return errors.New("Error creating webhook request: " + err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, signature)
+	res, err := s.Client.Do(req)
+	if err != nil {
+		return errors.New("Error sending webhook: " + err.Error())
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(res.Body, 4096)) //lets the connection be reused
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return errors.New("Webhook responded with status " + strconv.Itoa(res.StatusCode))
+	}
+	return nil
+}
+
+//Sign returns the value of the SignatureHeader of a webhook with the given body, sent by an action with the given secret
+func Sign(secret string, body []byte) (string, error) {
+	digest, err := hmac.DigestMethod{Key: []byte(secret)}.Digest(string(body))
+	if err != nil {
+		return "", errors.New("Error signing webhook: " + err.Error())
+	}
+	return "sha256=" + digest, nil
+}
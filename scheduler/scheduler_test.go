@@ -0,0 +1,193 @@
+package scheduler_test
+
+import (
+	"checkin"
+	myhttp "checkin/http"
+	"checkin/mock"
+	"checkin/scheduler"
+	"checkin/test"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guregu/null"
+)
+
+func TestRun(t *testing.T) {
+	var ts mock.TriggerService
+	var gm mock.GuestMessenger
+	s := scheduler.New(&ts, &gm, time.Minute)
+
+	var received []scheduler.WebhookPayload
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		test.Ok(t, err)
+		signature, err := scheduler.Sign("shh", body)
+		test.Ok(t, err)
+		test.Equals(t, signature, r.Header.Get(scheduler.SignatureHeader))
+		test.Equals(t, "application/json", r.Header.Get("Content-Type"))
+		var payload scheduler.WebhookPayload
+		test.Ok(t, json.Unmarshal(body, &payload))
+		received = append(received, payload)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	s.Client = server.Client() //as the test server is on loopback, which webhook clients refuse to connect to
+
+	triggerTime := time.Date(2019, 3, 1, 9, 0, 0, 0, time.UTC)
+	claimedUntil := time.Date(2019, 3, 1, 9, 1, 0, 0, time.UTC)
+	webhook := checkin.Delivery{ID: "10", ActionID: "1", EventID: "300", Trigger: "release", TriggerTime: triggerTime,
+		NextAttempt: null.TimeFrom(claimedUntil),
+		Action:      checkin.TriggerAction{Type: checkin.ActionWebhook, URL: null.StringFrom(server.URL), Secret: "shh"}}
+	broadcast := checkin.Delivery{ID: "11", ActionID: "2", EventID: "300", Trigger: "formrelease", TriggerTime: triggerTime,
+		NextAttempt: null.TimeFrom(claimedUntil),
+		Action:      checkin.TriggerAction{Type: checkin.ActionBroadcast, Message: null.StringFrom("Give us feedback!")}}
+	var scheduleErr, claimErr error
+	ts.ScheduleDeliveriesFn = func() (int, error) {
+		return 2, scheduleErr
+	}
+	var queued []checkin.Delivery
+	claims := 0
+	ts.ClaimDeliveriesFn = func(limit int, lease time.Duration) ([]checkin.Delivery, error) {
+		test.Equals(t, 1, limit) //each delivery is claimed as it is attempted
+		test.Equals(t, time.Minute, lease)
+		claims++
+		if claimErr != nil {
+			return nil, claimErr
+		} else if len(queued) == 0 {
+			return []checkin.Delivery{}, nil
+		}
+		claimed := queued[:1]
+		queued = queued[1:]
+		return claimed, nil
+	}
+	run := func() {
+		queued, claims = []checkin.Delivery{webhook, broadcast}, 0
+		s.Run()
+	}
+	var succeeded []string
+	failed := make(map[string]string)
+	ts.RecordSuccessFn = func(deliveryID string, until time.Time) error {
+		test.Equals(t, claimedUntil, until)
+		succeeded = append(succeeded, deliveryID)
+		return nil
+	}
+	ts.RecordFailureFn = func(deliveryID string, until time.Time, reason string) error {
+		test.Equals(t, claimedUntil, until)
+		failed[deliveryID] = reason
+		return nil
+	}
+	var broadcasts []myhttp.GuestMessage
+	var broadcastErr error
+	gm.SendAllFn = func(prefix string, msg myhttp.GuestMessage) error {
+		test.Equals(t, myhttp.EventGuestsPrefix("300"), prefix)
+		broadcasts = append(broadcasts, msg)
+		return broadcastErr
+	}
+
+	//test normal functionality
+	run()
+	test.Equals(t, 1, len(received))
+	test.Equals(t, "10", received[0].DeliveryID)
+	test.Equals(t, "1", received[0].ActionID)
+	test.Equals(t, "300", received[0].EventID)
+	test.Equals(t, "release", received[0].Trigger)
+	test.Equals(t, triggerTime, received[0].TriggerTime)
+	test.Equals(t, []myhttp.GuestMessage{{Title: "trigger/formrelease", Content: "Give us feedback!"}}, broadcasts)
+	test.Equals(t, []string{"10", "11"}, succeeded)
+	test.Equals(t, 0, len(failed))
+	test.Equals(t, 3, claims)
+
+	//test no more than a batch of deliveries are attempted each run
+	succeeded = nil
+	s.BatchSize = 1
+	run()
+	test.Equals(t, []string{"10"}, succeeded)
+	test.Equals(t, 1, claims)
+	s.BatchSize = 50
+
+	//test failed deliveries are recorded with the reason they failed
+	succeeded = nil
+	status = http.StatusInternalServerError
+	broadcastErr = errors.New("An error")
+	run()
+	test.Equals(t, 0, len(succeeded))
+	test.Assert(t, strings.Contains(failed["10"], "500"), "Failed webhook not recorded with its status")
+	test.Equals(t, "An error", failed["11"])
+	server.Close()
+	run()
+	test.Assert(t, strings.Contains(failed["10"], "Error sending webhook"), "Unreachable webhook not recorded as failed")
+	webhook.Action.Type = "email"
+	run()
+	test.Equals(t, "Unknown action type email", failed["10"])
+
+	//test deliveries already queued are still attempted when scheduling fails, but not when claiming fails
+	failed = make(map[string]string)
+	scheduleErr = errors.New("An error")
+	run()
+	test.Equals(t, 2, len(failed))
+	failed = make(map[string]string)
+	claimErr = errors.New("An error")
+	run()
+	test.Equals(t, 0, len(failed))
+}
+
+func TestWebhookClient(t *testing.T) {
+	redirected := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/hook", http.StatusFound)
+			return
+		}
+		redirected = true
+	}))
+	defer server.Close()
+	client := scheduler.NewWebhookClient(time.Second)
+
+	//test addresses which are not public are refused once resolved, including host names
+	_, err := client.Post(server.URL, "application/json", strings.NewReader("{}"))
+	test.Assert(t, err != nil && strings.Contains(err.Error(), "not public"), "Webhook sent to loopback address")
+	_, err = client.Post(strings.Replace(server.URL, "127.0.0.1", "localhost", 1), "application/json", strings.NewReader("{}"))
+	test.Assert(t, err != nil, "Webhook sent to localhost")
+
+	//test redirects are not followed, so they cannot bounce webhooks into the server's network
+	req := httptest.NewRequest("POST", server.URL+"/redirect", nil)
+	test.Equals(t, http.ErrUseLastResponse, client.CheckRedirect(req, []*http.Request{req}))
+	res, err := server.Client().Post(server.URL+"/redirect", "application/json", strings.NewReader("{}"))
+	test.Ok(t, err)
+	res.Body.Close()
+	test.Assert(t, redirected, "Test server does not redirect")
+}
+
+func TestStart(t *testing.T) {
+	var ts mock.TriggerService
+	s := scheduler.New(&ts, &mock.GuestMessenger{}, 10*time.Millisecond)
+	runs := make(chan bool, 10)
+	ts.ScheduleDeliveriesFn = func() (int, error) {
+		return 0, nil
+	}
+	ts.ClaimDeliveriesFn = func(limit int, lease time.Duration) ([]checkin.Delivery, error) {
+		select {
+		case runs <- true:
+		default:
+		}
+		return nil, nil
+	}
+
+	//test the scheduler runs straight away, and again every period
+	s.Start()
+	for i := 0; i < 3; i++ {
+		select {
+		case <-runs:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Scheduler did not run")
+		}
+	}
+	s.Close()
+}
@@ -9,6 +9,14 @@ import (
 //and it should be between 0 and 7
 type Size uint8
 
+//MaxSize is the largest size an element can have
+const MaxSize Size = 7
+
+//IsValid checks that the size is between 0 and MaxSize
+func (s Size) IsValid() bool {
+	return s <= MaxSize
+}
+
 //TextElement some words which have a size and some content
 type TextElement struct {
 	Content string `json:"cont"`
@@ -36,7 +44,12 @@ const (
 
 //UnmarshalJSON validates that the button type is either link or modal, or returns and error
 func (bt *ButtonType) UnmarshalJSON(bytes []byte) error {
-	input := ButtonType(bytes)
+	var str string
+	if err := json.Unmarshal(bytes, &str); err != nil {
+		*bt = Unknown
+		return err
+	}
+	input := ButtonType(str)
 
 	switch input {
 	case Link:
@@ -75,3 +88,25 @@ type GuestSite struct {
 	Details []DetailsElement `json:"details"`
 	Buttons []ButtonRow      `json:"buttons"`
 }
+
+//IsValid checks that every element of the guest site has a valid size
+//and that every button is either a link or a modal
+func (gs *GuestSite) IsValid() bool {
+	if !gs.Title.Size.IsValid() || !gs.Tagline.Size.IsValid() {
+		return false
+	}
+	for _, row := range gs.Buttons {
+		for _, button := range row {
+			if !button.Size.IsValid() || button.Type == Unknown {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+//GuestSiteService is for storing and fetching the guest website of an event
+type GuestSiteService interface {
+	GuestSite(eventID string) (GuestSite, error)
+	UpdateGuestSite(eventID string, site GuestSite) error
+}
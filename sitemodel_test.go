@@ -0,0 +1,45 @@
+package checkin_test
+
+import (
+	"checkin"
+	"checkin/test"
+	"encoding/json"
+	"testing"
+)
+
+func TestButtonTypeUnmarshalJSON(t *testing.T) {
+	var button checkin.ButtonElement
+	err := json.Unmarshal([]byte(`{"sz":2,"title":"Map","type":"link","cont":"https://maps.google.com"}`), &button)
+	test.Ok(t, err)
+	test.Equals(t, checkin.Link, button.Type)
+
+	err = json.Unmarshal([]byte(`{"sz":2,"title":"Info","type":"modal","cont":"Some info"}`), &button)
+	test.Ok(t, err)
+	test.Equals(t, checkin.Modal, button.Type)
+
+	err = json.Unmarshal([]byte(`{"sz":2,"title":"Info","type":"popup","cont":"Some info"}`), &button)
+	test.Assert(t, err != nil, "No error thrown when unmarshalling invalid button type")
+
+	err = json.Unmarshal([]byte(`{"sz":2,"title":"Info","type":3,"cont":"Some info"}`), &button)
+	test.Assert(t, err != nil, "No error thrown when unmarshalling non-string button type")
+}
+
+func TestGuestSiteIsValid(t *testing.T) {
+	site := checkin.GuestSite{
+		Title:   checkin.TextElement{Content: "Welcome", Size: 7},
+		Tagline: checkin.TextElement{Content: "To the parade", Size: 0},
+		Buttons: []checkin.ButtonRow{{{Size: 3, Title: "Map", Type: checkin.Link, Content: "https://maps.google.com"}}},
+	}
+	test.Equals(t, true, site.IsValid())
+
+	site.Title.Size = 8
+	test.Equals(t, false, site.IsValid())
+	site.Title.Size = 7
+
+	site.Buttons[0][0].Type = checkin.Unknown
+	test.Equals(t, false, site.IsValid())
+	site.Buttons[0][0].Type = checkin.Modal
+
+	site.Buttons[0][0].Size = 10
+	test.Equals(t, false, site.IsValid())
+}
@@ -0,0 +1,278 @@
+package checkin
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+//Operators of the nodes of a TagFilter
+const (
+	TagOpHas = "has" //guests with the tag of the node
+	TagOpAnd = "and" //guests matching every operand
+	TagOpOr  = "or"  //guests matching at least one operand
+	TagOpNot = "not" //guests not matching the only operand
+)
+
+//Limits on tag filter expressions, which keep the queries they are compiled to small
+const (
+	MaxTagFilterLength = 1024 //in bytes
+	MaxTagFilterTags   = 32
+)
+
+//TagFilter is a boolean expression over the tags of guests, such as "(VIP or SPEAKER) and not CANCELLED"
+//The zero TagFilter matches every guest
+type TagFilter struct {
+	Op       string      `json:"op"`
+	Tag      string      `json:"tag,omitempty"`      //for TagOpHas
+	Operands []TagFilter `json:"operands,omitempty"` //for TagOpAnd, TagOpOr and TagOpNot
+}
+
+//HasTag returns the filter for guests with the tag
+func HasTag(tag string) TagFilter {
+	return TagFilter{Op: TagOpHas, Tag: tag}
+}
+
+//HasAllTags returns the filter for guests with all of the tags, as given by repeated tag query parameters
+//No tags matches every guest
+func HasAllTags(tags []string) TagFilter {
+	if len(tags) == 0 {
+		return TagFilter{}
+	} else if len(tags) == 1 {
+		return HasTag(tags[0])
+	}
+	operands := make([]TagFilter, len(tags))
+	for i, tag := range tags {
+		operands[i] = HasTag(tag)
+	}
+	return TagFilter{Op: TagOpAnd, Operands: operands}
+}
+
+//And returns the filter for guests matching both filters, where an empty filter is ignored
+func (f TagFilter) And(other TagFilter) TagFilter {
+	if f.MatchesAll() {
+		return other
+	} else if other.MatchesAll() {
+		return f
+	}
+	return TagFilter{Op: TagOpAnd, Operands: []TagFilter{f, other}}
+}
+
+//MatchesAll checks if this is the empty filter, which matches every guest
+func (f TagFilter) MatchesAll() bool {
+	return f.Op == ""
+}
+
+//Matches checks if a guest with the given tags is picked out by the filter
+//Tags are compared without regard to case
+func (f TagFilter) Matches(tags []string) bool {
+	switch f.Op {
+	case TagOpHas:
+		for _, tag := range tags {
+			if strings.EqualFold(tag, f.Tag) {
+				return true
+			}
+		}
+		return false
+	case TagOpAnd:
+		for _, operand := range f.Operands {
+			if !operand.Matches(tags) {
+				return false
+			}
+		}
+		return true
+	case TagOpOr:
+		for _, operand := range f.Operands {
+			if operand.Matches(tags) {
+				return true
+			}
+		}
+		return false
+	case TagOpNot:
+		return !f.Operands[0].Matches(tags)
+	}
+	return true
+}
+
+//String writes the filter back out as an expression which parses to the same filter
+func (f TagFilter) String() string {
+	switch f.Op {
+	case TagOpHas:
+		if needsQuotes(f.Tag) {
+			return `"` + f.Tag + `"`
+		}
+		return f.Tag
+	case TagOpAnd, TagOpOr:
+		operands := make([]string, len(f.Operands))
+		for i, operand := range f.Operands {
+			operands[i] = operand.String()
+		}
+		return "(" + strings.Join(operands, " "+f.Op+" ") + ")"
+	case TagOpNot:
+		return "not " + f.Operands[0].String()
+	}
+	return ""
+}
+
+//ParseTagFilter parses a tag filter expression, made up of tags joined by the (non-case sensitive) operators
+//and, or and not, and grouped by parentheses. not binds tightest and or loosest, so
+//"VIP or SPEAKER and not CANCELLED" is "VIP or (SPEAKER and (not CANCELLED))"
+//Tags with spaces or parentheses, or which are operators, can be given in double quotes
+//An empty expression matches every guest
+func ParseTagFilter(expression string) (TagFilter, error) {
+	if len(expression) > MaxTagFilterLength {
+		return TagFilter{}, errors.New("Tag filter is too long")
+	}
+	tokens, err := tokenizeTagFilter(expression)
+	if err != nil {
+		return TagFilter{}, err
+	}
+	if len(tokens) == 0 {
+		return TagFilter{}, nil
+	}
+	p := tagFilterParser{tokens: tokens}
+	filter, err := p.parseOr()
+	if err != nil {
+		return TagFilter{}, err
+	}
+	if p.pos < len(p.tokens) {
+		return TagFilter{}, errors.New("Unexpected " + p.tokens[p.pos].text + " in tag filter")
+	}
+	if p.tags > MaxTagFilterTags {
+		return TagFilter{}, errors.New("Tag filter tests for too many tags")
+	}
+	return filter, nil
+}
+
+//tagFilterToken is a tag, operator or parenthesis of a tag filter expression
+//Quoted tokens are always tags
+type tagFilterToken struct {
+	text   string
+	quoted bool
+}
+
+//is checks if the token is the operator or parenthesis given
+func (t tagFilterToken) is(op string) bool {
+	return !t.quoted && strings.EqualFold(t.text, op)
+}
+
+//isTag checks if the token is a tag rather than an operator or parenthesis
+func (t tagFilterToken) isTag() bool {
+	return !(t.is(TagOpAnd) || t.is(TagOpOr) || t.is(TagOpNot) || t.is("(") || t.is(")"))
+}
+
+//tokenizeTagFilter splits the expression into tokens, separated by whitespace and parentheses
+func tokenizeTagFilter(expression string) ([]tagFilterToken, error) {
+	tokens := make([]tagFilterToken, 0)
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		switch r := runes[i]; {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			tokens = append(tokens, tagFilterToken{text: string(r)})
+			i++
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, errors.New("Unterminated quoted tag in tag filter")
+			}
+			if end == i+1 {
+				return nil, errors.New("Empty quoted tag in tag filter")
+			}
+			tokens = append(tokens, tagFilterToken{text: string(runes[i+1 : end]), quoted: true})
+			i = end + 1
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) && !strings.ContainsRune(`()"`, runes[end]) {
+				end++
+			}
+			tokens = append(tokens, tagFilterToken{text: string(runes[i:end])})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+//needsQuotes checks if the tag has to be quoted to be parsed back as a tag
+func needsQuotes(tag string) bool {
+	return strings.IndexFunc(tag, unicode.IsSpace) != -1 || strings.ContainsAny(tag, "()") ||
+		!(tagFilterToken{text: tag}).isTag()
+}
+
+//tagFilterParser is a recursive descent parser of tag filter expressions, with a method per level of precedence
+type tagFilterParser struct {
+	tokens []tagFilterToken
+	pos    int
+	tags   int //number of tags parsed so far
+}
+
+func (p *tagFilterParser) parseOr() (TagFilter, error) {
+	return p.parseJoined(TagOpOr, p.parseAnd)
+}
+
+func (p *tagFilterParser) parseAnd() (TagFilter, error) {
+	return p.parseJoined(TagOpAnd, p.parseNot)
+}
+
+//parseJoined parses operands given by parseOperand joined by op, only making a node for op if there
+//is more than one
+func (p *tagFilterParser) parseJoined(op string, parseOperand func() (TagFilter, error)) (TagFilter, error) {
+	first, err := parseOperand()
+	if err != nil {
+		return TagFilter{}, err
+	}
+	operands := []TagFilter{first}
+	for p.pos < len(p.tokens) && p.tokens[p.pos].is(op) {
+		p.pos++
+		operand, err := parseOperand()
+		if err != nil {
+			return TagFilter{}, err
+		}
+		operands = append(operands, operand)
+	}
+	if len(operands) == 1 {
+		return first, nil
+	}
+	return TagFilter{Op: op, Operands: operands}, nil
+}
+
+func (p *tagFilterParser) parseNot() (TagFilter, error) {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].is(TagOpNot) {
+		p.pos++
+		operand, err := p.parseNot()
+		if err != nil {
+			return TagFilter{}, err
+		}
+		return TagFilter{Op: TagOpNot, Operands: []TagFilter{operand}}, nil
+	}
+	return p.parseTerm()
+}
+
+//parseTerm parses a tag, or an expression in parentheses
+func (p *tagFilterParser) parseTerm() (TagFilter, error) {
+	if p.pos == len(p.tokens) {
+		return TagFilter{}, errors.New("Tag filter ends where a tag was expected")
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	if token.is("(") {
+		filter, err := p.parseOr()
+		if err != nil {
+			return TagFilter{}, err
+		}
+		if p.pos == len(p.tokens) || !p.tokens[p.pos].is(")") {
+			return TagFilter{}, errors.New("Missing closing parenthesis in tag filter")
+		}
+		p.pos++
+		return filter, nil
+	}
+	if !token.isTag() {
+		return TagFilter{}, errors.New("Expected a tag but got " + token.text + " in tag filter")
+	}
+	p.tags++
+	return HasTag(token.text), nil
+}
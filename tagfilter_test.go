@@ -0,0 +1,72 @@
+package checkin_test
+
+import (
+	"checkin"
+	"checkin/test"
+	"strings"
+	"testing"
+)
+
+func TestParseTagFilter(t *testing.T) {
+	vip, speaker, cancelled := checkin.HasTag("VIP"), checkin.HasTag("SPEAKER"), checkin.HasTag("CANCELLED")
+	not := func(f checkin.TagFilter) checkin.TagFilter {
+		return checkin.TagFilter{Op: checkin.TagOpNot, Operands: []checkin.TagFilter{f}}
+	}
+	or := func(operands ...checkin.TagFilter) checkin.TagFilter {
+		return checkin.TagFilter{Op: checkin.TagOpOr, Operands: operands}
+	}
+	and := func(operands ...checkin.TagFilter) checkin.TagFilter {
+		return checkin.TagFilter{Op: checkin.TagOpAnd, Operands: operands}
+	}
+
+	valid := map[string]checkin.TagFilter{
+		"":                                     {},
+		"   ":                                  {},
+		"VIP":                                  vip,
+		"VIP or SPEAKER or CANCELLED":          or(vip, speaker, cancelled),
+		"VIP OR SPEAKER AND NOT CANCELLED":     or(vip, and(speaker, not(cancelled))), //and binds tighter than or
+		"(VIP or SPEAKER) and not CANCELLED":   and(or(vip, speaker), not(cancelled)),
+		"not not VIP":                          not(not(vip)),
+		"((VIP))":                              vip,
+		"not(VIP)and(SPEAKER)":                 and(not(vip), speaker),
+		`"GUEST OF HONOUR" or "and"`:           or(checkin.HasTag("GUEST OF HONOUR"), checkin.HasTag("and")),
+		"VIP and SPEAKER and CANCELLED":        and(vip, speaker, cancelled),
+		"VIP or (SPEAKER and not (CANCELLED))": or(vip, and(speaker, not(cancelled))),
+	}
+	for expression, expected := range valid {
+		filter, err := checkin.ParseTagFilter(expression)
+		test.Ok(t, err)
+		test.Equals(t, expected, filter)
+	}
+
+	invalid := []string{"VIP or", "and VIP", "(VIP", "VIP)", "VIP SPEAKER", "not", "()", `"VIP`, `""`, "VIP or or SPEAKER",
+		strings.Repeat("VIP or ", checkin.MaxTagFilterTags) + "VIP", strings.Repeat("(", checkin.MaxTagFilterLength+1)}
+	for _, expression := range invalid {
+		_, err := checkin.ParseTagFilter(expression)
+		test.Assert(t, err != nil, "No error parsing invalid tag filter: "+expression)
+	}
+}
+
+func TestTagFilterMatches(t *testing.T) {
+	filter, err := checkin.ParseTagFilter("(VIP or SPEAKER) and not CANCELLED")
+	test.Ok(t, err)
+	test.Equals(t, true, filter.Matches([]string{"VIP"}))
+	test.Equals(t, true, filter.Matches([]string{"speaker", "ATTENDING"})) //not case sensitive
+	test.Equals(t, false, filter.Matches([]string{"VIP", "CANCELLED"}))
+	test.Equals(t, false, filter.Matches([]string{}))
+	test.Equals(t, true, checkin.TagFilter{}.Matches(nil))
+	test.Equals(t, true, checkin.HasAllTags(nil).MatchesAll())
+	test.Equals(t, true, checkin.HasAllTags([]string{"VIP", "SPEAKER"}).Matches([]string{"SPEAKER", "VIP"}))
+	test.Equals(t, false, checkin.HasAllTags([]string{"VIP", "SPEAKER"}).Matches([]string{"VIP"}))
+}
+
+func TestTagFilterString(t *testing.T) {
+	for _, expression := range []string{"VIP", "(VIP or SPEAKER)", `(not ("GUEST OF HONOUR" or "not") and SPEAKER)`} {
+		filter, err := checkin.ParseTagFilter(expression)
+		test.Ok(t, err)
+		test.Equals(t, expression, filter.String())
+	}
+	filter := checkin.HasAllTags([]string{"VIP"}).And(checkin.HasTag("SPEAKER"))
+	test.Equals(t, "(VIP and SPEAKER)", filter.String())
+	test.Equals(t, "", checkin.TagFilter{}.String())
+}
@@ -0,0 +1,114 @@
+package checkin
+
+import (
+	"net"
+	"time"
+
+	"github.com/guregu/null"
+)
+
+//Types of action which can be fired when a trigger of an event elapses
+const (
+	ActionWebhook   = "webhook"   //POSTs a signed JSON payload to a URL of the hosts
+	ActionBroadcast = "broadcast" //sends a message to every guest connected to the event
+)
+
+//Statuses of deliveries
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed" //given up on after too many failed attempts
+)
+
+//sharedAddressSpace is the range of addresses used by carrier grade NAT (RFC 6598), which is not publicly reachable
+var sharedAddressSpace = net.IPNet{IP: net.IP{100, 64, 0, 0}, Mask: net.CIDRMask(10, 32)}
+
+//IsPublicIP checks if the address is on the public internet, the only place webhooks may be sent to, so that hosts
+//cannot use them to reach the server's own network, e.g. loopback, private or link local addresses
+//(which include cloud metadata services)
+func IsPublicIP(ip net.IP) bool {
+	return ip != nil && !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified() && !sharedAddressSpace.Contains(ip)
+}
+
+//TriggerAction is an action fired when a trigger (time tag) of an event elapses
+//Secret is only given back when the action is created, and is the key webhooks are signed with
+type TriggerAction struct {
+	ID        string      `json:"actionId"`
+	EventID   string      `json:"eventId"`
+	Trigger   string      `json:"trigger"`
+	Type      string      `json:"type"`
+	URL       null.String `json:"url"`     //for webhooks
+	Message   null.String `json:"message"` //for broadcasts
+	Secret    string      `json:"secret,omitempty"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+//Delivery is a firing of an action for one time of its trigger, which is retried until it succeeds
+//or is given up on
+type Delivery struct {
+	ID          string        `json:"deliveryId"`
+	ActionID    string        `json:"actionId"`
+	EventID     string        `json:"eventId"`
+	Trigger     string        `json:"trigger"`
+	TriggerTime time.Time     `json:"triggerTime"` //the time the trigger elapsed at
+	Status      string        `json:"status"`
+	Attempts    int           `json:"attempts"`
+	NextAttempt null.Time     `json:"nextAttempt"` //null once delivered or given up on
+	LastError   null.String   `json:"lastError"`
+	DeliveredAt null.Time     `json:"deliveredAt"`
+	Action      TriggerAction `json:"-"` //only filled in by ClaimDeliveries
+}
+
+//RetryPolicy decides when failed deliveries are retried, and when they are given up on
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration //delay after the first failed attempt, doubled for every further failure
+	MaxDelay    time.Duration
+}
+
+//RetryDelay returns how long to wait before retrying after the given number of failed attempts
+//Returns false if the delivery should be given up on instead
+func (p RetryPolicy) RetryDelay(failedAttempts int) (time.Duration, bool) {
+	if failedAttempts >= p.MaxAttempts {
+		return 0, false
+	}
+	delay := p.BaseDelay
+	for i := 1; i < failedAttempts && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		return p.MaxDelay, true
+	}
+	return delay, true
+}
+
+//TriggerService manages the actions fired when the triggers of events elapse, and their deliveries
+type TriggerService interface {
+	//Actions returns the actions of the event, without their secrets
+	Actions(eventID string) ([]TriggerAction, error)
+	//CreateAction saves the action, with the ID and secret it is given
+	CreateAction(a TriggerAction) error
+	//DeleteAction deletes the action of the event with the given ID, along with its deliveries
+	//Returns false if the event has no such action
+	DeleteAction(eventID string, actionID string) (bool, error)
+	//Deliveries returns a page of the deliveries of the actions of the event, sorted by trigger time (the default)
+	//and searched by trigger prefix, along with the total number of their deliveries
+	Deliveries(eventID string, opts ListOptions) ([]Delivery, int, error)
+	//ScheduleDeliveries queues a delivery of every action whose trigger has elapsed since the action was created
+	//Each action is delivered once for every time its trigger is set to, so it is safe to call repeatedly,
+	//and from several instances of the server at once
+	//Returns the number of deliveries queued
+	ScheduleDeliveries() (int, error)
+	//ClaimDeliveries returns up to limit pending deliveries which are due, with their actions, and keeps
+	//them from being claimed again until lease has passed, in case the attempt is interrupted
+	//The NextAttempt of each delivery returned is the time it is claimed until
+	ClaimDeliveries(limit int, lease time.Duration) ([]Delivery, error)
+	//RecordSuccess marks the delivery claimed until claimedUntil as delivered
+	//Returns an error if the delivery is no longer pending, or was claimed again once the lease passed
+	RecordSuccess(deliveryID string, claimedUntil time.Time) error
+	//RecordFailure records a failed attempt at the delivery claimed until claimedUntil, which is retried or
+	//given up on as the retry policy decides
+	//Returns an error if the delivery is no longer pending, or was claimed again once the lease passed
+	RecordFailure(deliveryID string, claimedUntil time.Time, reason string) error
+}
@@ -0,0 +1,19 @@
+package checkin_test
+
+import (
+	"checkin"
+	"checkin/test"
+	"net"
+	"testing"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	for _, address := range []string{"93.184.216.34", "8.8.8.8", "2606:2800:220:1:248:1893:25c8:1946"} {
+		test.Assert(t, checkin.IsPublicIP(net.ParseIP(address)), address+" not public")
+	}
+	for _, address := range []string{"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "100.64.0.1",
+		"0.0.0.0", "224.0.0.1", "::1", "fe80::1", "fc00::1", "::ffff:127.0.0.1"} {
+		test.Assert(t, !checkin.IsPublicIP(net.ParseIP(address)), address+" is public")
+	}
+	test.Assert(t, !checkin.IsPublicIP(nil), "No address is public")
+}